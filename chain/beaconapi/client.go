@@ -0,0 +1,62 @@
+// Package beaconapi fetches SSZ encoded chain data from a beacon node, through the standard beacon HTTP API.
+package beaconapi
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// The API may respond with blocks of later forks, these are not supported yet.
+const supportedFork = "phase0"
+
+type Client struct {
+	// Base URL of the API, e.g. http://localhost:5052
+	Addr string
+	HTTP *http.Client
+}
+
+func NewClient(addr string, timeout time.Duration) *Client {
+	return &Client{
+		Addr: strings.TrimRight(addr, "/"),
+		HTTP: &http.Client{Timeout: timeout},
+	}
+}
+
+// BlockSSZ fetches the SSZ encoded SignedBeaconBlock by block id: a slot, 0x-prefixed root, "head", "genesis" or "finalized".
+// Returns exists=false if there is no block for the id, e.g. for an empty slot.
+func (c *Client) BlockSSZ(ctx context.Context, id string) (data []byte, exists bool, err error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, c.Addr+"/eth/v2/beacon/blocks/"+id, nil)
+	if err != nil {
+		return nil, false, err
+	}
+	req.Header.Set("Accept", "application/octet-stream")
+	resp, err := c.HTTP.Do(req)
+	if err != nil {
+		return nil, false, err
+	}
+	defer resp.Body.Close()
+	switch resp.StatusCode {
+	case http.StatusOK:
+	case http.StatusNotFound:
+		return nil, false, nil
+	default:
+		msg, _ := ioutil.ReadAll(io.LimitReader(resp.Body, 1024))
+		return nil, false, fmt.Errorf("block request %s failed with status %d: %s", id, resp.StatusCode, strings.TrimSpace(string(msg)))
+	}
+	if ct := resp.Header.Get("Content-Type"); ct != "" && !strings.HasPrefix(ct, "application/octet-stream") {
+		return nil, false, fmt.Errorf("expected SSZ response, got content type %q", ct)
+	}
+	if fork := resp.Header.Get("Eth-Consensus-Version"); fork != "" && fork != supportedFork {
+		return nil, true, fmt.Errorf("block %s is of unsupported fork %q", id, fork)
+	}
+	data, err = ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, true, fmt.Errorf("failed to read block %s: %v", id, err)
+	}
+	return data, true, nil
+}
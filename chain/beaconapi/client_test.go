@@ -0,0 +1,67 @@
+package beaconapi
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestBlockSSZ(t *testing.T) {
+	block := []byte{1, 2, 3, 4}
+	cases := []struct {
+		name        string
+		status      int
+		contentType string
+		fork        string
+		exists      bool
+		fails       bool
+	}{
+		{"ok", http.StatusOK, "application/octet-stream", "phase0", true, false},
+		{"ok without headers", http.StatusOK, "", "", true, false},
+		{"not found", http.StatusNotFound, "application/json", "", false, false},
+		{"server error", http.StatusInternalServerError, "application/json", "", false, true},
+		{"json response", http.StatusOK, "application/json", "phase0", false, true},
+		{"unsupported fork", http.StatusOK, "application/octet-stream", "altair", true, true},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if r.URL.Path != "/eth/v2/beacon/blocks/123" {
+					t.Errorf("unexpected path %s", r.URL.Path)
+				}
+				if accept := r.Header.Get("Accept"); accept != "application/octet-stream" {
+					t.Errorf("expected SSZ to be requested, got accept %q", accept)
+				}
+				if c.contentType != "" {
+					w.Header().Set("Content-Type", c.contentType)
+				}
+				if c.fork != "" {
+					w.Header().Set("Eth-Consensus-Version", c.fork)
+				}
+				w.WriteHeader(c.status)
+				if c.status == http.StatusOK {
+					_, _ = w.Write(block)
+				}
+			}))
+			defer srv.Close()
+
+			client := NewClient(srv.URL+"/", time.Second)
+			data, exists, err := client.BlockSSZ(context.Background(), "123")
+			if c.fails {
+				if err == nil {
+					t.Fatal("expected request to fail")
+				}
+			} else if err != nil {
+				t.Fatal(err)
+			}
+			if exists != c.exists {
+				t.Fatalf("expected exists: %v, got %v", c.exists, exists)
+			}
+			if !c.fails && c.exists && string(data) != string(block) {
+				t.Fatalf("expected block %x, got %x", block, data)
+			}
+		})
+	}
+}
@@ -187,6 +187,8 @@ type ChainID string
 type Chains interface {
 	Find(id ChainID) (pi FullChain, ok bool)
 	Create(id ChainID, anchor *HotEntry, spec *beacon.Spec) (pi FullChain, err error)
+	// CreateFrom creates a chain that finalizes into the given cold chain, with a hot chain starting at the anchor.
+	CreateFrom(id ChainID, anchor *HotEntry, coldCh *FinalizedChain) (pi FullChain, err error)
 	Remove(id ChainID) (existed bool)
 	List() []ChainID
 }
@@ -205,7 +207,14 @@ func (cs *ChainsMap) Find(id ChainID) (pi FullChain, ok bool) {
 }
 
 func (cs *ChainsMap) Create(id ChainID, anchor *HotEntry, spec *beacon.Spec) (pi FullChain, err error) {
-	coldCh := NewFinalizedChain(anchor.slot, spec)
+	return cs.CreateFrom(id, anchor, NewFinalizedChain(anchor.slot, spec))
+}
+
+func (cs *ChainsMap) CreateFrom(id ChainID, anchor *HotEntry, coldCh *FinalizedChain) (pi FullChain, err error) {
+	if end := coldCh.End(); end != anchor.slot {
+		return nil, fmt.Errorf("cold chain ends at slot %d, but anchor is at slot %d", end, anchor.slot)
+	}
+	spec := coldCh.Spec
 	hotCh, err := NewUnfinalizedChain(anchor,
 		BlockSinkFn(func(entry *HotEntry, canonical bool) error {
 			if canonical {
@@ -231,9 +240,15 @@ func (cs *ChainsMap) Create(id ChainID, anchor *HotEntry, spec *beacon.Spec) (pi
 }
 
 func (cs *ChainsMap) Remove(id ChainID) (existed bool) {
-	_, existed = cs.chains.Load(id)
+	v, existed := cs.chains.Load(id)
 	if existed {
 		cs.chains.Delete(id)
+		// Release the datastore of persisted chains, so it can be loaded again
+		if hc, ok := v.(*HotColdChain); ok {
+			if fin, ok := hc.ColdChain.(*FinalizedChain); ok && fin.Store != nil {
+				_ = fin.Store.Close()
+			}
+		}
 	}
 	return
 }
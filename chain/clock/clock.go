@@ -0,0 +1,101 @@
+// Package clock maps wall-clock time to beacon chain slots, to schedule work at a point in each slot.
+package clock
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/protolambda/zrnt/eth2/beacon"
+	"time"
+)
+
+type SlotClock struct {
+	// Start of slot 0
+	Genesis       time.Time
+	SlotDuration  time.Duration
+	SlotsPerEpoch beacon.Slot
+}
+
+func NewSlotClock(genesisTime beacon.Timestamp, secondsPerSlot beacon.Timestamp, slotsPerEpoch beacon.Slot) (*SlotClock, error) {
+	if secondsPerSlot == 0 {
+		return nil, errors.New("seconds per slot must not be 0")
+	}
+	if slotsPerEpoch == 0 {
+		return nil, errors.New("slots per epoch must not be 0")
+	}
+	return &SlotClock{
+		Genesis:       time.Unix(int64(genesisTime), 0),
+		SlotDuration:  time.Duration(secondsPerSlot) * time.Second,
+		SlotsPerEpoch: slotsPerEpoch,
+	}, nil
+}
+
+// FromState creates a clock with the genesis time of the state, and the slot timing of the spec.
+func FromState(spec *beacon.Spec, state *beacon.BeaconStateView) (*SlotClock, error) {
+	genesisTime, err := state.GenesisTime()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get genesis time: %v", err)
+	}
+	return NewSlotClock(genesisTime, spec.SECONDS_PER_SLOT, spec.SLOTS_PER_EPOCH)
+}
+
+// SlotAt is the slot at the given time. Slot 0 before genesis.
+func (c *SlotClock) SlotAt(t time.Time) beacon.Slot {
+	if t.Before(c.Genesis) {
+		return 0
+	}
+	return beacon.Slot(t.Sub(c.Genesis) / c.SlotDuration)
+}
+
+// CurrentSlot is the slot at the current time. Slot 0 before genesis.
+func (c *SlotClock) CurrentSlot() beacon.Slot {
+	return c.SlotAt(time.Now())
+}
+
+// SlotStart is the time at which the slot starts.
+func (c *SlotClock) SlotStart(slot beacon.Slot) time.Time {
+	return c.Genesis.Add(time.Duration(slot) * c.SlotDuration)
+}
+
+func (c *SlotClock) EpochOf(slot beacon.Slot) beacon.Epoch {
+	return beacon.Epoch(slot / c.SlotsPerEpoch)
+}
+
+func (c *SlotClock) IsEpochStart(slot beacon.Slot) bool {
+	return slot%c.SlotsPerEpoch == 0
+}
+
+// CheckOffset returns an error if the offset does not fall within a slot.
+func (c *SlotClock) CheckOffset(offset time.Duration) error {
+	if offset < 0 || offset >= c.SlotDuration {
+		return fmt.Errorf("slot offset %s is not within the slot duration %s", offset, c.SlotDuration)
+	}
+	return nil
+}
+
+// Next finds the first slot that reaches the offset into the slot after the given time, and the time it does so.
+// The offset may be negative, or longer than a slot.
+func (c *SlotClock) Next(t time.Time, offset time.Duration) (beacon.Slot, time.Time) {
+	// The first slot that starts after t - offset
+	slot := beacon.Slot(0)
+	if u := t.Add(-offset); !u.Before(c.Genesis) {
+		slot = c.SlotAt(u) + 1
+	}
+	return slot, c.SlotStart(slot).Add(offset)
+}
+
+// Schedule calls fn with every slot, at the offset into the slot, starting with the next slot to reach the offset.
+// Calls are synchronous: slots that pass while fn runs are skipped. Schedule returns when the context is done.
+func (c *SlotClock) Schedule(ctx context.Context, offset time.Duration, fn func(slot beacon.Slot)) {
+	for {
+		slot, at := c.Next(time.Now(), offset)
+		timer := time.NewTimer(time.Until(at))
+		select {
+		case <-timer.C:
+			fn(slot)
+		case <-ctx.Done():
+			timer.Stop()
+			return
+		}
+	}
+}
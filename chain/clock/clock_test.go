@@ -0,0 +1,122 @@
+package clock
+
+import (
+	"github.com/protolambda/zrnt/eth2/beacon"
+	"testing"
+	"time"
+)
+
+func testClock(t *testing.T) *SlotClock {
+	c, err := NewSlotClock(1000, 12, 32)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return c
+}
+
+func TestNewSlotClock(t *testing.T) {
+	if _, err := NewSlotClock(1000, 0, 32); err == nil {
+		t.Error("expected zero seconds per slot to be rejected")
+	}
+	if _, err := NewSlotClock(1000, 12, 0); err == nil {
+		t.Error("expected zero slots per epoch to be rejected")
+	}
+}
+
+func TestSlotAt(t *testing.T) {
+	c := testClock(t)
+	cases := []struct {
+		since time.Duration
+		slot  beacon.Slot
+	}{
+		{-time.Hour, 0},
+		{-time.Nanosecond, 0},
+		{0, 0},
+		{11999 * time.Millisecond, 0},
+		{12 * time.Second, 1},
+		{13 * time.Second, 1},
+		{12 * 32 * time.Second, 32},
+		{24 * time.Hour, 7200},
+	}
+	for _, x := range cases {
+		if slot := c.SlotAt(c.Genesis.Add(x.since)); slot != x.slot {
+			t.Errorf("at genesis %+v: expected slot %d, got %d", x.since, x.slot, slot)
+		}
+	}
+	if start := c.SlotStart(7200); !start.Equal(c.Genesis.Add(24 * time.Hour)) {
+		t.Errorf("expected slot 7200 to start a day after genesis, got %s", start)
+	}
+}
+
+func TestNext(t *testing.T) {
+	c := testClock(t)
+	cases := []struct {
+		name   string
+		since  time.Duration
+		offset time.Duration
+		slot   beacon.Slot
+		at     time.Duration
+	}{
+		{"genesis in the future", -time.Hour, 4 * time.Second, 0, 4 * time.Second},
+		{"genesis in the future, start of slot", -time.Second, 0, 0, 0},
+		{"at genesis", 0, 0, 1, 12 * time.Second},
+		{"before offset", 3 * time.Second, 4 * time.Second, 0, 4 * time.Second},
+		{"at offset", 4 * time.Second, 4 * time.Second, 1, 16 * time.Second},
+		{"after offset", 5 * time.Second, 4 * time.Second, 1, 16 * time.Second},
+		{"end of slot", 11999 * time.Millisecond, 4 * time.Second, 1, 16 * time.Second},
+		{"later slot", 12*100*time.Second + time.Second, 4 * time.Second, 100, 12*100*time.Second + 4*time.Second},
+		{"negative offset, before it", 9 * time.Second, -2 * time.Second, 1, 10 * time.Second},
+		{"negative offset, after it", 11 * time.Second, -2 * time.Second, 2, 22 * time.Second},
+		{"negative offset, next slot started", 13 * time.Second, -2 * time.Second, 2, 22 * time.Second},
+		{"negative offset, genesis in the future", -5 * time.Second, -2 * time.Second, 0, -2 * time.Second},
+		{"negative offset, just before genesis", -time.Second, -2 * time.Second, 1, 10 * time.Second},
+		{"offset past the slot", 5 * time.Second, 14 * time.Second, 0, 14 * time.Second},
+		{"offset past the slot, after it", 15 * time.Second, 14 * time.Second, 1, 26 * time.Second},
+	}
+	for _, x := range cases {
+		now := c.Genesis.Add(x.since)
+		slot, at := c.Next(now, x.offset)
+		if slot != x.slot || !at.Equal(c.Genesis.Add(x.at)) {
+			t.Errorf("%s: expected slot %d at genesis %+v, got slot %d at genesis %+v", x.name, x.slot, x.at, slot, at.Sub(c.Genesis))
+		}
+		if !at.After(now) {
+			t.Errorf("%s: next time %s is not after %s", x.name, at, now)
+		}
+	}
+}
+
+func TestCheckOffset(t *testing.T) {
+	c := testClock(t)
+	for _, offset := range []time.Duration{0, 4 * time.Second, 11999 * time.Millisecond} {
+		if err := c.CheckOffset(offset); err != nil {
+			t.Errorf("expected offset %s to be valid: %v", offset, err)
+		}
+	}
+	for _, offset := range []time.Duration{-time.Nanosecond, 12 * time.Second, time.Minute} {
+		if err := c.CheckOffset(offset); err == nil {
+			t.Errorf("expected offset %s to be rejected", offset)
+		}
+	}
+}
+
+func TestEpochOf(t *testing.T) {
+	c := testClock(t)
+	cases := []struct {
+		slot       beacon.Slot
+		epoch      beacon.Epoch
+		epochStart bool
+	}{
+		{0, 0, true},
+		{31, 0, false},
+		{32, 1, true},
+		{100, 3, false},
+	}
+	for _, x := range cases {
+		if epoch := c.EpochOf(x.slot); epoch != x.epoch {
+			t.Errorf("slot %d: expected epoch %d, got %d", x.slot, x.epoch, epoch)
+		}
+		if start := c.IsEpochStart(x.slot); start != x.epochStart {
+			t.Errorf("slot %d: expected epoch start %v, got %v", x.slot, x.epochStart, start)
+		}
+	}
+}
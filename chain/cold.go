@@ -6,6 +6,7 @@ import (
 	"fmt"
 	"github.com/protolambda/zrnt/eth2/beacon"
 	"github.com/protolambda/ztyp/tree"
+	"time"
 )
 
 type ColdChain interface {
@@ -69,6 +70,13 @@ type FinalizedChain struct {
 
 	// Spec is holds configuration information for the parameters and types of the chain
 	Spec *beacon.Spec
+
+	// Store persists the finalized entries and state snapshots. Optional, states are unavailable without it.
+	Store *ColdStore
+	// Blocks to replay from the last state snapshot to get the state of other slots. Optional.
+	Blocks BlockGetter
+	// Cache of recently used states, to avoid replaying blocks for every lookup.
+	Cache *StateCache
 }
 
 var _ = ColdChain((*FinalizedChain)(nil))
@@ -83,6 +91,7 @@ func NewFinalizedChain(anchorSlot Slot, spec *beacon.Spec) *FinalizedChain {
 		SlotsByBlockRoot: make(map[Root]Slot, initialCapacity),
 		SlotsByStateRoot: make(map[Root]Slot, initialCapacity),
 		Spec:             spec,
+		Cache:            NewStateCache(DefaultStateCacheSize),
 	}
 }
 
@@ -180,13 +189,19 @@ func (f *FinalizedChain) OnFinalizedEntry(entry *HotEntry) error {
 			end, entry.slot, entry.blockRoot.String())
 	}
 	postStateRoot := entry.state.HashTreeRoot(tree.GetHashFn())
+	if f.Store != nil {
+		if err := f.Store.PutEntry(entry.slot, entry.blockRoot, postStateRoot, entry.state); err != nil {
+			return fmt.Errorf("failed to persist finalized entry at slot %d: %v", entry.slot, err)
+		}
+	}
+	// If it's not an empty slot, remember it by block root.
+	// The first entry has no parent in the chain, it is always remembered, like the store loader does.
+	if n := len(f.BlockRoots); n == 0 || f.BlockRoots[n-1] != entry.blockRoot {
+		f.SlotsByBlockRoot[entry.blockRoot] = entry.slot
+	}
 	f.BlockRoots = append(f.BlockRoots, entry.blockRoot)
 	f.StateRoots = append(f.StateRoots, postStateRoot)
 	f.SlotsByStateRoot[postStateRoot] = entry.slot
-	if entry.parentRoot != entry.blockRoot {
-		// if it's not an empty slot, remember it by block root
-		f.SlotsByBlockRoot[entry.blockRoot] = entry.slot
-	}
 	return nil
 }
 
@@ -212,24 +227,91 @@ func (f *FinalizedChain) stateRoot(slot Slot) Root {
 }
 
 func (f *FinalizedChain) getEpochsContext(ctx context.Context, slot Slot) (*beacon.EpochsContext, error) {
-	epc := &beacon.EpochsContext{
-		PubkeyCache: f.PubkeyCache,
+	_, epc, err := f.loadState(ctx, slot)
+	return epc, err
+}
+
+func (f *FinalizedChain) getState(ctx context.Context, slot Slot) (*beacon.BeaconStateView, error) {
+	state, _, err := f.loadState(ctx, slot)
+	return state, err
+}
+
+// loadState gets the state and epochs-context of the slot from the cache,
+// or replays blocks from the closest cached state or state snapshot.
+func (f *FinalizedChain) loadState(ctx context.Context, slot Slot) (*beacon.BeaconStateView, *beacon.EpochsContext, error) {
+	if f.Store == nil {
+		return nil, nil, errors.New("finalized chain has no state storage")
 	}
-	// We do not store shuffling for older epochs
-	// TODO: maybe store it after all, for archive node functionality?
-	state, err := f.getState(ctx, slot)
+	if start := f.Start(); slot < start {
+		return nil, nil, fmt.Errorf("slot %d is too early. Chain starts at slot %d", slot, start)
+	}
+	if end := f.End(); slot >= end {
+		return nil, nil, fmt.Errorf("slot %d is too late. Chain ends at slot %d", slot, end)
+	}
+	if state, epc, ok := f.Cache.Get(slot); ok {
+		return state, epc, nil
+	}
+	snapSlot := f.Store.SnapshotBefore(slot)
+	// Start from the latest cached state after the snapshot, if any
+	state, epc, ok := f.Cache.Closest(snapSlot, slot)
+	if !ok {
+		var exists bool
+		var err error
+		state, exists, err = f.Store.GetState(snapSlot)
+		if err != nil {
+			return nil, nil, err
+		}
+		if !exists {
+			return nil, nil, fmt.Errorf("missing state snapshot of slot %d", snapSlot)
+		}
+		epc, err = f.Spec.NewEpochsContext(state)
+		if err != nil {
+			return nil, nil, err
+		}
+		f.Cache.Add(snapSlot, state, epc)
+	}
+	from, err := state.Slot()
 	if err != nil {
-		return nil, err
+		return nil, nil, err
 	}
-	if err := epc.LoadShuffling(state); err != nil {
-		return nil, err
+	if from == slot {
+		return state, epc, nil
 	}
-	if err := epc.LoadProposers(state); err != nil {
-		return nil, err
+	replayStart := time.Now()
+	var block beacon.SignedBeaconBlock
+	for s := from + 1; s <= slot; s++ {
+		blockRoot := f.blockRoot(s)
+		if blockRoot == f.blockRoot(s-1) {
+			if err := processEmptySlot(ctx, f.Spec, epc, state); err != nil {
+				return nil, nil, fmt.Errorf("failed to process empty slot %d: %v", s, err)
+			}
+		} else {
+			if f.Blocks == nil {
+				return nil, nil, fmt.Errorf("no blocks to replay from slot %d to slot %d", from, slot)
+			}
+			if exists, err := f.Blocks.Get(blockRoot, &block); err != nil {
+				return nil, nil, fmt.Errorf("failed to get block %s of slot %d: %v", blockRoot, s, err)
+			} else if !exists {
+				return nil, nil, fmt.Errorf("cannot replay missing block %s of slot %d", blockRoot, s)
+			}
+			// Blocks of the canonical chain were already verified, skip the state root check.
+			if err := f.Spec.StateTransition(ctx, epc, state, &block, false); err != nil {
+				return nil, nil, fmt.Errorf("failed to replay block %s of slot %d: %v", blockRoot, s, err)
+			}
+			// And seal the state, like the hot chain does.
+			if err := f.Spec.ProcessSlot(ctx, state); err != nil {
+				return nil, nil, err
+			}
+		}
+		// Keep the epoch boundaries, other lookups in the same epoch can replay from there.
+		if s%f.Spec.SLOTS_PER_EPOCH == 0 && s != slot {
+			f.Cache.Add(s, state, epc)
+		}
 	}
-	return epc, nil
-}
-
-func (f *FinalizedChain) getState(ctx context.Context, slot Slot) (*beacon.BeaconStateView, error) {
-	return nil, errors.New("todo: load state from state storage")
+	if root := state.HashTreeRoot(tree.GetHashFn()); root != f.stateRoot(slot) {
+		return nil, nil, fmt.Errorf("replayed state of slot %d has root %s, expected %s", slot, root, f.stateRoot(slot))
+	}
+	f.Cache.recordReplay(uint64(slot-from), time.Since(replayStart))
+	f.Cache.Add(slot, state, epc)
+	return state, epc, nil
 }
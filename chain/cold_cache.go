@@ -0,0 +1,168 @@
+package chain
+
+import (
+	"container/list"
+	"github.com/protolambda/zrnt/eth2/beacon"
+	"sync"
+	"time"
+)
+
+const DefaultStateCacheSize = 32
+
+type cachedState struct {
+	slot  Slot
+	state *beacon.BeaconStateView
+	epc   *beacon.EpochsContext
+}
+
+type StateCacheStats struct {
+	Size     int
+	Capacity int
+	Hits     uint64
+	Misses   uint64
+	// Number of times states were regenerated by replaying blocks
+	Replays uint64
+	// Total number of slots processed during replays
+	ReplayedSlots uint64
+	ReplayTotal   time.Duration
+	ReplayMax     time.Duration
+	ReplayLast    time.Duration
+}
+
+// HitRate is the fraction of lookups that were served from the cache, 0 if there were no lookups.
+func (s *StateCacheStats) HitRate() float64 {
+	if total := s.Hits + s.Misses; total > 0 {
+		return float64(s.Hits) / float64(total)
+	}
+	return 0
+}
+
+// StateCache is a bounded LRU cache of finalized states and their epochs-context, by slot.
+// The cached state and context are never modified, lookups return copies.
+type StateCache struct {
+	sync.Mutex
+	capacity int
+	order    *list.List // front is most recently used
+	entries  map[Slot]*list.Element
+	stats    StateCacheStats
+}
+
+func NewStateCache(capacity int) *StateCache {
+	if capacity < 0 {
+		capacity = 0
+	}
+	return &StateCache{
+		capacity: capacity,
+		order:    list.New(),
+		entries:  make(map[Slot]*list.Element, capacity),
+	}
+}
+
+// Get a copy of the state and epochs-context of the slot, and count it as hit or miss.
+func (c *StateCache) Get(slot Slot) (state *beacon.BeaconStateView, epc *beacon.EpochsContext, ok bool) {
+	c.Lock()
+	defer c.Unlock()
+	el, ok := c.entries[slot]
+	if !ok {
+		c.stats.Misses += 1
+		return nil, nil, false
+	}
+	c.stats.Hits += 1
+	c.order.MoveToFront(el)
+	v := el.Value.(*cachedState)
+	state, err := beacon.AsBeaconStateView(v.state.Copy())
+	if err != nil {
+		return nil, nil, false
+	}
+	return state, v.epc.Clone(), true
+}
+
+// Closest returns a copy of the cached state with the highest slot in the range [from, to], without counting a hit or miss.
+func (c *StateCache) Closest(from Slot, to Slot) (state *beacon.BeaconStateView, epc *beacon.EpochsContext, ok bool) {
+	c.Lock()
+	defer c.Unlock()
+	var best *list.Element
+	for slot, el := range c.entries {
+		if slot >= from && slot <= to && (best == nil || slot > best.Value.(*cachedState).slot) {
+			best = el
+		}
+	}
+	if best == nil {
+		return nil, nil, false
+	}
+	c.order.MoveToFront(best)
+	v := best.Value.(*cachedState)
+	state, err := beacon.AsBeaconStateView(v.state.Copy())
+	if err != nil {
+		return nil, nil, false
+	}
+	return state, v.epc.Clone(), true
+}
+
+// Add a copy of the state and epochs-context, evicting the least recently used entry if the cache is full.
+func (c *StateCache) Add(slot Slot, state *beacon.BeaconStateView, epc *beacon.EpochsContext) {
+	c.Lock()
+	defer c.Unlock()
+	if c.capacity <= 0 {
+		return
+	}
+	if el, ok := c.entries[slot]; ok {
+		c.order.MoveToFront(el)
+		return
+	}
+	stateCopy, err := beacon.AsBeaconStateView(state.Copy())
+	if err != nil {
+		return
+	}
+	c.entries[slot] = c.order.PushFront(&cachedState{slot: slot, state: stateCopy, epc: epc.Clone()})
+	c.evict()
+}
+
+func (c *StateCache) evict() {
+	for c.order.Len() > c.capacity {
+		el := c.order.Back()
+		c.order.Remove(el)
+		delete(c.entries, el.Value.(*cachedState).slot)
+	}
+}
+
+// Resize changes the capacity, evicting the least recently used entries if necessary. 0 disables the cache.
+func (c *StateCache) Resize(capacity int) {
+	if capacity < 0 {
+		capacity = 0
+	}
+	c.Lock()
+	defer c.Unlock()
+	c.capacity = capacity
+	c.evict()
+}
+
+// Clear removes all entries and resets the statistics.
+func (c *StateCache) Clear() {
+	c.Lock()
+	defer c.Unlock()
+	c.order.Init()
+	c.entries = make(map[Slot]*list.Element, c.capacity)
+	c.stats = StateCacheStats{}
+}
+
+func (c *StateCache) recordReplay(slots uint64, d time.Duration) {
+	c.Lock()
+	defer c.Unlock()
+	c.stats.Replays += 1
+	c.stats.ReplayedSlots += slots
+	c.stats.ReplayTotal += d
+	c.stats.ReplayLast = d
+	if d > c.stats.ReplayMax {
+		c.stats.ReplayMax = d
+	}
+}
+
+func (c *StateCache) Stats() StateCacheStats {
+	c.Lock()
+	defer c.Unlock()
+	out := c.stats
+	out.Size = c.order.Len()
+	out.Capacity = c.capacity
+	return out
+}
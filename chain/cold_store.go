@@ -0,0 +1,344 @@
+package chain
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	ds "github.com/ipfs/go-datastore"
+	"github.com/ipfs/go-datastore/query"
+	"github.com/protolambda/zrnt/eth2/beacon"
+	"github.com/protolambda/ztyp/codec"
+	"github.com/protolambda/ztyp/tree"
+	"strconv"
+	"strings"
+)
+
+const (
+	coldMetaAnchorKey   = "/cold/meta/anchor"
+	coldMetaIntervalKey = "/cold/meta/interval"
+	coldRootsPrefix     = "/cold/roots"
+	coldStatesPrefix    = "/cold/states"
+)
+
+func coldRootsKey(slot Slot) ds.Key {
+	return ds.NewKey(fmt.Sprintf("%s/%016x", coldRootsPrefix, uint64(slot)))
+}
+
+func coldStateKey(slot Slot) ds.Key {
+	return ds.NewKey(fmt.Sprintf("%s/%016x", coldStatesPrefix, uint64(slot)))
+}
+
+func parseSlotKey(key string) (Slot, error) {
+	i := strings.LastIndexByte(key, '/')
+	v, err := strconv.ParseUint(key[i+1:], 16, 64)
+	return Slot(v), err
+}
+
+// ColdStore persists a finalized chain in a datastore:
+// the canonical block and state root of every slot, and a full state snapshot every SnapshotInterval slots.
+// States in between snapshots are regenerated by replaying blocks.
+type ColdStore struct {
+	store ds.Batching
+	spec  *beacon.Spec
+	// Slot of the first entry
+	AnchorSlot Slot
+	// A state is stored for every slot that is a multiple of the interval away from the anchor slot.
+	SnapshotInterval Slot
+}
+
+// NewColdStore initializes an empty datastore to persist a finalized chain that starts at the anchor entry.
+// The anchor entry and its state are persisted right away, the chain can be loaded from it before anything else finalizes.
+func NewColdStore(store ds.Batching, spec *beacon.Spec, anchor *HotEntry, snapshotInterval Slot) (*ColdStore, error) {
+	if snapshotInterval == 0 {
+		return nil, errors.New("snapshot interval must be at least 1 slot")
+	}
+	if exists, err := store.Has(ds.NewKey(coldMetaAnchorKey)); err != nil {
+		return nil, err
+	} else if exists {
+		return nil, errors.New("datastore already contains a chain, load it instead")
+	}
+	s := &ColdStore{store: store, spec: spec, AnchorSlot: anchor.slot, SnapshotInterval: snapshotInterval}
+	if err := s.PutEntry(anchor.slot, anchor.blockRoot, anchor.StateRoot(), anchor.state); err != nil {
+		return nil, fmt.Errorf("failed to persist anchor entry: %v", err)
+	}
+	// The meta keys go last, a store that fails to initialize is not mistaken for a chain.
+	var tmp [8]byte
+	binary.LittleEndian.PutUint64(tmp[:], uint64(snapshotInterval))
+	if err := store.Put(ds.NewKey(coldMetaIntervalKey), tmp[:]); err != nil {
+		return nil, err
+	}
+	binary.LittleEndian.PutUint64(tmp[:], uint64(anchor.slot))
+	if err := store.Put(ds.NewKey(coldMetaAnchorKey), tmp[:]); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// OpenColdStore opens a datastore that was previously initialized with NewColdStore.
+func OpenColdStore(store ds.Batching, spec *beacon.Spec) (*ColdStore, error) {
+	readSlot := func(key string) (Slot, error) {
+		v, err := store.Get(ds.NewKey(key))
+		if err == ds.ErrNotFound {
+			return 0, errors.New("datastore does not contain a chain")
+		} else if err != nil {
+			return 0, err
+		}
+		if len(v) != 8 {
+			return 0, fmt.Errorf("bad %s value length: %d", key, len(v))
+		}
+		return Slot(binary.LittleEndian.Uint64(v)), nil
+	}
+	anchorSlot, err := readSlot(coldMetaAnchorKey)
+	if err != nil {
+		return nil, err
+	}
+	interval, err := readSlot(coldMetaIntervalKey)
+	if err != nil {
+		return nil, err
+	}
+	if interval == 0 {
+		return nil, errors.New("bad snapshot interval in datastore: 0")
+	}
+	return &ColdStore{store: store, spec: spec, AnchorSlot: anchorSlot, SnapshotInterval: interval}, nil
+}
+
+func (s *ColdStore) Spec() *beacon.Spec {
+	return s.spec
+}
+
+// IsSnapshot checks if the state of the given slot is persisted.
+func (s *ColdStore) IsSnapshot(slot Slot) bool {
+	return slot >= s.AnchorSlot && (slot-s.AnchorSlot)%s.SnapshotInterval == 0
+}
+
+// SnapshotBefore returns the slot of the last snapshot at or before the given slot.
+func (s *ColdStore) SnapshotBefore(slot Slot) Slot {
+	if slot <= s.AnchorSlot {
+		return s.AnchorSlot
+	}
+	return slot - (slot-s.AnchorSlot)%s.SnapshotInterval
+}
+
+// PutEntry persists the roots of the entry, and the state if the slot is a snapshot slot.
+func (s *ColdStore) PutEntry(slot Slot, blockRoot Root, stateRoot Root, state *beacon.BeaconStateView) error {
+	b, err := s.store.Batch()
+	if err != nil {
+		return err
+	}
+	var roots [64]byte
+	copy(roots[:32], blockRoot[:])
+	copy(roots[32:], stateRoot[:])
+	if err := b.Put(coldRootsKey(slot), roots[:]); err != nil {
+		return err
+	}
+	if s.IsSnapshot(slot) {
+		var buf bytes.Buffer
+		if err := state.Serialize(codec.NewEncodingWriter(&buf)); err != nil {
+			return fmt.Errorf("failed to serialize state snapshot of slot %d: %v", slot, err)
+		}
+		if err := b.Put(coldStateKey(slot), buf.Bytes()); err != nil {
+			return err
+		}
+	}
+	return b.Commit()
+}
+
+// GetState loads the state snapshot at the given slot. Returns exists=false if there is no snapshot.
+func (s *ColdStore) GetState(slot Slot) (state *beacon.BeaconStateView, exists bool, err error) {
+	v, err := s.store.Get(coldStateKey(slot))
+	if err == ds.ErrNotFound {
+		return nil, false, nil
+	} else if err != nil {
+		return nil, false, err
+	}
+	state, err = beacon.AsBeaconStateView(s.spec.BeaconState().Deserialize(
+		codec.NewDecodingReader(bytes.NewReader(v), uint64(len(v)))))
+	if err != nil {
+		return nil, true, fmt.Errorf("failed to decode state snapshot of slot %d: %v", slot, err)
+	}
+	return state, true, nil
+}
+
+// Truncate removes all roots and states at or after the given slot.
+func (s *ColdStore) Truncate(from Slot) error {
+	b, err := s.store.Batch()
+	if err != nil {
+		return err
+	}
+	for _, prefix := range []string{coldRootsPrefix, coldStatesPrefix} {
+		res, err := s.store.Query(query.Query{Prefix: prefix, KeysOnly: true})
+		if err != nil {
+			return err
+		}
+		entries, err := res.Rest()
+		if err != nil {
+			return err
+		}
+		for _, e := range entries {
+			slot, err := parseSlotKey(e.Key)
+			if err != nil {
+				return fmt.Errorf("bad key %s: %v", e.Key, err)
+			}
+			if slot >= from {
+				if err := b.Delete(ds.NewKey(e.Key)); err != nil {
+					return err
+				}
+			}
+		}
+	}
+	return b.Commit()
+}
+
+// Close closes the underlying datastore.
+func (s *ColdStore) Close() error {
+	return s.store.Close()
+}
+
+// LoadFinalizedChain loads the finalized chain from the store, up to (excluding) the last state snapshot.
+// The chain continues from the returned anchor entry at the slot of that snapshot.
+// The store is not changed. Once the chain continues from the anchor, the entries after it
+// should be removed with Truncate, they are persisted again when they are finalized again.
+func LoadFinalizedChain(s *ColdStore) (*FinalizedChain, *HotEntry, error) {
+	res, err := s.store.Query(query.Query{Prefix: coldRootsPrefix, Orders: []query.Order{query.OrderByKey{}}})
+	if err != nil {
+		return nil, nil, err
+	}
+	entries, err := res.Rest()
+	if err != nil {
+		return nil, nil, err
+	}
+	var blockRoots, stateRoots []Root
+	for _, e := range entries {
+		slot, err := parseSlotKey(e.Key)
+		if err != nil {
+			return nil, nil, fmt.Errorf("bad key %s: %v", e.Key, err)
+		}
+		// stop at the first gap, the chain must be contiguous
+		if slot != s.AnchorSlot+Slot(len(blockRoots)) {
+			break
+		}
+		if len(e.Value) != 64 {
+			return nil, nil, fmt.Errorf("bad roots value length at slot %d: %d", slot, len(e.Value))
+		}
+		var blockRoot, stateRoot Root
+		copy(blockRoot[:], e.Value[:32])
+		copy(stateRoot[:], e.Value[32:])
+		blockRoots = append(blockRoots, blockRoot)
+		stateRoots = append(stateRoots, stateRoot)
+	}
+	if len(blockRoots) == 0 {
+		return nil, nil, errors.New("datastore does not contain any finalized entries")
+	}
+
+	// Find the last snapshot to continue from
+	end := s.AnchorSlot + Slot(len(blockRoots))
+	var anchorState *beacon.BeaconStateView
+	snapSlot := s.SnapshotBefore(end - 1)
+	for {
+		state, exists, err := s.GetState(snapSlot)
+		if err != nil {
+			return nil, nil, err
+		}
+		if exists {
+			anchorState = state
+			break
+		}
+		if snapSlot == s.AnchorSlot {
+			return nil, nil, errors.New("datastore does not contain any state snapshot to continue from")
+		}
+		snapSlot -= s.SnapshotInterval
+	}
+	i := snapSlot - s.AnchorSlot
+	if root := anchorState.HashTreeRoot(tree.GetHashFn()); root != stateRoots[i] {
+		return nil, nil, fmt.Errorf("state snapshot of slot %d has root %s, expected %s", snapSlot, root, stateRoots[i])
+	}
+	anchor, err := HotEntryFromState(s.spec, anchorState)
+	if err != nil {
+		return nil, nil, fmt.Errorf("bad state snapshot of slot %d: %v", snapSlot, err)
+	}
+	if anchor.blockRoot != blockRoots[i] {
+		return nil, nil, fmt.Errorf("state snapshot of slot %d has latest block %s, expected %s", snapSlot, anchor.blockRoot, blockRoots[i])
+	}
+	f := NewFinalizedChain(s.AnchorSlot, s.spec)
+	f.Store = s
+	for j := Slot(0); j < i; j++ {
+		slot := s.AnchorSlot + j
+		f.BlockRoots = append(f.BlockRoots, blockRoots[j])
+		f.StateRoots = append(f.StateRoots, stateRoots[j])
+		f.SlotsByStateRoot[stateRoots[j]] = slot
+		if j == 0 || blockRoots[j] != blockRoots[j-1] {
+			f.SlotsByBlockRoot[blockRoots[j]] = slot
+		}
+	}
+	return f, anchor, nil
+}
+
+// HotEntryFromState creates a chain entry from a (post-)state, with the block roots from the latest block header.
+func HotEntryFromState(spec *beacon.Spec, state *beacon.BeaconStateView) (*HotEntry, error) {
+	slot, err := state.Slot()
+	if err != nil {
+		return nil, err
+	}
+	latestHeader, err := state.LatestBlockHeader()
+	if err != nil {
+		return nil, err
+	}
+	latestHeader, err = beacon.AsBeaconBlockHeader(latestHeader.Copy())
+	if err != nil {
+		return nil, err
+	}
+	headerStateRoot, err := latestHeader.StateRoot()
+	if err != nil {
+		return nil, err
+	}
+	if headerStateRoot == (beacon.Root{}) {
+		if err := latestHeader.SetStateRoot(state.HashTreeRoot(tree.GetHashFn())); err != nil {
+			return nil, err
+		}
+	}
+	blockRoot := latestHeader.HashTreeRoot(tree.GetHashFn())
+	parentRoot, err := latestHeader.ParentRoot()
+	if err != nil {
+		return nil, err
+	}
+	epc, err := spec.NewEpochsContext(state)
+	if err != nil {
+		return nil, err
+	}
+	return NewHotEntry(slot, blockRoot, parentRoot, state, epc), nil
+}
+
+// BlockGetter is what the finalized chain needs to replay blocks between state snapshots. The blocks DB implements it.
+type BlockGetter interface {
+	Get(root Root, dest *beacon.SignedBeaconBlock) (exists bool, err error)
+}
+
+// processEmptySlot transitions the state to the next slot, without a block.
+func processEmptySlot(ctx context.Context, spec *beacon.Spec, epc *beacon.EpochsContext, state *beacon.BeaconStateView) error {
+	slot, err := state.Slot()
+	if err != nil {
+		return err
+	}
+	if err := spec.ProcessSlot(ctx, state); err != nil {
+		return err
+	}
+	// Per-epoch transition happens at the start of the first slot of every epoch.
+	// (with the slot still at the end of the last epoch)
+	isEpochEnd := spec.SlotToEpoch(slot+1) != spec.SlotToEpoch(slot)
+	if isEpochEnd {
+		if err := spec.ProcessEpoch(ctx, epc, state); err != nil {
+			return err
+		}
+	}
+	if err := state.SetSlot(slot + 1); err != nil {
+		return err
+	}
+	if isEpochEnd {
+		if err := epc.RotateEpochs(state); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,176 @@
+package chain
+
+import (
+	"context"
+	ds "github.com/ipfs/go-datastore"
+	leveldb "github.com/ipfs/go-ds-leveldb"
+	"github.com/protolambda/zrnt/eth2/configs"
+	"github.com/protolambda/ztyp/tree"
+	"testing"
+)
+
+// createStored creates a chain from the genesis anchor, persisted in a leveldb datastore at the path.
+func createStored(t *testing.T, chains *ChainsMap, path string, anchor *HotEntry) *HotColdChain {
+	spec := configs.Minimal
+	store, err := leveldb.NewDatastore(path, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	coldStore, err := NewColdStore(store, spec, anchor, spec.SLOTS_PER_EPOCH)
+	if err != nil {
+		t.Fatal(err)
+	}
+	coldCh := NewFinalizedChain(anchor.slot, spec)
+	coldCh.Store = coldStore
+	c, err := chains.CreateFrom("test", anchor, coldCh)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return c.(*HotColdChain)
+}
+
+// loadStored loads the chain persisted at the path, like a restart does.
+func loadStored(t *testing.T, chains *ChainsMap, path string) (*HotColdChain, *FinalizedChain, *HotEntry) {
+	store, err := leveldb.NewDatastore(path, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	coldStore, err := OpenColdStore(store, configs.Minimal)
+	if err != nil {
+		t.Fatal(err)
+	}
+	coldCh, anchor, err := LoadFinalizedChain(coldStore)
+	if err != nil {
+		t.Fatal(err)
+	}
+	c, err := chains.CreateFrom("test", anchor, coldCh)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := coldStore.Truncate(anchor.slot + 1); err != nil {
+		t.Fatal(err)
+	}
+	return c.(*HotColdChain), coldCh, anchor
+}
+
+func TestColdStoreAnchorRoundTrip(t *testing.T) {
+	spec := configs.Minimal
+	anchor := interopGenesis(t, spec, spec.MIN_GENESIS_ACTIVE_VALIDATOR_COUNT)
+	path := t.TempDir()
+	var chains ChainsMap
+	createStored(t, &chains, path, anchor)
+	// Nothing is finalized yet, the anchor alone is enough to continue from.
+	chains.Remove("test")
+
+	// Loading twice, the chain continues from the anchor again.
+	loadStored(t, &chains, path)
+	chains.Remove("test")
+	c, coldCh, loaded := loadStored(t, &chains, path)
+	defer chains.Remove("test")
+	if loaded.BlockRoot() != anchor.BlockRoot() || loaded.StateRoot() != anchor.StateRoot() {
+		t.Fatalf("expected anchor %s with state %s, got %s with state %s",
+			anchor.BlockRoot(), anchor.StateRoot(), loaded.BlockRoot(), loaded.StateRoot())
+	}
+	if end := coldCh.End(); end != anchor.slot {
+		t.Fatalf("expected empty cold chain ending at the anchor slot %d, got end %d", anchor.slot, end)
+	}
+	proposeOn(t, c.HotChain.(*UnfinalizedChain), loaded, 1, Root{})
+}
+
+func TestColdStoreFinalizedRoundTrip(t *testing.T) {
+	spec := configs.Minimal
+	anchor := interopGenesis(t, spec, spec.MIN_GENESIS_ACTIVE_VALIDATOR_COUNT)
+	path := t.TempDir()
+	var chains ChainsMap
+	c := createStored(t, &chains, path, anchor)
+	uc := c.HotChain.(*UnfinalizedChain)
+	finalizingChain(t, uc, anchor, 4*spec.SLOTS_PER_EPOCH+1)
+
+	finalized := uc.Finalized()
+	ref, ok := uc.ForkChoice.GetBlock(finalized.Root)
+	if finalized.Epoch == 0 || !ok {
+		t.Fatalf("expected a finalized block, got checkpoint %v", finalized)
+	}
+	// The finalized block is the new anchor, everything before it moved to the cold chain.
+	if uc.AnchorSlot != ref.Slot {
+		t.Fatalf("expected hot anchor at finalized slot %d, got %d", ref.Slot, uc.AnchorSlot)
+	}
+	for key := range uc.Entries {
+		if key.Slot() < ref.Slot {
+			t.Fatalf("entry of slot %d was not pruned", key.Slot())
+		}
+	}
+	if end := c.ColdChain.End(); end != ref.Slot {
+		t.Fatalf("expected cold chain to end at finalized slot %d, got %d", ref.Slot, end)
+	}
+	if entry, err := c.ByBlockRoot(anchor.BlockRoot()); err != nil || entry.Slot() != anchor.slot {
+		t.Fatalf("expected anchor block in cold chain, got %v (err: %v)", entry, err)
+	}
+	// The last snapshot before the finalized block is where a restart continues from.
+	snapSlot := ref.Slot - spec.SLOTS_PER_EPOCH
+	snapEntry, err := c.BySlot(snapSlot)
+	if err != nil {
+		t.Fatal(err)
+	}
+	chains.Remove("test")
+
+	// Loading does not change the store by itself
+	store, err := leveldb.NewDatastore(path, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	coldStore, err := OpenColdStore(store, spec)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err := LoadFinalizedChain(coldStore); err != nil {
+		t.Fatal(err)
+	}
+	if exists, err := store.Has(coldRootsKey(ref.Slot - 1)); err != nil || !exists {
+		t.Fatalf("expected entry of slot %d to be kept after load (err: %v)", ref.Slot-1, err)
+	}
+	if err := coldStore.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	c, coldCh, loaded := loadStored(t, &chains, path)
+	defer chains.Remove("test")
+	if loaded.Slot() != snapSlot || loaded.BlockRoot() != snapEntry.BlockRoot() || loaded.StateRoot() != snapEntry.StateRoot() {
+		t.Fatalf("expected anchor %s at slot %d, got %s at slot %d",
+			snapEntry.BlockRoot(), snapSlot, loaded.BlockRoot(), loaded.Slot())
+	}
+	if end := coldCh.End(); end != snapSlot {
+		t.Fatalf("expected cold chain to end at snapshot slot %d, got %d", snapSlot, end)
+	}
+	// The anchor entry stays persisted, for the next load to continue from.
+	if exists, err := coldCh.Store.store.Has(coldStateKey(snapSlot)); err != nil || !exists {
+		t.Fatalf("expected snapshot of anchor slot %d to be kept (err: %v)", snapSlot, err)
+	}
+	if exists, err := coldCh.Store.store.Has(coldRootsKey(snapSlot + 1)); err != nil || exists {
+		t.Fatalf("expected entry of slot %d to be removed (err: %v)", snapSlot+1, err)
+	}
+	if entry, err := c.ByBlockRoot(anchor.BlockRoot()); err != nil || entry.Slot() != anchor.slot {
+		t.Fatalf("expected anchor block in loaded cold chain, got %v (err: %v)", entry, err)
+	}
+	// Snapshot states are available without replaying blocks
+	state, err := coldCh.getState(context.Background(), anchor.slot)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if root := state.HashTreeRoot(tree.GetHashFn()); root != anchor.StateRoot() {
+		t.Fatalf("expected anchor state %s, got %s", anchor.StateRoot(), root)
+	}
+	proposeOn(t, c.HotChain.(*UnfinalizedChain), loaded, snapSlot+1, Root{'z'})
+}
+
+func TestNewColdStoreExisting(t *testing.T) {
+	spec := configs.Minimal
+	anchor := interopGenesis(t, spec, spec.MIN_GENESIS_ACTIVE_VALIDATOR_COUNT)
+	store := ds.NewMapDatastore()
+	if _, err := NewColdStore(store, spec, anchor, spec.SLOTS_PER_EPOCH); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := NewColdStore(store, spec, anchor, spec.SLOTS_PER_EPOCH); err == nil {
+		t.Fatal("expected initialized datastore to be rejected")
+	}
+}
@@ -0,0 +1,231 @@
+package blocks
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"github.com/golang/snappy"
+	"github.com/protolambda/zrnt/eth2/beacon"
+	"io"
+	"io/ioutil"
+	"sort"
+)
+
+// Archives are era-style e2store files: a sequence of records, each with an 8 byte header
+// (2 byte type, 4 byte little-endian data length, 2 reserved zero bytes), followed by the data.
+//
+// An archive starts with a version record, then a record per block, with the snappy-framed SSZ of the
+// SignedBeaconBlock, and ends with an index record. Unlike era files, there may be multiple blocks per slot.
+// The index record has, for every block ordered by slot, the slot and the offset of the block record relative to
+// the start of the index record (8 bytes each, little-endian), followed by the block count (8 bytes).
+const (
+	archiveVersionType = 0x3265 // "e2"
+	archiveBlockType   = 0x0001
+	archiveIndexType   = 0x3269 // "i2"
+
+	archiveHeaderSize = 8
+)
+
+// ArchiveEntry locates a block in an archive.
+type ArchiveEntry struct {
+	Slot beacon.Slot
+	// Offset of the block record, from the start of the archive
+	Offset int64
+}
+
+type ArchiveWriter struct {
+	w      io.Writer
+	offset int64
+	index  []ArchiveEntry
+}
+
+// NewArchiveWriter starts an archive, Close writes the index to complete it.
+func NewArchiveWriter(w io.Writer) (*ArchiveWriter, error) {
+	aw := &ArchiveWriter{w: w}
+	if err := aw.writeRecord(archiveVersionType, nil); err != nil {
+		return nil, err
+	}
+	return aw, nil
+}
+
+func (aw *ArchiveWriter) writeRecord(typ uint16, data []byte) error {
+	if uint64(len(data)) > 0xffffffff {
+		return fmt.Errorf("record of %d bytes is too large", len(data))
+	}
+	var header [archiveHeaderSize]byte
+	binary.LittleEndian.PutUint16(header[0:2], typ)
+	binary.LittleEndian.PutUint32(header[2:6], uint32(len(data)))
+	if _, err := aw.w.Write(header[:]); err != nil {
+		return err
+	}
+	if _, err := aw.w.Write(data); err != nil {
+		return err
+	}
+	aw.offset += archiveHeaderSize + int64(len(data))
+	return nil
+}
+
+// WriteBlock adds a block, given as SSZ encoded SignedBeaconBlock, to the archive.
+func (aw *ArchiveWriter) WriteBlock(slot beacon.Slot, ssz []byte) error {
+	var buf bytes.Buffer
+	sw := snappy.NewBufferedWriter(&buf)
+	if _, err := sw.Write(ssz); err != nil {
+		return err
+	}
+	if err := sw.Close(); err != nil {
+		return err
+	}
+	offset := aw.offset
+	if err := aw.writeRecord(archiveBlockType, buf.Bytes()); err != nil {
+		return err
+	}
+	aw.index = append(aw.index, ArchiveEntry{Slot: slot, Offset: offset})
+	return nil
+}
+
+// Close writes the index record. It does not close the underlying writer.
+func (aw *ArchiveWriter) Close() error {
+	sort.SliceStable(aw.index, func(i, j int) bool {
+		return aw.index[i].Slot < aw.index[j].Slot
+	})
+	data := make([]byte, len(aw.index)*16+8)
+	for i, e := range aw.index {
+		binary.LittleEndian.PutUint64(data[i*16:i*16+8], uint64(e.Slot))
+		binary.LittleEndian.PutUint64(data[i*16+8:i*16+16], uint64(e.Offset-aw.offset))
+	}
+	binary.LittleEndian.PutUint64(data[len(data)-8:], uint64(len(aw.index)))
+	return aw.writeRecord(archiveIndexType, data)
+}
+
+func readRecordHeader(r io.Reader) (typ uint16, length uint32, err error) {
+	var header [archiveHeaderSize]byte
+	if _, err := io.ReadFull(r, header[:]); err != nil {
+		return 0, 0, err
+	}
+	return binary.LittleEndian.Uint16(header[0:2]), binary.LittleEndian.Uint32(header[2:6]), nil
+}
+
+// ReadArchiveIndex reads the index at the end of the archive.
+func ReadArchiveIndex(r io.ReadSeeker) ([]ArchiveEntry, error) {
+	end, err := r.Seek(0, io.SeekEnd)
+	if err != nil {
+		return nil, err
+	}
+	if end < 2*archiveHeaderSize+8 {
+		return nil, errors.New("archive is too small")
+	}
+	var countBytes [8]byte
+	if _, err := r.Seek(end-8, io.SeekStart); err != nil {
+		return nil, err
+	}
+	if _, err := io.ReadFull(r, countBytes[:]); err != nil {
+		return nil, err
+	}
+	count := binary.LittleEndian.Uint64(countBytes[:])
+	indexSize := int64(count)*16 + 8
+	if count > uint64(end/16) || indexSize+archiveHeaderSize > end {
+		return nil, fmt.Errorf("bad archive index count: %d", count)
+	}
+	indexStart := end - indexSize - archiveHeaderSize
+	if _, err := r.Seek(indexStart, io.SeekStart); err != nil {
+		return nil, err
+	}
+	typ, length, err := readRecordHeader(r)
+	if err != nil {
+		return nil, err
+	}
+	if typ != archiveIndexType || int64(length) != indexSize {
+		return nil, errors.New("archive does not end with an index")
+	}
+	data := make([]byte, indexSize)
+	if _, err := io.ReadFull(r, data); err != nil {
+		return nil, err
+	}
+	out := make([]ArchiveEntry, count)
+	for i := range out {
+		out[i].Slot = beacon.Slot(binary.LittleEndian.Uint64(data[i*16 : i*16+8]))
+		out[i].Offset = indexStart + int64(binary.LittleEndian.Uint64(data[i*16+8:i*16+16]))
+		if out[i].Offset < archiveHeaderSize || out[i].Offset >= indexStart {
+			return nil, fmt.Errorf("bad archive index offset of block %d", i)
+		}
+	}
+	return out, nil
+}
+
+// ReadArchiveBlock reads the SSZ encoded SignedBeaconBlock of the archive entry.
+func ReadArchiveBlock(r io.ReadSeeker, entry ArchiveEntry) ([]byte, error) {
+	if _, err := r.Seek(entry.Offset, io.SeekStart); err != nil {
+		return nil, err
+	}
+	typ, length, err := readRecordHeader(r)
+	if err != nil {
+		return nil, err
+	}
+	if typ != archiveBlockType {
+		return nil, fmt.Errorf("expected block record at offset %d, got type %04x", entry.Offset, typ)
+	}
+	return ioutil.ReadAll(snappy.NewReader(io.LimitReader(r, int64(length))))
+}
+
+// ExportArchive writes the blocks to an archive, in the given order.
+// Blocks that are not in the DB are skipped. Progress is called after every block.
+func ExportArchive(ctx context.Context, db DB, refs []BlockRef, w io.Writer, progress func(done int, total int)) (exported int, err error) {
+	aw, err := NewArchiveWriter(w)
+	if err != nil {
+		return 0, err
+	}
+	buf := getPoolBlockBuf()
+	defer dbBlockPool.Put(buf)
+	for i, ref := range refs {
+		if err := ctx.Err(); err != nil {
+			return exported, err
+		}
+		if _, exists := db.Size(ref.Root); exists {
+			buf.Reset()
+			if _, err := db.Export(ref.Root, buf); err != nil {
+				return exported, fmt.Errorf("failed to read block %s: %v", ref.Root, err)
+			}
+			if err := aw.WriteBlock(ref.Slot, buf.Bytes()); err != nil {
+				return exported, fmt.Errorf("failed to write block %s: %v", ref.Root, err)
+			}
+			exported += 1
+		}
+		if progress != nil {
+			progress(i+1, len(refs))
+		}
+	}
+	return exported, aw.Close()
+}
+
+// ImportArchive imports all blocks of the archive into the DB, in slot order.
+// Blocks that are already in the DB are skipped. Progress is called after every block.
+func ImportArchive(ctx context.Context, db DB, r io.ReadSeeker, progress func(done int, total int)) (imported int, existing int, err error) {
+	index, err := ReadArchiveIndex(r)
+	if err != nil {
+		return 0, 0, fmt.Errorf("failed to read archive index: %v", err)
+	}
+	for i, entry := range index {
+		if err := ctx.Err(); err != nil {
+			return imported, existing, err
+		}
+		data, err := ReadArchiveBlock(r, entry)
+		if err != nil {
+			return imported, existing, fmt.Errorf("failed to read block %d (slot %d) of archive: %v", i, entry.Slot, err)
+		}
+		exists, err := db.Import(bytes.NewReader(data))
+		if err != nil {
+			return imported, existing, fmt.Errorf("failed to import block %d (slot %d) of archive: %v", i, entry.Slot, err)
+		}
+		if exists {
+			existing += 1
+		} else {
+			imported += 1
+		}
+		if progress != nil {
+			progress(i+1, len(index))
+		}
+	}
+	return imported, existing, nil
+}
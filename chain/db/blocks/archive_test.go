@@ -0,0 +1,188 @@
+package blocks
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"github.com/protolambda/zrnt/eth2/beacon"
+	"testing"
+)
+
+// testArchive stores a small tree of blocks, with two blocks at slot 2, and exports them out of slot order.
+func testArchive(t *testing.T) (blocks []*BlockWithRoot, archive []byte) {
+	db, _ := newTestKVDB(t)
+	spec := db.Spec()
+	a := WithRoot(spec, testBlock(1, beacon.Root{}, 1))
+	b := WithRoot(spec, testBlock(2, a.Root, 2))
+	c := WithRoot(spec, testBlock(2, a.Root, 3))
+	d := WithRoot(spec, testBlock(4, b.Root, 4))
+	blocks = []*BlockWithRoot{d, b, a, c}
+	refs := make([]BlockRef, 0, len(blocks)+1)
+	for _, block := range blocks {
+		if _, err := db.Store(context.Background(), block); err != nil {
+			t.Fatal(err)
+		}
+		refs = append(refs, BlockRef{Slot: block.Block.Message.Slot, Root: block.Root})
+	}
+	// Blocks that are not in the DB are skipped
+	refs = append(refs, BlockRef{Slot: 3, Root: beacon.Root{0xff}})
+	var buf bytes.Buffer
+	exported, err := ExportArchive(context.Background(), db, refs, &buf, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if exported != len(blocks) {
+		t.Fatalf("expected %d exported blocks, got %d", len(blocks), exported)
+	}
+	return blocks, buf.Bytes()
+}
+
+func TestArchiveRoundTrip(t *testing.T) {
+	blocks, archive := testArchive(t)
+
+	index, err := ReadArchiveIndex(bytes.NewReader(archive))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(index) != len(blocks) {
+		t.Fatalf("expected %d index entries, got %d", len(blocks), len(index))
+	}
+	for i := 1; i < len(index); i++ {
+		if index[i-1].Slot > index[i].Slot {
+			t.Fatalf("index is not ordered by slot: %v", index)
+		}
+	}
+
+	db, _ := newTestKVDB(t)
+	var progress []int
+	imported, existing, err := ImportArchive(context.Background(), db, bytes.NewReader(archive), func(done int, total int) {
+		if total != len(blocks) {
+			t.Fatalf("expected progress total %d, got %d", len(blocks), total)
+		}
+		progress = append(progress, done)
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if imported != len(blocks) || existing != 0 {
+		t.Fatalf("expected %d imported and 0 existing blocks, got %d and %d", len(blocks), imported, existing)
+	}
+	if len(progress) != len(blocks) || progress[len(progress)-1] != len(blocks) {
+		t.Fatalf("unexpected progress: %v", progress)
+	}
+	for _, block := range blocks {
+		var dest beacon.SignedBeaconBlock
+		exists, err := db.Get(block.Root, &dest)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !exists {
+			t.Fatalf("block %s was not imported", block.Root)
+		}
+		if root := WithRoot(db.Spec(), &dest).Root; root != block.Root {
+			t.Fatalf("imported block %s has root %s", block.Root, root)
+		}
+	}
+	if refs, err := db.Range(2, 3); err != nil || len(refs) != 2 {
+		t.Fatalf("expected both blocks of slot 2 in the slot index, got %v (err: %v)", refs, err)
+	}
+
+	// Importing again only finds existing blocks
+	imported, existing, err = ImportArchive(context.Background(), db, bytes.NewReader(archive), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if imported != 0 || existing != len(blocks) {
+		t.Fatalf("expected 0 imported and %d existing blocks, got %d and %d", len(blocks), imported, existing)
+	}
+}
+
+func TestArchiveEmpty(t *testing.T) {
+	db, _ := newTestKVDB(t)
+	var buf bytes.Buffer
+	if _, err := ExportArchive(context.Background(), db, nil, &buf, nil); err != nil {
+		t.Fatal(err)
+	}
+	index, err := ReadArchiveIndex(bytes.NewReader(buf.Bytes()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(index) != 0 {
+		t.Fatalf("expected empty index, got %v", index)
+	}
+}
+
+func TestArchiveCorrupt(t *testing.T) {
+	blocks, archive := testArchive(t)
+	indexSize := len(blocks)*16 + 8
+	indexStart := len(archive) - indexSize - archiveHeaderSize
+
+	// setOffset changes the offset of the first index entry, relative to the start of the archive.
+	setOffset := func(data []byte, offset int) {
+		binary.LittleEndian.PutUint64(data[indexStart+archiveHeaderSize+8:], uint64(offset-indexStart))
+	}
+	cases := []struct {
+		name    string
+		corrupt func(data []byte) []byte
+	}{
+		{"offset in index", func(data []byte) []byte {
+			setOffset(data, indexStart)
+			return data
+		}},
+		{"offset past end", func(data []byte) []byte {
+			setOffset(data, len(data)+100)
+			return data
+		}},
+		{"offset in version record", func(data []byte) []byte {
+			setOffset(data, 0)
+			return data
+		}},
+		{"offset before archive", func(data []byte) []byte {
+			setOffset(data, -archiveHeaderSize)
+			return data
+		}},
+		{"offset in block record", func(data []byte) []byte {
+			// the data of the first block record, which starts with the snappy stream identifier
+			setOffset(data, 2*archiveHeaderSize)
+			return data
+		}},
+		{"count too large", func(data []byte) []byte {
+			binary.LittleEndian.PutUint64(data[len(data)-8:], uint64(len(blocks)+1))
+			return data
+		}},
+		{"count overflow", func(data []byte) []byte {
+			binary.LittleEndian.PutUint64(data[len(data)-8:], ^uint64(0))
+			return data
+		}},
+		{"no index type", func(data []byte) []byte {
+			binary.LittleEndian.PutUint16(data[indexStart:], archiveBlockType)
+			return data
+		}},
+		{"index length mismatch", func(data []byte) []byte {
+			binary.LittleEndian.PutUint32(data[indexStart+2:], uint32(indexSize-16))
+			return data
+		}},
+		{"truncated", func(data []byte) []byte {
+			return data[:len(data)-1]
+		}},
+		{"no index", func(data []byte) []byte {
+			return data[:indexStart]
+		}},
+		{"too small", func(data []byte) []byte {
+			return data[:archiveHeaderSize]
+		}},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			data := c.corrupt(append([]byte(nil), archive...))
+			db, _ := newTestKVDB(t)
+			imported, _, err := ImportArchive(context.Background(), db, bytes.NewReader(data), nil)
+			if err == nil {
+				t.Fatal("expected corrupt archive to fail to import")
+			}
+			if imported != 0 {
+				t.Fatalf("expected no imported blocks before the error, got %d", imported)
+			}
+		})
+	}
+}
@@ -34,11 +34,13 @@ type DB interface {
 	// The block is stored in serialized form, so the original instance may be mutated after storing it.
 	// This is an efficient convenience method for using Import.
 	// Returns exists=true if the block exists (previously), false otherwise. If error, it may not be accurate.
-	// Returns slashable=true if exists=true, but the signatures are different. The existing block is kept.
+	// Returns an error if exists=true, but the signatures are different. The existing block is kept.
+	// Blocks with the same root have the same message, so this is not slashable:
+	// conflicting blocks of a proposer have different roots, and are found by the slashing detector.
 	Store(ctx context.Context, block *BlockWithRoot) (exists bool, err error)
 	// Import inserts a SignedBeaconBlock, read directly from the reader stream.
 	// Returns exists=true if the block exists (previously), false otherwise. If error, it may not be accurate.
-	// Returns slashable=true if exists=true, but the signatures are different. The existing block is kept.
+	// Returns an error if exists=true, but the signatures are different. The existing block is kept.
 	Import(r io.Reader) (exists bool, err error)
 	// Get, an efficient convenience method for getting a block through Export. The block is safe to modify.
 	// The data at the pointer is mutated to the new block.
@@ -80,3 +82,29 @@ func getPoolBlockBuf() *bytes.Buffer {
 	buf.Reset()
 	return buf
 }
+
+// encodedSignature reads the signature of a SSZ encoded SignedBeaconBlock, right after the offset of the message.
+func encodedSignature(data []byte) (sig beacon.BLSSignature) {
+	if len(data) >= 4+len(sig) {
+		copy(sig[:], data[4:4+len(sig)])
+	}
+	return
+}
+
+// BlockRef identifies a block by slot and root.
+type BlockRef struct {
+	Slot beacon.Slot `json:"slot"`
+	Root beacon.Root `json:"root"`
+}
+
+// IndexedDB is a DB that indexes blocks by slot, parent root and proposer index,
+// to look up blocks without reading all of them.
+type IndexedDB interface {
+	DB
+	// Range lists the blocks with a slot in the range [start, end), ordered by slot.
+	Range(start beacon.Slot, end beacon.Slot) ([]BlockRef, error)
+	// Children lists the roots of the blocks with the given parent root.
+	Children(parent beacon.Root) ([]beacon.Root, error)
+	// ByProposer lists the blocks of the proposer, ordered by slot.
+	ByProposer(proposer beacon.ValidatorIndex) ([]BlockRef, error)
+}
@@ -0,0 +1,43 @@
+package blocks
+
+import (
+	"context"
+	"github.com/protolambda/zrnt/eth2/beacon"
+	"github.com/protolambda/zrnt/eth2/configs"
+	"testing"
+)
+
+func TestStoreSignatureConflict(t *testing.T) {
+	kv, _ := newTestKVDB(t)
+	dbs := map[string]DB{
+		"kv":   kv,
+		"mem":  &MemDB{spec: configs.Minimal},
+		"file": &FileDB{BasePath: t.TempDir(), spec: configs.Minimal},
+	}
+	for name, db := range dbs {
+		t.Run(name, func(t *testing.T) {
+			block := testBlock(3, beacon.Root{1}, 2)
+			block.Signature = beacon.BLSSignature{0xaa}
+			withRoot := WithRoot(configs.Minimal, block)
+			if exists, err := db.Store(context.Background(), withRoot); exists || err != nil {
+				t.Fatalf("expected new block to be stored, exists: %v, err: %v", exists, err)
+			}
+			if exists, err := db.Store(context.Background(), withRoot); !exists || err != nil {
+				t.Fatalf("expected the same block to exist, exists: %v, err: %v", exists, err)
+			}
+			// Same message, so the same root, but another signature
+			other := *block
+			other.Signature = beacon.BLSSignature{0xbb}
+			if exists, err := db.Store(context.Background(), &BlockWithRoot{Root: withRoot.Root, Block: &other}); !exists || err == nil {
+				t.Fatalf("expected signature conflict, exists: %v, err: %v", exists, err)
+			}
+			var dest beacon.SignedBeaconBlock
+			if exists, err := db.Get(withRoot.Root, &dest); !exists || err != nil {
+				t.Fatalf("failed to get block, exists: %v, err: %v", exists, err)
+			}
+			if dest.Signature != block.Signature {
+				t.Fatalf("expected existing block to be kept, got signature %s", dest.Signature)
+			}
+		})
+	}
+}
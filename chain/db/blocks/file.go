@@ -29,6 +29,14 @@ func (db *FileDB) Store(ctx context.Context, block *BlockWithRoot) (exists bool,
 	defer f.Close()
 	if err != nil {
 		if os.IsExist(err) {
+			existing, err := ioutil.ReadFile(outPath)
+			if err != nil {
+				return true, err
+			}
+			if existingSig := encodedSignature(existing); existingSig != block.Block.Signature {
+				return true, fmt.Errorf("block %s already exists, but its signature %s does not match new signature %s",
+					block.Root, existingSig, block.Block.Signature)
+			}
 			return true, nil
 		}
 		return false, err
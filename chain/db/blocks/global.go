@@ -12,6 +12,8 @@ type DBs interface {
 	Find(id DBID) (db DB, ok bool)
 	// Create a new database. An empty path creates a memory DB.
 	Create(id DBID, path string, spec *beacon.Spec) (db DB, err error)
+	// Put adds an opened database, such as a KVDB.
+	Put(id DBID, db DB) error
 	Remove(id DBID) (existed bool)
 	List() []DBID
 }
@@ -43,6 +45,14 @@ func (dbm *DBMap) Create(id DBID, path string, spec *beacon.Spec) (db DB, err er
 	return c, nil
 }
 
+func (dbm *DBMap) Put(id DBID, db DB) error {
+	_, alreadyExisted := dbm.dbs.LoadOrStore(id, db)
+	if alreadyExisted {
+		return errors.New("db already existed")
+	}
+	return nil
+}
+
 func (dbm *DBMap) Remove(id DBID) (existed bool) {
 	_, existed = dbm.dbs.Load(id)
 	if existed {
@@ -0,0 +1,57 @@
+package blocks
+
+import (
+	"fmt"
+	"github.com/protolambda/zrnt/eth2/beacon"
+	"sort"
+)
+
+// Range lists the blocks with a slot in the range [start, end), ordered by slot.
+// Uses the slot index of the DB if it has one, and reads every block otherwise.
+func Range(db DB, start beacon.Slot, end beacon.Slot) ([]BlockRef, error) {
+	if idb, ok := db.(IndexedDB); ok {
+		return idb.Range(start, end)
+	}
+	var out []BlockRef
+	err := scan(db, func(root beacon.Root, block *beacon.SignedBeaconBlock) {
+		if slot := block.Message.Slot; slot >= start && slot < end {
+			out = append(out, BlockRef{Slot: slot, Root: root})
+		}
+	})
+	sort.Slice(out, func(i, j int) bool {
+		if out[i].Slot == out[j].Slot {
+			return string(out[i].Root[:]) < string(out[j].Root[:])
+		}
+		return out[i].Slot < out[j].Slot
+	})
+	return out, err
+}
+
+// Children lists the roots of the blocks with the given parent root.
+// Uses the parent index of the DB if it has one, and reads every block otherwise.
+func Children(db DB, parent beacon.Root) ([]beacon.Root, error) {
+	if idb, ok := db.(IndexedDB); ok {
+		return idb.Children(parent)
+	}
+	var out []beacon.Root
+	err := scan(db, func(root beacon.Root, block *beacon.SignedBeaconBlock) {
+		if block.Message.ParentRoot == parent {
+			out = append(out, root)
+		}
+	})
+	return out, err
+}
+
+func scan(db DB, fn func(root beacon.Root, block *beacon.SignedBeaconBlock)) error {
+	for _, root := range db.List() {
+		var block beacon.SignedBeaconBlock
+		exists, err := db.Get(root, &block)
+		if err != nil {
+			return fmt.Errorf("failed to get block %s: %v", root, err)
+		}
+		if exists {
+			fn(root, &block)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,440 @@
+package blocks
+
+import (
+	"bytes"
+	"context"
+	"encoding/hex"
+	"fmt"
+	badgerdb "github.com/dgraph-io/badger"
+	ds "github.com/ipfs/go-datastore"
+	"github.com/ipfs/go-datastore/query"
+	badger "github.com/ipfs/go-ds-badger"
+	leveldb "github.com/ipfs/go-ds-leveldb"
+	"github.com/protolambda/zrnt/eth2/beacon"
+	"github.com/protolambda/ztyp/codec"
+	"io"
+	"io/ioutil"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+)
+
+const (
+	kvLastWriteKey    = "/blocks/meta/last"
+	kvDataPrefix      = "/blocks/data"
+	kvSlotPrefix      = "/blocks/slot"
+	kvParentPrefix    = "/blocks/parent"
+	kvProposersPrefix = "/blocks/proposer"
+)
+
+func kvDataKey(root beacon.Root) ds.Key {
+	return ds.NewKey(fmt.Sprintf("%s/%x", kvDataPrefix, root[:]))
+}
+
+func kvSlotKey(slot beacon.Slot, root beacon.Root) ds.Key {
+	return ds.NewKey(fmt.Sprintf("%s/%016x/%x", kvSlotPrefix, uint64(slot), root[:]))
+}
+
+func kvParentKey(parent beacon.Root, root beacon.Root) ds.Key {
+	return ds.NewKey(fmt.Sprintf("%s/%x/%x", kvParentPrefix, parent[:], root[:]))
+}
+
+func kvProposerKey(proposer beacon.ValidatorIndex, slot beacon.Slot, root beacon.Root) ds.Key {
+	return ds.NewKey(fmt.Sprintf("%s/%016x/%016x/%x", kvProposersPrefix, uint64(proposer), uint64(slot), root[:]))
+}
+
+// parseKeyRoot parses the root at the end of a key
+func parseKeyRoot(key string) (root beacon.Root, err error) {
+	i := strings.LastIndexByte(key, '/')
+	v, err := hex.DecodeString(key[i+1:])
+	if err != nil {
+		return root, err
+	}
+	if len(v) != len(root) {
+		return root, fmt.Errorf("bad root length: %d", len(v))
+	}
+	copy(root[:], v)
+	return root, nil
+}
+
+// parseKeyRef parses the slot and root at the end of a key
+func parseKeyRef(key string) (ref BlockRef, err error) {
+	if ref.Root, err = parseKeyRoot(key); err != nil {
+		return
+	}
+	key = key[:strings.LastIndexByte(key, '/')]
+	v, err := strconv.ParseUint(key[strings.LastIndexByte(key, '/')+1:], 16, 64)
+	ref.Slot = beacon.Slot(v)
+	return
+}
+
+// KVDB persists blocks in a datastore (leveldb or badger), with indices on slot, parent root and proposer index.
+type KVDB struct {
+	store ds.Batching
+	spec  *beacon.Spec
+	path  string
+	// Locked during writes, to keep the block data and indices consistent
+	writeLock sync.Mutex
+	stats     DBStats
+}
+
+// NewKVDB opens a blocks DB in the datastore. Blocks that were previously persisted in the datastore are kept.
+func NewKVDB(store ds.Batching, path string, spec *beacon.Spec) (*KVDB, error) {
+	db := &KVDB{store: store, spec: spec, path: path}
+	res, err := store.Query(query.Query{Prefix: kvDataPrefix, KeysOnly: true})
+	if err != nil {
+		return nil, err
+	}
+	entries, err := res.Rest()
+	if err != nil {
+		return nil, err
+	}
+	db.stats.Count = int64(len(entries))
+	last, err := store.Get(ds.NewKey(kvLastWriteKey))
+	if err == nil {
+		copy(db.stats.LastWrite[:], last)
+	} else if err != ds.ErrNotFound {
+		return nil, err
+	}
+	return db, nil
+}
+
+func (db *KVDB) Store(ctx context.Context, block *BlockWithRoot) (exists bool, err error) {
+	buf := getPoolBlockBuf()
+	defer dbBlockPool.Put(buf)
+	if err := block.Block.Serialize(db.spec, codec.NewEncodingWriter(buf)); err != nil {
+		return false, fmt.Errorf("failed to store block %s: %v", block.Root, err)
+	}
+	db.writeLock.Lock()
+	defer db.writeLock.Unlock()
+	existing, err := db.store.Get(kvDataKey(block.Root))
+	if err == nil {
+		if existingSig := encodedSignature(existing); existingSig != block.Block.Signature {
+			return true, fmt.Errorf("block %s already exists, but its signature %s does not match new signature %s",
+				block.Root, existingSig, block.Block.Signature)
+		}
+		return true, nil
+	} else if err != ds.ErrNotFound {
+		return false, err
+	}
+	b, err := db.store.Batch()
+	if err != nil {
+		return false, err
+	}
+	msg := &block.Block.Message
+	// Copy the data, the buffer goes back to the pool, and not every datastore copies the value it is given.
+	data := append([]byte(nil), buf.Bytes()...)
+	if err := b.Put(kvDataKey(block.Root), data); err != nil {
+		return false, err
+	}
+	if err := b.Put(kvSlotKey(msg.Slot, block.Root), nil); err != nil {
+		return false, err
+	}
+	if err := b.Put(kvParentKey(msg.ParentRoot, block.Root), nil); err != nil {
+		return false, err
+	}
+	if err := b.Put(kvProposerKey(msg.ProposerIndex, msg.Slot, block.Root), nil); err != nil {
+		return false, err
+	}
+	if err := b.Put(ds.NewKey(kvLastWriteKey), block.Root[:]); err != nil {
+		return false, err
+	}
+	if err := b.Commit(); err != nil {
+		return false, fmt.Errorf("failed to store block %s: %v", block.Root, err)
+	}
+	atomic.AddInt64(&db.stats.Count, 1)
+	db.stats.LastWrite = block.Root
+	return false, nil
+}
+
+func (db *KVDB) Import(r io.Reader) (exists bool, err error) {
+	buf := getPoolBlockBuf()
+	defer dbBlockPool.Put(buf)
+	if _, err := buf.ReadFrom(r); err != nil {
+		return false, err
+	}
+	var dest beacon.SignedBeaconBlock
+	err = dest.Deserialize(db.spec, codec.NewDecodingReader(buf, uint64(len(buf.Bytes()))))
+	if err != nil {
+		return false, fmt.Errorf("failed to decode block, need valid block to get block root. Err: %v", err)
+	}
+	// Take the hash-tree-root of the BeaconBlock, ignore the signature.
+	return db.Store(context.Background(), WithRoot(db.spec, &dest))
+}
+
+func (db *KVDB) Get(root beacon.Root, dest *beacon.SignedBeaconBlock) (exists bool, err error) {
+	v, err := db.store.Get(kvDataKey(root))
+	if err == ds.ErrNotFound {
+		return false, nil
+	} else if err != nil {
+		return false, err
+	}
+	err = dest.Deserialize(db.spec, codec.NewDecodingReader(bytes.NewReader(v), uint64(len(v))))
+	return true, err
+}
+
+func (db *KVDB) Size(root beacon.Root) (size uint64, exists bool) {
+	n, err := db.store.GetSize(kvDataKey(root))
+	if err != nil {
+		return 0, false
+	}
+	return uint64(n), true
+}
+
+func (db *KVDB) Export(root beacon.Root, w io.Writer) (exists bool, err error) {
+	v, err := db.store.Get(kvDataKey(root))
+	if err == ds.ErrNotFound {
+		return false, nil
+	} else if err != nil {
+		return false, err
+	}
+	_, err = w.Write(v)
+	return true, err
+}
+
+func (db *KVDB) Stream(root beacon.Root) (r io.ReadCloser, size uint64, exists bool, err error) {
+	v, err := db.store.Get(kvDataKey(root))
+	if err == ds.ErrNotFound {
+		return nil, 0, false, nil
+	} else if err != nil {
+		return nil, 0, false, err
+	}
+	return ioutil.NopCloser(bytes.NewReader(v)), uint64(len(v)), true, nil
+}
+
+func (db *KVDB) Remove(root beacon.Root) (exists bool, err error) {
+	db.writeLock.Lock()
+	defer db.writeLock.Unlock()
+	var block beacon.SignedBeaconBlock
+	exists, err = db.Get(root, &block)
+	if !exists {
+		return false, err
+	}
+	var keys []ds.Key
+	if err == nil {
+		msg := &block.Message
+		keys = []ds.Key{
+			kvSlotKey(msg.Slot, root),
+			kvParentKey(msg.ParentRoot, root),
+			kvProposerKey(msg.ProposerIndex, msg.Slot, root),
+		}
+	} else {
+		// The block data is corrupt, the index keys cannot be derived from it. Find them instead.
+		if keys, err = db.indexKeys(root); err != nil {
+			return true, fmt.Errorf("failed to find index keys of block %s: %v", root, err)
+		}
+	}
+	b, err := db.store.Batch()
+	if err != nil {
+		return true, err
+	}
+	for _, key := range append(keys, kvDataKey(root)) {
+		if err := b.Delete(key); err != nil {
+			return true, err
+		}
+	}
+	if err := b.Commit(); err != nil {
+		return true, fmt.Errorf("failed to remove block %s: %v", root, err)
+	}
+	atomic.AddInt64(&db.stats.Count, -1)
+	return true, nil
+}
+
+// rootSuffixFilter matches keys that end with the root
+type rootSuffixFilter struct {
+	suffix string
+}
+
+func (f rootSuffixFilter) Filter(e query.Entry) bool {
+	return strings.HasSuffix(e.Key, f.suffix)
+}
+
+// indexKeys finds the slot, parent and proposer index keys of the block, by scanning all index keys.
+func (db *KVDB) indexKeys(root beacon.Root) ([]ds.Key, error) {
+	filter := rootSuffixFilter{suffix: fmt.Sprintf("/%x", root[:])}
+	var keys []ds.Key
+	for _, prefix := range []string{kvSlotPrefix, kvParentPrefix, kvProposersPrefix} {
+		res, err := db.store.Query(query.Query{Prefix: prefix, Filters: []query.Filter{filter}, KeysOnly: true})
+		if err != nil {
+			return nil, err
+		}
+		entries, err := res.Rest()
+		if err != nil {
+			return nil, err
+		}
+		for _, e := range entries {
+			keys = append(keys, ds.NewKey(e.Key))
+		}
+	}
+	return keys, nil
+}
+
+func (db *KVDB) Stats() DBStats {
+	// return a copy (struct is small and has no pointers)
+	return db.stats
+}
+
+func (db *KVDB) List() (out []beacon.Root) {
+	res, err := db.store.Query(query.Query{Prefix: kvDataPrefix, KeysOnly: true})
+	if err != nil {
+		return nil
+	}
+	entries, err := res.Rest()
+	if err != nil {
+		return nil
+	}
+	out = make([]beacon.Root, 0, len(entries))
+	for _, e := range entries {
+		root, err := parseKeyRoot(e.Key)
+		if err != nil {
+			continue
+		}
+		out = append(out, root)
+	}
+	return out
+}
+
+// queryRefs lists the slot and root of every key with the prefix, ordered by key.
+func (db *KVDB) queryRefs(prefix string, filters ...query.Filter) ([]BlockRef, error) {
+	res, err := db.store.Query(query.Query{
+		Prefix:   prefix,
+		Filters:  filters,
+		Orders:   []query.Order{query.OrderByKey{}},
+		KeysOnly: true,
+	})
+	if err != nil {
+		return nil, err
+	}
+	entries, err := res.Rest()
+	if err != nil {
+		return nil, err
+	}
+	out := make([]BlockRef, 0, len(entries))
+	for _, e := range entries {
+		ref, err := parseKeyRef(e.Key)
+		if err != nil {
+			return nil, fmt.Errorf("bad key %s: %v", e.Key, err)
+		}
+		out = append(out, ref)
+	}
+	return out, nil
+}
+
+func (db *KVDB) Range(start beacon.Slot, end beacon.Slot) ([]BlockRef, error) {
+	if end <= start {
+		return nil, nil
+	}
+	// Slots are encoded as fixed length hex, keys are ordered by slot.
+	startKey := fmt.Sprintf("%s/%016x", kvSlotPrefix, uint64(start))
+	endKey := fmt.Sprintf("%s/%016x", kvSlotPrefix, uint64(end))
+	var out []BlockRef
+	err := db.keyRange(startKey, endKey, func(key string) error {
+		ref, err := parseKeyRef(key)
+		if err != nil {
+			return fmt.Errorf("bad key %s: %v", key, err)
+		}
+		out = append(out, ref)
+		return nil
+	})
+	return out, err
+}
+
+// keyRange calls fn for every key from the start key (inclusive) to the end key (exclusive), in key order.
+// Leveldb and badger iterate from the start key directly, other datastores iterate the slot index up to the end key.
+func (db *KVDB) keyRange(startKey string, endKey string, fn func(key string) error) error {
+	switch store := db.store.(type) {
+	case *leveldb.Datastore:
+		iter := store.DB.NewIterator(nil, nil)
+		defer iter.Release()
+		for ok := iter.Seek([]byte(startKey)); ok; ok = iter.Next() {
+			key := string(iter.Key())
+			if key >= endKey {
+				break
+			}
+			if err := fn(key); err != nil {
+				return err
+			}
+		}
+		return iter.Error()
+	case *badger.Datastore:
+		return store.DB.View(func(txn *badgerdb.Txn) error {
+			opts := badgerdb.DefaultIteratorOptions
+			opts.PrefetchValues = false
+			iter := txn.NewIterator(opts)
+			defer iter.Close()
+			for iter.Seek([]byte(startKey)); iter.Valid(); iter.Next() {
+				key := string(iter.Item().Key())
+				if key >= endKey {
+					break
+				}
+				if err := fn(key); err != nil {
+					return err
+				}
+			}
+			return nil
+		})
+	default:
+		res, err := db.store.Query(query.Query{
+			Prefix:   kvSlotPrefix,
+			Orders:   []query.Order{query.OrderByKey{}},
+			KeysOnly: true,
+		})
+		if err != nil {
+			return err
+		}
+		defer res.Close()
+		for r := range res.Next() {
+			if r.Error != nil {
+				return r.Error
+			}
+			if r.Key < startKey {
+				continue
+			}
+			if r.Key >= endKey {
+				break
+			}
+			if err := fn(r.Key); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+}
+
+func (db *KVDB) Children(parent beacon.Root) ([]beacon.Root, error) {
+	res, err := db.store.Query(query.Query{Prefix: fmt.Sprintf("%s/%x", kvParentPrefix, parent[:]), KeysOnly: true})
+	if err != nil {
+		return nil, err
+	}
+	entries, err := res.Rest()
+	if err != nil {
+		return nil, err
+	}
+	out := make([]beacon.Root, 0, len(entries))
+	for _, e := range entries {
+		root, err := parseKeyRoot(e.Key)
+		if err != nil {
+			return nil, fmt.Errorf("bad key %s: %v", e.Key, err)
+		}
+		out = append(out, root)
+	}
+	return out, nil
+}
+
+func (db *KVDB) ByProposer(proposer beacon.ValidatorIndex) ([]BlockRef, error) {
+	return db.queryRefs(fmt.Sprintf("%s/%016x", kvProposersPrefix, uint64(proposer)))
+}
+
+func (db *KVDB) Path() string {
+	return db.path
+}
+
+func (db *KVDB) Spec() *beacon.Spec {
+	return db.spec
+}
+
+// Close closes the underlying datastore.
+func (db *KVDB) Close() error {
+	return db.store.Close()
+}
@@ -0,0 +1,155 @@
+package blocks
+
+import (
+	"context"
+	ds "github.com/ipfs/go-datastore"
+	dssync "github.com/ipfs/go-datastore/sync"
+	badger "github.com/ipfs/go-ds-badger"
+	leveldb "github.com/ipfs/go-ds-leveldb"
+	"github.com/protolambda/zrnt/eth2/beacon"
+	"github.com/protolambda/zrnt/eth2/configs"
+	"testing"
+)
+
+func testBlock(slot beacon.Slot, parent beacon.Root, proposer beacon.ValidatorIndex) *beacon.SignedBeaconBlock {
+	return &beacon.SignedBeaconBlock{
+		Message: beacon.BeaconBlock{Slot: slot, ParentRoot: parent, ProposerIndex: proposer},
+	}
+}
+
+func newTestKVDB(t *testing.T) (*KVDB, ds.Batching) {
+	store := dssync.MutexWrap(ds.NewMapDatastore())
+	db, err := NewKVDB(store, "", configs.Minimal)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return db, store
+}
+
+func TestKVDBRemoveCorrupt(t *testing.T) {
+	db, store := newTestKVDB(t)
+	spec := db.Spec()
+	parent := WithRoot(spec, testBlock(1, beacon.Root{}, 3))
+	child := WithRoot(spec, testBlock(2, parent.Root, 4))
+	for _, b := range []*BlockWithRoot{parent, child} {
+		if _, err := db.Store(context.Background(), b); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := store.Put(kvDataKey(child.Root), []byte("corrupt")); err != nil {
+		t.Fatal(err)
+	}
+	var dest beacon.SignedBeaconBlock
+	if exists, err := db.Get(child.Root, &dest); !exists || err == nil {
+		t.Fatalf("expected corrupt block to exist and fail to decode, exists: %v, err: %v", exists, err)
+	}
+	if exists, err := db.Remove(child.Root); !exists || err != nil {
+		t.Fatalf("failed to remove corrupt block, exists: %v, err: %v", exists, err)
+	}
+	if exists, err := db.Get(child.Root, &dest); exists || err != nil {
+		t.Fatalf("expected block to be removed, exists: %v, err: %v", exists, err)
+	}
+	if refs, err := db.Range(0, 10); err != nil || len(refs) != 1 || refs[0].Root != parent.Root {
+		t.Fatalf("expected only the parent in the slot index, got %v (err: %v)", refs, err)
+	}
+	if children, err := db.Children(parent.Root); err != nil || len(children) != 0 {
+		t.Fatalf("expected no children in the parent index, got %v (err: %v)", children, err)
+	}
+	if refs, err := db.ByProposer(4); err != nil || len(refs) != 0 {
+		t.Fatalf("expected no blocks in the proposer index, got %v (err: %v)", refs, err)
+	}
+	if refs, err := db.ByProposer(3); err != nil || len(refs) != 1 {
+		t.Fatalf("expected the parent in the proposer index, got %v (err: %v)", refs, err)
+	}
+	if n := db.Stats().Count; n != 1 {
+		t.Fatalf("expected 1 block, got %d", n)
+	}
+}
+
+func TestKVDBRange(t *testing.T) {
+	stores := map[string]func(t *testing.T) ds.Batching{
+		"mem": func(t *testing.T) ds.Batching {
+			return dssync.MutexWrap(ds.NewMapDatastore())
+		},
+		"leveldb": func(t *testing.T) ds.Batching {
+			store, err := leveldb.NewDatastore(t.TempDir(), nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			return store
+		},
+		"badger": func(t *testing.T) ds.Batching {
+			store, err := badger.NewDatastore(t.TempDir(), nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			return store
+		},
+	}
+	for name, open := range stores {
+		t.Run(name, func(t *testing.T) {
+			db, err := NewKVDB(open(t), "", configs.Minimal)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer db.Close()
+			// two blocks at slot 0x10, and others at both sides of the hex digit boundaries
+			slots := []beacon.Slot{0, 1, 0xf, 0x10, 0x10, 0x11, 0xff, 0x100, 1 << 40}
+			for i, slot := range slots {
+				if _, err := db.Store(context.Background(), WithRoot(db.Spec(), testBlock(slot, beacon.Root{}, beacon.ValidatorIndex(i)))); err != nil {
+					t.Fatal(err)
+				}
+			}
+			cases := []struct {
+				start, end beacon.Slot
+				expected   []beacon.Slot
+			}{
+				{0, 1, []beacon.Slot{0}},
+				{1, 0x10, []beacon.Slot{1, 0xf}},
+				{0x10, 0x11, []beacon.Slot{0x10, 0x10}},
+				{0xf, 0x100, []beacon.Slot{0xf, 0x10, 0x10, 0x11, 0xff}},
+				{0x101, 1 << 40, nil},
+				{0x100, 1<<40 + 1, []beacon.Slot{0x100, 1 << 40}},
+				{5, 5, nil},
+				{6, 5, nil},
+			}
+			for _, c := range cases {
+				refs, err := db.Range(c.start, c.end)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if len(refs) != len(c.expected) {
+					t.Fatalf("range [%d, %d): expected %d blocks, got %v", c.start, c.end, len(c.expected), refs)
+				}
+				for i, ref := range refs {
+					if ref.Slot != c.expected[i] {
+						t.Fatalf("range [%d, %d): expected slot %d at %d, got %d", c.start, c.end, c.expected[i], i, ref.Slot)
+					}
+				}
+			}
+		})
+	}
+}
+
+func TestKVDBStoreKeepsData(t *testing.T) {
+	db, _ := newTestKVDB(t)
+	spec := db.Spec()
+	blocks := make([]*BlockWithRoot, 4)
+	for i := range blocks {
+		blocks[i] = WithRoot(spec, testBlock(beacon.Slot(i), beacon.Root{}, beacon.ValidatorIndex(i)))
+		if _, err := db.Store(context.Background(), blocks[i]); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// The map datastore keeps the value it is given, storing other blocks should not change it.
+	for _, b := range blocks {
+		var dest beacon.SignedBeaconBlock
+		if exists, err := db.Get(b.Root, &dest); !exists || err != nil {
+			t.Fatalf("failed to get block %s, exists: %v, err: %v", b.Root, exists, err)
+		}
+		if root := WithRoot(spec, &dest).Root; root != b.Root {
+			t.Fatalf("block %s changed after storing other blocks, got %s with slot %d",
+				b.Root, root, dest.Message.Slot)
+		}
+	}
+}
@@ -13,7 +13,7 @@ import (
 )
 
 type MemDB struct {
-	// beacon.Root -> []byte (serialized SignedBeaconBlock)
+	// beacon.Root -> *bytes.Buffer (serialized SignedBeaconBlock)
 	data        sync.Map
 	removalLock sync.Mutex
 	stats       DBStats
@@ -27,14 +27,13 @@ func (db *MemDB) Store(ctx context.Context, block *BlockWithRoot) (exists bool,
 	if err != nil {
 		return false, fmt.Errorf("failed to store block %s: %v", block.Root, err)
 	}
-	existing, loaded := db.data.LoadOrStore(block.Root, buf.Bytes())
+	existing, loaded := db.data.LoadOrStore(block.Root, buf)
 	if loaded {
-		existingBlock := existing.(*beacon.SignedBeaconBlock)
-		sigDifference := existingBlock.Signature != block.Block.Signature
+		existingSig := encodedSignature(existing.(*bytes.Buffer).Bytes())
 		dbBlockPool.Put(buf) // put it back, we didn't store it
-		if sigDifference {
-			return true, fmt.Errorf("block %s already exists, but its signature %x does not match new signature %s",
-				block.Root, existingBlock.Signature, block.Block.Signature)
+		if existingSig != block.Block.Signature {
+			return true, fmt.Errorf("block %s already exists, but its signature %s does not match new signature %s",
+				block.Root, existingSig, block.Block.Signature)
 		}
 	} else {
 		atomic.AddInt64(&db.stats.Count, 1)
@@ -50,20 +49,20 @@ func (db *MemDB) Import(r io.Reader) (exists bool, err error) {
 		return false, err
 	}
 	var dest beacon.SignedBeaconBlock
-	err = dest.Deserialize(db.spec, codec.NewDecodingReader(buf, uint64(len(buf.Bytes()))))
+	err = dest.Deserialize(db.spec, codec.NewDecodingReader(bytes.NewReader(buf.Bytes()), uint64(len(buf.Bytes()))))
 	if err != nil {
+		dbBlockPool.Put(buf) // put it back, we didn't use it
 		return false, fmt.Errorf("failed to decode block, nee valid block to get block root. Err: %v", err)
 	}
 	// Take the hash-tree-root of the BeaconBlock, ignore the signature.
 	root := dest.Message.HashTreeRoot(db.spec, tree.GetHashFn())
-	existing, loaded := db.data.LoadOrStore(root, buf.Bytes())
+	existing, loaded := db.data.LoadOrStore(root, buf)
 	if loaded {
-		existingBlock := existing.(*beacon.SignedBeaconBlock)
-		sigDifference := existingBlock.Signature != dest.Signature
+		existingSig := encodedSignature(existing.(*bytes.Buffer).Bytes())
 		dbBlockPool.Put(buf) // put it back, we didn't store it
-		if sigDifference {
+		if existingSig != dest.Signature {
 			return true, fmt.Errorf("block %s already exists, but its signature %s does not match new signature %s",
-				root, existingBlock.Signature, dest.Signature)
+				root, existingSig, dest.Signature)
 		}
 	} else {
 		atomic.AddInt64(&db.stats.Count, 1)
@@ -78,7 +77,7 @@ func (db *MemDB) Get(root beacon.Root, dest *beacon.SignedBeaconBlock) (exists b
 		return false, nil
 	}
 	buf := dat.(*bytes.Buffer)
-	err = dest.Deserialize(db.spec, codec.NewDecodingReader(buf, uint64(len(buf.Bytes()))))
+	err = dest.Deserialize(db.spec, codec.NewDecodingReader(bytes.NewReader(buf.Bytes()), uint64(len(buf.Bytes()))))
 	return true, err
 }
 
@@ -97,7 +96,7 @@ func (db *MemDB) Export(root beacon.Root, w io.Writer) (exists bool, err error)
 		return false, nil
 	}
 	buf := dat.(*bytes.Buffer)
-	_, err = buf.WriteTo(w)
+	_, err = w.Write(buf.Bytes())
 	return true, err
 }
 
@@ -115,7 +114,7 @@ func (db *MemDB) Stream(root beacon.Root) (r io.ReadCloser, size uint64, exists
 		return nil, 0, false, nil
 	}
 	buf := dat.(*bytes.Buffer)
-	return noClose{buf}, uint64(buf.Len()), true, nil
+	return noClose{bytes.NewReader(buf.Bytes())}, uint64(buf.Len()), true, nil
 }
 
 func (db *MemDB) Remove(root beacon.Root) (exists bool, err error) {
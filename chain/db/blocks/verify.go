@@ -0,0 +1,117 @@
+package blocks
+
+import (
+	"bytes"
+	"context"
+	"encoding/hex"
+	"fmt"
+	hbls "github.com/herumi/bls-eth-go-binary/bls"
+	"github.com/protolambda/zrnt/eth2/beacon"
+	"github.com/protolambda/ztyp/codec"
+	"io/ioutil"
+	"os"
+	"path"
+)
+
+// Problem is what is wrong with an entry of a DB.
+type Problem string
+
+const (
+	// The entry cannot be read, or does not decode
+	ProblemCorrupt Problem = "corrupt"
+	// The entry decodes, but its root does not match the root it is stored by
+	ProblemMismatch Problem = "mismatch"
+	// The signature is not a valid compressed BLS signature
+	ProblemSignature Problem = "bad_signature"
+	// The parent block is not in the DB
+	ProblemOrphan Problem = "orphan"
+)
+
+type Issue struct {
+	Root    beacon.Root `json:"root"`
+	Problem Problem     `json:"problem"`
+	Detail  string      `json:"detail"`
+}
+
+// Verify re-reads and decodes every block of the DB, and calls fn with each problem it finds:
+// entries that do not decode, decode to a block with a different root, have a malformed signature,
+// or have a parent that is not in the DB. Blocks at slot 0 are not checked for a signature or parent.
+// Returns the number of checked blocks.
+func Verify(ctx context.Context, db DB, fn func(issue Issue)) (checked int, err error) {
+	roots := db.List()
+	known := make(map[beacon.Root]struct{}, len(roots))
+	for _, root := range roots {
+		known[root] = struct{}{}
+	}
+	spec := db.Spec()
+	for _, root := range roots {
+		if err := ctx.Err(); err != nil {
+			return checked, err
+		}
+		checked += 1
+		data, exists, err := readRaw(db, root)
+		if !exists && err == nil {
+			// removed while verifying
+			continue
+		}
+		if err != nil {
+			fn(Issue{Root: root, Problem: ProblemCorrupt, Detail: err.Error()})
+			continue
+		}
+		var block beacon.SignedBeaconBlock
+		if err := block.Deserialize(spec, codec.NewDecodingReader(bytes.NewReader(data), uint64(len(data)))); err != nil {
+			fn(Issue{Root: root, Problem: ProblemCorrupt, Detail: err.Error()})
+			continue
+		}
+		if actual := WithRoot(spec, &block).Root; actual != root {
+			fn(Issue{Root: root, Problem: ProblemMismatch, Detail: fmt.Sprintf("block has root %s", actual)})
+			continue
+		}
+		if block.Message.Slot == 0 {
+			continue
+		}
+		var sig hbls.Sign
+		if err := sig.Deserialize(block.Signature[:]); err != nil {
+			fn(Issue{Root: root, Problem: ProblemSignature, Detail: err.Error()})
+		}
+		if _, ok := known[block.Message.ParentRoot]; !ok {
+			fn(Issue{Root: root, Problem: ProblemOrphan, Detail: fmt.Sprintf("parent %s is missing", block.Message.ParentRoot)})
+		}
+	}
+	return checked, nil
+}
+
+func readRaw(db DB, root beacon.Root) (data []byte, exists bool, err error) {
+	r, size, exists, err := db.Stream(root)
+	if err != nil || !exists {
+		return nil, exists, err
+	}
+	defer r.Close()
+	data, err = ioutil.ReadAll(r)
+	if err != nil {
+		return nil, true, err
+	}
+	if uint64(len(data)) != size {
+		return nil, true, fmt.Errorf("read %d bytes, expected %d", len(data), size)
+	}
+	return data, true, nil
+}
+
+// Quarantine moves the block out of the DB, to a 0x<root>.ssz file in the given directory, as-is.
+func Quarantine(db DB, root beacon.Root, dir string) error {
+	data, exists, err := readRaw(db, root)
+	if err != nil {
+		return fmt.Errorf("failed to read block %s: %v", root, err)
+	}
+	if !exists {
+		return nil
+	}
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return err
+	}
+	if err := ioutil.WriteFile(path.Join(dir, "0x"+hex.EncodeToString(root[:])+".ssz"), data, 0644); err != nil {
+		return fmt.Errorf("failed to write quarantined block %s: %v", root, err)
+	}
+	_, err = db.Remove(root)
+	return err
+}
@@ -0,0 +1,78 @@
+package states
+
+import (
+	"bytes"
+	"context"
+	"encoding/hex"
+	"fmt"
+	"github.com/protolambda/zrnt/eth2/beacon"
+	"github.com/protolambda/ztyp/codec"
+	"github.com/protolambda/ztyp/tree"
+	"io/ioutil"
+	"os"
+	"path"
+)
+
+// Problem is what is wrong with an entry of a DB.
+type Problem string
+
+const (
+	// The entry cannot be read as a state
+	ProblemCorrupt Problem = "corrupt"
+	// The state root does not match the root it is stored by
+	ProblemMismatch Problem = "mismatch"
+)
+
+type Issue struct {
+	Root    beacon.Root `json:"root"`
+	Problem Problem     `json:"problem"`
+	Detail  string      `json:"detail"`
+}
+
+// Verify reads every state of the DB, and calls fn with each state that cannot be read,
+// or has a different root than it is stored by. Returns the number of checked states.
+func Verify(ctx context.Context, db DB, fn func(issue Issue)) (checked int, err error) {
+	for _, root := range db.List() {
+		if err := ctx.Err(); err != nil {
+			return checked, err
+		}
+		checked += 1
+		state, exists, err := db.Get(root)
+		if !exists && err == nil {
+			// removed while verifying
+			continue
+		}
+		if err != nil {
+			fn(Issue{Root: root, Problem: ProblemCorrupt, Detail: err.Error()})
+			continue
+		}
+		if actual := state.HashTreeRoot(tree.GetHashFn()); actual != root {
+			fn(Issue{Root: root, Problem: ProblemMismatch, Detail: fmt.Sprintf("state has root %s", actual)})
+		}
+	}
+	return checked, nil
+}
+
+// Quarantine moves the state out of the DB, to a 0x<root>.ssz file in the given directory.
+// A state that cannot be read cannot be saved either, it is kept in the DB and an error is returned.
+func Quarantine(db DB, root beacon.Root, dir string) error {
+	state, exists, err := db.Get(root)
+	if err != nil {
+		return fmt.Errorf("cannot quarantine unreadable state %s: %v", root, err)
+	}
+	if !exists {
+		return nil
+	}
+	var buf bytes.Buffer
+	if err := state.Serialize(codec.NewEncodingWriter(&buf)); err != nil {
+		return fmt.Errorf("failed to encode state %s: %v", root, err)
+	}
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return err
+	}
+	if err := ioutil.WriteFile(path.Join(dir, "0x"+hex.EncodeToString(root[:])+".ssz"), buf.Bytes(), 0644); err != nil {
+		return fmt.Errorf("failed to write quarantined state %s: %v", root, err)
+	}
+	_, err = db.Remove(root)
+	return err
+}
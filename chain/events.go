@@ -0,0 +1,228 @@
+package chain
+
+import (
+	"github.com/protolambda/rumor/chain/slashing"
+	"github.com/protolambda/zrnt/eth2/beacon"
+	"github.com/protolambda/zrnt/eth2/forkchoice"
+	"github.com/protolambda/ztyp/tree"
+	"sync"
+)
+
+type ChainEventKind string
+
+const (
+	// The head changed to a descendant of the previous head
+	HeadEvent ChainEventKind = "head"
+	// The head changed to a block that does not descend from the previous head
+	ReorgEvent ChainEventKind = "reorg"
+	// The justified checkpoint of the head state changed
+	JustifiedEvent ChainEventKind = "justified"
+	// The finalized checkpoint of the head state changed
+	FinalizedEvent ChainEventKind = "finalized"
+	// A block was pruned from the hot chain
+	PrunedEvent ChainEventKind = "pruned"
+	// A block of the chain conflicts with an earlier block of the same proposer and slot
+	ProposerSlashingEvent ChainEventKind = "proposer_slashing"
+	// An attestation of the chain is a double or surround vote with an earlier attestation
+	AttesterSlashingEvent ChainEventKind = "attester_slashing"
+)
+
+// ChainEventKinds lists all kinds of chain events
+var ChainEventKinds = []ChainEventKind{
+	HeadEvent, ReorgEvent, JustifiedEvent, FinalizedEvent, PrunedEvent, ProposerSlashingEvent, AttesterSlashingEvent,
+}
+
+// ChainEvent describes a change of the hot chain. Which fields are set depends on the kind.
+type ChainEvent struct {
+	Kind ChainEventKind
+	// New head, checkpoint or pruned block
+	Slot Slot
+	Root Root
+	// Previous head, for head and reorg events
+	OldSlot Slot
+	OldRoot Root
+	// Last block shared by the old and new head, for reorg events. Zeroed if unknown.
+	AncestorSlot Slot
+	AncestorRoot Root
+	// Number of slots between the old head and the common ancestor, for reorg events
+	Depth uint64
+	// Checkpoint epoch, for justified and finalized events
+	Epoch Epoch
+	// If the pruned block was canonical, for pruned events
+	Canonical bool
+	// The detected slashing, for slashing events
+	ProposerSlashing *beacon.ProposerSlashing
+	AttesterSlashing *beacon.AttesterSlashing
+}
+
+// Fields summarizes the event for logging.
+func (ev *ChainEvent) Fields() map[string]interface{} {
+	switch ev.Kind {
+	case HeadEvent:
+		return map[string]interface{}{
+			"slot": ev.Slot, "root": ev.Root, "old_slot": ev.OldSlot, "old_root": ev.OldRoot,
+		}
+	case ReorgEvent:
+		return map[string]interface{}{
+			"slot": ev.Slot, "root": ev.Root, "old_slot": ev.OldSlot, "old_root": ev.OldRoot,
+			"ancestor_slot": ev.AncestorSlot, "ancestor_root": ev.AncestorRoot, "depth": ev.Depth,
+		}
+	case JustifiedEvent, FinalizedEvent:
+		return map[string]interface{}{"epoch": ev.Epoch, "root": ev.Root}
+	case PrunedEvent:
+		return map[string]interface{}{"slot": ev.Slot, "root": ev.Root, "canonical": ev.Canonical}
+	case ProposerSlashingEvent:
+		return ProposerSlashingFields(ev.ProposerSlashing)
+	case AttesterSlashingEvent:
+		return AttesterSlashingFields(ev.AttesterSlashing)
+	default:
+		return map[string]interface{}{"slot": ev.Slot, "root": ev.Root}
+	}
+}
+
+// ProposerSlashingFields summarizes the slashing for logging.
+func ProposerSlashingFields(s *beacon.ProposerSlashing) map[string]interface{} {
+	h := tree.GetHashFn()
+	return map[string]interface{}{
+		"proposer": s.SignedHeader1.Message.ProposerIndex,
+		"slot":     s.SignedHeader1.Message.Slot,
+		"root_1":   s.SignedHeader1.Message.HashTreeRoot(h),
+		"root_2":   s.SignedHeader2.Message.HashTreeRoot(h),
+	}
+}
+
+// AttesterSlashingFields summarizes the slashing for logging.
+func AttesterSlashingFields(s *beacon.AttesterSlashing) map[string]interface{} {
+	a, b := &s.Attestation1.Data, &s.Attestation2.Data
+	kind := "surround"
+	if a.Target.Epoch == b.Target.Epoch {
+		kind = "double"
+	}
+	return map[string]interface{}{
+		"vote":     kind,
+		"indices":  slashing.SlashableIndices(s),
+		"source_1": a.Source.Epoch,
+		"target_1": a.Target.Epoch,
+		"root_1":   a.BeaconBlockRoot,
+		"source_2": b.Source.Epoch,
+		"target_2": b.Target.Epoch,
+		"root_2":   b.BeaconBlockRoot,
+	}
+}
+
+type ChainEventFn func(ev *ChainEvent)
+
+type chainEvents struct {
+	sync.Mutex
+	nextID      int
+	subscribers map[int]ChainEventFn
+}
+
+// SubscribeEvents registers a callback for all chain events, until unsubscribe is called.
+// Callbacks run synchronously with the chain processing, and should not block.
+// The chain is locked while they run, callbacks must not call its methods.
+func (uc *UnfinalizedChain) SubscribeEvents(fn ChainEventFn) (unsubscribe func()) {
+	uc.events.Lock()
+	defer uc.events.Unlock()
+	if uc.events.subscribers == nil {
+		uc.events.subscribers = make(map[int]ChainEventFn)
+	}
+	id := uc.events.nextID
+	uc.events.nextID += 1
+	uc.events.subscribers[id] = fn
+	return func() {
+		uc.events.Lock()
+		defer uc.events.Unlock()
+		delete(uc.events.subscribers, id)
+	}
+}
+
+func (uc *UnfinalizedChain) emit(ev *ChainEvent) {
+	uc.events.Lock()
+	subs := make([]ChainEventFn, 0, len(uc.events.subscribers))
+	for _, fn := range uc.events.subscribers {
+		subs = append(subs, fn)
+	}
+	uc.events.Unlock()
+	for _, fn := range subs {
+		fn(ev)
+	}
+}
+
+// commonAncestor walks back from both blocks until they meet, ok is false if the history is not available.
+func (uc *UnfinalizedChain) commonAncestor(a forkchoice.BlockRef, b forkchoice.BlockRef) (out forkchoice.BlockRef, ok bool) {
+	parent := func(ref forkchoice.BlockRef) (forkchoice.BlockRef, bool) {
+		entry, ok := uc.Entries[NewBlockSlotKey(ref.Root, ref.Slot)]
+		if !ok || entry.parentRoot == (Root{}) {
+			return forkchoice.BlockRef{}, false
+		}
+		p, ok := uc.ForkChoice.GetBlock(entry.parentRoot)
+		return p, ok
+	}
+	for a.Root != b.Root {
+		if a.Slot >= b.Slot {
+			if a, ok = parent(a); !ok {
+				return forkchoice.BlockRef{}, false
+			}
+		} else {
+			if b, ok = parent(b); !ok {
+				return forkchoice.BlockRef{}, false
+			}
+		}
+	}
+	return a, true
+}
+
+// checkHead compares the head and its checkpoints with the previous check, and emits events for the changes.
+func (uc *UnfinalizedChain) checkHead() error {
+	head, err := uc.ForkChoice.FindHead()
+	if err != nil {
+		return err
+	}
+	prev := uc.lastHead
+	if head.Root == prev.Root {
+		return nil
+	}
+	uc.lastHead = head
+	ancestor, ok := uc.commonAncestor(prev, head)
+	if ok && ancestor.Root == prev.Root {
+		uc.emit(&ChainEvent{Kind: HeadEvent, Slot: head.Slot, Root: head.Root, OldSlot: prev.Slot, OldRoot: prev.Root})
+	} else {
+		ev := &ChainEvent{Kind: ReorgEvent, Slot: head.Slot, Root: head.Root, OldSlot: prev.Slot, OldRoot: prev.Root}
+		if ok {
+			ev.AncestorSlot = ancestor.Slot
+			ev.AncestorRoot = ancestor.Root
+			ev.Depth = uint64(prev.Slot - ancestor.Slot)
+		}
+		uc.emit(ev)
+	}
+	entry, ok := uc.Entries[NewBlockSlotKey(head.Root, head.Slot)]
+	if !ok {
+		return nil
+	}
+	just, err := entry.state.CurrentJustifiedCheckpoint()
+	if err != nil {
+		return err
+	}
+	justCh, err := just.Raw()
+	if err != nil {
+		return err
+	}
+	if justCh != uc.lastJustified {
+		uc.lastJustified = justCh
+		uc.emit(&ChainEvent{Kind: JustifiedEvent, Epoch: justCh.Epoch, Root: justCh.Root})
+	}
+	fin, err := entry.state.FinalizedCheckpoint()
+	if err != nil {
+		return err
+	}
+	finCh, err := fin.Raw()
+	if err != nil {
+		return err
+	}
+	if finCh != uc.lastFinalized {
+		uc.lastFinalized = finCh
+		uc.emit(&ChainEvent{Kind: FinalizedEvent, Epoch: finCh.Epoch, Root: finCh.Root})
+	}
+	return nil
+}
@@ -0,0 +1,115 @@
+package chain
+
+import (
+	"github.com/protolambda/zrnt/eth2/forkchoice"
+)
+
+// ForkChoice is the proto-array fork-choice of zrnt, with the votes and balances tracked here.
+// The zrnt (v0.12.4) fork-choice extends its votes up to the index of a new validator, not past it,
+// and then indexes past the end: it panics on the first vote of every validator.
+// Nodes are not pruned from the proto-array, so the index of a node is the order it was added in.
+// The hot chain prunes its own entries when the finalized checkpoint advances.
+type ForkChoice struct {
+	protoArray *forkchoice.ProtoArray
+	// Proto-array index of every block root
+	indices   map[Root]forkchoice.ProtoNodeIndex
+	votes     []forkchoice.VoteTracker
+	balances  []Gwei
+	justified Checkpoint
+	finalized Checkpoint
+}
+
+func NewForkChoice(finalized Checkpoint, justified Checkpoint) *ForkChoice {
+	return &ForkChoice{
+		protoArray: forkchoice.NewProtoArray(justified.Epoch, finalized.Epoch, nil),
+		indices:    make(map[Root]forkchoice.ProtoNodeIndex),
+		justified:  justified,
+		finalized:  finalized,
+	}
+}
+
+// ProcessAttestation registers the vote, if it has a later target epoch than the previous vote of the validator.
+// Like the spec, the vote only counts after the next UpdateJustified.
+func (fc *ForkChoice) ProcessAttestation(index ValidatorIndex, blockRoot Root, targetEpoch Epoch) {
+	if count := ValidatorIndex(len(fc.votes)); index >= count {
+		fc.votes = append(fc.votes, make([]forkchoice.VoteTracker, index+1-count)...)
+	}
+	vote := &fc.votes[index]
+	if targetEpoch > vote.NextEpoch {
+		vote.NextRoot = blockRoot
+		vote.NextEpoch = targetEpoch
+	}
+}
+
+func (fc *ForkChoice) ProcessBlock(block forkchoice.BlockRef, parentRoot Root, justifiedEpoch Epoch, finalizedEpoch Epoch) {
+	if _, ok := fc.indices[block.Root]; ok {
+		return
+	}
+	fc.indices[block.Root] = forkchoice.ProtoNodeIndex(len(fc.indices))
+	fc.protoArray.OnBlock(block, parentRoot, justifiedEpoch, finalizedEpoch)
+}
+
+// UpdateJustified applies the votes and balance changes since the last update to the weights of the blocks,
+// and updates the checkpoints.
+func (fc *ForkChoice) UpdateJustified(justified Checkpoint, finalized Checkpoint, justifiedStateBalances []Gwei) error {
+	deltas := computeDeltas(fc.indices, fc.votes, fc.balances, justifiedStateBalances)
+	if err := fc.protoArray.ApplyScoreChanges(deltas, justified.Epoch, finalized.Epoch); err != nil {
+		return err
+	}
+	fc.balances = justifiedStateBalances
+	fc.justified = justified
+	fc.finalized = finalized
+	return nil
+}
+
+func (fc *ForkChoice) Justified() Checkpoint {
+	return fc.justified
+}
+
+func (fc *ForkChoice) Finalized() Checkpoint {
+	return fc.finalized
+}
+
+func (fc *ForkChoice) BlocksAroundSlot(anchor Root, slot Slot) (before forkchoice.BlockRef, at forkchoice.BlockRef, after forkchoice.BlockRef, err error) {
+	return fc.protoArray.BlocksAroundSlot(anchor, slot)
+}
+
+func (fc *ForkChoice) GetBlock(root Root) (block forkchoice.BlockRef, ok bool) {
+	return fc.protoArray.GetBlock(root)
+}
+
+func (fc *ForkChoice) FindHead() (forkchoice.BlockRef, error) {
+	return fc.protoArray.FindHead(fc.justified.Root)
+}
+
+// computeDeltas returns the weight change of every block, from moved votes and changed balances.
+// Like in the zrnt fork-choice, votes for blocks outside of the tree are ignored.
+func computeDeltas(indices map[Root]forkchoice.ProtoNodeIndex, votes []forkchoice.VoteTracker,
+	oldBalances []Gwei, newBalances []Gwei) []forkchoice.SignedGwei {
+	deltas := make([]forkchoice.SignedGwei, len(indices))
+	for i := range votes {
+		vote := &votes[i]
+		// The validator has not voted yet
+		if vote.CurrentRoot == (Root{}) && vote.NextRoot == (Root{}) {
+			continue
+		}
+		oldBal := Gwei(0)
+		if i < len(oldBalances) {
+			oldBal = oldBalances[i]
+		}
+		newBal := Gwei(0)
+		if i < len(newBalances) {
+			newBal = newBalances[i]
+		}
+		if vote.CurrentRoot != vote.NextRoot || oldBal != newBal {
+			if currentIndex, ok := indices[vote.CurrentRoot]; ok {
+				deltas[currentIndex] -= forkchoice.SignedGwei(oldBal)
+			}
+			if nextIndex, ok := indices[vote.NextRoot]; ok {
+				deltas[nextIndex] += forkchoice.SignedGwei(newBal)
+			}
+			vote.CurrentRoot = vote.NextRoot
+		}
+	}
+	return deltas
+}
@@ -0,0 +1,184 @@
+package chain
+
+import (
+	"bytes"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// LatestVote is the most recent fork-choice vote of a validator, by target epoch.
+type LatestVote struct {
+	Root  Root
+	Epoch Epoch
+}
+
+// ForkChoiceNode is a block in the hot chain, with its fork-choice attributes.
+type ForkChoiceNode struct {
+	Slot Slot `json:"slot"`
+	Root Root `json:"root"`
+	// Zeroed if the parent is not part of the hot chain
+	Parent         Root  `json:"parent"`
+	JustifiedEpoch Epoch `json:"justified_epoch"`
+	FinalizedEpoch Epoch `json:"finalized_epoch"`
+	// Number of latest votes for this block (not including descendants)
+	Votes int `json:"votes"`
+	// Sum of the effective balances of the latest votes for this block and its descendants
+	Weight Gwei `json:"weight"`
+	// Zeroed if the block has no children
+	BestChild      Root `json:"best_child"`
+	BestDescendant Root `json:"best_descendant"`
+	Head           bool `json:"head"`
+}
+
+// ForkChoiceNodes lists all blocks in the hot chain, ordered by slot and root.
+// The fork-choice library does not expose its proto-array, so the tree is reconstructed from the hot entries,
+// weighted with the same votes and justified balances that were passed to the fork-choice.
+// The fork-choice also skips branches that do not match its justified and finalized epochs,
+// which is not reconstructed: the best descendant may differ from the head, which is taken from the fork-choice.
+func (uc *UnfinalizedChain) ForkChoiceNodes() ([]ForkChoiceNode, error) {
+	uc.Lock()
+	defer uc.Unlock()
+	return uc.forkChoiceNodes()
+}
+
+func (uc *UnfinalizedChain) forkChoiceNodes() ([]ForkChoiceNode, error) {
+	var nodes []ForkChoiceNode
+	for _, entry := range uc.Entries {
+		ref, ok := uc.ForkChoice.GetBlock(entry.blockRoot)
+		if !ok || ref.Slot != entry.slot {
+			// empty slot entry
+			continue
+		}
+		just, err := entry.state.CurrentJustifiedCheckpoint()
+		if err != nil {
+			return nil, err
+		}
+		justEpoch, err := just.Epoch()
+		if err != nil {
+			return nil, err
+		}
+		fin, err := entry.state.FinalizedCheckpoint()
+		if err != nil {
+			return nil, err
+		}
+		finEpoch, err := fin.Epoch()
+		if err != nil {
+			return nil, err
+		}
+		nodes = append(nodes, ForkChoiceNode{
+			Slot:           entry.slot,
+			Root:           entry.blockRoot,
+			Parent:         entry.parentRoot,
+			JustifiedEpoch: justEpoch,
+			FinalizedEpoch: finEpoch,
+		})
+	}
+	sort.Slice(nodes, func(i, j int) bool {
+		if nodes[i].Slot == nodes[j].Slot {
+			return bytes.Compare(nodes[i].Root[:], nodes[j].Root[:]) < 0
+		}
+		return nodes[i].Slot < nodes[j].Slot
+	})
+	indices := make(map[Root]int, len(nodes))
+	for i := range nodes {
+		indices[nodes[i].Root] = i
+	}
+	for i := range nodes {
+		if _, ok := indices[nodes[i].Parent]; !ok {
+			nodes[i].Parent = Root{}
+		}
+	}
+
+	if len(uc.LatestVotes) > 0 {
+		balances, err := uc.justifiedBalances(uc.ForkChoice.Justified())
+		if err != nil {
+			return nil, fmt.Errorf("no justified balances to weigh votes with: %v", err)
+		}
+		for index, vote := range uc.LatestVotes {
+			i, ok := indices[vote.Root]
+			if !ok || uint64(index) >= uint64(len(balances)) {
+				continue
+			}
+			nodes[i].Votes += 1
+			nodes[i].Weight += balances[index]
+		}
+	}
+	// Children come after parents, accumulate weights backwards
+	for i := len(nodes) - 1; i >= 0; i-- {
+		if p, ok := indices[nodes[i].Parent]; ok && nodes[i].Parent != (Root{}) {
+			nodes[p].Weight += nodes[i].Weight
+		}
+	}
+	// Best child: most weight, ties are broken by the highest root, like the proto-array
+	for i := range nodes {
+		p, ok := indices[nodes[i].Parent]
+		if !ok || nodes[i].Parent == (Root{}) {
+			continue
+		}
+		if best := nodes[p].BestChild; best == (Root{}) {
+			nodes[p].BestChild = nodes[i].Root
+		} else {
+			b := &nodes[indices[best]]
+			if nodes[i].Weight > b.Weight || (nodes[i].Weight == b.Weight && bytes.Compare(nodes[i].Root[:], b.Root[:]) > 0) {
+				nodes[p].BestChild = nodes[i].Root
+			}
+		}
+	}
+	for i := range nodes {
+		d := nodes[i].Root
+		for nodes[indices[d]].BestChild != (Root{}) {
+			d = nodes[indices[d]].BestChild
+		}
+		nodes[i].BestDescendant = d
+	}
+	if head, err := uc.ForkChoice.FindHead(); err == nil {
+		if i, ok := indices[head.Root]; ok {
+			nodes[i].Head = true
+		}
+	}
+	return nodes, nil
+}
+
+func shortRoot(root Root) string {
+	return root.String()[:10]
+}
+
+// ForkChoiceDOT formats the fork-choice nodes as a Graphviz DOT graph, with edges from children to parents.
+// The best child edges are bold, the head is filled, and justified and finalized blocks are marked.
+func ForkChoiceDOT(nodes []ForkChoiceNode, justified Checkpoint, finalized Checkpoint) string {
+	var buf strings.Builder
+	buf.WriteString("digraph forkchoice {\n")
+	buf.WriteString("\trankdir=BT;\n")
+	buf.WriteString("\tnode [shape=box, fontname=\"monospace\"];\n")
+	for _, n := range nodes {
+		label := fmt.Sprintf("slot %d\\n%s\\nweight %d (%d votes)\\nj %d f %d",
+			n.Slot, shortRoot(n.Root), n.Weight, n.Votes, n.JustifiedEpoch, n.FinalizedEpoch)
+		attrs := []string{fmt.Sprintf("label=\"%s\"", label)}
+		if n.Head {
+			attrs = append(attrs, "style=filled", "fillcolor=lightblue")
+		}
+		if n.Root == finalized.Root {
+			attrs = append(attrs, "peripheries=3")
+		} else if n.Root == justified.Root {
+			attrs = append(attrs, "peripheries=2")
+		}
+		fmt.Fprintf(&buf, "\t\"%s\" [%s];\n", n.Root, strings.Join(attrs, ", "))
+	}
+	bestChild := make(map[Root]Root, len(nodes))
+	for _, n := range nodes {
+		bestChild[n.Root] = n.BestChild
+	}
+	for _, n := range nodes {
+		if n.Parent == (Root{}) {
+			continue
+		}
+		if bestChild[n.Parent] == n.Root {
+			fmt.Fprintf(&buf, "\t\"%s\" -> \"%s\" [style=bold];\n", n.Root, n.Parent)
+		} else {
+			fmt.Fprintf(&buf, "\t\"%s\" -> \"%s\";\n", n.Root, n.Parent)
+		}
+	}
+	buf.WriteString("}\n")
+	return buf.String()
+}
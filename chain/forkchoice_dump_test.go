@@ -0,0 +1,43 @@
+package chain
+
+import "testing"
+
+func TestForkChoiceNodesMatchHead(t *testing.T) {
+	uc, low, high := competingBlocks(t)
+	attestTo(t, uc, low)
+	// Votes with the same target epoch do not replace the earlier votes, like in the fork-choice.
+	attestTo(t, uc, high)
+
+	nodes, err := uc.ForkChoiceNodes()
+	if err != nil {
+		t.Fatal(err)
+	}
+	head, err := uc.Head()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var votes int
+	var weight Gwei
+	for _, n := range nodes {
+		if n.Head != (n.Root == head.BlockRoot()) {
+			t.Fatalf("node %s head flag is %v, head is %s", n.Root, n.Head, head.BlockRoot())
+		}
+		if n.BestDescendant != head.BlockRoot() && n.Root != high {
+			t.Fatalf("node %s has best descendant %s, head is %s", n.Root, n.BestDescendant, head.BlockRoot())
+		}
+		switch n.Root {
+		case low:
+			votes, weight = n.Votes, n.Weight
+		case high:
+			if n.Votes != 0 || n.Weight != 0 {
+				t.Fatalf("expected no votes for %s, got %d votes with weight %d", high, n.Votes, n.Weight)
+			}
+		}
+	}
+	if votes != len(uc.LatestVotes) || votes == 0 {
+		t.Fatalf("expected all %d votes for %s, got %d", len(uc.LatestVotes), low, votes)
+	}
+	if expected := Gwei(votes) * uc.Spec.MAX_EFFECTIVE_BALANCE; weight != expected {
+		t.Fatalf("expected weight %d, got %d", expected, weight)
+	}
+}
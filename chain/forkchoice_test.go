@@ -0,0 +1,57 @@
+package chain
+
+import (
+	"github.com/protolambda/zrnt/eth2/beacon"
+	"github.com/protolambda/zrnt/eth2/forkchoice"
+	"testing"
+)
+
+func TestForkChoiceNewVoters(t *testing.T) {
+	anchor := Root{1}
+	cp := Checkpoint{Epoch: 0, Root: anchor}
+	fc := NewForkChoice(cp, cp)
+	fc.ProcessBlock(forkchoice.BlockRef{Slot: 0, Root: anchor}, Root{}, 0, 0)
+
+	a := Root{0xa}
+	b := Root{0xb}
+	fc.ProcessBlock(forkchoice.BlockRef{Slot: 1, Root: a}, anchor, 0, 0)
+	fc.ProcessBlock(forkchoice.BlockRef{Slot: 1, Root: b}, anchor, 0, 0)
+
+	// Every index is new to the fork-choice: the first, the next, one far ahead, and one in between.
+	for _, index := range []ValidatorIndex{0, 1, 5, 3} {
+		fc.ProcessAttestation(index, a, 1)
+	}
+	// A later vote moves the validator, an earlier vote is ignored.
+	fc.ProcessAttestation(3, b, 2)
+	fc.ProcessAttestation(5, b, 0)
+
+	balances := make([]beacon.Gwei, 6)
+	for i := range balances {
+		balances[i] = 32_000_000_000
+	}
+	if err := fc.UpdateJustified(cp, cp, balances); err != nil {
+		t.Fatal(err)
+	}
+	head, err := fc.FindHead()
+	if err != nil {
+		t.Fatal(err)
+	}
+	// a: validators 0, 1 and 5. b: validator 3. The other indices did not vote.
+	if head.Root != a {
+		t.Fatalf("expected head %s, got %s", a, head.Root)
+	}
+
+	// Move the majority to b
+	fc.ProcessAttestation(0, b, 2)
+	fc.ProcessAttestation(1, b, 2)
+	if err := fc.UpdateJustified(cp, cp, balances); err != nil {
+		t.Fatal(err)
+	}
+	head, err = fc.FindHead()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if head.Root != b {
+		t.Fatalf("expected head %s, got %s", b, head.Root)
+	}
+}
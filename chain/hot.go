@@ -1,12 +1,16 @@
 package chain
 
 import (
+	"bytes"
 	"context"
 	"errors"
 	"fmt"
+	"github.com/protolambda/rumor/chain/slashing"
 	"github.com/protolambda/zrnt/eth2/beacon"
 	"github.com/protolambda/zrnt/eth2/forkchoice"
 	"github.com/protolambda/ztyp/tree"
+	"sort"
+	"sync"
 )
 
 type HotEntry struct {
@@ -70,8 +74,9 @@ type HotChain interface {
 }
 
 type UnfinalizedChain struct {
-	ForkChoice *forkchoice.ForkChoice
+	ForkChoice *ForkChoice
 
+	// Slot of the first entry, the last finalized block. Earlier entries are pruned into the block sink.
 	AnchorSlot Slot
 
 	// block++slot -> Entry
@@ -87,10 +92,38 @@ type UnfinalizedChain struct {
 
 	// Spec is holds configuration information for the parameters and types of the chain
 	Spec *beacon.Spec
+
+	// Tracer records a trace of each processed block, if not nil. Changed with SetTracer while the chain is in use.
+	Tracer *TransitionTracer
+
+	// LatestVotes mirrors the votes passed to the fork-choice, which does not expose them.
+	// Used to reconstruct the weights of the fork-choice tree in a dump.
+	LatestVotes map[ValidatorIndex]LatestVote
+
+	// Attestations keeps the attestations added to the chain, for block proposals
+	Attestations *AttestationPool
+
+	// Slashings detects conflicting blocks and attestations added to the chain
+	Slashings *slashing.Detector
+
+	// Guards the entries, votes and fork-choice. Blocks and attestations may be added by background tasks,
+	// while the chain is read by others, e.g. the shell and the API server.
+	// The fields should only be accessed directly while holding the lock.
+	sync.Mutex
+
+	// Effective balances of the active validators in the justified state, and the justified root they are of
+	balances     []Gwei
+	balancesRoot Root
+
+	// Subscribers to chain events, and the last seen head and checkpoints to detect changes with
+	events        chainEvents
+	lastHead      forkchoice.BlockRef
+	lastJustified Checkpoint
+	lastFinalized Checkpoint
 }
 
 type HotChainIter struct {
-	// ordered from head to 0
+	// ordered from head to anchor, an entry for every slot
 	entries  []*HotEntry
 	headSlot Slot
 }
@@ -107,23 +140,102 @@ func (fi *HotChainIter) Entry(slot Slot) (entry ChainEntry, err error) {
 	if slot < fi.Start() || slot >= fi.End() {
 		return nil, fmt.Errorf("out of range slot: %d, range: [%d, %d)", slot, fi.Start(), fi.End())
 	}
-	return fi.entries[slot-fi.headSlot], nil
+	return fi.entries[fi.headSlot-slot], nil
 }
 
 func (uc *UnfinalizedChain) Iter() (ChainIter, error) {
+	uc.Lock()
+	defer uc.Unlock()
+	return uc.iter()
+}
+
+func (uc *UnfinalizedChain) ByStateRoot(root Root) (ChainEntry, error) {
+	uc.Lock()
+	defer uc.Unlock()
+	return uc.byStateRoot(root)
+}
+
+func (uc *UnfinalizedChain) ByBlockSlot(key BlockSlotKey) (ChainEntry, error) {
+	uc.Lock()
+	defer uc.Unlock()
+	return uc.byBlockSlot(key)
+}
+
+func (uc *UnfinalizedChain) ByBlockRoot(root Root) (ChainEntry, error) {
+	uc.Lock()
+	defer uc.Unlock()
+	return uc.byBlockRoot(root)
+}
+
+func (uc *UnfinalizedChain) ClosestFrom(fromBlockRoot Root, toSlot Slot) (ChainEntry, error) {
+	uc.Lock()
+	defer uc.Unlock()
+	return uc.closestFrom(fromBlockRoot, toSlot)
+}
+
+func (uc *UnfinalizedChain) BySlot(slot Slot) (ChainEntry, error) {
+	uc.Lock()
+	defer uc.Unlock()
+	return uc.bySlot(slot)
+}
+
+func (uc *UnfinalizedChain) Justified() Checkpoint {
+	uc.Lock()
+	defer uc.Unlock()
+	return uc.ForkChoice.Justified()
+}
+
+func (uc *UnfinalizedChain) Finalized() Checkpoint {
+	uc.Lock()
+	defer uc.Unlock()
+	return uc.ForkChoice.Finalized()
+}
+
+func (uc *UnfinalizedChain) Head() (ChainEntry, error) {
+	uc.Lock()
+	defer uc.Unlock()
+	return uc.head()
+}
+
+// GetTracer returns the tracer of the chain, nil if tracing is disabled.
+func (uc *UnfinalizedChain) GetTracer() *TransitionTracer {
+	uc.Lock()
+	defer uc.Unlock()
+	return uc.Tracer
+}
+
+// SetTracer replaces the tracer of the chain, nil to disable tracing.
+func (uc *UnfinalizedChain) SetTracer(tracer *TransitionTracer) {
+	uc.Lock()
+	defer uc.Unlock()
+	uc.Tracer = tracer
+}
+
+func (uc *UnfinalizedChain) AddBlock(ctx context.Context, signedBlock *beacon.SignedBeaconBlock) error {
+	uc.Lock()
+	defer uc.Unlock()
+	return uc.addBlock(ctx, signedBlock)
+}
+
+func (uc *UnfinalizedChain) AddAttestation(att *beacon.Attestation) error {
+	uc.Lock()
+	defer uc.Unlock()
+	return uc.addAttestation(att)
+}
+
+func (uc *UnfinalizedChain) iter() (ChainIter, error) {
 	headRef, err := uc.ForkChoice.FindHead()
 	if err != nil {
 		return nil, err
 	}
-	entries := make([]*HotEntry, 0)
-	root := headRef.Root
-	for {
-		entry, err := uc.ByBlockRoot(root)
-		if err != nil {
-			break
-		}
-		entries = append(entries, entry.(*HotEntry))
-		root = entry.ParentRoot()
+	head, ok := uc.Entries[NewBlockSlotKey(headRef.Root, headRef.Slot)]
+	if !ok {
+		return nil, fmt.Errorf("unknown head block %s at slot %d", headRef.Root, headRef.Slot)
+	}
+	keys := uc.ancestry(head)
+	entries := make([]*HotEntry, len(keys))
+	for i, key := range keys {
+		entries[i] = uc.Entries[key]
 	}
 	return &HotChainIter{entries, headRef.Slot}, nil
 }
@@ -158,70 +270,45 @@ func NewUnfinalizedChain(finalizedBlock *HotEntry, sink BlockSink, spec *beacon.
 	}
 	key := NewBlockSlotKey(finalizedBlock.blockRoot, finalizedBlock.slot)
 	uc := &UnfinalizedChain{
-		ForkChoice: nil,
-		Entries:    map[BlockSlotKey]*HotEntry{key: finalizedBlock},
-		State2Key:  map[Root]BlockSlotKey{finalizedBlock.StateRoot(): key},
-		BlockSink:  sink,
-		Spec:       spec,
-	}
-	uc.ForkChoice = forkchoice.NewForkChoice(finCh, justCh, forkchoice.BlockSinkFn(uc.OnPrunedBlock))
+		ForkChoice:    nil,
+		AnchorSlot:    finalizedBlock.slot,
+		Entries:       map[BlockSlotKey]*HotEntry{key: finalizedBlock},
+		State2Key:     map[Root]BlockSlotKey{finalizedBlock.StateRoot(): key},
+		BlockSink:     sink,
+		Spec:          spec,
+		LatestVotes:   make(map[ValidatorIndex]LatestVote),
+		Attestations:  NewAttestationPool(),
+		Slashings:     slashing.NewDetector(spec),
+		lastHead:      forkchoice.BlockRef{Slot: finalizedBlock.slot, Root: finalizedBlock.blockRoot},
+		lastJustified: justCh,
+		lastFinalized: finCh,
+	}
+	uc.ForkChoice = newAnchoredForkChoice(finalizedBlock, finCh, justCh)
 	return uc, nil
 }
 
-func (uc *UnfinalizedChain) OnPrunedBlock(node *forkchoice.ProtoNode, canonical bool) error {
-	blockRef := node.Block
-
-	key := NewBlockSlotKey(blockRef.Root, blockRef.Slot)
-	entry, ok := uc.Entries[key]
-	if ok {
-		pruned := make([]*HotEntry, 0)
-		pruned = append(pruned, entry)
-		// Remove block from hot state
-		delete(uc.Entries, key)
-		delete(uc.State2Key, entry.StateRoot())
-		if entry.slot > uc.AnchorSlot {
-			uc.AnchorSlot = entry.slot
-		}
-		// There may be empty slots leading up to the block,
-		// If this block is not canonical, we cannot delete them,
-		// because a later block may still share the history, and be canonical.
-		prevBlockRoot := entry.ParentRoot()
-		for slot := blockRef.Slot; true; slot-- {
-			key = NewBlockSlotKey(prevBlockRoot, slot)
-			prevEntry, ok := uc.Entries[key]
-			if ok {
-				pruned = append(pruned, prevEntry)
-			} else {
-				break
-			}
-		}
-		if canonical {
-			// sink from oldest to newest entry
-			for i := len(pruned); i >= 0; i-- {
-				entry := pruned[i]
-				if err := uc.BlockSink.Sink(entry, true); err != nil {
-					return err
-				}
-			}
-		} else {
-			// Only sink the actual block that was pruned, if non-canonical.
-			if err := uc.BlockSink.Sink(entry, true); err != nil {
-				return err
-			}
-		}
-	}
-	return nil
+// newAnchoredForkChoice starts the fork-choice from the anchor block, the root of the hot block tree.
+// The checkpoints of the anchor state point to older blocks (or the zero root at genesis), which are not in the tree,
+// so the anchor is used as justified and finalized root instead, with the epochs of the state.
+// Without this, the fork-choice has no node to find the head from, and no parent for the first block.
+func newAnchoredForkChoice(anchor *HotEntry, finalized Checkpoint, justified Checkpoint) *ForkChoice {
+	finalized.Root = anchor.blockRoot
+	justified.Root = anchor.blockRoot
+	fc := NewForkChoice(finalized, justified)
+	fc.ProcessBlock(forkchoice.BlockRef{Slot: anchor.slot, Root: anchor.blockRoot},
+		anchor.parentRoot, justified.Epoch, finalized.Epoch)
+	return fc
 }
 
-func (uc *UnfinalizedChain) ByStateRoot(root Root) (ChainEntry, error) {
+func (uc *UnfinalizedChain) byStateRoot(root Root) (ChainEntry, error) {
 	key, ok := uc.State2Key[root]
 	if !ok {
 		return nil, fmt.Errorf("unknown state %s", root)
 	}
-	return uc.ByBlockSlot(key)
+	return uc.byBlockSlot(key)
 }
 
-func (uc *UnfinalizedChain) ByBlockSlot(key BlockSlotKey) (ChainEntry, error) {
+func (uc *UnfinalizedChain) byBlockSlot(key BlockSlotKey) (ChainEntry, error) {
 	entry, ok := uc.Entries[key]
 	if !ok {
 		return nil, fmt.Errorf("unknown block slot, root: %s slot: %d", key.Root(), key.Slot())
@@ -229,21 +316,26 @@ func (uc *UnfinalizedChain) ByBlockSlot(key BlockSlotKey) (ChainEntry, error) {
 	return entry, nil
 }
 
-func (uc *UnfinalizedChain) ByBlockRoot(root Root) (ChainEntry, error) {
+func (uc *UnfinalizedChain) byBlockRoot(root Root) (ChainEntry, error) {
 	ref, ok := uc.ForkChoice.GetBlock(root)
 	if !ok {
 		return nil, fmt.Errorf("unknown block %s", root)
 	}
-	return uc.ByBlockSlot(NewBlockSlotKey(root, ref.Slot))
+	return uc.byBlockSlot(NewBlockSlotKey(root, ref.Slot))
 }
 
-func (uc *UnfinalizedChain) ClosestFrom(fromBlockRoot Root, toSlot Slot) (ChainEntry, error) {
+func (uc *UnfinalizedChain) closestFrom(fromBlockRoot Root, toSlot Slot) (ChainEntry, error) {
+	// BlocksAroundSlot only looks at the canonical chain up to the head, and errors if there is no block at the slot.
+	// So it cannot find the parent of a block that extends the head, skips slots, or forks off an older block.
+	if entry, ok := uc.closestOnBranch(fromBlockRoot, toSlot); ok {
+		return entry, nil
+	}
 	before, at, _, err := uc.ForkChoice.BlocksAroundSlot(fromBlockRoot, toSlot)
 	if err != nil {
 		return nil, err
 	}
 	if at.Root != (Root{}) {
-		return uc.ByBlockSlot(NewBlockSlotKey(at.Root, at.Slot))
+		return uc.byBlockSlot(NewBlockSlotKey(at.Root, at.Slot))
 	}
 	for slot := toSlot; slot >= before.Slot && slot != 0; slot-- {
 		key := NewBlockSlotKey(before.Root, slot)
@@ -255,38 +347,48 @@ func (uc *UnfinalizedChain) ClosestFrom(fromBlockRoot Root, toSlot Slot) (ChainE
 	return nil, fmt.Errorf("could not find closest hot block starting from root %s, up to slot %d", fromBlockRoot, toSlot)
 }
 
-func (uc *UnfinalizedChain) BySlot(slot Slot) (ChainEntry, error) {
-	_, at, _, err := uc.ForkChoice.BlocksAroundSlot(uc.Justified().Root, slot)
+// closestOnBranch finds the block itself, or the latest empty slot after it, up to the given slot.
+func (uc *UnfinalizedChain) closestOnBranch(blockRoot Root, toSlot Slot) (*HotEntry, bool) {
+	ref, ok := uc.ForkChoice.GetBlock(blockRoot)
+	if !ok || ref.Slot > toSlot {
+		return nil, false
+	}
+	for slot := toSlot; slot >= ref.Slot; slot-- {
+		if entry, ok := uc.Entries[NewBlockSlotKey(blockRoot, slot)]; ok {
+			return entry, true
+		}
+		if slot == 0 {
+			break
+		}
+	}
+	return nil, false
+}
+
+func (uc *UnfinalizedChain) bySlot(slot Slot) (ChainEntry, error) {
+	_, at, _, err := uc.ForkChoice.BlocksAroundSlot(uc.ForkChoice.Justified().Root, slot)
 	if err != nil {
 		return nil, err
 	}
 	if at.Slot == slot {
-		return uc.ByBlockSlot(NewBlockSlotKey(at.Root, at.Slot))
+		return uc.byBlockSlot(NewBlockSlotKey(at.Root, at.Slot))
 	}
 	return nil, fmt.Errorf("no hot entry known for slot %d", slot)
 }
 
-func (uc *UnfinalizedChain) Justified() Checkpoint {
-	return uc.ForkChoice.Justified()
-}
-
-func (uc *UnfinalizedChain) Finalized() Checkpoint {
-	return uc.ForkChoice.Finalized()
-}
-
-func (uc *UnfinalizedChain) Head() (ChainEntry, error) {
+func (uc *UnfinalizedChain) head() (ChainEntry, error) {
 	ref, err := uc.ForkChoice.FindHead()
 	if err != nil {
 		return nil, err
 	}
-	return uc.ByBlockRoot(ref.Root)
+	return uc.byBlockRoot(ref.Root)
 }
 
-func (uc *UnfinalizedChain) AddBlock(ctx context.Context, signedBlock *beacon.SignedBeaconBlock) error {
+func (uc *UnfinalizedChain) addBlock(ctx context.Context, signedBlock *beacon.SignedBeaconBlock) error {
 	block := &signedBlock.Message
 	blockRoot := block.HashTreeRoot(uc.Spec, tree.GetHashFn())
+	tracer := uc.Tracer
 
-	pre, err := uc.ClosestFrom(block.ParentRoot, block.Slot)
+	pre, err := uc.closestFrom(block.ParentRoot, block.Slot)
 	if err != nil {
 		return err
 	}
@@ -305,35 +407,32 @@ func (uc *UnfinalizedChain) AddBlock(ctx context.Context, signedBlock *beacon.Si
 		return err
 	}
 
-	// Process empty slots
-	for slot := pre.Slot() + 1; slot < block.Slot; {
-		if err := uc.Spec.ProcessSlot(ctx, state); err != nil {
+	var trace *BlockTrace
+	if tracer != nil {
+		trace, err = newBlockTrace(block, blockRoot, state)
+		if err != nil {
 			return err
 		}
-		// Per-epoch transition happens at the start of the first slot of every epoch.
-		// (with the slot still at the end of the last epoch)
-		isEpochEnd := uc.Spec.SlotToEpoch(slot+1) != uc.Spec.SlotToEpoch(slot)
-		if isEpochEnd {
-			if err := uc.Spec.ProcessEpoch(ctx, epc, state); err != nil {
-				return err
-			}
+	}
+
+	// Process empty slots
+	for slot := pre.Slot() + 1; slot < block.Slot; slot++ {
+		if trace != nil {
+			err = trace.processSlot(ctx, uc.Spec, epc, state)
+		} else {
+			err = processEmptySlot(ctx, uc.Spec, epc, state)
 		}
-		slot += 1
-		if err := state.SetSlot(slot); err != nil {
+		if err != nil {
 			return err
 		}
-		if isEpochEnd {
-			if err := epc.RotateEpochs(state); err != nil {
-				return err
-			}
-		}
 
-		// Add empty slot entry
+		// Add empty slot entry. Entries keep their epochs-context,
+		// blocks on top of them and attestations to them need it.
 		uc.Entries[NewBlockSlotKey(block.ParentRoot, slot)] = &HotEntry{
-			slot:       block.Slot,
-			epc:        nil,
+			slot:       slot,
+			epc:        epc,
 			state:      state,
-			blockRoot:  blockRoot,
+			blockRoot:  block.ParentRoot,
 			parentRoot: Root{},
 		}
 
@@ -344,58 +443,67 @@ func (uc *UnfinalizedChain) AddBlock(ctx context.Context, signedBlock *beacon.Si
 		epc = epc.Clone()
 	}
 
-	if err := uc.Spec.StateTransition(ctx, epc, state, signedBlock, true); err != nil {
+	if trace != nil {
+		err = trace.stateTransition(ctx, uc.Spec, epc, state, signedBlock, true)
+	} else {
+		err = uc.Spec.StateTransition(ctx, epc, state, signedBlock, true)
+	}
+	if err != nil {
 		return err
 	}
 	// And seal the state, need the header and block/state roots to update.
 	if err := uc.Spec.ProcessSlot(ctx, state); err != nil {
 		return err
 	}
-
-	var finalizedEpoch, justifiedEpoch Epoch
-	{
-		finalizedCh, err := state.FinalizedCheckpoint()
-		if err != nil {
-			return err
-		}
-		finalizedEpoch, err = finalizedCh.Epoch()
-		if err != nil {
-			return err
-		}
-		justifiedCh, err := state.CurrentJustifiedCheckpoint()
-		if err != nil {
-			return err
-		}
-		justifiedEpoch, err = justifiedCh.Epoch()
-		if err != nil {
+	if trace != nil {
+		if err := trace.finish(state); err != nil {
 			return err
 		}
 	}
 
+	justifiedCh, finalizedCh, err := stateCheckpoints(state)
+	if err != nil {
+		return err
+	}
+	justified, finalized := uc.laterCheckpoints(justifiedCh, finalizedCh)
+	// A new justified checkpoint needs the balances of its state, get them before the chain is changed.
+	if _, err := uc.justifiedBalances(justified); err != nil {
+		return err
+	}
+
 	uc.Entries[NewBlockSlotKey(blockRoot, block.Slot)] = &HotEntry{
 		slot:       block.Slot,
-		epc:        nil,
+		epc:        epc,
 		state:      state,
 		blockRoot:  blockRoot,
 		parentRoot: block.ParentRoot,
 	}
 	uc.ForkChoice.ProcessBlock(
 		forkchoice.BlockRef{Slot: block.Slot, Root: blockRoot},
-		block.ParentRoot, justifiedEpoch, finalizedEpoch)
+		block.ParentRoot, justifiedCh.Epoch, finalizedCh.Epoch)
+	if err := uc.applyVotes(justified, finalized); err != nil {
+		return fmt.Errorf("failed to apply block to fork-choice: %v", err)
+	}
 
-	if block.Slot < uc.AnchorSlot {
-		uc.AnchorSlot = block.Slot
+	if trace != nil {
+		tracer.Record(trace)
+	}
+	uc.Attestations.Remove(block.Body.Attestations)
+	if s := uc.Slashings.OnBlock(signedBlock); s != nil {
+		uc.emit(&ChainEvent{Kind: ProposerSlashingEvent, Slot: block.Slot, Root: blockRoot, ProposerSlashing: s})
 	}
+	// The block is processed, failing to derive events does not undo that.
+	_ = uc.checkHead()
 	return nil
 }
 
-func (uc *UnfinalizedChain) AddAttestation(att *beacon.Attestation) error {
+func (uc *UnfinalizedChain) addAttestation(att *beacon.Attestation) error {
 	blockRoot := att.Data.BeaconBlockRoot
-	block, err := uc.ByBlockRoot(blockRoot)
+	block, err := uc.byBlockRoot(blockRoot)
 	if err != nil {
 		return err
 	}
-	_, ok := block.(*HotEntry)
+	entry, ok := block.(*HotEntry)
 	if !ok {
 		return errors.New("expected HotEntry, need epochs-context to be present")
 	}
@@ -415,6 +523,199 @@ func (uc *UnfinalizedChain) AddAttestation(att *beacon.Attestation) error {
 	targetEpoch := att.Data.Target.Epoch
 	for _, index := range indexedAtt.AttestingIndices {
 		uc.ForkChoice.ProcessAttestation(index, blockRoot, targetEpoch)
+		// Like the fork-choice: only a vote with a later target counts, and votes for epoch 0 are ignored.
+		if vote := uc.LatestVotes[index]; targetEpoch > vote.Epoch {
+			uc.LatestVotes[index] = LatestVote{Root: blockRoot, Epoch: targetEpoch}
+		}
+	}
+	justifiedCh, finalizedCh, err := stateCheckpoints(entry.state)
+	if err != nil {
+		return err
+	}
+	if err := uc.applyVotes(uc.laterCheckpoints(justifiedCh, finalizedCh)); err != nil {
+		return fmt.Errorf("failed to apply votes to fork-choice: %v", err)
+	}
+	uc.Attestations.Add(att)
+	for _, s := range uc.Slashings.OnAttestation(indexedAtt) {
+		s := s
+		uc.emit(&ChainEvent{Kind: AttesterSlashingEvent, Slot: att.Data.Slot, Root: blockRoot, AttesterSlashing: &s})
 	}
+	_ = uc.checkHead()
 	return nil
 }
+
+// applyVotes moves the fork-choice to the checkpoints, and updates its weights with the votes it received since the last update.
+// The fork-choice only counts votes after this, weighted by the balances of the justified state.
+// Entries before a new finalized block are pruned.
+func (uc *UnfinalizedChain) applyVotes(justified Checkpoint, finalized Checkpoint) error {
+	balances, err := uc.justifiedBalances(justified)
+	if err != nil {
+		return err
+	}
+	if err := uc.ForkChoice.UpdateJustified(justified, finalized, balances); err != nil {
+		return err
+	}
+	if ref, ok := uc.ForkChoice.GetBlock(finalized.Root); ok && ref.Slot > uc.AnchorSlot {
+		return uc.prune(ref)
+	}
+	return nil
+}
+
+// prune moves the anchor of the hot chain to the finalized block.
+// The canonical entries before it are passed to the block sink, from oldest to newest, and removed from the hot chain.
+// Then the blocks that conflict with it are passed to the block sink as non-canonical, and removed with their empty slots.
+// The zrnt proto-array does not prune nodes, the removed blocks are only known to the fork-choice after this.
+func (uc *UnfinalizedChain) prune(finalized forkchoice.BlockRef) error {
+	finalizedEntry, ok := uc.Entries[NewBlockSlotKey(finalized.Root, finalized.Slot)]
+	if !ok {
+		return fmt.Errorf("unknown finalized block %s at slot %d", finalized.Root, finalized.Slot)
+	}
+	canonical := uc.ancestry(finalizedEntry)[1:]
+	if last := finalized.Slot - Slot(len(canonical)); last != uc.AnchorSlot {
+		return fmt.Errorf("missing canonical entry at slot %d, before finalized block %s", last-1, finalized.Root)
+	}
+	// Sink from oldest to newest entry. The anchor moves with every sunk entry,
+	// so pruning can continue from where it stopped if the sink fails.
+	for i := len(canonical) - 1; i >= 0; i-- {
+		entry := uc.Entries[canonical[i]]
+		if err := uc.BlockSink.Sink(entry, true); err != nil {
+			return fmt.Errorf("failed to sink finalized entry at slot %d: %v", entry.slot, err)
+		}
+		uc.removeEntry(canonical[i], true)
+		uc.AnchorSlot = entry.slot + 1
+	}
+	// Everything else before the finalized block conflicts with it
+	var conflicting []BlockSlotKey
+	for key, entry := range uc.Entries {
+		if entry.slot < finalized.Slot {
+			conflicting = append(conflicting, key)
+		}
+	}
+	sort.Slice(conflicting, func(i, j int) bool {
+		a, b := &conflicting[i], &conflicting[j]
+		if a.Slot() != b.Slot() {
+			return a.Slot() < b.Slot()
+		}
+		return bytes.Compare(a[:32], b[:32]) < 0
+	})
+	for _, key := range conflicting {
+		entry := uc.Entries[key]
+		if uc.isBlockEntry(entry) {
+			if err := uc.BlockSink.Sink(entry, false); err != nil {
+				return fmt.Errorf("failed to sink non-canonical block %s at slot %d: %v", entry.blockRoot, entry.slot, err)
+			}
+		}
+		uc.removeEntry(key, false)
+	}
+	uc.AnchorSlot = finalized.Slot
+	return nil
+}
+
+// ancestry walks back from the entry to the anchor, over the blocks and empty slots, and returns the keys of the entries,
+// from newest to oldest, starting with the entry itself. The walk stops early at a missing entry.
+func (uc *UnfinalizedChain) ancestry(entry *HotEntry) []BlockSlotKey {
+	keys := []BlockSlotKey{NewBlockSlotKey(entry.blockRoot, entry.slot)}
+	for slot := entry.slot; slot > uc.AnchorSlot; slot-- {
+		root := entry.blockRoot
+		if !entry.IsEmpty() {
+			root = entry.parentRoot
+		}
+		key := NewBlockSlotKey(root, slot-1)
+		var ok bool
+		if entry, ok = uc.Entries[key]; !ok {
+			break
+		}
+		keys = append(keys, key)
+	}
+	return keys
+}
+
+// isBlockEntry checks if the entry is of the slot of its block, and not an empty slot after it.
+// The anchor entry has no known parent, so IsEmpty does not tell.
+func (uc *UnfinalizedChain) isBlockEntry(entry *HotEntry) bool {
+	ref, ok := uc.ForkChoice.GetBlock(entry.blockRoot)
+	return ok && ref.Slot == entry.slot
+}
+
+// removeEntry removes the entry from the hot chain, and emits a pruned event if it is the entry of a block.
+func (uc *UnfinalizedChain) removeEntry(key BlockSlotKey, canonical bool) {
+	entry := uc.Entries[key]
+	delete(uc.Entries, key)
+	if !uc.isBlockEntry(entry) {
+		return
+	}
+	delete(uc.State2Key, entry.StateRoot())
+	uc.emit(&ChainEvent{Kind: PrunedEvent, Slot: entry.slot, Root: entry.blockRoot, Canonical: canonical})
+}
+
+// laterCheckpoints returns the checkpoints of a post-state where they are later than those of the fork-choice,
+// like the spec updates the store checkpoints with each block.
+// Checkpoints of blocks that are not in the fork-choice, e.g. from before the anchor, are not used.
+func (uc *UnfinalizedChain) laterCheckpoints(stateJustified Checkpoint, stateFinalized Checkpoint) (justified Checkpoint, finalized Checkpoint) {
+	justified, finalized = uc.ForkChoice.Justified(), uc.ForkChoice.Finalized()
+	if _, ok := uc.ForkChoice.GetBlock(stateJustified.Root); ok && stateJustified.Epoch > justified.Epoch {
+		justified = stateJustified
+	}
+	if _, ok := uc.ForkChoice.GetBlock(stateFinalized.Root); ok && stateFinalized.Epoch > finalized.Epoch {
+		finalized = stateFinalized
+	}
+	return justified, finalized
+}
+
+func stateCheckpoints(state *beacon.BeaconStateView) (justified Checkpoint, finalized Checkpoint, err error) {
+	just, err := state.CurrentJustifiedCheckpoint()
+	if err != nil {
+		return Checkpoint{}, Checkpoint{}, err
+	}
+	if justified, err = just.Raw(); err != nil {
+		return Checkpoint{}, Checkpoint{}, err
+	}
+	fin, err := state.FinalizedCheckpoint()
+	if err != nil {
+		return Checkpoint{}, Checkpoint{}, err
+	}
+	if finalized, err = fin.Raw(); err != nil {
+		return Checkpoint{}, Checkpoint{}, err
+	}
+	return justified, finalized, nil
+}
+
+// justifiedBalances returns the effective balances of the validators that are active in the justified state,
+// indexed by validator. Inactive validators have a zero balance.
+func (uc *UnfinalizedChain) justifiedBalances(justified Checkpoint) ([]Gwei, error) {
+	if uc.balances != nil && uc.balancesRoot == justified.Root {
+		return uc.balances, nil
+	}
+	entry, err := uc.byBlockRoot(justified.Root)
+	if err != nil {
+		return nil, fmt.Errorf("no justified state: %v", err)
+	}
+	state := entry.(*HotEntry).state
+	epoch := uc.Spec.SlotToEpoch(entry.Slot())
+	validators, err := state.Validators()
+	if err != nil {
+		return nil, err
+	}
+	count, err := validators.ValidatorCount()
+	if err != nil {
+		return nil, err
+	}
+	balances := make([]Gwei, count)
+	for i := range balances {
+		v, err := validators.Validator(ValidatorIndex(i))
+		if err != nil {
+			return nil, err
+		}
+		if active, err := uc.Spec.IsActive(v, epoch); err != nil {
+			return nil, err
+		} else if !active {
+			continue
+		}
+		if balances[i], err = v.EffectiveBalance(); err != nil {
+			return nil, err
+		}
+	}
+	uc.balances = balances
+	uc.balancesRoot = justified.Root
+	return balances, nil
+}
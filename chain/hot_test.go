@@ -0,0 +1,427 @@
+package chain
+
+import (
+	"bytes"
+	"context"
+	"github.com/protolambda/rumor/chain/interop"
+	"github.com/protolambda/zrnt/eth2/beacon"
+	"github.com/protolambda/zrnt/eth2/configs"
+	"github.com/protolambda/zrnt/eth2/forkchoice"
+	"github.com/protolambda/ztyp/tree"
+	"testing"
+)
+
+func TestClosestFrom(t *testing.T) {
+	anchor := Root{1}
+	a := Root{0xa}
+	cp := Checkpoint{Epoch: 0, Root: anchor}
+	uc := &UnfinalizedChain{
+		ForkChoice: NewForkChoice(cp, cp),
+		Entries:    make(map[BlockSlotKey]*HotEntry),
+	}
+	add := func(root Root, slot Slot, parent Root) {
+		uc.ForkChoice.ProcessBlock(forkchoice.BlockRef{Slot: slot, Root: root}, parent, 0, 0)
+		uc.Entries[NewBlockSlotKey(root, slot)] = &HotEntry{slot: slot, blockRoot: root, parentRoot: parent}
+	}
+	// anchor at slot 0, an empty slot 1, and block a at slot 2, the head.
+	add(anchor, 0, Root{})
+	uc.Entries[NewBlockSlotKey(anchor, 1)] = &HotEntry{slot: 1, blockRoot: anchor}
+	add(a, 2, anchor)
+
+	cases := []struct {
+		name     string
+		from     Root
+		toSlot   Slot
+		expected BlockSlotKey
+	}{
+		{"extend head", a, 3, NewBlockSlotKey(a, 2)},
+		{"extend head after empty slots", a, 10, NewBlockSlotKey(a, 2)},
+		{"at block", a, 2, NewBlockSlotKey(a, 2)},
+		{"empty slot", anchor, 1, NewBlockSlotKey(anchor, 1)},
+		{"fork off anchor", anchor, 2, NewBlockSlotKey(anchor, 1)},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			entry, err := uc.ClosestFrom(c.from, c.toSlot)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if key := NewBlockSlotKey(entry.BlockRoot(), entry.Slot()); key != c.expected {
+				t.Fatalf("expected root %s slot %d, got root %s slot %d",
+					c.expected.Root(), c.expected.Slot(), key.Root(), key.Slot())
+			}
+		})
+	}
+
+	// These are the cases the fork-choice lookup gets wrong on its own:
+	// it errors past the head, or without a block at the slot...
+	if _, _, _, err := uc.ForkChoice.BlocksAroundSlot(a, 3); err == nil {
+		t.Fatal("expected fork-choice lookup past the head to fail")
+	}
+	if _, _, _, err := uc.ForkChoice.BlocksAroundSlot(anchor, 1); err == nil {
+		t.Fatal("expected fork-choice lookup of empty slot to fail")
+	}
+	// ...and finds the canonical block, not the ancestor on the branch of the requested root.
+	if _, at, _, err := uc.ForkChoice.BlocksAroundSlot(anchor, 2); err != nil || at.Root != a {
+		t.Fatalf("expected fork-choice lookup to return canonical block %s, got %s (err: %v)", a, at.Root, err)
+	}
+}
+
+func interopGenesis(t *testing.T, spec *beacon.Spec, validators uint64) *HotEntry {
+	deps := make([]beacon.Deposit, validators)
+	for i := range deps {
+		deps[i].Data = beacon.DepositData{
+			Pubkey: interop.Pubkey(ValidatorIndex(i)),
+			Amount: spec.MAX_EFFECTIVE_BALANCE,
+		}
+	}
+	state, _, err := spec.GenesisFromEth1(Root{0x42}, spec.MIN_GENESIS_TIME, deps, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	entry, err := HotEntryFromState(spec, state)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return entry
+}
+
+func proposeOn(t *testing.T, uc *UnfinalizedChain, parent ChainEntry, slot Slot, graffiti Root) Root {
+	return proposeWith(t, uc, parent, slot, graffiti, nil)
+}
+
+// proposeWith adds a block on top of the parent entry, including the pending attestations.
+func proposeWith(t *testing.T, uc *UnfinalizedChain, parent ChainEntry, slot Slot, graffiti Root, pending []beacon.Attestation) Root {
+	ctx := context.Background()
+	epc, err := parent.EpochsContext(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	state, err := parent.State(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	block, err := interop.ProposeBlock(ctx, uc.Spec, epc, state, parent.BlockRoot(), slot, graffiti, pending)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := uc.AddBlock(ctx, block); err != nil {
+		t.Fatal(err)
+	}
+	return block.Message.HashTreeRoot(uc.Spec, tree.GetHashFn())
+}
+
+func TestUnfinalizedChainAnchor(t *testing.T) {
+	spec := configs.Minimal
+	anchor := interopGenesis(t, spec, spec.MIN_GENESIS_ACTIVE_VALIDATOR_COUNT)
+	sink := BlockSinkFn(func(entry *HotEntry, canonical bool) error { return nil })
+	uc, err := NewUnfinalizedChain(anchor, sink, spec)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The genesis checkpoints have a zero root, the fork-choice starts from the anchor block instead.
+	if root := uc.Justified().Root; root != anchor.BlockRoot() {
+		t.Fatalf("expected justified root to be the anchor %s, got %s", anchor.BlockRoot(), root)
+	}
+	head, err := uc.Head()
+	if err != nil {
+		t.Fatalf("no head with only the anchor: %v", err)
+	}
+	if head.BlockRoot() != anchor.BlockRoot() {
+		t.Fatalf("expected anchor as head, got %s", head.BlockRoot())
+	}
+
+	// The first block needs the anchor as parent in the fork-choice.
+	a := proposeOn(t, uc, anchor, 1, Root{})
+	head, err = uc.Head()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if head.BlockRoot() != a {
+		t.Fatalf("expected head %s, got %s", a, head.BlockRoot())
+	}
+}
+
+func TestHotEntriesKeepEpochsContext(t *testing.T) {
+	spec := configs.Minimal
+	anchor := interopGenesis(t, spec, spec.MIN_GENESIS_ACTIVE_VALIDATOR_COUNT)
+	sink := BlockSinkFn(func(entry *HotEntry, canonical bool) error { return nil })
+	uc, err := NewUnfinalizedChain(anchor, sink, spec)
+	if err != nil {
+		t.Fatal(err)
+	}
+	a := proposeOn(t, uc, anchor, 1, Root{})
+	entryA, err := uc.ByBlockRoot(a)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Building on top of the new block, with an empty slot in between, needs the epochs-context of its entry.
+	b := proposeOn(t, uc, entryA, 3, Root{})
+	for _, key := range []BlockSlotKey{NewBlockSlotKey(a, 1), NewBlockSlotKey(a, 2), NewBlockSlotKey(b, 3)} {
+		entry, err := uc.ByBlockSlot(key)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if entry.(*HotEntry).epc == nil {
+			t.Fatalf("entry at slot %d has no epochs-context", key.Slot())
+		}
+	}
+	head, err := uc.Head()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if head.BlockRoot() != b {
+		t.Fatalf("expected head %s, got %s", b, head.BlockRoot())
+	}
+	// Attestations to the block need the epochs-context for its committees.
+	entryB, err := uc.ByBlockRoot(b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	epc, err := entryB.EpochsContext(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	state, err := entryB.State(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	atts, err := interop.Attestations(spec, epc, state, b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := range atts {
+		if err := uc.AddAttestation(&atts[i]); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestEmptySlotEntries(t *testing.T) {
+	spec := configs.Minimal
+	anchor := interopGenesis(t, spec, spec.MIN_GENESIS_ACTIVE_VALIDATOR_COUNT)
+	sink := BlockSinkFn(func(entry *HotEntry, canonical bool) error { return nil })
+	uc, err := NewUnfinalizedChain(anchor, sink, spec)
+	if err != nil {
+		t.Fatal(err)
+	}
+	a := proposeOn(t, uc, anchor, 1, Root{})
+	entryA, err := uc.ByBlockRoot(a)
+	if err != nil {
+		t.Fatal(err)
+	}
+	proposeOn(t, uc, entryA, 4, Root{})
+	// The skipped slots are entries of the parent block, at their own slot, with the state of that slot.
+	for _, slot := range []Slot{2, 3} {
+		entry, err := uc.ByBlockSlot(NewBlockSlotKey(a, slot))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if entry.Slot() != slot || entry.BlockRoot() != a || !entry.IsEmpty() {
+			t.Fatalf("expected empty entry of block %s at slot %d, got block %s at slot %d (empty: %v)",
+				a, slot, entry.BlockRoot(), entry.Slot(), entry.IsEmpty())
+		}
+		state, err := entry.State(context.Background())
+		if err != nil {
+			t.Fatal(err)
+		}
+		if stateSlot, err := state.Slot(); err != nil || stateSlot != slot {
+			t.Fatalf("expected state of slot %d, got %d (err: %v)", slot, stateSlot, err)
+		}
+	}
+	// A fork of the same parent, at a skipped slot, builds on the empty slot before it.
+	c := proposeOn(t, uc, entryA, 3, Root{'c'})
+	if entry, err := uc.ByBlockRoot(c); err != nil || entry.Slot() != 3 {
+		t.Fatalf("expected fork block at slot 3, got %v (err: %v)", entry, err)
+	}
+}
+
+// competingBlocks starts a chain with two blocks in epoch 1 on top of the genesis anchor,
+// the fork-choice ignores votes with target epoch 0.
+func competingBlocks(t *testing.T) (uc *UnfinalizedChain, low Root, high Root) {
+	spec := configs.Minimal
+	anchor := interopGenesis(t, spec, spec.MIN_GENESIS_ACTIVE_VALIDATOR_COUNT)
+	sink := BlockSinkFn(func(entry *HotEntry, canonical bool) error { return nil })
+	uc, err := NewUnfinalizedChain(anchor, sink, spec)
+	if err != nil {
+		t.Fatal(err)
+	}
+	slot := spec.SLOTS_PER_EPOCH + 1
+	low = proposeOn(t, uc, anchor, slot, Root{'x'})
+	high = proposeOn(t, uc, anchor, slot, Root{'y'})
+	if bytes.Compare(low[:], high[:]) > 0 {
+		low, high = high, low
+	}
+	return uc, low, high
+}
+
+// attestTo adds attestations of all committees at the slot of the block, voting for it.
+func attestTo(t *testing.T, uc *UnfinalizedChain, root Root) {
+	entry, err := uc.ByBlockRoot(root)
+	if err != nil {
+		t.Fatal(err)
+	}
+	epc, err := entry.EpochsContext(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	state, err := entry.State(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	atts, err := interop.Attestations(uc.Spec, epc, state, root)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := range atts {
+		if err := uc.AddAttestation(&atts[i]); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestAddAttestationMovesHead(t *testing.T) {
+	uc, low, high := competingBlocks(t)
+	head, err := uc.Head()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if head.BlockRoot() != high {
+		t.Fatalf("expected tie-break on highest root %s without votes, got %s", high, head.BlockRoot())
+	}
+	attestTo(t, uc, low)
+	head, err = uc.Head()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if head.BlockRoot() != low {
+		t.Fatalf("expected voted block %s as head, got %s", low, head.BlockRoot())
+	}
+}
+
+// finalizingChain builds a block at every slot up to the given slot on top of the genesis anchor,
+// each including the attestations of all validators to the block before it.
+func finalizingChain(t *testing.T, uc *UnfinalizedChain, anchor *HotEntry, slot Slot) (head Root) {
+	var parent ChainEntry = anchor
+	for s := Slot(1); s <= slot; s++ {
+		epc, err := parent.EpochsContext(context.Background())
+		if err != nil {
+			t.Fatal(err)
+		}
+		state, err := parent.State(context.Background())
+		if err != nil {
+			t.Fatal(err)
+		}
+		atts, err := interop.Attestations(uc.Spec, epc, state, parent.BlockRoot())
+		if err != nil {
+			t.Fatal(err)
+		}
+		head = proposeWith(t, uc, parent, s, Root{}, atts)
+		if parent, err = uc.ByBlockRoot(head); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return head
+}
+
+func TestForkChoiceCheckpointsFromBlocks(t *testing.T) {
+	spec := configs.Minimal
+	anchor := interopGenesis(t, spec, spec.MIN_GENESIS_ACTIVE_VALIDATOR_COUNT)
+	sink := BlockSinkFn(func(entry *HotEntry, canonical bool) error { return nil })
+	uc, err := NewUnfinalizedChain(anchor, sink, spec)
+	if err != nil {
+		t.Fatal(err)
+	}
+	last := finalizingChain(t, uc, anchor, 4*spec.SLOTS_PER_EPOCH+1)
+	entry, err := uc.ByBlockRoot(last)
+	if err != nil {
+		t.Fatal(err)
+	}
+	state, err := entry.State(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	stateJustified, stateFinalized, err := stateCheckpoints(state)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stateFinalized.Epoch == 0 {
+		t.Fatal("expected the chain to finalize")
+	}
+	// The fork-choice follows the checkpoints of the post-states.
+	if justified := uc.Justified(); justified != stateJustified {
+		t.Fatalf("expected fork-choice justified checkpoint %v, got %v", stateJustified, justified)
+	}
+	if finalized := uc.Finalized(); finalized != stateFinalized {
+		t.Fatalf("expected fork-choice finalized checkpoint %v, got %v", stateFinalized, finalized)
+	}
+	head, err := uc.Head()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if head.BlockRoot() != last {
+		t.Fatalf("expected head %s, got %s", last, head.BlockRoot())
+	}
+}
+
+func TestPrunedEvents(t *testing.T) {
+	spec := configs.Minimal
+	anchor := interopGenesis(t, spec, spec.MIN_GENESIS_ACTIVE_VALIDATOR_COUNT)
+	var sunk []*HotEntry
+	sink := BlockSinkFn(func(entry *HotEntry, canonical bool) error {
+		if canonical {
+			sunk = append(sunk, entry)
+		}
+		return nil
+	})
+	uc, err := NewUnfinalizedChain(anchor, sink, spec)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var pruned []*ChainEvent
+	uc.SubscribeEvents(func(ev *ChainEvent) {
+		if ev.Kind == PrunedEvent {
+			pruned = append(pruned, ev)
+		}
+	})
+	// A branch without votes, which conflicts with the finalized chain
+	a := proposeOn(t, uc, anchor, 1, Root{'a'})
+	entryA, err := uc.ByBlockRoot(a)
+	if err != nil {
+		t.Fatal(err)
+	}
+	b := proposeOn(t, uc, entryA, 3, Root{'b'})
+	finalizingChain(t, uc, anchor, 4*spec.SLOTS_PER_EPOCH+1)
+
+	finalized, ok := uc.ForkChoice.GetBlock(uc.Finalized().Root)
+	if !ok || finalized.Slot == 0 {
+		t.Fatalf("expected a finalized block, got %v", uc.Finalized())
+	}
+	// Every slot before the finalized block is sunk, from oldest to newest
+	if Slot(len(sunk)) != finalized.Slot {
+		t.Fatalf("expected %d sunk entries, got %d", finalized.Slot, len(sunk))
+	}
+	for i, entry := range sunk {
+		if entry.Slot() != Slot(i) {
+			t.Fatalf("expected sunk entry %d at slot %d, got slot %d", i, i, entry.Slot())
+		}
+	}
+	// The canonical blocks are pruned first, then the conflicting blocks
+	if len(pruned) != len(sunk)+2 {
+		t.Fatalf("expected %d pruned events, got %d", len(sunk)+2, len(pruned))
+	}
+	for i, entry := range sunk {
+		if ev := pruned[i]; !ev.Canonical || ev.Slot != entry.Slot() || ev.Root != entry.BlockRoot() {
+			t.Fatalf("expected canonical pruned event of block %s at slot %d, got %v", entry.BlockRoot(), entry.Slot(), ev)
+		}
+	}
+	for i, root := range []Root{a, b} {
+		if ev := pruned[len(sunk)+i]; ev.Canonical || ev.Root != root {
+			t.Fatalf("expected non-canonical pruned event of block %s, got %v", root, ev)
+		}
+	}
+	if _, err := uc.ByBlockRoot(b); err == nil {
+		t.Fatal("expected conflicting block to be removed")
+	}
+}
@@ -0,0 +1,56 @@
+// Package interop implements the deterministic validator keys of interop genesis states,
+// and signs blocks and attestations with them, to progress local devnets.
+package interop
+
+import (
+	"crypto/sha256"
+	"encoding/binary"
+	"fmt"
+	hbls "github.com/herumi/bls-eth-go-binary/bls"
+	"github.com/protolambda/zrnt/eth2/beacon"
+	"sync"
+)
+
+// validator index -> *hbls.SecretKey
+var secretKeys sync.Map
+
+// SecretKey derives the interop secret key of the validator:
+// the little-endian sha256 of the 32 byte little-endian index, modulo the curve order.
+func SecretKey(index beacon.ValidatorIndex) *hbls.SecretKey {
+	if sk, ok := secretKeys.Load(index); ok {
+		return sk.(*hbls.SecretKey)
+	}
+	var buf [32]byte
+	binary.LittleEndian.PutUint64(buf[:8], uint64(index))
+	h := sha256.Sum256(buf[:])
+	var sk hbls.SecretKey
+	if err := sk.SetLittleEndianMod(h[:]); err != nil {
+		// only fails if the BLS library is not initialized
+		panic(fmt.Errorf("failed to derive interop key %d: %v", index, err))
+	}
+	secretKeys.Store(index, &sk)
+	return &sk
+}
+
+// Pubkey returns the compressed interop pubkey of the validator.
+func Pubkey(index beacon.ValidatorIndex) (out beacon.BLSPubkey) {
+	copy(out[:], SecretKey(index).GetPublicKey().Serialize())
+	return out
+}
+
+// CheckKey returns an error if the validator in the epochs-context does not have the interop pubkey.
+func CheckKey(epc *beacon.EpochsContext, index beacon.ValidatorIndex) error {
+	pub, ok := epc.PubkeyCache.Pubkey(index)
+	if !ok {
+		return fmt.Errorf("unknown validator %d", index)
+	}
+	if pub.Compressed != Pubkey(index) {
+		return fmt.Errorf("validator %d does not have the interop pubkey, is the chain an interop genesis?", index)
+	}
+	return nil
+}
+
+func sign(index beacon.ValidatorIndex, signingRoot beacon.Root) (out beacon.BLSSignature) {
+	copy(out[:], SecretKey(index).SignByte(signingRoot[:]).Serialize())
+	return out
+}
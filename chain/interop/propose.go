@@ -0,0 +1,164 @@
+package interop
+
+import (
+	"context"
+	"fmt"
+	hbls "github.com/herumi/bls-eth-go-binary/bls"
+	"github.com/protolambda/zrnt/eth2/beacon"
+	"github.com/protolambda/ztyp/tree"
+	"github.com/protolambda/ztyp/view"
+)
+
+// ProposeBlock builds a block at the given slot on top of the parent block and its post-state, signed with the interop key of the proposer.
+// The pending attestations are included in order, up to the block limit, skipping those that are not valid for the block.
+// The state and epochs-context are processed to the slot of the block, the caller should pass copies.
+func ProposeBlock(ctx context.Context, spec *beacon.Spec, epc *beacon.EpochsContext, state *beacon.BeaconStateView,
+	parentRoot beacon.Root, slot beacon.Slot, graffiti beacon.Root, pending []beacon.Attestation) (*beacon.SignedBeaconBlock, error) {
+	preSlot, err := state.Slot()
+	if err != nil {
+		return nil, err
+	}
+	if slot <= preSlot {
+		return nil, fmt.Errorf("cannot propose at slot %d, parent state is already at slot %d", slot, preSlot)
+	}
+	if err := spec.ProcessSlots(ctx, epc, state, slot); err != nil {
+		return nil, fmt.Errorf("failed to process slots: %v", err)
+	}
+	proposer, err := epc.GetBeaconProposer(slot)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get proposer: %v", err)
+	}
+	if err := CheckKey(epc, proposer); err != nil {
+		return nil, err
+	}
+	h := tree.GetHashFn()
+	epoch := spec.SlotToEpoch(slot)
+
+	randaoDom, err := state.GetDomain(spec.DOMAIN_RANDAO, epoch)
+	if err != nil {
+		return nil, err
+	}
+	block := &beacon.BeaconBlock{
+		Slot:          slot,
+		ProposerIndex: proposer,
+		ParentRoot:    parentRoot,
+	}
+	body := &block.Body
+	body.RandaoReveal = sign(proposer, beacon.ComputeSigningRoot(epoch.HashTreeRoot(h), randaoDom))
+	body.Graffiti = graffiti
+
+	// No eth1 connection, vote for the current eth1 data
+	eth1Data, err := state.Eth1Data()
+	if err != nil {
+		return nil, err
+	}
+	if body.Eth1Data.DepositRoot, err = eth1Data.DepositRoot(); err != nil {
+		return nil, err
+	}
+	if body.Eth1Data.DepositCount, err = eth1Data.DepositCount(); err != nil {
+		return nil, err
+	}
+	if body.Eth1Data.BlockHash, err = view.AsRoot(eth1Data.Get(2)); err != nil {
+		return nil, err
+	}
+
+	// Each included attestation is processed, for later attestations to be checked against
+	work, err := beacon.AsBeaconStateView(state.Copy())
+	if err != nil {
+		return nil, err
+	}
+	for i := range pending {
+		if uint64(len(body.Attestations)) >= spec.MAX_ATTESTATIONS {
+			break
+		}
+		if err := spec.ProcessAttestation(epc, work, &pending[i]); err != nil {
+			continue
+		}
+		body.Attestations = append(body.Attestations, pending[i])
+	}
+
+	post, err := beacon.AsBeaconStateView(state.Copy())
+	if err != nil {
+		return nil, err
+	}
+	if err := spec.ProcessBlock(ctx, epc, post, block); err != nil {
+		return nil, fmt.Errorf("failed to process built block: %v", err)
+	}
+	block.StateRoot = post.HashTreeRoot(h)
+
+	proposerDom, err := state.GetDomain(spec.DOMAIN_BEACON_PROPOSER, epoch)
+	if err != nil {
+		return nil, err
+	}
+	return &beacon.SignedBeaconBlock{
+		Message:   *block,
+		Signature: sign(proposer, beacon.ComputeSigningRoot(block.HashTreeRoot(spec, h), proposerDom)),
+	}, nil
+}
+
+// Attestations creates an attestation for each committee at the slot of the state, aggregated over all its members,
+// with the interop keys. The state must be processed to the slot, and the head block must be its latest block.
+func Attestations(spec *beacon.Spec, epc *beacon.EpochsContext, state *beacon.BeaconStateView,
+	headRoot beacon.Root) ([]beacon.Attestation, error) {
+	slot, err := state.Slot()
+	if err != nil {
+		return nil, err
+	}
+	epoch := spec.SlotToEpoch(slot)
+	target := beacon.Checkpoint{Epoch: epoch, Root: headRoot}
+	if start := spec.EpochStartSlot(epoch); start < slot {
+		blockRoots, err := state.BlockRoots()
+		if err != nil {
+			return nil, err
+		}
+		if target.Root, err = blockRoots.GetRoot(start); err != nil {
+			return nil, err
+		}
+	}
+	justified, err := state.CurrentJustifiedCheckpoint()
+	if err != nil {
+		return nil, err
+	}
+	source, err := justified.Raw()
+	if err != nil {
+		return nil, err
+	}
+	dom, err := state.GetDomain(spec.DOMAIN_BEACON_ATTESTER, epoch)
+	if err != nil {
+		return nil, err
+	}
+	count, err := epc.GetCommitteeCountAtSlot(slot)
+	if err != nil {
+		return nil, err
+	}
+	h := tree.GetHashFn()
+	out := make([]beacon.Attestation, 0, count)
+	for index := beacon.CommitteeIndex(0); uint64(index) < count; index++ {
+		committee, err := epc.GetBeaconCommittee(slot, index)
+		if err != nil {
+			return nil, err
+		}
+		data := beacon.AttestationData{
+			Slot:            slot,
+			Index:           index,
+			BeaconBlockRoot: headRoot,
+			Source:          source,
+			Target:          target,
+		}
+		signingRoot := beacon.ComputeSigningRoot(data.HashTreeRoot(h), dom)
+		// bitlist, with a delimiter bit after the last committee member
+		bits := make(beacon.CommitteeBits, len(committee)/8+1)
+		sigs := make([]hbls.Sign, len(committee))
+		for i, member := range committee {
+			bits.SetBit(uint64(i), true)
+			sigs[i] = *SecretKey(member).SignByte(signingRoot[:])
+		}
+		bits.SetBit(uint64(len(committee)), true)
+		var agg hbls.Sign
+		agg.Aggregate(sigs)
+		att := beacon.Attestation{AggregationBits: bits, Data: data}
+		copy(att.Signature[:], agg.Serialize())
+		out = append(out, att)
+	}
+	return out, nil
+}
@@ -0,0 +1,92 @@
+package chain
+
+import (
+	"bytes"
+	"github.com/protolambda/zrnt/eth2/beacon"
+	"sort"
+	"sync"
+)
+
+// AttestationPool keeps the attestations that were added to the chain, to include them in new blocks.
+type AttestationPool struct {
+	sync.Mutex
+	bySlot map[Slot][]beacon.Attestation
+}
+
+func NewAttestationPool() *AttestationPool {
+	return &AttestationPool{bySlot: make(map[Slot][]beacon.Attestation)}
+}
+
+// Add keeps a copy of the attestation, duplicates are ignored.
+func (p *AttestationPool) Add(att *beacon.Attestation) {
+	p.Lock()
+	defer p.Unlock()
+	slot := att.Data.Slot
+	for i := range p.bySlot[slot] {
+		prev := &p.bySlot[slot][i]
+		if prev.Data == att.Data && bytes.Equal(prev.AggregationBits, att.AggregationBits) {
+			return
+		}
+	}
+	cpy := *att
+	cpy.AggregationBits = append(beacon.CommitteeBits(nil), att.AggregationBits...)
+	p.bySlot[slot] = append(p.bySlot[slot], cpy)
+}
+
+// Remove drops the given attestations from the pool, e.g. when they are included in a block.
+func (p *AttestationPool) Remove(atts []beacon.Attestation) {
+	p.Lock()
+	defer p.Unlock()
+	for i := range atts {
+		att := &atts[i]
+		slot := att.Data.Slot
+		kept := p.bySlot[slot][:0]
+		for _, prev := range p.bySlot[slot] {
+			if prev.Data != att.Data || !bytes.Equal(prev.AggregationBits, att.AggregationBits) {
+				kept = append(kept, prev)
+			}
+		}
+		if len(kept) == 0 {
+			delete(p.bySlot, slot)
+		} else {
+			p.bySlot[slot] = kept
+		}
+	}
+}
+
+// Includable lists the attestations that may be included in a block at the given slot, oldest first.
+// The attestations are not validated against any state.
+func (p *AttestationPool) Includable(spec *beacon.Spec, slot Slot) (out []beacon.Attestation) {
+	p.Lock()
+	defer p.Unlock()
+	for attSlot, atts := range p.bySlot {
+		if attSlot+spec.MIN_ATTESTATION_INCLUSION_DELAY <= slot && slot <= attSlot+spec.SLOTS_PER_EPOCH {
+			out = append(out, atts...)
+		}
+	}
+	sort.SliceStable(out, func(i, j int) bool {
+		return out[i].Data.Slot < out[j].Data.Slot
+	})
+	return out
+}
+
+// Prune removes the attestations of slots before the given slot.
+func (p *AttestationPool) Prune(before Slot) {
+	p.Lock()
+	defer p.Unlock()
+	for slot := range p.bySlot {
+		if slot < before {
+			delete(p.bySlot, slot)
+		}
+	}
+}
+
+// Len counts the attestations in the pool.
+func (p *AttestationPool) Len() (count int) {
+	p.Lock()
+	defer p.Unlock()
+	for _, atts := range p.bySlot {
+		count += len(atts)
+	}
+	return count
+}
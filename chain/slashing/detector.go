@@ -0,0 +1,212 @@
+// Package slashing detects conflicting block proposals and attestations, and builds the slashings that prove them.
+package slashing
+
+import (
+	"github.com/protolambda/zrnt/eth2/beacon"
+	"github.com/protolambda/ztyp/tree"
+	"sort"
+	"sync"
+)
+
+type proposerSlot struct {
+	proposer beacon.ValidatorIndex
+	slot     beacon.Slot
+}
+
+// conflict is a pair of roots of conflicting messages, ordered by root
+type conflict [2]beacon.Root
+
+func newConflict(a beacon.Root, b beacon.Root) conflict {
+	if string(a[:]) > string(b[:]) {
+		a, b = b, a
+	}
+	return conflict{a, b}
+}
+
+type vote struct {
+	source beacon.Epoch
+	target beacon.Epoch
+	// Root of the attestation data
+	dataRoot beacon.Root
+	att      *beacon.IndexedAttestation
+}
+
+// Detector indexes block headers by proposer and slot, and attestations by validator,
+// to find the conflicting messages that validators can be slashed for.
+type Detector struct {
+	sync.Mutex
+	spec    *beacon.Spec
+	headers map[proposerSlot]beacon.SignedBeaconBlockHeader
+	votes   map[beacon.ValidatorIndex][]*vote
+	// Conflicts that were already reported, and the epoch of the latest message, to prune them with.
+	// Messages may arrive again, e.g. gossip duplicates, or aggregates with the same data.
+	reported map[conflict]beacon.Epoch
+
+	ProposerSlashings []beacon.ProposerSlashing
+	AttesterSlashings []beacon.AttesterSlashing
+}
+
+func NewDetector(spec *beacon.Spec) *Detector {
+	return &Detector{
+		spec:     spec,
+		headers:  make(map[proposerSlot]beacon.SignedBeaconBlockHeader),
+		votes:    make(map[beacon.ValidatorIndex][]*vote),
+		reported: make(map[conflict]beacon.Epoch),
+	}
+}
+
+// OnBlock indexes the header of the block, see OnHeader.
+func (d *Detector) OnBlock(block *beacon.SignedBeaconBlock) *beacon.ProposerSlashing {
+	return d.OnHeader(&beacon.SignedBeaconBlockHeader{
+		Message:   *block.Message.Header(d.spec),
+		Signature: block.Signature,
+	})
+}
+
+// OnHeader indexes the header, and returns a slashing if the proposer signed a different header for the same slot before.
+// The signatures are not verified.
+func (d *Detector) OnHeader(header *beacon.SignedBeaconBlockHeader) *beacon.ProposerSlashing {
+	d.Lock()
+	defer d.Unlock()
+	key := proposerSlot{proposer: header.Message.ProposerIndex, slot: header.Message.Slot}
+	prev, ok := d.headers[key]
+	if !ok {
+		d.headers[key] = *header
+		return nil
+	}
+	if prev.Message == header.Message {
+		return nil
+	}
+	pair := newConflict(prev.Message.HashTreeRoot(tree.GetHashFn()), header.Message.HashTreeRoot(tree.GetHashFn()))
+	if _, ok := d.reported[pair]; ok {
+		return nil
+	}
+	d.reported[pair] = d.spec.SlotToEpoch(header.Message.Slot)
+	slashing := beacon.ProposerSlashing{SignedHeader1: prev, SignedHeader2: *header}
+	d.ProposerSlashings = append(d.ProposerSlashings, slashing)
+	return &slashing
+}
+
+// OnAttestation indexes the votes of the attestation, and returns a slashing for each earlier attestation
+// that has a double vote (same target epoch, different data) or surround vote with it, by any of the same validators.
+// The signatures are not verified.
+func (d *Detector) OnAttestation(att *beacon.IndexedAttestation) (out []beacon.AttesterSlashing) {
+	d.Lock()
+	defer d.Unlock()
+	v := &vote{
+		source:   att.Data.Source.Epoch,
+		target:   att.Data.Target.Epoch,
+		dataRoot: att.Data.HashTreeRoot(tree.GetHashFn()),
+	}
+	// Conflicting earlier votes, by data root
+	conflicts := make(map[beacon.Root]*vote)
+	for _, index := range att.AttestingIndices {
+		known := false
+		for _, prev := range d.votes[index] {
+			if prev.dataRoot == v.dataRoot {
+				known = true
+				continue
+			}
+			if _, ok := d.reported[newConflict(prev.dataRoot, v.dataRoot)]; ok {
+				continue
+			}
+			if isSlashable(prev, v) {
+				conflicts[prev.dataRoot] = prev
+			}
+		}
+		if !known {
+			if v.att == nil {
+				cpy := *att
+				cpy.AttestingIndices = append(beacon.CommitteeIndices(nil), att.AttestingIndices...)
+				v.att = &cpy
+			}
+			d.votes[index] = append(d.votes[index], v)
+		}
+	}
+	if len(conflicts) == 0 {
+		return nil
+	}
+	roots := make([]beacon.Root, 0, len(conflicts))
+	for root := range conflicts {
+		roots = append(roots, root)
+	}
+	sort.Slice(roots, func(i, j int) bool {
+		return string(roots[i][:]) < string(roots[j][:])
+	})
+	for _, root := range roots {
+		prev := conflicts[root]
+		latest := v.target
+		if prev.target > latest {
+			latest = prev.target
+		}
+		d.reported[newConflict(prev.dataRoot, v.dataRoot)] = latest
+		slashing := beacon.AttesterSlashing{Attestation1: *prev.att, Attestation2: *att}
+		d.AttesterSlashings = append(d.AttesterSlashings, slashing)
+		out = append(out, slashing)
+	}
+	return out
+}
+
+func isSlashable(a *vote, b *vote) bool {
+	// double vote
+	if a.target == b.target {
+		return true
+	}
+	// surround vote, either way
+	return (a.source < b.source && b.target < a.target) || (b.source < a.source && a.target < b.target)
+}
+
+// SlashableIndices lists the validators that are slashed by the attester slashing, in ascending order.
+func SlashableIndices(slashing *beacon.AttesterSlashing) (out []beacon.ValidatorIndex) {
+	in2 := make(map[beacon.ValidatorIndex]struct{}, len(slashing.Attestation2.AttestingIndices))
+	for _, index := range slashing.Attestation2.AttestingIndices {
+		in2[index] = struct{}{}
+	}
+	for _, index := range slashing.Attestation1.AttestingIndices {
+		if _, ok := in2[index]; ok {
+			out = append(out, index)
+		}
+	}
+	sort.Slice(out, func(i, j int) bool {
+		return out[i] < out[j]
+	})
+	return out
+}
+
+// Prune forgets the headers of slots before the epoch, and the attestations with a target before the epoch,
+// and the reported conflicts of these. Later conflicts with pruned messages are not detected.
+func (d *Detector) Prune(before beacon.Epoch) {
+	d.Lock()
+	defer d.Unlock()
+	startSlot := d.spec.EpochStartSlot(before)
+	for key := range d.headers {
+		if key.slot < startSlot {
+			delete(d.headers, key)
+		}
+	}
+	for key, epoch := range d.reported {
+		if epoch < before {
+			delete(d.reported, key)
+		}
+	}
+	for index, votes := range d.votes {
+		kept := votes[:0]
+		for _, v := range votes {
+			if v.target >= before {
+				kept = append(kept, v)
+			}
+		}
+		if len(kept) == 0 {
+			delete(d.votes, index)
+		} else {
+			d.votes[index] = kept
+		}
+	}
+}
+
+// Stats counts the indexed headers, the validators with indexed votes, and the detected slashings.
+func (d *Detector) Stats() (headers int, voters int, proposerSlashings int, attesterSlashings int) {
+	d.Lock()
+	defer d.Unlock()
+	return len(d.headers), len(d.votes), len(d.ProposerSlashings), len(d.AttesterSlashings)
+}
@@ -0,0 +1,184 @@
+package slashing
+
+import (
+	"github.com/protolambda/zrnt/eth2/beacon"
+	"github.com/protolambda/zrnt/eth2/configs"
+	"testing"
+)
+
+func testAttestation(source beacon.Epoch, target beacon.Epoch, root byte, indices ...beacon.ValidatorIndex) *beacon.IndexedAttestation {
+	return &beacon.IndexedAttestation{
+		AttestingIndices: indices,
+		Data: beacon.AttestationData{
+			BeaconBlockRoot: beacon.Root{root},
+			Source:          beacon.Checkpoint{Epoch: source},
+			Target:          beacon.Checkpoint{Epoch: target},
+		},
+	}
+}
+
+func TestOnAttestation(t *testing.T) {
+	cases := []struct {
+		name string
+		// attestations are added in order
+		atts []*beacon.IndexedAttestation
+		// number of slashings returned for each attestation
+		slashings []int
+		// slashable indices of the last slashing
+		slashable []beacon.ValidatorIndex
+	}{
+		{
+			name:      "double vote",
+			atts:      []*beacon.IndexedAttestation{testAttestation(0, 1, 'a', 1, 2), testAttestation(0, 1, 'b', 2, 3)},
+			slashings: []int{0, 1},
+			slashable: []beacon.ValidatorIndex{2},
+		},
+		{
+			name:      "double vote, other validators",
+			atts:      []*beacon.IndexedAttestation{testAttestation(0, 1, 'a', 1, 2), testAttestation(0, 1, 'b', 3, 4)},
+			slashings: []int{0, 0},
+		},
+		{
+			name:      "same data",
+			atts:      []*beacon.IndexedAttestation{testAttestation(0, 1, 'a', 1, 2), testAttestation(0, 1, 'a', 1, 2)},
+			slashings: []int{0, 0},
+		},
+		{
+			name:      "surrounding vote",
+			atts:      []*beacon.IndexedAttestation{testAttestation(2, 3, 'a', 1), testAttestation(1, 4, 'b', 1)},
+			slashings: []int{0, 1},
+			slashable: []beacon.ValidatorIndex{1},
+		},
+		{
+			name:      "surrounded vote",
+			atts:      []*beacon.IndexedAttestation{testAttestation(1, 4, 'a', 1), testAttestation(2, 3, 'b', 1)},
+			slashings: []int{0, 1},
+			slashable: []beacon.ValidatorIndex{1},
+		},
+		{
+			name:      "same source, not surrounding",
+			atts:      []*beacon.IndexedAttestation{testAttestation(1, 3, 'a', 1), testAttestation(1, 4, 'b', 1)},
+			slashings: []int{0, 0},
+		},
+		{
+			name:      "consecutive votes",
+			atts:      []*beacon.IndexedAttestation{testAttestation(1, 2, 'a', 1), testAttestation(2, 3, 'b', 1)},
+			slashings: []int{0, 0},
+		},
+		{
+			name: "conflicts with multiple earlier votes",
+			atts: []*beacon.IndexedAttestation{
+				testAttestation(0, 1, 'a', 1), testAttestation(0, 1, 'b', 2), testAttestation(0, 1, 'c', 1, 2)},
+			slashings: []int{0, 0, 2},
+			slashable: []beacon.ValidatorIndex{2},
+		},
+		{
+			name: "duplicate is reported once",
+			atts: []*beacon.IndexedAttestation{
+				testAttestation(0, 1, 'a', 1), testAttestation(0, 1, 'b', 1), testAttestation(0, 1, 'b', 1)},
+			slashings: []int{0, 1, 0},
+		},
+		{
+			name: "aggregate with the same data is reported once",
+			atts: []*beacon.IndexedAttestation{
+				testAttestation(0, 1, 'a', 1), testAttestation(0, 1, 'b', 1, 2), testAttestation(0, 1, 'b', 1, 3)},
+			slashings: []int{0, 1, 0},
+		},
+		{
+			name: "earlier vote again is reported once",
+			atts: []*beacon.IndexedAttestation{
+				testAttestation(0, 1, 'a', 1), testAttestation(0, 1, 'b', 1), testAttestation(0, 1, 'a', 1, 2)},
+			slashings: []int{0, 1, 0},
+		},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			d := NewDetector(configs.Minimal)
+			var last []beacon.AttesterSlashing
+			total := 0
+			for i, att := range c.atts {
+				out := d.OnAttestation(att)
+				if len(out) != c.slashings[i] {
+					t.Fatalf("attestation %d: expected %d slashings, got %d", i, c.slashings[i], len(out))
+				}
+				if len(out) > 0 {
+					last = out
+				}
+				total += len(out)
+			}
+			if len(d.AttesterSlashings) != total {
+				t.Fatalf("expected %d recorded slashings, got %d", total, len(d.AttesterSlashings))
+			}
+			if c.slashable != nil {
+				got := SlashableIndices(&last[len(last)-1])
+				if len(got) != len(c.slashable) {
+					t.Fatalf("expected slashable indices %v, got %v", c.slashable, got)
+				}
+				for i := range got {
+					if got[i] != c.slashable[i] {
+						t.Fatalf("expected slashable indices %v, got %v", c.slashable, got)
+					}
+				}
+			}
+		})
+	}
+}
+
+func testHeader(proposer beacon.ValidatorIndex, slot beacon.Slot, body byte) *beacon.SignedBeaconBlockHeader {
+	return &beacon.SignedBeaconBlockHeader{
+		Message: beacon.BeaconBlockHeader{Slot: slot, ProposerIndex: proposer, BodyRoot: beacon.Root{body}},
+	}
+}
+
+func TestOnHeader(t *testing.T) {
+	cases := []struct {
+		name      string
+		headers   []*beacon.SignedBeaconBlockHeader
+		slashings []bool
+	}{
+		{"same header", []*beacon.SignedBeaconBlockHeader{testHeader(1, 5, 'a'), testHeader(1, 5, 'a')}, []bool{false, false}},
+		{"double proposal", []*beacon.SignedBeaconBlockHeader{testHeader(1, 5, 'a'), testHeader(1, 5, 'b')}, []bool{false, true}},
+		{"other slot", []*beacon.SignedBeaconBlockHeader{testHeader(1, 5, 'a'), testHeader(1, 6, 'b')}, []bool{false, false}},
+		{"other proposer", []*beacon.SignedBeaconBlockHeader{testHeader(1, 5, 'a'), testHeader(2, 5, 'b')}, []bool{false, false}},
+		{"duplicate is reported once", []*beacon.SignedBeaconBlockHeader{
+			testHeader(1, 5, 'a'), testHeader(1, 5, 'b'), testHeader(1, 5, 'b')}, []bool{false, true, false}},
+		{"third header", []*beacon.SignedBeaconBlockHeader{
+			testHeader(1, 5, 'a'), testHeader(1, 5, 'b'), testHeader(1, 5, 'c')}, []bool{false, true, true}},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			d := NewDetector(configs.Minimal)
+			for i, h := range c.headers {
+				s := d.OnHeader(h)
+				if (s != nil) != c.slashings[i] {
+					t.Fatalf("header %d: expected slashing: %v, got %v", i, c.slashings[i], s)
+				}
+				if s != nil && (s.SignedHeader1.Message == s.SignedHeader2.Message ||
+					s.SignedHeader1.Message.Slot != s.SignedHeader2.Message.Slot ||
+					s.SignedHeader1.Message.ProposerIndex != s.SignedHeader2.Message.ProposerIndex) {
+					t.Fatalf("header %d: slashing is not a valid proof: %v", i, s)
+				}
+			}
+		})
+	}
+}
+
+func TestPrune(t *testing.T) {
+	d := NewDetector(configs.Minimal)
+	d.OnAttestation(testAttestation(0, 1, 'a', 1))
+	d.OnAttestation(testAttestation(0, 3, 'b', 2))
+	d.OnHeader(testHeader(1, 1, 'a'))
+	d.OnHeader(testHeader(1, configs.Minimal.EpochStartSlot(3), 'a'))
+	d.Prune(2)
+	headers, voters, _, _ := d.Stats()
+	if headers != 1 || voters != 1 {
+		t.Fatalf("expected 1 header and 1 voter after pruning, got %d and %d", headers, voters)
+	}
+	// The pruned vote is forgotten, a conflicting vote is not detected
+	if out := d.OnAttestation(testAttestation(0, 1, 'c', 1)); len(out) != 0 {
+		t.Fatalf("expected no slashing with a pruned vote, got %d", len(out))
+	}
+	if out := d.OnAttestation(testAttestation(0, 3, 'c', 2)); len(out) != 1 {
+		t.Fatalf("expected a slashing with a kept vote, got %d", len(out))
+	}
+}
@@ -0,0 +1,235 @@
+package sszpath
+
+import (
+	"errors"
+	"fmt"
+	"github.com/protolambda/ztyp/tree"
+	"github.com/protolambda/ztyp/view"
+	"strconv"
+)
+
+// Difference is a node that differs between two trees of the same type.
+// A or B is nil if the node only exists in the other tree, e.g. when lists have different lengths.
+type Difference struct {
+	Path string      `json:"path"`
+	A    interface{} `json:"a"`
+	B    interface{} `json:"b"`
+}
+
+var errDiffLimit = errors.New("difference limit reached")
+
+type differ struct {
+	h     tree.HashFn
+	out   []Difference
+	limit int
+}
+
+// Diff compares two trees of the given type, and returns the paths of the most specific differing nodes, with their values.
+// Subtrees with equal roots are skipped. At most limit differences are returned (no limit if 0),
+// truncated is true if there were more.
+func Diff(typ view.TypeDef, a tree.Node, b tree.Node, limit int) (diffs []Difference, truncated bool, err error) {
+	d := &differ{h: tree.GetHashFn(), limit: limit}
+	if err := d.diff("", typ, a, b); err == errDiffLimit {
+		return d.out, true, nil
+	} else if err != nil {
+		return d.out, false, err
+	}
+	return d.out, false, nil
+}
+
+func (d *differ) add(path string, typ view.TypeDef, a tree.Node, b tree.Node, packedIndex int) error {
+	if d.limit > 0 && len(d.out) >= d.limit {
+		return errDiffLimit
+	}
+	if path == "" {
+		path = "/"
+	}
+	diff := Difference{Path: path}
+	if a != nil {
+		r := Result{Path: path, Type: typ, Node: a, PackedIndex: packedIndex}
+		v, err := r.Value()
+		if err != nil {
+			return fmt.Errorf("failed to get value of %s in A: %v", path, err)
+		}
+		diff.A = v
+	}
+	if b != nil {
+		r := Result{Path: path, Type: typ, Node: b, PackedIndex: packedIndex}
+		v, err := r.Value()
+		if err != nil {
+			return fmt.Errorf("failed to get value of %s in B: %v", path, err)
+		}
+		diff.B = v
+	}
+	d.out = append(d.out, diff)
+	return nil
+}
+
+func (d *differ) diff(path string, typ view.TypeDef, a tree.Node, b tree.Node) error {
+	if a.MerkleRoot(d.h) == b.MerkleRoot(d.h) {
+		return nil
+	}
+	switch t := typ.(type) {
+	case *view.ContainerTypeDef:
+		depth := tree.CoverDepth(uint64(len(t.Fields)))
+		for i, f := range t.Fields {
+			g := tree.Gindex64((uint64(1) << depth) | uint64(i))
+			fa, err := a.Getter(g)
+			if err != nil {
+				return err
+			}
+			fb, err := b.Getter(g)
+			if err != nil {
+				return err
+			}
+			if err := d.diff(path+"/"+f.Name, f.Type, fa, fb); err != nil {
+				return err
+			}
+		}
+		return nil
+	case *view.ComplexVectorTypeDef:
+		return d.walk(tree.CoverDepth(t.Length()), a, b, 0, t.Length(), func(i uint64, ea tree.Node, eb tree.Node) error {
+			return d.diff(path+"/"+strconv.FormatUint(i, 10), t.ElementType(), ea, eb)
+		})
+	case *view.ComplexListTypeDef:
+		return d.diffList(path, a, b, tree.CoverDepth(t.Limit()), 1, t.ElementType(), t.ElementType())
+	case *view.BasicVectorTypeDef:
+		elemSize := t.ElementType().TypeByteLength()
+		chunks := (t.Length()*elemSize + 31) / 32
+		return d.walk(tree.CoverDepth(chunks), a, b, 0, chunks, d.chunkDiff(path, t.ElementType(), t.Length()))
+	case *view.BasicListTypeDef:
+		elemSize := t.ElementType().TypeByteLength()
+		return d.diffList(path, a, b, tree.CoverDepth((t.Limit()*elemSize+31)/32), 32/elemSize, t.ElementType(), nil)
+	default:
+		return d.add(path, typ, a, b, -1)
+	}
+}
+
+// diffList compares the length and contents of two lists. Elements are packed perChunk per content chunk.
+// complexElem is nil if the elements are basic.
+func (d *differ) diffList(path string, a tree.Node, b tree.Node, depth uint8, perChunk uint64,
+	elemType view.TypeDef, complexElem view.TypeDef) error {
+	lenA, err := listLength(a)
+	if err != nil {
+		return err
+	}
+	lenB, err := listLength(b)
+	if err != nil {
+		return err
+	}
+	if lenA != lenB {
+		if err := d.add(path+"/len", view.Uint64Type, view.Uint64View(lenA).Backing(), view.Uint64View(lenB).Backing(), 0); err != nil {
+			return err
+		}
+	}
+	contentsA, err := a.Getter(tree.Gindex64(2))
+	if err != nil {
+		return err
+	}
+	contentsB, err := b.Getter(tree.Gindex64(2))
+	if err != nil {
+		return err
+	}
+	common := lenA
+	if lenB < common {
+		common = lenB
+	}
+	// Compare the elements both lists have
+	var fn func(i uint64, ea tree.Node, eb tree.Node) error
+	if complexElem != nil {
+		fn = func(i uint64, ea tree.Node, eb tree.Node) error {
+			return d.diff(path+"/"+strconv.FormatUint(i, 10), complexElem, ea, eb)
+		}
+	} else {
+		fn = d.chunkDiff(path, elemType, common)
+	}
+	if err := d.walk(depth, contentsA, contentsB, 0, (common+perChunk-1)/perChunk, fn); err != nil {
+		return err
+	}
+	// Then the elements that only one of the lists has
+	longer, longerLen, isA := contentsA, lenA, true
+	if lenB > lenA {
+		longer, longerLen, isA = contentsB, lenB, false
+	}
+	for i := common; i < longerLen; i++ {
+		chunkIndex := i / perChunk
+		node, err := longer.Getter(tree.Gindex64((uint64(1) << depth) | chunkIndex))
+		if err != nil {
+			return err
+		}
+		packed := -1
+		if complexElem == nil {
+			packed = int(i % perChunk)
+		}
+		elemPath := path + "/" + strconv.FormatUint(i, 10)
+		if isA {
+			err = d.add(elemPath, elemType, node, nil, packed)
+		} else {
+			err = d.add(elemPath, elemType, nil, node, packed)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// chunkDiff compares the packed basic elements in differing chunks, up to the given element count.
+func (d *differ) chunkDiff(path string, elemType view.TypeDef, count uint64) func(i uint64, ca tree.Node, cb tree.Node) error {
+	perChunk := 32 / elemType.TypeByteLength()
+	return func(chunkIndex uint64, ca tree.Node, cb tree.Node) error {
+		rootA, ok := ca.(*tree.Root)
+		if !ok {
+			return fmt.Errorf("expected leaf chunk at %s", path)
+		}
+		rootB, ok := cb.(*tree.Root)
+		if !ok {
+			return fmt.Errorf("expected leaf chunk at %s", path)
+		}
+		elemSize := elemType.TypeByteLength()
+		for j := uint64(0); j < perChunk; j++ {
+			i := chunkIndex*perChunk + j
+			if i >= count {
+				break
+			}
+			if string(rootA[j*elemSize:(j+1)*elemSize]) == string(rootB[j*elemSize:(j+1)*elemSize]) {
+				continue
+			}
+			if err := d.add(path+"/"+strconv.FormatUint(i, 10), elemType, ca, cb, int(j)); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+}
+
+// walk calls fn for every differing node at the bottom of two subtrees of the given depth, with index in [offset, end).
+func (d *differ) walk(depth uint8, a tree.Node, b tree.Node, offset uint64, end uint64,
+	fn func(i uint64, a tree.Node, b tree.Node) error) error {
+	if offset >= end || a.MerkleRoot(d.h) == b.MerkleRoot(d.h) {
+		return nil
+	}
+	if depth == 0 {
+		return fn(offset, a, b)
+	}
+	la, err := a.Left()
+	if err != nil {
+		return err
+	}
+	lb, err := b.Left()
+	if err != nil {
+		return err
+	}
+	if err := d.walk(depth-1, la, lb, offset, end, fn); err != nil {
+		return err
+	}
+	ra, err := a.Right()
+	if err != nil {
+		return err
+	}
+	rb, err := b.Right()
+	if err != nil {
+		return err
+	}
+	return d.walk(depth-1, ra, rb, offset+(uint64(1)<<(depth-1)), end, fn)
+}
@@ -0,0 +1,133 @@
+package sszpath
+
+import (
+	"github.com/protolambda/zrnt/eth2/beacon"
+	"github.com/protolambda/ztyp/tree"
+	"github.com/protolambda/ztyp/view"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestDiffState(t *testing.T) {
+	state := testState(t)
+	before := state.Backing()
+	if diffs, truncated, err := Diff(state.Type(), before, state.Backing(), 0); err != nil || truncated || len(diffs) != 0 {
+		t.Fatalf("expected no differences with itself, got %v (truncated: %v, err: %v)", diffs, truncated, err)
+	}
+
+	if err := state.SetSlot(5); err != nil {
+		t.Fatal(err)
+	}
+	balances, err := state.Balances()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := balances.SetBalance(2, 123); err != nil {
+		t.Fatal(err)
+	}
+	validators, err := state.Validators()
+	if err != nil {
+		t.Fatal(err)
+	}
+	validator, err := validators.Validator(3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	prevEff, err := validator.EffectiveBalance()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := validator.SetEffectiveBalance(456); err != nil {
+		t.Fatal(err)
+	}
+	prevBal := view.Uint64View(prevEff)
+
+	// In the order of the fields, with the most specific path of each difference
+	expected := []Difference{
+		{Path: "/slot", A: view.Uint64View(0), B: view.Uint64View(5)},
+		{Path: "/validators/3/effective_balance", A: prevBal, B: view.Uint64View(456)},
+		{Path: "/balances/2", A: prevBal, B: view.Uint64View(123)},
+	}
+	diffs, truncated, err := Diff(state.Type(), before, state.Backing(), 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if truncated || !reflect.DeepEqual(diffs, expected) {
+		t.Fatalf("expected differences %v, got %v (truncated: %v)", expected, diffs, truncated)
+	}
+
+	diffs, truncated, err = Diff(state.Type(), before, state.Backing(), 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !truncated || !reflect.DeepEqual(diffs, expected[:2]) {
+		t.Fatalf("expected the first 2 differences and truncation, got %v (truncated: %v)", diffs, truncated)
+	}
+}
+
+func uint64List(t *testing.T, typ *view.BasicListTypeDef, values ...uint64) tree.Node {
+	list := typ.New()
+	for _, v := range values {
+		if err := list.Append(view.Uint64View(v)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return list.Backing()
+}
+
+func TestDiffList(t *testing.T) {
+	typ := view.BasicListType(view.Uint64Type, 16)
+	cases := []struct {
+		name     string
+		a, b     []uint64
+		expected []Difference
+	}{
+		{"equal", []uint64{1, 2, 3}, []uint64{1, 2, 3}, nil},
+		{"changed", []uint64{1, 2, 3, 4, 5, 6}, []uint64{1, 2, 3, 4, 7, 6}, []Difference{
+			{Path: "/4", A: view.Uint64View(5), B: view.Uint64View(7)},
+		}},
+		{"longer B", []uint64{1, 2, 3}, []uint64{1, 5, 3, 4, 5}, []Difference{
+			{Path: "/len", A: view.Uint64View(3), B: view.Uint64View(5)},
+			{Path: "/1", A: view.Uint64View(2), B: view.Uint64View(5)},
+			{Path: "/3", B: view.Uint64View(4)},
+			{Path: "/4", B: view.Uint64View(5)},
+		}},
+		{"longer A", []uint64{1, 2, 3, 4, 5}, []uint64{1, 2, 3}, []Difference{
+			{Path: "/len", A: view.Uint64View(5), B: view.Uint64View(3)},
+			{Path: "/3", A: view.Uint64View(4)},
+			{Path: "/4", A: view.Uint64View(5)},
+		}},
+		{"empty A", nil, []uint64{0}, []Difference{
+			{Path: "/len", A: view.Uint64View(0), B: view.Uint64View(1)},
+			{Path: "/0", B: view.Uint64View(0)},
+		}},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			diffs, truncated, err := Diff(typ, uint64List(t, typ, c.a...), uint64List(t, typ, c.b...), 0)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if truncated || !reflect.DeepEqual(diffs, c.expected) {
+				t.Fatalf("expected differences %v, got %v (truncated: %v)", c.expected, diffs, truncated)
+			}
+		})
+	}
+}
+
+func TestDiffCheckpoint(t *testing.T) {
+	typ := beacon.CheckpointType
+	a := (&beacon.Checkpoint{Epoch: 1, Root: beacon.Root{1}}).View()
+	b := (&beacon.Checkpoint{Epoch: 1, Root: beacon.Root{2}}).View()
+	diffs, _, err := Diff(typ, a.Backing(), b.Backing(), 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(diffs) != 1 || diffs[0].Path != "/root" {
+		t.Fatalf("expected only the root to differ, got %v", diffs)
+	}
+	if diffs[0].A != "0x"+"01"+strings.Repeat("00", 31) || diffs[0].B != "0x"+"02"+strings.Repeat("00", 31) {
+		t.Fatalf("expected roots as hex, got %v and %v", diffs[0].A, diffs[0].B)
+	}
+}
@@ -0,0 +1,450 @@
+// Package sszpath selects nodes in SSZ trees with a path, and produces Merkle proofs for them.
+//
+// A path is a list of segments separated by "/". A segment is a container field name, a list or vector index,
+// a slice "a:b" of a list or vector (bounds optional), or "len" for the length of a list.
+// Indices and slices may also be written in brackets after the previous segment:
+//
+//	validators/12345/effective_balance
+//	validators[12345]/effective_balance
+//	balances[0:64]
+//	finalized_checkpoint
+//	validators/len
+package sszpath
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"github.com/protolambda/ztyp/codec"
+	"github.com/protolambda/ztyp/tree"
+	"github.com/protolambda/ztyp/view"
+	"math/bits"
+	"strconv"
+	"strings"
+)
+
+// Result is a selected node of the tree.
+type Result struct {
+	// Path of the node, with slices expanded into indices
+	Path string
+	// Generalized index of the node, relative to the root of the queried tree
+	Gindex uint64
+	// Type of the node. For packed basic elements, the type of the element, not the chunk.
+	Type view.TypeDef
+	// Node is the tree node, for packed basic elements the chunk that contains the element.
+	Node tree.Node
+	// For packed basic elements, the index of the element within the chunk node. -1 otherwise.
+	PackedIndex int
+}
+
+// ParsePath splits a path into segments.
+func ParsePath(path string) ([]string, error) {
+	var out []string
+	for _, part := range strings.Split(path, "/") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+		for {
+			i := strings.IndexByte(part, '[')
+			if i < 0 {
+				out = append(out, part)
+				break
+			}
+			if i > 0 {
+				out = append(out, part[:i])
+			}
+			end := strings.IndexByte(part, ']')
+			if end < i {
+				return nil, fmt.Errorf("unclosed bracket in path segment %q", part)
+			}
+			out = append(out, part[i+1:end])
+			part = part[end+1:]
+			if part == "" {
+				break
+			}
+		}
+	}
+	return out, nil
+}
+
+// Query selects the nodes at the path, in the tree of the given type.
+func Query(typ view.TypeDef, root tree.Node, path string) ([]Result, error) {
+	segments, err := ParsePath(path)
+	if err != nil {
+		return nil, err
+	}
+	var out []Result
+	err = query(&out, Result{Path: "", Gindex: 1, Type: typ, Node: root, PackedIndex: -1}, segments)
+	return out, err
+}
+
+func query(out *[]Result, current Result, segments []string) error {
+	if len(segments) == 0 {
+		*out = append(*out, current)
+		return nil
+	}
+	seg := segments[0]
+	if current.PackedIndex >= 0 {
+		return fmt.Errorf("cannot select %q in basic value at %s", seg, current.Path)
+	}
+	if i := strings.IndexByte(seg, ':'); i >= 0 {
+		length, err := seqLength(current.Type, current.Node)
+		if err != nil {
+			return fmt.Errorf("cannot slice %s: %v", current.Path, err)
+		}
+		start, end := uint64(0), length
+		if s := strings.TrimSpace(seg[:i]); s != "" {
+			if start, err = strconv.ParseUint(s, 10, 64); err != nil {
+				return fmt.Errorf("bad slice start in %q: %v", seg, err)
+			}
+		}
+		if s := strings.TrimSpace(seg[i+1:]); s != "" {
+			if end, err = strconv.ParseUint(s, 10, 64); err != nil {
+				return fmt.Errorf("bad slice end in %q: %v", seg, err)
+			}
+		}
+		if end > length {
+			end = length
+		}
+		for x := start; x < end; x++ {
+			child, err := step(current, strconv.FormatUint(x, 10))
+			if err != nil {
+				return err
+			}
+			if err := query(out, child, segments[1:]); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	child, err := step(current, seg)
+	if err != nil {
+		return err
+	}
+	return query(out, child, segments[1:])
+}
+
+// seqLength returns the length of a list or vector
+func seqLength(typ view.TypeDef, node tree.Node) (uint64, error) {
+	switch t := typ.(type) {
+	case *view.ComplexVectorTypeDef:
+		return t.Length(), nil
+	case *view.BasicVectorTypeDef:
+		return t.Length(), nil
+	case *view.ComplexListTypeDef, *view.BasicListTypeDef:
+		return listLength(node)
+	default:
+		return 0, fmt.Errorf("type %s is not a list or vector", typ.String())
+	}
+}
+
+func listLength(node tree.Node) (uint64, error) {
+	lengthNode, err := node.Getter(tree.Gindex64(3))
+	if err != nil {
+		return 0, err
+	}
+	r, ok := lengthNode.(*tree.Root)
+	if !ok {
+		return 0, errors.New("list length is not a leaf node")
+	}
+	return binary.LittleEndian.Uint64(r[:8]), nil
+}
+
+// step selects a direct child (or packed element) of the current node.
+func step(current Result, seg string) (Result, error) {
+	var childType view.TypeDef
+	// generalized index of the child relative to the current node
+	var rel uint64
+	var depth uint8
+	packed := -1
+	index := func(limit uint64) (uint64, error) {
+		x, err := strconv.ParseUint(seg, 10, 64)
+		if err != nil {
+			return 0, fmt.Errorf("expected index at %s, got %q", current.Path, seg)
+		}
+		if x >= limit {
+			return 0, fmt.Errorf("index %d out of range at %s, length is %d", x, current.Path, limit)
+		}
+		return x, nil
+	}
+	switch t := current.Type.(type) {
+	case *view.ContainerTypeDef:
+		found := -1
+		for i, f := range t.Fields {
+			if f.Name == seg {
+				found = i
+				break
+			}
+		}
+		if found < 0 {
+			names := make([]string, len(t.Fields))
+			for i, f := range t.Fields {
+				names[i] = f.Name
+			}
+			return Result{}, fmt.Errorf("no field %q in %s, fields: %s", seg, t.ContainerName, strings.Join(names, ", "))
+		}
+		childType = t.Fields[found].Type
+		depth = tree.CoverDepth(uint64(len(t.Fields)))
+		rel = (uint64(1) << depth) | uint64(found)
+	case *view.ComplexVectorTypeDef:
+		x, err := index(t.Length())
+		if err != nil {
+			return Result{}, err
+		}
+		childType = t.ElementType()
+		depth = tree.CoverDepth(t.Length())
+		rel = (uint64(1) << depth) | x
+	case *view.ComplexListTypeDef:
+		if seg == "len" {
+			childType, depth, rel, packed = view.Uint64Type, 1, 3, 0
+			break
+		}
+		length, err := listLength(current.Node)
+		if err != nil {
+			return Result{}, err
+		}
+		x, err := index(length)
+		if err != nil {
+			return Result{}, err
+		}
+		childType = t.ElementType()
+		depth = tree.CoverDepth(t.Limit()) + 1
+		rel = (uint64(1) << depth) | x
+	case *view.BasicVectorTypeDef:
+		x, err := index(t.Length())
+		if err != nil {
+			return Result{}, err
+		}
+		elemSize := t.ElementType().TypeByteLength()
+		perChunk := 32 / elemSize
+		childType = t.ElementType()
+		depth = tree.CoverDepth((t.Length()*elemSize + 31) / 32)
+		rel = (uint64(1) << depth) | (x / perChunk)
+		packed = int(x % perChunk)
+	case *view.BasicListTypeDef:
+		if seg == "len" {
+			childType, depth, rel, packed = view.Uint64Type, 1, 3, 0
+			break
+		}
+		length, err := listLength(current.Node)
+		if err != nil {
+			return Result{}, err
+		}
+		x, err := index(length)
+		if err != nil {
+			return Result{}, err
+		}
+		elemSize := t.ElementType().TypeByteLength()
+		perChunk := 32 / elemSize
+		childType = t.ElementType()
+		depth = tree.CoverDepth((t.Limit()*elemSize+31)/32) + 1
+		rel = (uint64(1) << depth) | (x / perChunk)
+		packed = int(x % perChunk)
+	default:
+		return Result{}, fmt.Errorf("cannot select %q in %s at %s", seg, current.Type.String(), current.Path)
+	}
+	node, err := current.Node.Getter(tree.Gindex64(rel))
+	if err != nil {
+		return Result{}, fmt.Errorf("failed to get node of %q at %s: %v", seg, current.Path, err)
+	}
+	if bits.Len64(current.Gindex)+int(depth) > 64 {
+		return Result{}, fmt.Errorf("path is too deep, generalized index of %q at %s does not fit in 64 bits", seg, current.Path)
+	}
+	// Append the relative gindex (without its leading 1 bit) to the current gindex
+	gindex := (current.Gindex << depth) | (rel ^ (uint64(1) << depth))
+	path := current.Path + "/" + seg
+	return Result{Path: path, Gindex: gindex, Type: childType, Node: node, PackedIndex: packed}, nil
+}
+
+// Proof returns the leaf and the Merkle branch of the result, from the leaf up to the root.
+// For packed basic elements, the leaf is the chunk that contains the element.
+func Proof(root tree.Node, gindex uint64, h tree.HashFn) (leaf tree.Root, branch []tree.Root, err error) {
+	leafNode, err := root.Getter(tree.Gindex64(gindex))
+	if err != nil {
+		return tree.Root{}, nil, err
+	}
+	leaf = leafNode.MerkleRoot(h)
+	for g := gindex; g > 1; g >>= 1 {
+		sibling, err := root.Getter(tree.Gindex64(g ^ 1))
+		if err != nil {
+			return tree.Root{}, nil, err
+		}
+		branch = append(branch, sibling.MerkleRoot(h))
+	}
+	return leaf, branch, nil
+}
+
+// VerifyProof checks that the leaf at the generalized index hashes up to the root, with the branch.
+func VerifyProof(root tree.Root, gindex uint64, leaf tree.Root, branch []tree.Root, h tree.HashFn) bool {
+	value := leaf
+	g := gindex
+	for _, sibling := range branch {
+		if g <= 1 {
+			return false
+		}
+		if g&1 == 1 {
+			value = h(sibling, value)
+		} else {
+			value = h(value, sibling)
+		}
+		g >>= 1
+	}
+	return g == 1 && value == root
+}
+
+// Value converts the selected node into a value that can be encoded as JSON:
+// numbers and booleans as is, byte vectors, roots and bitfields as hex strings,
+// containers as objects and other lists and vectors as arrays.
+func (r *Result) Value() (interface{}, error) {
+	if r.PackedIndex >= 0 {
+		basicType, ok := r.Type.(view.BasicTypeDef)
+		if !ok {
+			return nil, fmt.Errorf("expected basic type, got %s", r.Type.String())
+		}
+		chunk, ok := r.Node.(*tree.Root)
+		if !ok {
+			return nil, errors.New("packed element is not in a leaf node")
+		}
+		v, err := basicType.BasicViewFromBacking(chunk, uint8(r.PackedIndex))
+		if err != nil {
+			return nil, err
+		}
+		return viewValue(v)
+	}
+	v, err := r.Type.ViewFromBacking(r.Node, nil)
+	if err != nil {
+		return nil, err
+	}
+	return viewValue(v)
+}
+
+func hexValue(v view.View) (interface{}, error) {
+	var buf bytes.Buffer
+	if err := v.Serialize(codec.NewEncodingWriter(&buf)); err != nil {
+		return nil, err
+	}
+	return "0x" + hex.EncodeToString(buf.Bytes()), nil
+}
+
+func isByteType(typ view.TypeDef) bool {
+	return typ == view.ByteType || typ == view.Uint8Type
+}
+
+func viewValue(v view.View) (interface{}, error) {
+	switch x := v.(type) {
+	case view.Uint8View, view.Uint16View, view.Uint32View, view.Uint64View, view.BoolView:
+		return x, nil
+	case *view.RootView:
+		return "0x" + hex.EncodeToString(x[:]), nil
+	case view.SmallByteVecView:
+		return "0x" + hex.EncodeToString(x), nil
+	case *view.BitListView, *view.BitVectorView:
+		return hexValue(v)
+	case *view.ContainerView:
+		t := x.Type().(*view.ContainerTypeDef)
+		out := make(map[string]interface{}, len(t.Fields))
+		for i, f := range t.Fields {
+			fv, err := x.Get(uint64(i))
+			if err != nil {
+				return nil, err
+			}
+			if out[f.Name], err = viewValue(fv); err != nil {
+				return nil, err
+			}
+		}
+		return out, nil
+	case *view.BasicVectorView:
+		if isByteType(x.Type().(*view.BasicVectorTypeDef).ElementType()) {
+			return hexValue(v)
+		}
+		return seqValue(x.Type().(*view.BasicVectorTypeDef).Length(), func(i uint64) (view.View, error) { return x.Get(i) })
+	case *view.BasicListView:
+		if isByteType(x.Type().(*view.BasicListTypeDef).ElementType()) {
+			return hexValue(v)
+		}
+		length, err := x.Length()
+		if err != nil {
+			return nil, err
+		}
+		return seqValue(length, func(i uint64) (view.View, error) { return x.Get(i) })
+	case *view.ComplexVectorView:
+		return seqValue(x.Type().(*view.ComplexVectorTypeDef).Length(), x.Get)
+	case *view.ComplexListView:
+		length, err := x.Length()
+		if err != nil {
+			return nil, err
+		}
+		return seqValue(length, x.Get)
+	default:
+		return hexValue(v)
+	}
+}
+
+func seqValue(length uint64, get func(i uint64) (view.View, error)) (interface{}, error) {
+	out := make([]interface{}, 0, length)
+	for i := uint64(0); i < length; i++ {
+		v, err := get(i)
+		if err != nil {
+			return nil, err
+		}
+		ev, err := viewValue(v)
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, ev)
+	}
+	return out, nil
+}
+
+// Describe queries the path and summarizes the result for output:
+// the value (or the values, if the path contains a slice), and optionally the Merkle proof of a single selected node.
+func Describe(typ view.TypeDef, root tree.Node, path string, withProof bool) (map[string]interface{}, error) {
+	results, err := Query(typ, root, path)
+	if err != nil {
+		return nil, err
+	}
+	segments, _ := ParsePath(path)
+	isSlice := false
+	for _, seg := range segments {
+		if strings.IndexByte(seg, ':') >= 0 {
+			isSlice = true
+		}
+	}
+	out := map[string]interface{}{"path": path}
+	if isSlice {
+		if withProof {
+			return nil, errors.New("proofs are not supported for slices, query the elements individually")
+		}
+		values := make([]interface{}, 0, len(results))
+		for i := range results {
+			v, err := results[i].Value()
+			if err != nil {
+				return nil, fmt.Errorf("failed to get value of %s: %v", results[i].Path, err)
+			}
+			values = append(values, v)
+		}
+		out["values"] = values
+		return out, nil
+	}
+	r := &results[0]
+	v, err := r.Value()
+	if err != nil {
+		return nil, err
+	}
+	out["value"] = v
+	out["gindex"] = r.Gindex
+	if withProof {
+		h := tree.GetHashFn()
+		leaf, branch, err := Proof(root, r.Gindex, h)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create proof: %v", err)
+		}
+		out["leaf"] = leaf
+		out["branch"] = branch
+		out["root"] = root.MerkleRoot(h)
+	}
+	return out, nil
+}
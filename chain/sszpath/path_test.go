@@ -0,0 +1,203 @@
+package sszpath
+
+import (
+	"github.com/protolambda/rumor/chain/interop"
+	"github.com/protolambda/zrnt/eth2/beacon"
+	"github.com/protolambda/zrnt/eth2/configs"
+	"github.com/protolambda/ztyp/tree"
+	"github.com/protolambda/ztyp/view"
+	"reflect"
+	"testing"
+)
+
+const testValidators = 8
+
+func testState(t *testing.T) *beacon.BeaconStateView {
+	spec := configs.Minimal
+	deps := make([]beacon.Deposit, testValidators)
+	for i := range deps {
+		deps[i].Data = beacon.DepositData{
+			Pubkey: interop.Pubkey(beacon.ValidatorIndex(i)),
+			Amount: spec.MAX_EFFECTIVE_BALANCE,
+		}
+	}
+	state, _, err := spec.GenesisFromEth1(beacon.Root{0x42}, spec.MIN_GENESIS_TIME, deps, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return state
+}
+
+func TestParsePath(t *testing.T) {
+	cases := []struct {
+		path     string
+		expected []string
+	}{
+		{"", nil},
+		{"slot", []string{"slot"}},
+		{"/validators/12/effective_balance/", []string{"validators", "12", "effective_balance"}},
+		{"validators[12]/effective_balance", []string{"validators", "12", "effective_balance"}},
+		{"balances[0:64]", []string{"balances", "0:64"}},
+		{"block_roots[1][2]", []string{"block_roots", "1", "2"}},
+		{"[3]", []string{"3"}},
+	}
+	for _, c := range cases {
+		segments, err := ParsePath(c.path)
+		if err != nil {
+			t.Fatalf("%q: %v", c.path, err)
+		}
+		if !reflect.DeepEqual(segments, c.expected) {
+			t.Errorf("%q: expected segments %q, got %q", c.path, c.expected, segments)
+		}
+	}
+	if _, err := ParsePath("validators[12"); err == nil {
+		t.Error("expected unclosed bracket to be rejected")
+	}
+}
+
+func TestQueryGindex(t *testing.T) {
+	state := testState(t)
+	// The phase0 state has 21 fields, at depth 5: field i is at gindex 32+i.
+	// Validators (field 11) and balances (field 12) have a limit of 2**40 elements, with 4 balances per chunk,
+	// and the length mixed in one level above the contents.
+	cases := []struct {
+		path   string
+		gindex uint64
+		packed int
+	}{
+		{"genesis_time", 32, -1},
+		{"slot", 34, -1},
+		{"finalized_checkpoint", 52, -1},
+		// FINALIZED_ROOT_INDEX of the light client spec
+		{"finalized_checkpoint/root", 105, -1},
+		{"block_roots/3", 37<<6 | 3, -1},
+		{"validators/len", 43<<1 | 1, 0},
+		{"validators/0", 43 << 41, -1},
+		{"validators[5]/effective_balance", (43<<41|5)<<3 | 2, -1},
+		{"balances/5", 44<<39 | 1, 1},
+		{"balances[7]", 44<<39 | 1, 3},
+	}
+	for _, c := range cases {
+		results, err := Query(state.Type(), state.Backing(), c.path)
+		if err != nil {
+			t.Fatalf("%s: %v", c.path, err)
+		}
+		if len(results) != 1 {
+			t.Fatalf("%s: expected 1 result, got %d", c.path, len(results))
+		}
+		r := results[0]
+		if r.Gindex != c.gindex || r.PackedIndex != c.packed {
+			t.Errorf("%s: expected gindex %d (packed %d), got %d (packed %d)", c.path, c.gindex, c.packed, r.Gindex, r.PackedIndex)
+		}
+		// The gindex is relative to the state root
+		node, err := state.Backing().Getter(tree.Gindex64(r.Gindex))
+		if err != nil {
+			t.Fatalf("%s: %v", c.path, err)
+		}
+		if node.MerkleRoot(tree.GetHashFn()) != r.Node.MerkleRoot(tree.GetHashFn()) {
+			t.Errorf("%s: selected node is not the node at gindex %d", c.path, r.Gindex)
+		}
+	}
+}
+
+func TestQueryValues(t *testing.T) {
+	spec := configs.Minimal
+	state := testState(t)
+	cases := []struct {
+		path     string
+		expected []interface{}
+	}{
+		{"validators/len", []interface{}{view.Uint64View(testValidators)}},
+		{"balances/3", []interface{}{view.Uint64View(spec.MAX_EFFECTIVE_BALANCE)}},
+		{"validators/2/effective_balance", []interface{}{view.Uint64View(spec.MAX_EFFECTIVE_BALANCE)}},
+		{"balances[6:]", []interface{}{view.Uint64View(spec.MAX_EFFECTIVE_BALANCE), view.Uint64View(spec.MAX_EFFECTIVE_BALANCE)}},
+		{"balances[:100]/", make([]interface{}, testValidators)},
+	}
+	for _, c := range cases {
+		results, err := Query(state.Type(), state.Backing(), c.path)
+		if err != nil {
+			t.Fatalf("%s: %v", c.path, err)
+		}
+		if len(results) != len(c.expected) {
+			t.Fatalf("%s: expected %d results, got %d", c.path, len(c.expected), len(results))
+		}
+		for i := range results {
+			v, err := results[i].Value()
+			if err != nil {
+				t.Fatalf("%s: %v", results[i].Path, err)
+			}
+			if c.expected[i] == nil {
+				continue
+			}
+			if v != c.expected[i] {
+				t.Errorf("%s: expected %v, got %v", results[i].Path, c.expected[i], v)
+			}
+		}
+	}
+
+	bad := []string{
+		"no_such_field",
+		"validators/8",
+		"validators/x",
+		"balances/3/more",
+		"slot/0",
+		"validators[0",
+	}
+	for _, path := range bad {
+		if _, err := Query(state.Type(), state.Backing(), path); err == nil {
+			t.Errorf("%s: expected query to fail", path)
+		}
+	}
+}
+
+func TestProof(t *testing.T) {
+	state := testState(t)
+	h := tree.GetHashFn()
+	root := state.HashTreeRoot(h)
+	for _, path := range []string{"slot", "finalized_checkpoint/root", "validators/3/pubkey", "balances/6", "validators/len"} {
+		results, err := Query(state.Type(), state.Backing(), path)
+		if err != nil {
+			t.Fatalf("%s: %v", path, err)
+		}
+		gindex := results[0].Gindex
+		leaf, branch, err := Proof(state.Backing(), gindex, h)
+		if err != nil {
+			t.Fatalf("%s: %v", path, err)
+		}
+		if len(branch) != int(tree.Gindex64(gindex).Depth()) {
+			t.Errorf("%s: expected branch of depth %d, got %d", path, tree.Gindex64(gindex).Depth(), len(branch))
+		}
+		if !VerifyProof(root, gindex, leaf, branch, h) {
+			t.Fatalf("%s: proof does not verify against the state root", path)
+		}
+		changed := leaf
+		changed[31] ^= 1
+		if VerifyProof(root, gindex, changed, branch, h) {
+			t.Errorf("%s: proof with a changed leaf verifies", path)
+		}
+		if len(branch) > 0 {
+			branch[0][0] ^= 1
+			if VerifyProof(root, gindex, leaf, branch, h) {
+				t.Errorf("%s: proof with a changed branch verifies", path)
+			}
+		}
+	}
+}
+
+func TestDescribeProof(t *testing.T) {
+	state := testState(t)
+	out, err := Describe(state.Type(), state.Backing(), "finalized_checkpoint/root", true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	h := tree.GetHashFn()
+	if out["root"] != state.HashTreeRoot(h) || out["gindex"] != uint64(105) {
+		t.Fatalf("expected proof for gindex 105 against the state root, got %v", out)
+	}
+	if !VerifyProof(out["root"].(tree.Root), 105, out["leaf"].(tree.Root), out["branch"].([]tree.Root), h) {
+		t.Fatal("described proof does not verify")
+	}
+	if _, err := Describe(state.Type(), state.Backing(), "balances[0:2]", true); err == nil {
+		t.Fatal("expected proof of a slice to be rejected")
+	}
+}
@@ -0,0 +1,402 @@
+package chain
+
+import (
+	"context"
+	"errors"
+	"github.com/protolambda/zrnt/eth2/beacon"
+	"github.com/protolambda/ztyp/tree"
+	"github.com/protolambda/ztyp/view"
+	"sync"
+	"time"
+)
+
+const DefaultTraceCapacity = 128
+
+// EpochTrace is the time spent in each of the epoch processing steps.
+type EpochTrace struct {
+	// The epoch that was processed (the epoch before the transition)
+	Epoch               Epoch         `json:"epoch"`
+	Prepare             time.Duration `json:"prepare"`
+	Justification       time.Duration `json:"justification"`
+	RewardsAndPenalties time.Duration `json:"rewards_and_penalties"`
+	RegistryUpdates     time.Duration `json:"registry_updates"`
+	Slashings           time.Duration `json:"slashings"`
+	FinalUpdates        time.Duration `json:"final_updates"`
+	// Time spent rotating the epochs-context to the next epoch
+	Rotate time.Duration `json:"rotate"`
+}
+
+func (et *EpochTrace) Total() time.Duration {
+	return et.Prepare + et.Justification + et.RewardsAndPenalties + et.RegistryUpdates + et.Slashings + et.FinalUpdates + et.Rotate
+}
+
+// OpTrace is the number of operations of a type in a block, and the time spent processing them.
+type OpTrace struct {
+	Count int           `json:"count"`
+	Time  time.Duration `json:"time"`
+}
+
+type BalanceDelta struct {
+	Index ValidatorIndex `json:"index"`
+	Delta int64          `json:"delta"`
+}
+
+// BlockTrace records what happened while a block was processed, including the empty slots before it.
+type BlockTrace struct {
+	Slot       Slot `json:"slot"`
+	BlockRoot  Root `json:"block_root"`
+	ParentRoot Root `json:"parent_root"`
+
+	// Number of processed slots, and the time spent caching the state and block roots of them
+	Slots          int           `json:"slots"`
+	SlotProcessing time.Duration `json:"slot_processing"`
+	// Epoch transitions crossed by the slots before the block
+	Epochs []EpochTrace `json:"epochs"`
+
+	Signature         time.Duration `json:"signature"`
+	Header            time.Duration `json:"header"`
+	Randao            time.Duration `json:"randao"`
+	Eth1Vote          time.Duration `json:"eth1_vote"`
+	ProposerSlashings OpTrace       `json:"proposer_slashings"`
+	AttesterSlashings OpTrace       `json:"attester_slashings"`
+	Attestations      OpTrace       `json:"attestations"`
+	Deposits          OpTrace       `json:"deposits"`
+	VoluntaryExits    OpTrace       `json:"voluntary_exits"`
+	// Time spent computing the post-state root, to validate it
+	StateRoot time.Duration `json:"state_root"`
+	Total     time.Duration `json:"total"`
+
+	// Balance changes of validators, between the pre-state of the parent and the post-state, ordered by index
+	BalanceDeltas    []BalanceDelta `json:"balance_deltas"`
+	BalanceIncreased int            `json:"balance_increased"`
+	BalanceDecreased int            `json:"balance_decreased"`
+	BalanceNetDelta  int64          `json:"balance_net_delta"`
+
+	start       time.Time
+	preBalances *beacon.RegistryBalancesView
+}
+
+func newBlockTrace(block *beacon.BeaconBlock, blockRoot Root, pre *beacon.BeaconStateView) (*BlockTrace, error) {
+	bals, err := pre.Balances()
+	if err != nil {
+		return nil, err
+	}
+	// Detach the balances from the state, the state will be modified
+	preBalances, err := beacon.AsRegistryBalances(bals.Copy())
+	if err != nil {
+		return nil, err
+	}
+	return &BlockTrace{
+		Slot:        block.Slot,
+		BlockRoot:   blockRoot,
+		ParentRoot:  block.ParentRoot,
+		start:       time.Now(),
+		preBalances: preBalances,
+	}, nil
+}
+
+func timed(d *time.Duration, fn func() error) error {
+	start := time.Now()
+	err := fn()
+	*d += time.Since(start)
+	return err
+}
+
+// processSlot is a traced version of processing a single slot, like processEmptySlot.
+func (t *BlockTrace) processSlot(ctx context.Context, spec *beacon.Spec, epc *beacon.EpochsContext, state *beacon.BeaconStateView) error {
+	slot, err := state.Slot()
+	if err != nil {
+		return err
+	}
+	t.Slots += 1
+	if err := timed(&t.SlotProcessing, func() error { return spec.ProcessSlot(ctx, state) }); err != nil {
+		return err
+	}
+	isEpochEnd := spec.SlotToEpoch(slot+1) != spec.SlotToEpoch(slot)
+	var et EpochTrace
+	if isEpochEnd {
+		et.Epoch = spec.SlotToEpoch(slot)
+		var process *beacon.EpochProcess
+		steps := []struct {
+			d  *time.Duration
+			fn func() error
+		}{
+			{&et.Prepare, func() (err error) {
+				process, err = spec.PrepareEpochProcess(ctx, epc, state)
+				return
+			}},
+			{&et.Justification, func() error { return spec.ProcessEpochJustification(ctx, epc, process, state) }},
+			{&et.RewardsAndPenalties, func() error { return spec.ProcessEpochRewardsAndPenalties(ctx, epc, process, state) }},
+			{&et.RegistryUpdates, func() error { return spec.ProcessEpochRegistryUpdates(ctx, epc, process, state) }},
+			{&et.Slashings, func() error { return spec.ProcessEpochSlashings(ctx, epc, process, state) }},
+			{&et.FinalUpdates, func() error { return spec.ProcessEpochFinalUpdates(ctx, epc, process, state) }},
+		}
+		for _, s := range steps {
+			if err := timed(s.d, s.fn); err != nil {
+				return err
+			}
+		}
+	}
+	if err := state.SetSlot(slot + 1); err != nil {
+		return err
+	}
+	if isEpochEnd {
+		if err := timed(&et.Rotate, func() error { return epc.RotateEpochs(state) }); err != nil {
+			return err
+		}
+		t.Epochs = append(t.Epochs, et)
+	}
+	return nil
+}
+
+// stateTransition is a traced version of spec.StateTransition: it processes the remaining slots up to the block, then the block.
+func (t *BlockTrace) stateTransition(ctx context.Context, spec *beacon.Spec, epc *beacon.EpochsContext,
+	state *beacon.BeaconStateView, signedBlock *beacon.SignedBeaconBlock, validateResult bool) error {
+	block := &signedBlock.Message
+	for {
+		slot, err := state.Slot()
+		if err != nil {
+			return err
+		}
+		if slot >= block.Slot {
+			if slot > block.Slot {
+				return errors.New("cannot transition from pre-state with higher or equal slot than transition target")
+			}
+			break
+		}
+		select {
+		case <-ctx.Done():
+			return beacon.TransitionCancelErr
+		default:
+		}
+		if err := t.processSlot(ctx, spec, epc, state); err != nil {
+			return err
+		}
+	}
+	if validateResult {
+		if err := timed(&t.Signature, func() error {
+			if !spec.VerifyBlockSignature(epc, state, signedBlock, false) {
+				return errors.New("block has invalid signature")
+			}
+			return nil
+		}); err != nil {
+			return err
+		}
+	}
+	body := &block.Body
+	if err := timed(&t.Header, func() error { return spec.ProcessHeader(ctx, epc, state, block) }); err != nil {
+		return err
+	}
+	if err := timed(&t.Randao, func() error { return spec.ProcessRandaoReveal(ctx, epc, state, body.RandaoReveal) }); err != nil {
+		return err
+	}
+	if err := timed(&t.Eth1Vote, func() error { return spec.ProcessEth1Vote(ctx, epc, state, body.Eth1Data) }); err != nil {
+		return err
+	}
+	if err := body.CheckLimits(spec); err != nil {
+		return err
+	}
+	t.ProposerSlashings.Count = len(body.ProposerSlashings)
+	if err := timed(&t.ProposerSlashings.Time, func() error {
+		return spec.ProcessProposerSlashings(ctx, epc, state, body.ProposerSlashings)
+	}); err != nil {
+		return err
+	}
+	t.AttesterSlashings.Count = len(body.AttesterSlashings)
+	if err := timed(&t.AttesterSlashings.Time, func() error {
+		return spec.ProcessAttesterSlashings(ctx, epc, state, body.AttesterSlashings)
+	}); err != nil {
+		return err
+	}
+	t.Attestations.Count = len(body.Attestations)
+	if err := timed(&t.Attestations.Time, func() error {
+		return spec.ProcessAttestations(ctx, epc, state, body.Attestations)
+	}); err != nil {
+		return err
+	}
+	t.Deposits.Count = len(body.Deposits)
+	if err := timed(&t.Deposits.Time, func() error {
+		return spec.ProcessDeposits(ctx, epc, state, body.Deposits)
+	}); err != nil {
+		return err
+	}
+	t.VoluntaryExits.Count = len(body.VoluntaryExits)
+	if err := timed(&t.VoluntaryExits.Time, func() error {
+		return spec.ProcessVoluntaryExits(ctx, epc, state, body.VoluntaryExits)
+	}); err != nil {
+		return err
+	}
+	if validateResult {
+		var root Root
+		_ = timed(&t.StateRoot, func() error {
+			root = state.HashTreeRoot(tree.GetHashFn())
+			return nil
+		})
+		if block.StateRoot != root {
+			return errors.New("block has invalid state root")
+		}
+	}
+	return nil
+}
+
+// finish computes the balance deltas and the total time.
+func (t *BlockTrace) finish(post *beacon.BeaconStateView) error {
+	t.Total = time.Since(t.start)
+	postBalances, err := post.Balances()
+	if err != nil {
+		return err
+	}
+	preIter := t.preBalances.ReadonlyIter()
+	postIter := postBalances.ReadonlyIter()
+	for i := ValidatorIndex(0); ; i++ {
+		preElem, preOk, err := preIter.Next()
+		if err != nil {
+			return err
+		}
+		postElem, postOk, err := postIter.Next()
+		if err != nil {
+			return err
+		}
+		if !postOk {
+			break
+		}
+		var pre, post view.Uint64View
+		if preOk {
+			pre = preElem.(view.Uint64View)
+		}
+		post = postElem.(view.Uint64View)
+		if pre == post {
+			continue
+		}
+		delta := int64(post) - int64(pre)
+		t.BalanceDeltas = append(t.BalanceDeltas, BalanceDelta{Index: i, Delta: delta})
+		t.BalanceNetDelta += delta
+		if delta > 0 {
+			t.BalanceIncreased += 1
+		} else {
+			t.BalanceDecreased += 1
+		}
+	}
+	t.preBalances = nil
+	return nil
+}
+
+// TraceSummary aggregates the traces of all processed blocks since the tracer was enabled or cleared.
+type TraceSummary struct {
+	Blocks         int
+	Slots          int
+	Epochs         int
+	Total          time.Duration
+	Max            time.Duration
+	MaxSlot        Slot
+	SlotProcessing time.Duration
+	EpochTotals    EpochTrace
+	Signature      time.Duration
+	Header         time.Duration
+	Randao         time.Duration
+	Eth1Vote       time.Duration
+	StateRoot      time.Duration
+	// By operation type: proposer_slashings, attester_slashings, attestations, deposits, voluntary_exits
+	Operations map[string]OpTrace
+}
+
+// TransitionTracer keeps the traces of the most recent processed blocks, and a summary of all of them.
+type TransitionTracer struct {
+	sync.Mutex
+	capacity int
+	traces   []*BlockTrace
+	summary  TraceSummary
+}
+
+func NewTransitionTracer(capacity int) *TransitionTracer {
+	return &TransitionTracer{capacity: capacity, summary: TraceSummary{Operations: make(map[string]OpTrace)}}
+}
+
+func (tr *TransitionTracer) Record(t *BlockTrace) {
+	tr.Lock()
+	defer tr.Unlock()
+	if tr.capacity > 0 {
+		tr.traces = append(tr.traces, t)
+		if len(tr.traces) > tr.capacity {
+			tr.traces = tr.traces[len(tr.traces)-tr.capacity:]
+		}
+	}
+	s := &tr.summary
+	s.Blocks += 1
+	s.Slots += t.Slots
+	s.Epochs += len(t.Epochs)
+	s.Total += t.Total
+	if t.Total > s.Max {
+		s.Max = t.Total
+		s.MaxSlot = t.Slot
+	}
+	s.SlotProcessing += t.SlotProcessing
+	for _, et := range t.Epochs {
+		s.EpochTotals.Prepare += et.Prepare
+		s.EpochTotals.Justification += et.Justification
+		s.EpochTotals.RewardsAndPenalties += et.RewardsAndPenalties
+		s.EpochTotals.RegistryUpdates += et.RegistryUpdates
+		s.EpochTotals.Slashings += et.Slashings
+		s.EpochTotals.FinalUpdates += et.FinalUpdates
+		s.EpochTotals.Rotate += et.Rotate
+	}
+	s.Signature += t.Signature
+	s.Header += t.Header
+	s.Randao += t.Randao
+	s.Eth1Vote += t.Eth1Vote
+	s.StateRoot += t.StateRoot
+	for name, op := range map[string]OpTrace{
+		"proposer_slashings": t.ProposerSlashings,
+		"attester_slashings": t.AttesterSlashings,
+		"attestations":       t.Attestations,
+		"deposits":           t.Deposits,
+		"voluntary_exits":    t.VoluntaryExits,
+	} {
+		total := s.Operations[name]
+		total.Count += op.Count
+		total.Time += op.Time
+		s.Operations[name] = total
+	}
+}
+
+// Last returns up to n of the most recent traces, oldest first.
+func (tr *TransitionTracer) Last(n int) []*BlockTrace {
+	tr.Lock()
+	defer tr.Unlock()
+	if n > len(tr.traces) || n < 0 {
+		n = len(tr.traces)
+	}
+	out := make([]*BlockTrace, n)
+	copy(out, tr.traces[len(tr.traces)-n:])
+	return out
+}
+
+// ByBlockRoot returns the most recent trace of the given block, if it is still kept.
+func (tr *TransitionTracer) ByBlockRoot(root Root) (*BlockTrace, bool) {
+	tr.Lock()
+	defer tr.Unlock()
+	for i := len(tr.traces) - 1; i >= 0; i-- {
+		if tr.traces[i].BlockRoot == root {
+			return tr.traces[i], true
+		}
+	}
+	return nil, false
+}
+
+func (tr *TransitionTracer) Summary() TraceSummary {
+	tr.Lock()
+	defer tr.Unlock()
+	out := tr.summary
+	out.Operations = make(map[string]OpTrace, len(tr.summary.Operations))
+	for k, v := range tr.summary.Operations {
+		out.Operations[k] = v
+	}
+	return out
+}
+
+func (tr *TransitionTracer) Clear() {
+	tr.Lock()
+	defer tr.Unlock()
+	tr.traces = nil
+	tr.summary = TraceSummary{Operations: make(map[string]OpTrace)}
+}
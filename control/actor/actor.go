@@ -6,13 +6,17 @@ import (
 	"fmt"
 	"github.com/ethereum/go-ethereum/p2p/enode"
 	"github.com/libp2p/go-libp2p-core/crypto"
+	p2phost "github.com/libp2p/go-libp2p-core/host"
 	"github.com/protolambda/ask"
 	chaindata "github.com/protolambda/rumor/chain"
 	bdb "github.com/protolambda/rumor/chain/db/blocks"
 	sdb "github.com/protolambda/rumor/chain/db/states"
+	"github.com/protolambda/rumor/control/actor/api"
 	"github.com/protolambda/rumor/control/actor/base"
 	"github.com/protolambda/rumor/control/actor/blocks"
 	"github.com/protolambda/rumor/control/actor/chain"
+	"github.com/protolambda/rumor/control/actor/chain/clockcmd"
+	"github.com/protolambda/rumor/control/actor/crawl"
 	"github.com/protolambda/rumor/control/actor/dv5"
 	"github.com/protolambda/rumor/control/actor/enr"
 	"github.com/protolambda/rumor/control/actor/gossip"
@@ -25,7 +29,9 @@ import (
 	"github.com/protolambda/rumor/control/actor/states"
 	"github.com/protolambda/rumor/control/tool"
 	"github.com/protolambda/rumor/p2p/addrutil"
+	"github.com/protolambda/rumor/p2p/memnet"
 	"github.com/protolambda/rumor/p2p/track"
+	"github.com/protolambda/zrnt/eth2/beacon"
 	"github.com/sirupsen/logrus"
 	"io"
 	"sync"
@@ -38,6 +44,7 @@ type GlobalActorData struct {
 	GlobalChains     chaindata.Chains
 	GlobalBlocksDBs  bdb.DBs
 	GlobalStatesDBs  sdb.DBs
+	GlobalMemNet     *memnet.Network
 }
 
 type ActorID string
@@ -53,6 +60,7 @@ type Actor struct {
 	PeerMetadataState metadata.PeerMetadataState
 
 	ChainState  chain.ChainState
+	ClockState  clockcmd.ClockState
 	BlocksState blocks.DBState
 	StatesState states.DBState
 
@@ -117,6 +125,42 @@ func (r *Actor) GetNode() (n *enode.Node, ok bool) {
 	}
 }
 
+// apiBackend exposes the current chain, blocks DB and peerstore of the actor to the beacon API.
+type apiBackend struct {
+	*Actor
+}
+
+func (b apiBackend) Host() (h p2phost.Host, err error) {
+	return b.HostState.Host()
+}
+
+func (b apiBackend) Chain() (chaindata.FullChain, error) {
+	ch, ok := b.GlobalChains.Find(b.ChainState.CurrentChain)
+	if !ok {
+		return nil, errors.New("no chain available, try 'chain create'")
+	}
+	return ch, nil
+}
+
+func (b apiBackend) Blocks() (bdb.DB, error) {
+	db, ok := b.GlobalBlocksDBs.Find(b.BlocksState.CurrentDB)
+	if !ok {
+		return nil, errors.New("no blocks DB available, try 'blocks create'")
+	}
+	return db, nil
+}
+
+func (b apiBackend) Peerstore() (track.ExtendedPeerstore, error) {
+	if !b.CurrentPeerstore.Initialized() {
+		return nil, errors.New("no peerstore available, try 'peerstore create'")
+	}
+	return b.CurrentPeerstore, nil
+}
+
+func (b apiBackend) LocalMetadata() beacon.MetaData {
+	return b.PeerMetadataState.Local
+}
+
 func (r *Actor) MakeCmd(log logrus.FieldLogger, control base.Control, out io.Writer) *ActorCmd {
 	return &ActorCmd{
 		Actor:   r,
@@ -169,6 +213,7 @@ func (c *ActorCmd) Cmd(route string) (cmd interface{}, err error) {
 			WithCloseHost:    &c.HostState,
 			GlobalPeerstores: c.GlobalPeerstores,
 			CurrentPeerstore: c.CurrentPeerstore,
+			MemNet:           c.GlobalMemNet,
 		}
 	case "enr":
 		cmd = &enr.EnrCmd{Base: b, Lazy: &c.LazyEnrState, PrivSettings: c, WithHostPriv: &c.HostState}
@@ -181,6 +226,7 @@ func (c *ActorCmd) Cmd(route string) (cmd interface{}, err error) {
 			Base:              b,
 			PeerStatusState:   &c.PeerStatusState,
 			PeerMetadataState: &c.PeerMetadataState,
+			Clock:             &c.ClockState,
 			Store:             store,
 		}
 	case "peerstore":
@@ -195,6 +241,15 @@ func (c *ActorCmd) Cmd(route string) (cmd interface{}, err error) {
 			return nil, errors.New("Discv5 needs an ENR first. Use 'enr make'.")
 		}
 		cmd = &dv5.Dv5Cmd{Base: b, Dv5State: &c.Dv5State, Dv5Settings: settings, CurrentPeerstore: c.CurrentPeerstore}
+	case "crawl":
+		cmd = &crawl.CrawlCmd{
+			Base:              b,
+			Dv5State:          &c.Dv5State,
+			Store:             c.CurrentPeerstore,
+			PeerStatusState:   &c.PeerStatusState,
+			PeerMetadataState: &c.PeerMetadataState,
+			Clock:             &c.ClockState,
+		}
 	case "gossip":
 		cmd = &gossip.GossipCmd{Base: b, GossipState: &c.GossipState}
 	case "rpc":
@@ -213,7 +268,9 @@ func (c *ActorCmd) Cmd(route string) (cmd interface{}, err error) {
 			return nil, errors.New("no states DB available, try 'states create'")
 		}
 		cmd = &chain.ChainCmd{Base: b, Chains: c.GlobalChains,
-			ChainState: &c.ChainState, Blocks: bl, States: st}
+			ChainState: &c.ChainState, ClockState: &c.ClockState, Blocks: bl, States: st, GossipState: &c.GossipState}
+	case "api":
+		cmd = &api.ApiCmd{Base: b, Backend: apiBackend{c.Actor}}
 	case "sleep":
 		cmd = &SleepCmd{Base: b}
 	case "tool":
@@ -224,8 +281,8 @@ func (c *ActorCmd) Cmd(route string) (cmd interface{}, err error) {
 	return cmd, nil
 }
 
-var topRoutes = []string{"host", "enr", "peer", "peerstore", "dv5", "gossip",
-	"rpc", "blocks", "states", "chain", "sleep", "tool"}
+var topRoutes = []string{"host", "enr", "peer", "peerstore", "dv5", "crawl", "gossip",
+	"rpc", "blocks", "states", "chain", "api", "sleep", "tool"}
 var topRoutesMap = map[string]struct{}{}
 
 func init() {
@@ -0,0 +1,44 @@
+package api
+
+import (
+	"github.com/protolambda/ask"
+	"github.com/protolambda/rumor/chain"
+	bdb "github.com/protolambda/rumor/chain/db/blocks"
+	"github.com/protolambda/rumor/control/actor/base"
+	"github.com/protolambda/rumor/p2p/track"
+	"github.com/protolambda/zrnt/eth2/beacon"
+)
+
+// Backend provides the data of an actor to the API.
+// Everything is looked up per request, the actor may switch chains, DBs or peerstores while the API is running.
+type Backend interface {
+	base.WithHost
+	base.WithEnrNode
+	Chain() (chain.FullChain, error)
+	Blocks() (bdb.DB, error)
+	Peerstore() (track.ExtendedPeerstore, error)
+	LocalMetadata() beacon.MetaData
+}
+
+type ApiCmd struct {
+	*base.Base
+	Backend Backend
+}
+
+func (c *ApiCmd) Cmd(route string) (cmd interface{}, err error) {
+	switch route {
+	case "serve":
+		cmd = &ServeCmd{Base: c.Base, Backend: c.Backend}
+	default:
+		return nil, ask.UnrecognizedErr
+	}
+	return cmd, nil
+}
+
+func (c *ApiCmd) Routes() []string {
+	return []string{"serve"}
+}
+
+func (c *ApiCmd) Help() string {
+	return "Serve a subset of the standard eth2 beacon node HTTP API"
+}
@@ -0,0 +1,52 @@
+package api
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"time"
+
+	"github.com/protolambda/rumor/control/actor/base"
+)
+
+type ServeCmd struct {
+	*base.Base
+	Backend Backend
+	Addr    string `ask:"--addr" help:"Address to serve the HTTP API on"`
+}
+
+func (c *ServeCmd) Default() {
+	c.Addr = "localhost:5052"
+}
+
+func (c *ServeCmd) Help() string {
+	return "Serve the beacon API (headers, blocks, finality checkpoints, states, node identity and peers) until the call is cancelled."
+}
+
+func (c *ServeCmd) Run(ctx context.Context, args ...string) error {
+	listener, err := net.Listen("tcp", c.Addr)
+	if err != nil {
+		return err
+	}
+	srv := &http.Server{
+		Handler:      NewServer(c.Backend, c.Log),
+		WriteTimeout: time.Second * 30,
+		ReadTimeout:  time.Second * 15,
+		IdleTimeout:  time.Second * 60,
+	}
+	go func() {
+		if err := srv.Serve(listener); err != nil && err != http.ErrServerClosed {
+			c.Log.WithError(err).Error("beacon API server stopped")
+		}
+	}()
+	c.Log.WithField("addr", "http://"+listener.Addr().String()).Info("Started serving beacon API")
+
+	c.Control.RegisterStop(func(ctx context.Context) error {
+		if err := srv.Shutdown(ctx); err != nil {
+			return err
+		}
+		c.Log.Info("Stopped serving beacon API")
+		return nil
+	})
+	return nil
+}
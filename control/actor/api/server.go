@@ -0,0 +1,590 @@
+package api
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/libp2p/go-libp2p-core/network"
+	"github.com/protolambda/rumor/chain"
+	bdb "github.com/protolambda/rumor/chain/db/blocks"
+	"github.com/protolambda/zrnt/eth2/beacon"
+	"github.com/protolambda/ztyp/codec"
+	"github.com/protolambda/ztyp/tree"
+	"github.com/sirupsen/logrus"
+)
+
+type apiErr struct {
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+}
+
+func (e *apiErr) Error() string {
+	return e.Message
+}
+
+func notFound(format string, args ...interface{}) error {
+	return &apiErr{Code: http.StatusNotFound, Message: fmt.Sprintf(format, args...)}
+}
+
+func badRequest(format string, args ...interface{}) error {
+	return &apiErr{Code: http.StatusBadRequest, Message: fmt.Sprintf(format, args...)}
+}
+
+type dataResponse struct {
+	Data interface{} `json:"data"`
+}
+
+type Server struct {
+	backend Backend
+	log     logrus.FieldLogger
+	mux     *http.ServeMux
+}
+
+func NewServer(backend Backend, log logrus.FieldLogger) *Server {
+	s := &Server{backend: backend, log: log, mux: http.NewServeMux()}
+	s.mux.HandleFunc("/eth/v1/node/identity", s.handle(s.nodeIdentity))
+	s.mux.HandleFunc("/eth/v1/node/peers", s.handle(s.nodePeers))
+	s.mux.HandleFunc("/eth/v1/beacon/headers", s.handle(s.headers))
+	s.mux.HandleFunc("/eth/v1/beacon/headers/", s.handle(s.header))
+	s.mux.HandleFunc("/eth/v1/beacon/blocks/", s.handle(s.block))
+	s.mux.HandleFunc("/eth/v1/beacon/states/", s.handle(s.stateSubResource))
+	s.mux.HandleFunc("/eth/v1/debug/beacon/states/", s.handle(s.debugState))
+	return s
+}
+
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		s.writeJSON(w, http.StatusMethodNotAllowed, &apiErr{Code: http.StatusMethodNotAllowed, Message: "only GET is supported"})
+		return
+	}
+	s.mux.ServeHTTP(w, r)
+}
+
+// A handler returns the response object, or nil if it wrote the response itself (e.g. SSZ responses).
+type handlerFn func(w http.ResponseWriter, r *http.Request) (interface{}, error)
+
+func (s *Server) handle(fn handlerFn) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		out, err := fn(w, r)
+		if err != nil {
+			if e, ok := err.(*apiErr); ok {
+				s.writeJSON(w, e.Code, e)
+			} else {
+				s.log.WithError(err).WithField("path", r.URL.Path).Warn("beacon API request failed")
+				s.writeJSON(w, http.StatusInternalServerError, &apiErr{Code: http.StatusInternalServerError, Message: err.Error()})
+			}
+			return
+		}
+		if out != nil {
+			s.writeJSON(w, http.StatusOK, out)
+		}
+	}
+}
+
+func (s *Server) writeJSON(w http.ResponseWriter, code int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json; charset=UTF-8")
+	w.WriteHeader(code)
+	if err := json.NewEncoder(w).Encode(v); err != nil {
+		s.log.WithError(err).Warn("failed to write beacon API response")
+	}
+}
+
+func wantsSSZ(r *http.Request) bool {
+	return strings.Contains(r.Header.Get("Accept"), "application/octet-stream")
+}
+
+func writeSSZ(w http.ResponseWriter, size uint64, fn func(w *codec.EncodingWriter) error) error {
+	w.Header().Set("Content-Type", "application/octet-stream")
+	w.Header().Set("Content-Length", strconv.FormatUint(size, 10))
+	w.WriteHeader(http.StatusOK)
+	return fn(codec.NewEncodingWriter(w))
+}
+
+// quoteNumbers re-encodes the JSON of v with all numbers as decimal strings, as the beacon API expects for uint64 values.
+func quoteNumbers(v interface{}) (interface{}, error) {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+	var out interface{}
+	if err := dec.Decode(&out); err != nil {
+		return nil, err
+	}
+	var quote func(v interface{}) interface{}
+	quote = func(v interface{}) interface{} {
+		switch x := v.(type) {
+		case json.Number:
+			return x.String()
+		case map[string]interface{}:
+			for k, el := range x {
+				x[k] = quote(el)
+			}
+		case []interface{}:
+			for i, el := range x {
+				x[i] = quote(el)
+			}
+		}
+		return v
+	}
+	return quote(out), nil
+}
+
+// pathParams returns the path segments after the given prefix.
+func pathParams(r *http.Request, prefix string) []string {
+	rest := strings.Trim(strings.TrimPrefix(r.URL.Path, prefix), "/")
+	if rest == "" {
+		return nil
+	}
+	return strings.Split(rest, "/")
+}
+
+func (s *Server) blocks() (bdb.DB, error) {
+	db, err := s.backend.Blocks()
+	if err != nil {
+		return nil, &apiErr{Code: http.StatusServiceUnavailable, Message: err.Error()}
+	}
+	return db, nil
+}
+
+func (s *Server) chain() (chain.FullChain, error) {
+	ch, err := s.backend.Chain()
+	if err != nil {
+		return nil, &apiErr{Code: http.StatusServiceUnavailable, Message: err.Error()}
+	}
+	return ch, nil
+}
+
+// entryByID resolves a block or state identifier to a chain entry.
+// The identifier is one of "head", "genesis", "finalized", "justified", a slot, or a 0x-prefixed root.
+// Roots are block roots, or state roots if stateRoots is true.
+func (s *Server) entryByID(ch chain.FullChain, id string, stateRoots bool) (chain.ChainEntry, error) {
+	var entry chain.ChainEntry
+	var err error
+	switch id {
+	case "head":
+		entry, err = ch.Head()
+	case "genesis":
+		entry, err = ch.BySlot(0)
+	case "finalized":
+		entry, err = ch.ByBlockRoot(ch.Finalized().Root)
+	case "justified":
+		entry, err = ch.ByBlockRoot(ch.Justified().Root)
+	default:
+		if strings.HasPrefix(id, "0x") {
+			var root beacon.Root
+			if err := root.UnmarshalText([]byte(id)); err != nil {
+				return nil, badRequest("invalid root %q: %v", id, err)
+			}
+			if stateRoots {
+				entry, err = ch.ByStateRoot(root)
+			} else {
+				entry, err = ch.ByBlockRoot(root)
+			}
+		} else {
+			slot, parseErr := strconv.ParseUint(id, 10, 64)
+			if parseErr != nil {
+				return nil, badRequest("invalid identifier %q", id)
+			}
+			entry, err = ch.BySlot(beacon.Slot(slot))
+		}
+	}
+	if err != nil {
+		return nil, notFound("could not find %q: %v", id, err)
+	}
+	return entry, nil
+}
+
+func (s *Server) blockByID(ch chain.FullChain, id string) (beacon.Root, *beacon.SignedBeaconBlock, error) {
+	entry, err := s.entryByID(ch, id, false)
+	if err != nil {
+		return beacon.Root{}, nil, err
+	}
+	if entry.IsEmpty() {
+		return beacon.Root{}, nil, notFound("no block at slot %d", entry.Slot())
+	}
+	blocks, err := s.blocks()
+	if err != nil {
+		return beacon.Root{}, nil, err
+	}
+	root := entry.BlockRoot()
+	var block beacon.SignedBeaconBlock
+	exists, err := blocks.Get(root, &block)
+	if err != nil {
+		return root, nil, fmt.Errorf("failed to read block %s from the blocks DB: %v", root, err)
+	}
+	if !exists {
+		return root, nil, notFound("block %s is not in the blocks DB", root)
+	}
+	return root, &block, nil
+}
+
+func (s *Server) stateByID(ctx context.Context, ch chain.FullChain, id string) (*beacon.BeaconStateView, error) {
+	entry, err := s.entryByID(ch, id, true)
+	if err != nil {
+		return nil, err
+	}
+	return entry.State(ctx)
+}
+
+type headerMessageJSON struct {
+	Slot          string      `json:"slot"`
+	ProposerIndex string      `json:"proposer_index"`
+	ParentRoot    beacon.Root `json:"parent_root"`
+	StateRoot     beacon.Root `json:"state_root"`
+	BodyRoot      beacon.Root `json:"body_root"`
+}
+
+type signedHeaderJSON struct {
+	Message   headerMessageJSON   `json:"message"`
+	Signature beacon.BLSSignature `json:"signature"`
+}
+
+type headerJSON struct {
+	Root      beacon.Root      `json:"root"`
+	Canonical bool             `json:"canonical"`
+	Header    signedHeaderJSON `json:"header"`
+}
+
+func (s *Server) headerData(ch chain.FullChain, spec *beacon.Spec, root beacon.Root, block *beacon.SignedBeaconBlock) *headerJSON {
+	canonical := false
+	if entry, err := ch.BySlot(block.Message.Slot); err == nil {
+		canonical = entry.BlockRoot() == root
+	}
+	return &headerJSON{
+		Root:      root,
+		Canonical: canonical,
+		Header: signedHeaderJSON{
+			Message: headerMessageJSON{
+				Slot:          strconv.FormatUint(uint64(block.Message.Slot), 10),
+				ProposerIndex: strconv.FormatUint(uint64(block.Message.ProposerIndex), 10),
+				ParentRoot:    block.Message.ParentRoot,
+				StateRoot:     block.Message.StateRoot,
+				BodyRoot:      block.Message.Body.HashTreeRoot(spec, tree.GetHashFn()),
+			},
+			Signature: block.Signature,
+		},
+	}
+}
+
+// GET /eth/v1/beacon/headers?slot=&parent_root=
+func (s *Server) headers(w http.ResponseWriter, r *http.Request) (interface{}, error) {
+	ch, err := s.chain()
+	if err != nil {
+		return nil, err
+	}
+	q := r.URL.Query()
+	id := "head"
+	if slot := q.Get("slot"); slot != "" {
+		id = slot
+	}
+	out := make([]*headerJSON, 0, 1)
+	root, block, err := s.blockByID(ch, id)
+	if err != nil {
+		if e, ok := err.(*apiErr); ok && e.Code == http.StatusNotFound {
+			return &dataResponse{Data: out}, nil
+		}
+		return nil, err
+	}
+	if parent := q.Get("parent_root"); parent != "" {
+		var parentRoot beacon.Root
+		if err := parentRoot.UnmarshalText([]byte(parent)); err != nil {
+			return nil, badRequest("invalid parent root: %v", err)
+		}
+		if block.Message.ParentRoot != parentRoot {
+			return &dataResponse{Data: out}, nil
+		}
+	}
+	db, err := s.blocks()
+	if err != nil {
+		return nil, err
+	}
+	out = append(out, s.headerData(ch, db.Spec(), root, block))
+	return &dataResponse{Data: out}, nil
+}
+
+// GET /eth/v1/beacon/headers/{block_id}
+func (s *Server) header(w http.ResponseWriter, r *http.Request) (interface{}, error) {
+	params := pathParams(r, "/eth/v1/beacon/headers/")
+	if len(params) != 1 {
+		return nil, notFound("unknown path %s", r.URL.Path)
+	}
+	ch, err := s.chain()
+	if err != nil {
+		return nil, err
+	}
+	root, block, err := s.blockByID(ch, params[0])
+	if err != nil {
+		return nil, err
+	}
+	db, err := s.blocks()
+	if err != nil {
+		return nil, err
+	}
+	return &dataResponse{Data: s.headerData(ch, db.Spec(), root, block)}, nil
+}
+
+// GET /eth/v1/beacon/blocks/{block_id}, JSON or SSZ
+func (s *Server) block(w http.ResponseWriter, r *http.Request) (interface{}, error) {
+	params := pathParams(r, "/eth/v1/beacon/blocks/")
+	if len(params) == 2 && params[1] == "root" {
+		ch, err := s.chain()
+		if err != nil {
+			return nil, err
+		}
+		entry, err := s.entryByID(ch, params[0], false)
+		if err != nil {
+			return nil, err
+		}
+		return &dataResponse{Data: map[string]beacon.Root{"root": entry.BlockRoot()}}, nil
+	}
+	if len(params) != 1 {
+		return nil, notFound("unknown path %s", r.URL.Path)
+	}
+	ch, err := s.chain()
+	if err != nil {
+		return nil, err
+	}
+	_, block, err := s.blockByID(ch, params[0])
+	if err != nil {
+		return nil, err
+	}
+	db, err := s.blocks()
+	if err != nil {
+		return nil, err
+	}
+	spec := db.Spec()
+	if wantsSSZ(r) {
+		return nil, writeSSZ(w, block.ByteLength(spec), func(w *codec.EncodingWriter) error {
+			return block.Serialize(spec, w)
+		})
+	}
+	data, err := quoteNumbers(block)
+	if err != nil {
+		return nil, err
+	}
+	return &dataResponse{Data: data}, nil
+}
+
+type checkpointJSON struct {
+	Epoch string      `json:"epoch"`
+	Root  beacon.Root `json:"root"`
+}
+
+func checkpointData(v *beacon.CheckpointView, err error) (*checkpointJSON, error) {
+	if err != nil {
+		return nil, err
+	}
+	cp, err := v.Raw()
+	if err != nil {
+		return nil, err
+	}
+	return &checkpointJSON{Epoch: strconv.FormatUint(uint64(cp.Epoch), 10), Root: cp.Root}, nil
+}
+
+// GET /eth/v1/beacon/states/{state_id}/finality_checkpoints
+func (s *Server) stateSubResource(w http.ResponseWriter, r *http.Request) (interface{}, error) {
+	params := pathParams(r, "/eth/v1/beacon/states/")
+	if len(params) != 2 || params[1] != "finality_checkpoints" {
+		return nil, notFound("unknown path %s", r.URL.Path)
+	}
+	ch, err := s.chain()
+	if err != nil {
+		return nil, err
+	}
+	state, err := s.stateByID(r.Context(), ch, params[0])
+	if err != nil {
+		return nil, err
+	}
+	prevJust, err := checkpointData(state.PreviousJustifiedCheckpoint())
+	if err != nil {
+		return nil, err
+	}
+	currJust, err := checkpointData(state.CurrentJustifiedCheckpoint())
+	if err != nil {
+		return nil, err
+	}
+	fin, err := checkpointData(state.FinalizedCheckpoint())
+	if err != nil {
+		return nil, err
+	}
+	return &dataResponse{Data: map[string]*checkpointJSON{
+		"previous_justified": prevJust,
+		"current_justified":  currJust,
+		"finalized":          fin,
+	}}, nil
+}
+
+// GET /eth/v1/debug/beacon/states/{state_id}, JSON or SSZ
+func (s *Server) debugState(w http.ResponseWriter, r *http.Request) (interface{}, error) {
+	params := pathParams(r, "/eth/v1/debug/beacon/states/")
+	if len(params) != 1 {
+		return nil, notFound("unknown path %s", r.URL.Path)
+	}
+	ch, err := s.chain()
+	if err != nil {
+		return nil, err
+	}
+	state, err := s.stateByID(r.Context(), ch, params[0])
+	if err != nil {
+		return nil, err
+	}
+	if wantsSSZ(r) {
+		size, err := state.ValueByteLength()
+		if err != nil {
+			return nil, err
+		}
+		return nil, writeSSZ(w, size, state.Serialize)
+	}
+	db, err := s.blocks()
+	if err != nil {
+		return nil, err
+	}
+	raw, err := state.Raw(db.Spec())
+	if err != nil {
+		return nil, err
+	}
+	data, err := quoteNumbers(raw)
+	if err != nil {
+		return nil, err
+	}
+	return &dataResponse{Data: data}, nil
+}
+
+type identityMetadataJSON struct {
+	SeqNumber string            `json:"seq_number"`
+	Attnets   beacon.AttnetBits `json:"attnets"`
+}
+
+type identityJSON struct {
+	PeerID             string               `json:"peer_id"`
+	ENR                string               `json:"enr"`
+	P2PAddresses       []string             `json:"p2p_addresses"`
+	DiscoveryAddresses []string             `json:"discovery_addresses"`
+	Metadata           identityMetadataJSON `json:"metadata"`
+}
+
+// GET /eth/v1/node/identity
+func (s *Server) nodeIdentity(w http.ResponseWriter, r *http.Request) (interface{}, error) {
+	h, err := s.backend.Host()
+	if err != nil {
+		return nil, &apiErr{Code: http.StatusServiceUnavailable, Message: err.Error()}
+	}
+	md := s.backend.LocalMetadata()
+	out := &identityJSON{
+		PeerID:             h.ID().String(),
+		P2PAddresses:       make([]string, 0),
+		DiscoveryAddresses: make([]string, 0),
+		Metadata: identityMetadataJSON{
+			SeqNumber: strconv.FormatUint(uint64(md.SeqNumber), 10),
+			Attnets:   md.Attnets,
+		},
+	}
+	for _, a := range h.Addrs() {
+		out.P2PAddresses = append(out.P2PAddresses, a.String()+"/p2p/"+h.ID().String())
+	}
+	if n, ok := s.backend.GetNode(); ok {
+		out.ENR = n.String()
+		if ip := n.IP(); ip != nil && n.UDP() != 0 {
+			ipProto := "ip4"
+			if ip.To4() == nil {
+				ipProto = "ip6"
+			}
+			out.DiscoveryAddresses = append(out.DiscoveryAddresses,
+				fmt.Sprintf("/%s/%s/udp/%d/p2p/%s", ipProto, ip.String(), n.UDP(), h.ID().String()))
+		}
+	}
+	return &dataResponse{Data: out}, nil
+}
+
+type peerJSON struct {
+	PeerID             string `json:"peer_id"`
+	ENR                string `json:"enr,omitempty"`
+	LastSeenP2PAddress string `json:"last_seen_p2p_address"`
+	State              string `json:"state"`
+	Direction          string `json:"direction"`
+}
+
+type peersResponse struct {
+	Data []*peerJSON       `json:"data"`
+	Meta map[string]uint64 `json:"meta"`
+}
+
+func connectednessState(c network.Connectedness) string {
+	switch c {
+	case network.Connected:
+		return "connected"
+	default:
+		return "disconnected"
+	}
+}
+
+func directionStr(d network.Direction) string {
+	switch d {
+	case network.DirInbound:
+		return "inbound"
+	case network.DirOutbound:
+		return "outbound"
+	default:
+		return "unknown"
+	}
+}
+
+// GET /eth/v1/node/peers?state=&direction=
+func (s *Server) nodePeers(w http.ResponseWriter, r *http.Request) (interface{}, error) {
+	h, err := s.backend.Host()
+	if err != nil {
+		return nil, &apiErr{Code: http.StatusServiceUnavailable, Message: err.Error()}
+	}
+	store, err := s.backend.Peerstore()
+	if err != nil {
+		return nil, &apiErr{Code: http.StatusServiceUnavailable, Message: err.Error()}
+	}
+	q := r.URL.Query()
+	matches := func(key string, v string) bool {
+		filter := q[key]
+		if len(filter) == 0 {
+			return true
+		}
+		for _, f := range filter {
+			for _, el := range strings.Split(f, ",") {
+				if el == v {
+					return true
+				}
+			}
+		}
+		return false
+	}
+	out := &peersResponse{Data: make([]*peerJSON, 0)}
+	for _, p := range store.Peers() {
+		if p == h.ID() {
+			continue
+		}
+		item := &peerJSON{
+			PeerID:    p.String(),
+			State:     connectednessState(h.Network().Connectedness(p)),
+			Direction: "unknown",
+		}
+		if n := store.LatestENR(p); n != nil {
+			item.ENR = n.String()
+		}
+		if conns := h.Network().ConnsToPeer(p); len(conns) > 0 {
+			item.LastSeenP2PAddress = conns[0].RemoteMultiaddr().String()
+			item.Direction = directionStr(conns[0].Stat().Direction)
+		} else if addrs := store.Addrs(p); len(addrs) > 0 {
+			item.LastSeenP2PAddress = addrs[0].String()
+		}
+		if !matches("state", item.State) || !matches("direction", item.Direction) {
+			continue
+		}
+		out.Data = append(out.Data, item)
+	}
+	out.Meta = map[string]uint64{"count": uint64(len(out.Data))}
+	return out, nil
+}
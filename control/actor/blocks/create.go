@@ -2,6 +2,11 @@ package blocks
 
 import (
 	"context"
+	"errors"
+	"fmt"
+	ds "github.com/ipfs/go-datastore"
+	badger "github.com/ipfs/go-ds-badger"
+	leveldb "github.com/ipfs/go-ds-leveldb"
 	bdb "github.com/protolambda/rumor/chain/db/blocks"
 	"github.com/protolambda/rumor/control/actor/base"
 	"github.com/protolambda/zrnt/eth2/configs"
@@ -11,8 +16,13 @@ type CreateCmd struct {
 	*base.Base
 	bdb.DBs
 	*DBState
-	Name bdb.DBID `ask:"<name>" help:"The name to give to the created db. Must not exist yet."`
-	Path string   `ask:"[path]" help:"The path used for the DB. It will be a memory DB if left empty."`
+	Name      bdb.DBID `ask:"<name>" help:"The name to give to the created db. Must not exist yet."`
+	Path      string   `ask:"[path]" help:"The path used for the DB. It will be a memory DB if left empty."`
+	StoreType string   `ask:"--store-type" help:"The type of DB: 'file' (one SSZ file per block), or 'leveldb' or 'badger' (indexed by slot, parent and proposer). Ignored for memory DBs."`
+}
+
+func (c *CreateCmd) Default() {
+	c.StoreType = "file"
 }
 
 func (c *CreateCmd) Help() string {
@@ -20,8 +30,38 @@ func (c *CreateCmd) Help() string {
 }
 
 func (c *CreateCmd) Run(ctx context.Context, args ...string) error {
-	_, err := c.DBs.Create(c.Name, c.Path, configs.Mainnet) // TODO choose config
+	spec := configs.Mainnet // TODO choose config
+	if c.Path == "" || c.StoreType == "file" {
+		_, err := c.DBs.Create(c.Name, c.Path, spec)
+		if err != nil {
+			return err
+		}
+		c.DBState.CurrentDB = c.Name
+		return nil
+	}
+	if _, exists := c.DBs.Find(c.Name); exists {
+		return errors.New("db already existed")
+	}
+	var store ds.Batching
+	var err error
+	switch c.StoreType {
+	case "leveldb":
+		store, err = leveldb.NewDatastore(c.Path, nil)
+	case "badger":
+		store, err = badger.NewDatastore(c.Path, nil)
+	default:
+		return fmt.Errorf("unrecognized store type: %s", c.StoreType)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to open %s datastore: %v", c.StoreType, err)
+	}
+	db, err := bdb.NewKVDB(store, c.Path, spec)
 	if err != nil {
+		_ = store.Close()
+		return fmt.Errorf("failed to open blocks DB: %v", err)
+	}
+	if err := c.DBs.Put(c.Name, db); err != nil {
+		_ = db.Close()
 		return err
 	}
 	c.DBState.CurrentDB = c.Name
@@ -0,0 +1,88 @@
+package dbcmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	bdb "github.com/protolambda/rumor/chain/db/blocks"
+	"github.com/protolambda/rumor/control/actor/base"
+	"github.com/protolambda/zrnt/eth2/beacon"
+	"github.com/sirupsen/logrus"
+	"os"
+	"time"
+)
+
+type BlocksExportArchiveCmd struct {
+	*base.Base
+	bdb.DB
+	Output string      `ask:"--output" help:"File path to write the archive to"`
+	Start  beacon.Slot `ask:"--start" help:"Start slot, inclusive"`
+	End    beacon.Slot `ask:"--end" help:"End slot, exclusive"`
+}
+
+func (c *BlocksExportArchiveCmd) Default() {
+	c.End = ^beacon.Slot(0)
+}
+
+func (c *BlocksExportArchiveCmd) Help() string {
+	return "Export the blocks within a slot range into a single snappy-compressed archive, indexed by slot"
+}
+
+func (c *BlocksExportArchiveCmd) Run(ctx context.Context, args ...string) error {
+	if c.Output == "" {
+		return errors.New("no output, specify a file path with --output")
+	}
+	refs, err := bdb.Range(c.DB, c.Start, c.End)
+	if err != nil {
+		return err
+	}
+	f, err := os.OpenFile(c.Output, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0644)
+	if err != nil {
+		return fmt.Errorf("failed to open %s: %v", c.Output, err)
+	}
+	defer f.Close()
+	exported, err := bdb.ExportArchive(ctx, c.DB, refs, f, ArchiveProgress(c.Log, "exporting blocks"))
+	if err != nil {
+		return err
+	}
+	c.Log.WithFields(logrus.Fields{"output": c.Output, "blocks": exported}).Info("exported archive")
+	return nil
+}
+
+type BlocksImportArchiveCmd struct {
+	*base.Base
+	bdb.DB
+	Input string `ask:"--input" help:"File path of the archive to import"`
+}
+
+func (c *BlocksImportArchiveCmd) Help() string {
+	return "Import all blocks of an archive, skipping blocks that are already known"
+}
+
+func (c *BlocksImportArchiveCmd) Run(ctx context.Context, args ...string) error {
+	if c.Input == "" {
+		return errors.New("no input, specify a file path with --input")
+	}
+	f, err := os.Open(c.Input)
+	if err != nil {
+		return fmt.Errorf("failed to open %s: %v", c.Input, err)
+	}
+	defer f.Close()
+	imported, existing, err := bdb.ImportArchive(ctx, c.DB, f, ArchiveProgress(c.Log, "importing blocks"))
+	if err != nil {
+		return err
+	}
+	c.Log.WithFields(logrus.Fields{"input": c.Input, "imported": imported, "existing": existing}).Info("imported archive")
+	return nil
+}
+
+// ArchiveProgress logs the progress of a bulk archive import or export, at most every few seconds.
+func ArchiveProgress(log logrus.FieldLogger, msg string) func(done int, total int) {
+	last := time.Now()
+	return func(done int, total int) {
+		if now := time.Now(); now.Sub(last) >= 5*time.Second {
+			last = now
+			log.WithFields(logrus.Fields{"done": done, "total": total}).Info(msg)
+		}
+	}
+}
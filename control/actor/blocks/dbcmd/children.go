@@ -0,0 +1,27 @@
+package dbcmd
+
+import (
+	"context"
+	bdb "github.com/protolambda/rumor/chain/db/blocks"
+	"github.com/protolambda/rumor/control/actor/base"
+	"github.com/protolambda/zrnt/eth2/beacon"
+)
+
+type BlocksChildrenCmd struct {
+	*base.Base
+	bdb.DB
+	Parent beacon.Root `ask:"<root>" help:"Root of the parent block"`
+}
+
+func (c *BlocksChildrenCmd) Help() string {
+	return "List the roots of the blocks that build on the given block. Fast for leveldb and badger DBs, other DBs read every block."
+}
+
+func (c *BlocksChildrenCmd) Run(ctx context.Context, args ...string) error {
+	roots, err := bdb.Children(c.DB, c.Parent)
+	if err != nil {
+		return err
+	}
+	c.Log.WithField("parent", c.Parent).WithField("block_roots", roots).Infof("got %d children", len(roots))
+	return nil
+}
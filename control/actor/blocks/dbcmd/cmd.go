@@ -12,10 +12,9 @@ type DBCmd struct {
 }
 
 // TODO: more blocks command ideas:
-//  - download from http source
 //  - prune based on chain
 //  - automatic upload/export to some place
-//  - query blocks by attribute (slot, state root, parent root, eth1 data, etc.)
+//  - query blocks by more attributes (state root, eth1 data, etc.)
 
 func (c *DBCmd) Cmd(route string) (cmd interface{}, err error) {
 	switch route {
@@ -31,6 +30,18 @@ func (c *DBCmd) Cmd(route string) (cmd interface{}, err error) {
 		cmd = &BlocksStatsCmd{Base: c.Base, DB: c.DB}
 	case "list":
 		cmd = &BlocksListCmd{Base: c.Base, DB: c.DB}
+	case "export-archive":
+		cmd = &BlocksExportArchiveCmd{Base: c.Base, DB: c.DB}
+	case "import-archive":
+		cmd = &BlocksImportArchiveCmd{Base: c.Base, DB: c.DB}
+	case "fetch":
+		cmd = &BlocksFetchCmd{Base: c.Base, DB: c.DB}
+	case "verify":
+		cmd = &BlocksVerifyCmd{Base: c.Base, DB: c.DB}
+	case "range":
+		cmd = &BlocksRangeCmd{Base: c.Base, DB: c.DB}
+	case "children":
+		cmd = &BlocksChildrenCmd{Base: c.Base, DB: c.DB}
 	default:
 		return nil, ask.UnrecognizedErr
 	}
@@ -38,7 +49,7 @@ func (c *DBCmd) Cmd(route string) (cmd interface{}, err error) {
 }
 
 func (c *DBCmd) Routes() []string {
-	return []string{"import", "export", "get", "rm", "stats", "list"}
+	return []string{"import", "export", "get", "rm", "stats", "list", "range", "children", "verify", "export-archive", "import-archive", "fetch"}
 }
 
 func (c *DBCmd) Help() string {
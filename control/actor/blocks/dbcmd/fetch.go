@@ -0,0 +1,133 @@
+package dbcmd
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"github.com/protolambda/rumor/chain/beaconapi"
+	bdb "github.com/protolambda/rumor/chain/db/blocks"
+	"github.com/protolambda/rumor/control/actor/base"
+	"github.com/protolambda/zrnt/eth2/beacon"
+	"github.com/protolambda/ztyp/codec"
+	"github.com/sirupsen/logrus"
+	"io/ioutil"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+type BlocksFetchCmd struct {
+	*base.Base
+	bdb.DB
+	BeaconAPI string        `ask:"--beacon-api" help:"Base URL of a beacon node HTTP API, e.g. http://localhost:5052"`
+	Start     beacon.Slot   `ask:"--start" help:"Start slot, inclusive"`
+	End       beacon.Slot   `ask:"--end" help:"End slot, exclusive"`
+	Timeout   time.Duration `ask:"--timeout" help:"Timeout for each block request. 0 to disable"`
+	Progress  string        `ask:"--progress" help:"File to keep the next slot to fetch in. If it exists, fetching resumes from that slot."`
+}
+
+func (c *BlocksFetchCmd) Default() {
+	c.BeaconAPI = "http://localhost:5052"
+	c.Timeout = 20 * time.Second
+}
+
+func (c *BlocksFetchCmd) Help() string {
+	return "Download the SSZ blocks of a slot range from a beacon node HTTP API, check them and store them"
+}
+
+func (c *BlocksFetchCmd) Run(ctx context.Context, args ...string) error {
+	if c.End <= c.Start {
+		return errors.New("end slot must be after start slot, specify a range with --start and --end")
+	}
+	start := c.Start
+	if c.Progress != "" {
+		next, ok, err := readProgress(c.Progress)
+		if err != nil {
+			return err
+		}
+		if ok && next > start {
+			c.Log.WithField("slot", next).Info("resuming fetch")
+			start = next
+		}
+	}
+	spec := c.DB.Spec()
+	client := beaconapi.NewClient(c.BeaconAPI, c.Timeout)
+	var fetched, existing int
+	// Root of the last fetched block, to check that the next block builds on it
+	var prevRoot beacon.Root
+	var prevSlot beacon.Slot
+	hasPrev := false
+	lastLog := time.Now()
+	for slot := start; slot < c.End; slot++ {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		data, exists, err := client.BlockSSZ(ctx, strconv.FormatUint(uint64(slot), 10))
+		if err != nil {
+			return fmt.Errorf("failed to fetch block at slot %d: %v", slot, err)
+		}
+		if exists {
+			var block beacon.SignedBeaconBlock
+			if err := block.Deserialize(spec, codec.NewDecodingReader(bytes.NewReader(data), uint64(len(data)))); err != nil {
+				return fmt.Errorf("failed to decode block at slot %d: %v", slot, err)
+			}
+			if block.Message.Slot != slot {
+				return fmt.Errorf("requested block at slot %d, but got block of slot %d", slot, block.Message.Slot)
+			}
+			withRoot := bdb.WithRoot(spec, &block)
+			if hasPrev && block.Message.ParentRoot != prevRoot {
+				return fmt.Errorf("block %s at slot %d does not build on block %s at slot %d, the node may have reorged, retry",
+					withRoot.Root, slot, prevRoot, prevSlot)
+			}
+			known, err := c.DB.Store(ctx, withRoot)
+			if err != nil {
+				return fmt.Errorf("failed to store block %s: %v", withRoot.Root, err)
+			}
+			if known {
+				existing += 1
+			} else {
+				fetched += 1
+			}
+			prevRoot, prevSlot, hasPrev = withRoot.Root, slot, true
+		}
+		if c.Progress != "" {
+			if err := writeProgress(c.Progress, slot+1); err != nil {
+				return err
+			}
+		}
+		if now := time.Now(); now.Sub(lastLog) >= 5*time.Second {
+			lastLog = now
+			c.Log.WithFields(logrus.Fields{"slot": slot, "fetched": fetched, "existing": existing}).Info("fetching blocks")
+		}
+	}
+	c.Log.WithFields(logrus.Fields{
+		"start":    start,
+		"end":      c.End,
+		"fetched":  fetched,
+		"existing": existing,
+	}).Info("fetched blocks")
+	return nil
+}
+
+func readProgress(path string) (next beacon.Slot, ok bool, err error) {
+	data, err := ioutil.ReadFile(path)
+	if os.IsNotExist(err) {
+		return 0, false, nil
+	} else if err != nil {
+		return 0, false, fmt.Errorf("failed to read progress file: %v", err)
+	}
+	v, err := strconv.ParseUint(strings.TrimSpace(string(data)), 10, 64)
+	if err != nil {
+		return 0, false, fmt.Errorf("bad progress file: %v", err)
+	}
+	return beacon.Slot(v), true, nil
+}
+
+func writeProgress(path string, next beacon.Slot) error {
+	if err := ioutil.WriteFile(path, []byte(strconv.FormatUint(uint64(next), 10)+"\n"), 0644); err != nil {
+		return fmt.Errorf("failed to write progress file: %v", err)
+	}
+	return nil
+}
@@ -0,0 +1,202 @@
+package dbcmd
+
+import (
+	"bytes"
+	"context"
+	ds "github.com/ipfs/go-datastore"
+	dssync "github.com/ipfs/go-datastore/sync"
+	bdb "github.com/protolambda/rumor/chain/db/blocks"
+	"github.com/protolambda/rumor/control/actor/base"
+	"github.com/protolambda/zrnt/eth2/beacon"
+	"github.com/protolambda/zrnt/eth2/configs"
+	"github.com/protolambda/ztyp/codec"
+	"github.com/sirupsen/logrus"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// stubNode serves SSZ blocks by slot, like the blocks endpoint of a beacon node.
+type stubNode struct {
+	sync.Mutex
+	blocks    map[beacon.Slot]*beacon.SignedBeaconBlock
+	requested []beacon.Slot
+}
+
+func (n *stubNode) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	v, err := strconv.ParseUint(strings.TrimPrefix(r.URL.Path, "/eth/v2/beacon/blocks/"), 10, 64)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	slot := beacon.Slot(v)
+	n.Lock()
+	n.requested = append(n.requested, slot)
+	block, ok := n.blocks[slot]
+	n.Unlock()
+	if !ok {
+		http.Error(w, "not found", http.StatusNotFound)
+		return
+	}
+	var buf bytes.Buffer
+	if err := block.Serialize(configs.Minimal, codec.NewEncodingWriter(&buf)); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/octet-stream")
+	w.Header().Set("Eth-Consensus-Version", "phase0")
+	_, _ = w.Write(buf.Bytes())
+}
+
+// stubChain builds a chain with a block at every slot up to the end slot, except the empty slots.
+func stubChain(end beacon.Slot, empty ...beacon.Slot) (blocks map[beacon.Slot]*beacon.SignedBeaconBlock, roots map[beacon.Slot]beacon.Root) {
+	blocks = make(map[beacon.Slot]*beacon.SignedBeaconBlock)
+	roots = make(map[beacon.Slot]beacon.Root)
+	var parent beacon.Root
+outer:
+	for slot := beacon.Slot(0); slot < end; slot++ {
+		for _, e := range empty {
+			if slot == e {
+				continue outer
+			}
+		}
+		block := &beacon.SignedBeaconBlock{Message: beacon.BeaconBlock{Slot: slot, ParentRoot: parent}}
+		blocks[slot] = block
+		parent = bdb.WithRoot(configs.Minimal, block).Root
+		roots[slot] = parent
+	}
+	return blocks, roots
+}
+
+func newFetchCmd(t *testing.T, node *stubNode) (*BlocksFetchCmd, bdb.DB) {
+	srv := httptest.NewServer(node)
+	t.Cleanup(srv.Close)
+	db, err := bdb.NewKVDB(dssync.MutexWrap(ds.NewMapDatastore()), "", configs.Minimal)
+	if err != nil {
+		t.Fatal(err)
+	}
+	log := logrus.New()
+	log.SetOutput(ioutil.Discard)
+	cmd := &BlocksFetchCmd{Base: &base.Base{Log: log}, DB: db}
+	cmd.Default()
+	cmd.BeaconAPI = srv.URL
+	cmd.Progress = filepath.Join(t.TempDir(), "progress")
+	return cmd, db
+}
+
+func readTestProgress(t *testing.T, cmd *BlocksFetchCmd) beacon.Slot {
+	next, ok, err := readProgress(cmd.Progress)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !ok {
+		t.Fatal("no progress file")
+	}
+	return next
+}
+
+func TestBlocksFetch(t *testing.T) {
+	blocks, roots := stubChain(6, 3)
+	node := &stubNode{blocks: blocks}
+	cmd, db := newFetchCmd(t, node)
+	cmd.Start, cmd.End = 1, 6
+	if err := cmd.Run(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	for slot := beacon.Slot(1); slot < 6; slot++ {
+		_, exists := db.Size(roots[slot])
+		if expected := slot != 3; exists != expected {
+			t.Fatalf("expected block at slot %d to be stored: %v, got %v", slot, expected, exists)
+		}
+	}
+	if _, exists := db.Size(roots[0]); exists {
+		t.Fatal("block before the start slot was stored")
+	}
+	if next := readTestProgress(t, cmd); next != 6 {
+		t.Fatalf("expected progress at slot 6, got %d", next)
+	}
+}
+
+func TestBlocksFetchResume(t *testing.T) {
+	blocks, roots := stubChain(6)
+	node := &stubNode{blocks: blocks}
+	cmd, db := newFetchCmd(t, node)
+	cmd.Start, cmd.End = 1, 6
+	if err := writeProgress(cmd.Progress, 4); err != nil {
+		t.Fatal(err)
+	}
+	if err := cmd.Run(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if len(node.requested) != 2 || node.requested[0] != 4 || node.requested[1] != 5 {
+		t.Fatalf("expected to resume at slot 4, requested slots %v", node.requested)
+	}
+	if _, exists := db.Size(roots[3]); exists {
+		t.Fatal("block before the progress slot was stored")
+	}
+	if _, exists := db.Size(roots[5]); !exists {
+		t.Fatal("block after the progress slot was not stored")
+	}
+
+	// Progress before the start slot is ignored
+	node.requested = nil
+	cmd.Start = 5
+	if err := writeProgress(cmd.Progress, 2); err != nil {
+		t.Fatal(err)
+	}
+	if err := cmd.Run(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if len(node.requested) != 1 || node.requested[0] != 5 {
+		t.Fatalf("expected to start at slot 5, requested slots %v", node.requested)
+	}
+
+	// A bad progress file is an error, not a restart
+	if err := ioutil.WriteFile(cmd.Progress, []byte("foobar"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := cmd.Run(context.Background()); err == nil {
+		t.Fatal("expected bad progress file to fail")
+	}
+}
+
+func TestBlocksFetchSlotMismatch(t *testing.T) {
+	blocks, _ := stubChain(4)
+	// The node answers the request for slot 2 with the block of slot 1
+	blocks[2] = blocks[1]
+	cmd, db := newFetchCmd(t, &stubNode{blocks: blocks})
+	cmd.Start, cmd.End = 1, 4
+	err := cmd.Run(context.Background())
+	if err == nil || !strings.Contains(err.Error(), "got block of slot 1") {
+		t.Fatalf("expected slot mismatch error, got %v", err)
+	}
+	if n := db.Stats().Count; n != 1 {
+		t.Fatalf("expected only the block of slot 1 to be stored, got %d blocks", n)
+	}
+	if next := readTestProgress(t, cmd); next != 2 {
+		t.Fatalf("expected progress at the mismatched slot 2, got %d", next)
+	}
+}
+
+func TestBlocksFetchParentMismatch(t *testing.T) {
+	blocks, roots := stubChain(5, 2)
+	// A block at slot 3 from another branch, it does not build on slot 1
+	blocks[3] = &beacon.SignedBeaconBlock{Message: beacon.BeaconBlock{Slot: 3, ParentRoot: roots[0]}}
+	cmd, db := newFetchCmd(t, &stubNode{blocks: blocks})
+	cmd.Start, cmd.End = 1, 5
+	err := cmd.Run(context.Background())
+	if err == nil || !strings.Contains(err.Error(), "does not build on block") {
+		t.Fatalf("expected parent mismatch error, got %v", err)
+	}
+	if n := db.Stats().Count; n != 1 {
+		t.Fatalf("expected only the block of slot 1 to be stored, got %d blocks", n)
+	}
+	if next := readTestProgress(t, cmd); next != 3 {
+		t.Fatalf("expected progress at the mismatched slot 3, got %d", next)
+	}
+}
@@ -0,0 +1,36 @@
+package dbcmd
+
+import (
+	"context"
+	"fmt"
+	bdb "github.com/protolambda/rumor/chain/db/blocks"
+	"github.com/protolambda/rumor/control/actor/base"
+	"github.com/protolambda/zrnt/eth2/beacon"
+)
+
+type BlocksRangeCmd struct {
+	*base.Base
+	bdb.DB
+	Start beacon.Slot `ask:"--start" help:"Start slot, inclusive"`
+	End   beacon.Slot `ask:"--end" help:"End slot, exclusive"`
+}
+
+func (c *BlocksRangeCmd) Default() {
+	c.End = ^beacon.Slot(0)
+}
+
+func (c *BlocksRangeCmd) Help() string {
+	return "List the blocks within a slot range, ordered by slot. Fast for leveldb and badger DBs, other DBs read every block."
+}
+
+func (c *BlocksRangeCmd) Run(ctx context.Context, args ...string) error {
+	if c.End < c.Start {
+		return fmt.Errorf("end slot %d is before start slot %d", c.End, c.Start)
+	}
+	refs, err := bdb.Range(c.DB, c.Start, c.End)
+	if err != nil {
+		return err
+	}
+	c.Log.WithField("blocks", refs).Infof("got %d blocks", len(refs))
+	return nil
+}
@@ -0,0 +1,67 @@
+package dbcmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	bdb "github.com/protolambda/rumor/chain/db/blocks"
+	"github.com/protolambda/rumor/control/actor/base"
+	"github.com/sirupsen/logrus"
+)
+
+type BlocksVerifyCmd struct {
+	*base.Base
+	bdb.DB
+	Quarantine string `ask:"--quarantine" help:"Move bad entries out of the DB, to 0x<root>.ssz files in this directory"`
+	Remove     bool   `ask:"--remove" help:"Remove bad entries from the DB"`
+	Orphans    bool   `ask:"--orphans" help:"Also quarantine or remove orphaned blocks, not only corrupt, mismatched and badly signed entries"`
+}
+
+func (c *BlocksVerifyCmd) Help() string {
+	return "Re-decode every block, check its root, signature structure and parent, and report and optionally quarantine or remove bad entries"
+}
+
+func (c *BlocksVerifyCmd) Run(ctx context.Context, args ...string) error {
+	if c.Quarantine != "" && c.Remove {
+		return errors.New("cannot both quarantine and remove bad entries")
+	}
+	counts := make(map[bdb.Problem]int)
+	var bad []bdb.Issue
+	checked, err := bdb.Verify(ctx, c.DB, func(issue bdb.Issue) {
+		counts[issue.Problem] += 1
+		c.Log.WithFields(logrus.Fields{
+			"root":    issue.Root,
+			"problem": issue.Problem,
+			"detail":  issue.Detail,
+		}).Warn("bad block entry")
+		if issue.Problem != bdb.ProblemOrphan || c.Orphans {
+			bad = append(bad, issue)
+		}
+	})
+	if err != nil {
+		return err
+	}
+	repaired := 0
+	if c.Quarantine != "" || c.Remove {
+		for _, issue := range bad {
+			if c.Quarantine != "" {
+				err = bdb.Quarantine(c.DB, issue.Root, c.Quarantine)
+			} else {
+				_, err = c.DB.Remove(issue.Root)
+			}
+			if err != nil {
+				return fmt.Errorf("failed to repair DB, %d of %d bad entries done: %v", repaired, len(bad), err)
+			}
+			repaired += 1
+		}
+	}
+	c.Log.WithFields(logrus.Fields{
+		"checked":       checked,
+		"corrupt":       counts[bdb.ProblemCorrupt],
+		"mismatch":      counts[bdb.ProblemMismatch],
+		"bad_signature": counts[bdb.ProblemSignature],
+		"orphan":        counts[bdb.ProblemOrphan],
+		"repaired":      repaired,
+	}).Info("verified blocks DB")
+	return nil
+}
@@ -2,9 +2,11 @@ package blocks
 
 import (
 	"context"
+	"fmt"
 	bdb "github.com/protolambda/rumor/chain/db/blocks"
 	"github.com/protolambda/rumor/control/actor/base"
 	"github.com/sirupsen/logrus"
+	"io"
 )
 
 type RemoveCmd struct {
@@ -18,7 +20,14 @@ func (c *RemoveCmd) Help() string {
 }
 
 func (c *RemoveCmd) Run(ctx context.Context, args ...string) error {
+	db, _ := c.DBs.Find(c.Name)
 	existed := c.DBs.Remove(c.Name)
 	c.Log.WithFields(logrus.Fields{"existed": existed, "name": c.Name}).Info("removed DB")
+	// Release datastore-backed DBs, the persisted blocks are kept
+	if closer, ok := db.(io.Closer); ok {
+		if err := closer.Close(); err != nil {
+			return fmt.Errorf("failed to close DB: %v", err)
+		}
+	}
 	return nil
 }
@@ -8,6 +8,8 @@ import (
 	sdb "github.com/protolambda/rumor/chain/db/states"
 	"github.com/protolambda/rumor/control/actor/base"
 	"github.com/protolambda/rumor/control/actor/chain/chcmd"
+	"github.com/protolambda/rumor/control/actor/chain/clockcmd"
+	"github.com/protolambda/rumor/control/actor/gossip"
 )
 
 type ChainState struct {
@@ -18,8 +20,10 @@ type ChainCmd struct {
 	*base.Base
 	chain.Chains
 	*ChainState
-	Blocks bdb.DB
-	States sdb.DB
+	ClockState *clockcmd.ClockState
+	Blocks     bdb.DB
+	States     sdb.DB
+	*gossip.GossipState
 }
 
 // TODO: more chain command ideas:
@@ -28,7 +32,9 @@ type ChainCmd struct {
 func (c *ChainCmd) Cmd(route string) (cmd interface{}, err error) {
 	switch route {
 	case "create":
-		cmd = &ChainCreateCmd{Base: c.Base, Chains: c.Chains, States: c.States, ChainState: c.ChainState}
+		cmd = &ChainCreateCmd{Base: c.Base, Chains: c.Chains, Blocks: c.Blocks, States: c.States, ChainState: c.ChainState}
+	case "checkpoint-sync":
+		cmd = &ChainCheckpointSyncCmd{Base: c.Base, Chains: c.Chains, Blocks: c.Blocks, States: c.States, ChainState: c.ChainState}
 	case "copy":
 		cmd = &ChainCopyCmd{Base: c.Base}
 	case "switch":
@@ -37,14 +43,17 @@ func (c *ChainCmd) Cmd(route string) (cmd interface{}, err error) {
 		cmd = &ChainRemoveCmd{Base: c.Base, Chains: c.Chains}
 	case "list":
 		cmd = &ChainListCmd{Base: c.Base, Chains: c.Chains, ChainState: c.ChainState}
+	case "clock":
+		currentChain, _ := c.Chains.Find(c.ChainState.CurrentChain)
+		cmd = &clockcmd.ClockCmd{Base: c.Base, ClockState: c.ClockState, Chain: currentChain}
 	case "this":
 		currentChain, ok := c.Chains.Find(c.ChainState.CurrentChain)
 		if !ok {
 			return nil, fmt.Errorf("current chain was not found. Use 'chain create' to create chains")
 		}
-		cmd = &chcmd.ChainCmd{Base: c.Base, Chain: currentChain, Blocks: c.Blocks, States: c.States}
+		cmd = &chcmd.ChainCmd{Base: c.Base, Chain: currentChain, Blocks: c.Blocks, States: c.States, ClockState: c.ClockState, GossipState: c.GossipState}
 	case "on":
-		cmd = &OnCmd{Base: c.Base, Chains: c.Chains, Blocks: c.Blocks, States: c.States}
+		cmd = &OnCmd{Base: c.Base, Chains: c.Chains, ClockState: c.ClockState, Blocks: c.Blocks, States: c.States, GossipState: c.GossipState}
 	default:
 		return nil, ask.UnrecognizedErr
 	}
@@ -52,7 +61,7 @@ func (c *ChainCmd) Cmd(route string) (cmd interface{}, err error) {
 }
 
 func (c *ChainCmd) Routes() []string {
-	return []string{"create", "copy", "switch", "rm", "list", "this", "on"}
+	return []string{"create", "checkpoint-sync", "copy", "switch", "rm", "list", "clock", "this", "on"}
 }
 
 func (c *ChainCmd) Help() string {
@@ -0,0 +1,83 @@
+package chcmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/protolambda/rumor/chain"
+	bdb "github.com/protolambda/rumor/chain/db/blocks"
+	"github.com/protolambda/rumor/control/actor/base"
+	"github.com/protolambda/rumor/control/actor/blocks/dbcmd"
+	"github.com/protolambda/zrnt/eth2/beacon"
+	"github.com/sirupsen/logrus"
+	"os"
+)
+
+type ExportArchiveCmd struct {
+	*base.Base
+	Chain  chain.FullChain
+	Blocks bdb.DB
+	Output string      `ask:"--output" help:"File path to write the archive to"`
+	Start  beacon.Slot `ask:"--start" help:"Start slot, inclusive. Defaults to the start of the chain."`
+	End    beacon.Slot `ask:"--end" help:"End slot, exclusive. Defaults to the end of the chain."`
+}
+
+func (c *ExportArchiveCmd) Default() {
+	c.End = ^beacon.Slot(0)
+}
+
+func (c *ExportArchiveCmd) Help() string {
+	return "Export the blocks of the canonical chain into a single snappy-compressed archive, indexed by slot, with the blocks from the blocks DB"
+}
+
+func (c *ExportArchiveCmd) Run(ctx context.Context, args ...string) error {
+	if c.Output == "" {
+		return errors.New("no output, specify a file path with --output")
+	}
+	iter, err := c.Chain.Iter()
+	if err != nil {
+		return fmt.Errorf("cannot iterate chain: %v", err)
+	}
+	start, end := c.Start, c.End
+	if start < iter.Start() {
+		start = iter.Start()
+	}
+	if end > iter.End() {
+		end = iter.End()
+	}
+	var refs []bdb.BlockRef
+	var prev beacon.Root
+	for slot := start; slot < end; slot++ {
+		entry, err := iter.Entry(slot)
+		if err != nil {
+			return fmt.Errorf("cannot get entry for slot %d: %v", slot, err)
+		}
+		if entry == nil || entry.IsEmpty() {
+			continue
+		}
+		// Entries of empty slots repeat the root of the last block
+		if root := entry.BlockRoot(); root != prev {
+			refs = append(refs, bdb.BlockRef{Slot: slot, Root: root})
+			prev = root
+		}
+	}
+	f, err := os.OpenFile(c.Output, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0644)
+	if err != nil {
+		return fmt.Errorf("failed to open %s: %v", c.Output, err)
+	}
+	exported, err := bdb.ExportArchive(ctx, c.Blocks, refs, f, dbcmd.ArchiveProgress(c.Log, "exporting chain"))
+	if err != nil {
+		_ = f.Close()
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return fmt.Errorf("failed to close %s: %v", c.Output, err)
+	}
+	fields := logrus.Fields{"output": c.Output, "blocks": exported, "start": start, "end": end}
+	if missing := len(refs) - exported; missing > 0 {
+		c.Log.WithFields(fields).WithField("missing", missing).Warn("exported archive, but blocks DB is missing canonical blocks")
+	} else {
+		c.Log.WithFields(fields).Info("exported archive")
+	}
+	return nil
+}
@@ -0,0 +1,144 @@
+package chcmd
+
+import (
+	"context"
+	ds "github.com/ipfs/go-datastore"
+	dssync "github.com/ipfs/go-datastore/sync"
+	"github.com/protolambda/rumor/chain"
+	bdb "github.com/protolambda/rumor/chain/db/blocks"
+	"github.com/protolambda/rumor/chain/interop"
+	"github.com/protolambda/rumor/control/actor/base"
+	"github.com/protolambda/zrnt/eth2/beacon"
+	"github.com/protolambda/zrnt/eth2/configs"
+	"github.com/sirupsen/logrus"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func newTestBlocksDB(t *testing.T) bdb.DB {
+	db, err := bdb.NewKVDB(dssync.MutexWrap(ds.NewMapDatastore()), "", configs.Minimal)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return db
+}
+
+// hotTestChain creates a chain from an interop genesis state, with a block at each of the slots,
+// each block on top of the previous one. The blocks are stored in the DB.
+func hotTestChain(t *testing.T, db bdb.DB, slots ...beacon.Slot) (chain.FullChain, []beacon.Root) {
+	spec := configs.Minimal
+	deps := make([]beacon.Deposit, spec.MIN_GENESIS_ACTIVE_VALIDATOR_COUNT)
+	for i := range deps {
+		deps[i].Data = beacon.DepositData{
+			Pubkey: interop.Pubkey(beacon.ValidatorIndex(i)),
+			Amount: spec.MAX_EFFECTIVE_BALANCE,
+		}
+	}
+	state, _, err := spec.GenesisFromEth1(beacon.Root{0x42}, spec.MIN_GENESIS_TIME, deps, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	anchor, err := chain.HotEntryFromState(spec, state)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var chains chain.ChainsMap
+	ch, err := chains.Create("test", anchor, spec)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := context.Background()
+	var parent chain.ChainEntry = anchor
+	roots := make([]beacon.Root, 0, len(slots))
+	for _, slot := range slots {
+		epc, err := parent.EpochsContext(ctx)
+		if err != nil {
+			t.Fatal(err)
+		}
+		pre, err := parent.State(ctx)
+		if err != nil {
+			t.Fatal(err)
+		}
+		block, err := interop.ProposeBlock(ctx, spec, epc, pre, parent.BlockRoot(), slot, beacon.Root{}, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := ch.AddBlock(ctx, block); err != nil {
+			t.Fatal(err)
+		}
+		withRoot := bdb.WithRoot(spec, block)
+		if _, err := db.Store(ctx, withRoot); err != nil {
+			t.Fatal(err)
+		}
+		roots = append(roots, withRoot.Root)
+		if parent, err = ch.ByBlockRoot(withRoot.Root); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return ch, roots
+}
+
+func TestExportArchiveHotRange(t *testing.T) {
+	db := newTestBlocksDB(t)
+	// Slot 3 is empty
+	slots := []beacon.Slot{1, 2, 4, 5}
+	ch, roots := hotTestChain(t, db, slots...)
+
+	log := logrus.New()
+	log.SetOutput(ioutil.Discard)
+	cmd := &ExportArchiveCmd{Base: &base.Base{Log: log}, Chain: ch, Blocks: db}
+	cmd.Default()
+	cmd.Output = filepath.Join(t.TempDir(), "chain.archive")
+	if err := cmd.Run(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	f, err := os.Open(cmd.Output)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	index, err := bdb.ReadArchiveIndex(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(index) != len(slots) {
+		t.Fatalf("expected %d archived blocks, got %d", len(slots), len(index))
+	}
+	for i, e := range index {
+		if e.Slot != slots[i] {
+			t.Fatalf("expected archived block %d at slot %d, got slot %d", i, slots[i], e.Slot)
+		}
+	}
+	imported := newTestBlocksDB(t)
+	if _, err := f.Seek(0, 0); err != nil {
+		t.Fatal(err)
+	}
+	if n, _, err := bdb.ImportArchive(context.Background(), imported, f, nil); err != nil || n != len(roots) {
+		t.Fatalf("expected %d imported blocks, got %d (err: %v)", len(roots), n, err)
+	}
+	for i, root := range roots {
+		if _, exists := imported.Size(root); !exists {
+			t.Fatalf("block %s of slot %d was not archived", root, slots[i])
+		}
+	}
+
+	// A range in the middle of the hot chain, starting at the empty slot
+	cmd.Start, cmd.End = 3, 5
+	if err := cmd.Run(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	out, err := os.Open(cmd.Output)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer out.Close()
+	if index, err = bdb.ReadArchiveIndex(out); err != nil {
+		t.Fatal(err)
+	}
+	if len(index) != 1 || index[0].Slot != 4 {
+		t.Fatalf("expected only the block of slot 4, got %v", index)
+	}
+}
@@ -15,6 +15,8 @@ type BlockCmd struct {
 	Blocks bdb.DB
 	Chain  chain.FullChain
 	Root   beacon.Root `ask:"<root>" help:"Root of the block to add to the chain"`
+	Trace  bool        `ask:"--trace" help:"Trace the state transition of the block, and log the time spent in each step"`
+	Deltas bool        `ask:"--deltas" help:"With --trace, also log the balance delta of each validator"`
 }
 
 func (c *BlockCmd) Help() string {
@@ -30,8 +32,30 @@ func (c *BlockCmd) Run(ctx context.Context, args ...string) error {
 	if !exists {
 		return errors.New("block does not exist")
 	}
+	if !c.Trace {
+		if err := c.Chain.AddBlock(ctx, &block); err != nil {
+			return fmt.Errorf("could not add block to chain: %v", err)
+		}
+		return nil
+	}
+	uc, err := unfinalizedChain(c.Chain)
+	if err != nil {
+		return err
+	}
+	tracer := uc.GetTracer()
+	if tracer == nil {
+		// Trace just this block
+		tracer = chain.NewTransitionTracer(1)
+		uc.SetTracer(tracer)
+		defer uc.SetTracer(nil)
+	}
 	if err := c.Chain.AddBlock(ctx, &block); err != nil {
 		return fmt.Errorf("could not add block to chain: %v", err)
 	}
+	t, ok := tracer.ByBlockRoot(c.Root)
+	if !ok {
+		return errors.New("block was processed, but no trace was recorded")
+	}
+	c.Log.WithFields(traceFields(t, c.Deltas)).Info("block trace")
 	return nil
 }
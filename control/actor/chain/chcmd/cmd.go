@@ -7,17 +7,23 @@ import (
 	sdb "github.com/protolambda/rumor/chain/db/states"
 	"github.com/protolambda/rumor/control/actor/base"
 	"github.com/protolambda/rumor/control/actor/chain/chcmd/cold"
+	"github.com/protolambda/rumor/control/actor/chain/chcmd/forkchoice"
 	"github.com/protolambda/rumor/control/actor/chain/chcmd/head"
 	"github.com/protolambda/rumor/control/actor/chain/chcmd/hot"
 	"github.com/protolambda/rumor/control/actor/chain/chcmd/serve"
+	"github.com/protolambda/rumor/control/actor/chain/chcmd/slashings"
 	"github.com/protolambda/rumor/control/actor/chain/chcmd/sync"
+	"github.com/protolambda/rumor/control/actor/chain/clockcmd"
+	"github.com/protolambda/rumor/control/actor/gossip"
 )
 
 type ChainCmd struct {
 	*base.Base
-	Chain  chain.FullChain
-	Blocks bdb.DB
-	States sdb.DB
+	Chain      chain.FullChain
+	Blocks     bdb.DB
+	States     sdb.DB
+	ClockState *clockcmd.ClockState
+	*gossip.GossipState
 }
 
 func (c *ChainCmd) Cmd(route string) (cmd interface{}, err error) {
@@ -26,16 +32,34 @@ func (c *ChainCmd) Cmd(route string) (cmd interface{}, err error) {
 		cmd = &AttestationCmd{Base: c.Base}
 	case "block":
 		cmd = &BlockCmd{Base: c.Base, Chain: c.Chain, Blocks: c.Blocks}
+	case "duties":
+		cmd = &DutiesCmd{Base: c.Base, Chain: c.Chain}
+	case "committees":
+		cmd = &CommitteesCmd{Base: c.Base, Chain: c.Chain}
+	case "events":
+		cmd = &EventsCmd{Base: c.Base, Chain: c.Chain}
+	case "export-archive":
+		cmd = &ExportArchiveCmd{Base: c.Base, Chain: c.Chain, Blocks: c.Blocks}
+	case "forkchoice":
+		cmd = &forkchoice.ForkChoiceCmd{Base: c.Base, Chain: c.Chain}
 	case "hot":
 		cmd = &hot.HotCmd{Base: c.Base}
 	case "cold":
-		cmd = &cold.ColdCmd{Base: c.Base}
+		cmd = &cold.ColdCmd{Base: c.Base, Chain: c.Chain}
 	case "head":
 		cmd = &head.HeadCmd{Base: c.Base}
+	case "propose":
+		cmd = &ProposeCmd{Base: c.Base, Chain: c.Chain, Blocks: c.Blocks, ClockState: c.ClockState, GossipState: c.GossipState}
 	case "serve":
 		cmd = &serve.ServeCmd{Base: c.Base, Chain: c.Chain, Blocks: c.Blocks}
 	case "sync":
 		cmd = &sync.SyncCmd{Base: c.Base, Chain: c.Chain, Blocks: c.Blocks}
+	case "slashings":
+		cmd = &slashings.SlashingsCmd{Base: c.Base, Chain: c.Chain, Blocks: c.Blocks, GossipState: c.GossipState}
+	case "state-query":
+		cmd = &StateQueryCmd{Base: c.Base, Chain: c.Chain}
+	case "trace":
+		cmd = &TraceCmd{Base: c.Base, Chain: c.Chain}
 	case "votes":
 		cmd = &VotesCmd{Base: c.Base}
 	default:
@@ -45,7 +69,7 @@ func (c *ChainCmd) Cmd(route string) (cmd interface{}, err error) {
 }
 
 func (c *ChainCmd) Routes() []string {
-	return []string{"attestation", "block", "hot", "cold", "head", "serve", "sync", "votes"}
+	return []string{"attestation", "block", "duties", "committees", "events", "export-archive", "forkchoice", "hot", "cold", "head", "propose", "serve", "slashings", "sync", "state-query", "trace", "votes"}
 }
 
 func (c *ChainCmd) Help() string {
@@ -0,0 +1,60 @@
+package cold
+
+import (
+	"context"
+	"errors"
+	"github.com/protolambda/rumor/chain"
+	"github.com/protolambda/rumor/control/actor/base"
+	"github.com/sirupsen/logrus"
+)
+
+type CacheCmd struct {
+	*base.Base
+	Chain chain.FullChain
+	Size  int  `ask:"--size" help:"Change the number of states to keep in the cache. 0 to disable the cache, -1 to keep the current size."`
+	Clear bool `ask:"--clear" help:"Remove all cached states and reset the statistics"`
+}
+
+func (c *CacheCmd) Default() {
+	c.Size = -1
+}
+
+func (c *CacheCmd) Help() string {
+	return "Show the statistics of the state cache of the cold chain, and optionally resize or clear it."
+}
+
+func (c *CacheCmd) Run(ctx context.Context, args ...string) error {
+	hc, ok := c.Chain.(*chain.HotColdChain)
+	if !ok {
+		return errors.New("chain has no cold part")
+	}
+	fin, ok := hc.ColdChain.(*chain.FinalizedChain)
+	if !ok || fin.Cache == nil {
+		return errors.New("cold chain has no state cache")
+	}
+	if c.Clear {
+		fin.Cache.Clear()
+	}
+	if c.Size >= 0 {
+		fin.Cache.Resize(c.Size)
+	}
+	stats := fin.Cache.Stats()
+	var replayAvg float64
+	if stats.Replays > 0 {
+		replayAvg = stats.ReplayTotal.Seconds() / float64(stats.Replays)
+	}
+	c.Log.WithFields(logrus.Fields{
+		"size":           stats.Size,
+		"capacity":       stats.Capacity,
+		"hits":           stats.Hits,
+		"misses":         stats.Misses,
+		"hit_rate":       stats.HitRate(),
+		"replays":        stats.Replays,
+		"replayed_slots": stats.ReplayedSlots,
+		"replay_total":   stats.ReplayTotal.Seconds(),
+		"replay_avg":     replayAvg,
+		"replay_max":     stats.ReplayMax.Seconds(),
+		"replay_last":    stats.ReplayLast.Seconds(),
+	}).Info("state cache stats")
+	return nil
+}
@@ -2,17 +2,21 @@ package cold
 
 import (
 	"github.com/protolambda/ask"
+	"github.com/protolambda/rumor/chain"
 	"github.com/protolambda/rumor/control/actor/base"
 )
 
 type ColdCmd struct {
 	*base.Base
+	Chain chain.FullChain
 }
 
 func (c *ColdCmd) Cmd(route string) (cmd interface{}, err error) {
 	switch route {
-	case "cold":
+	case "view":
 		cmd = &ViewCmd{Base: c.Base}
+	case "cache":
+		cmd = &CacheCmd{Base: c.Base, Chain: c.Chain}
 	default:
 		return nil, ask.UnrecognizedErr
 	}
@@ -20,7 +24,7 @@ func (c *ColdCmd) Cmd(route string) (cmd interface{}, err error) {
 }
 
 func (c *ColdCmd) Routes() []string {
-	return []string{"view"}
+	return []string{"view", "cache"}
 }
 
 func (c *ColdCmd) Help() string {
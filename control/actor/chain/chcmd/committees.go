@@ -0,0 +1,72 @@
+package chcmd
+
+import (
+	"context"
+	"fmt"
+	"github.com/protolambda/rumor/chain"
+	"github.com/protolambda/rumor/control/actor/base"
+	"github.com/protolambda/zrnt/eth2/beacon"
+	"github.com/sirupsen/logrus"
+)
+
+type CommitteesCmd struct {
+	*base.Base
+	Chain chain.FullChain
+	Epoch beacon.Epoch `ask:"--epoch" help:"The epoch to list the committees of. Defaults to the epoch of the head."`
+	Slot  beacon.Slot  `ask:"--slot" help:"Only list the committees of this slot. Overrides --epoch."`
+	Index int64        `ask:"--index" help:"Only list the committee with this index. -1 for all committees."`
+}
+
+func (c *CommitteesCmd) Default() {
+	c.Epoch = HeadEpoch
+	c.Slot = ^beacon.Slot(0)
+	c.Index = -1
+}
+
+func (c *CommitteesCmd) Help() string {
+	return "List the committees of an epoch or slot."
+}
+
+func (c *CommitteesCmd) Run(ctx context.Context, args ...string) error {
+	epoch := c.Epoch
+	filterSlot := c.Slot != ^beacon.Slot(0)
+	if filterSlot {
+		head, err := c.Chain.Head()
+		if err != nil {
+			return fmt.Errorf("failed to get head: %v", err)
+		}
+		headEpc, err := head.EpochsContext(ctx)
+		if err != nil {
+			return err
+		}
+		epoch = headEpc.Spec.SlotToEpoch(c.Slot)
+	}
+	epc, shuf, _, err := epochShuffling(ctx, c.Chain, epoch)
+	if err != nil {
+		return err
+	}
+	spec := epc.Spec
+	start := spec.EpochStartSlot(shuf.Epoch)
+	count := 0
+	for i, slotComms := range shuf.Committees {
+		slot := start + beacon.Slot(i)
+		if filterSlot && slot != c.Slot {
+			continue
+		}
+		for ci, committee := range slotComms {
+			if c.Index >= 0 && int64(ci) != c.Index {
+				continue
+			}
+			c.Log.WithFields(logrus.Fields{
+				"slot":    slot,
+				"index":   ci,
+				"subnet":  attestationSubnet(spec, uint64(len(slotComms)), slot, beacon.CommitteeIndex(ci)),
+				"size":    len(committee),
+				"members": committee,
+			}).Info("committee")
+			count += 1
+		}
+	}
+	c.Log.WithFields(logrus.Fields{"epoch": shuf.Epoch, "count": count}).Info("listed committees")
+	return nil
+}
@@ -0,0 +1,166 @@
+package chcmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/protolambda/rumor/chain"
+	"github.com/protolambda/rumor/control/actor/base"
+	"github.com/protolambda/rumor/control/actor/flags"
+	"github.com/protolambda/zrnt/eth2/beacon"
+	"github.com/sirupsen/logrus"
+)
+
+// HeadEpoch is the default epoch flag value, to use the epoch of the head of the chain.
+const HeadEpoch = ^beacon.Epoch(0)
+
+// epochShuffling finds the epochs-context of a chain entry that has the shuffling of the given epoch.
+// The epoch after the head is supported, but then the proposers are unknown (isCurrent=false).
+func epochShuffling(ctx context.Context, ch chain.FullChain, epoch beacon.Epoch) (
+	epc *beacon.EpochsContext, shuf *beacon.ShufflingEpoch, isCurrent bool, err error) {
+	head, err := ch.Head()
+	if err != nil {
+		return nil, nil, false, fmt.Errorf("failed to get head: %v", err)
+	}
+	epc, err = head.EpochsContext(ctx)
+	if err != nil {
+		return nil, nil, false, fmt.Errorf("failed to get epochs context of head: %v", err)
+	}
+	spec := epc.Spec
+	headEpoch := spec.SlotToEpoch(head.Slot())
+	if epoch == HeadEpoch {
+		epoch = headEpoch
+	}
+	if epoch > headEpoch+1 {
+		return nil, nil, false, fmt.Errorf("epoch %d is too far ahead of the head epoch %d", epoch, headEpoch)
+	}
+	if epoch < headEpoch {
+		epc = nil
+		start := spec.EpochStartSlot(epoch)
+		for slot := start; slot < start+spec.SLOTS_PER_EPOCH; slot++ {
+			entry, err := ch.BySlot(slot)
+			if err != nil {
+				continue
+			}
+			epc, err = entry.EpochsContext(ctx)
+			if err != nil {
+				return nil, nil, false, fmt.Errorf("failed to get epochs context of slot %d: %v", slot, err)
+			}
+			break
+		}
+		if epc == nil {
+			return nil, nil, false, fmt.Errorf("no chain entry available in epoch %d", epoch)
+		}
+	}
+	// The current epoch first: at genesis the previous epoch is the same
+	switch epoch {
+	case epc.CurrentEpoch.Epoch:
+		return epc, epc.CurrentEpoch, true, nil
+	case epc.PreviousEpoch.Epoch:
+		return epc, epc.PreviousEpoch, false, nil
+	case epc.NextEpoch.Epoch:
+		return epc, epc.NextEpoch, false, nil
+	default:
+		return nil, nil, false, fmt.Errorf("no shuffling available for epoch %d", epoch)
+	}
+}
+
+// attestationSubnet computes the subnet that attestations of the committee are published to.
+func attestationSubnet(spec *beacon.Spec, committeesPerSlot uint64, slot beacon.Slot, index beacon.CommitteeIndex) uint64 {
+	slotsSinceEpochStart := uint64(slot % spec.SLOTS_PER_EPOCH)
+	committeesSinceEpochStart := committeesPerSlot * slotsSinceEpochStart
+	return (committeesSinceEpochStart + uint64(index)) % beacon.ATTESTATION_SUBNET_COUNT
+}
+
+type DutiesCmd struct {
+	*base.Base
+	Chain      chain.FullChain
+	Epoch      beacon.Epoch               `ask:"--epoch" help:"The epoch to get duties for. Defaults to the epoch of the head."`
+	Validators flags.ValidatorIndicesFlag `ask:"--validators" help:"Validator indices to get attester duties for, e.g. '1,2,5-10'"`
+}
+
+func (c *DutiesCmd) Default() {
+	c.Epoch = HeadEpoch
+}
+
+func (c *DutiesCmd) Help() string {
+	return "Get the proposers of an epoch, and the attester duties of the given validators."
+}
+
+func (c *DutiesCmd) Run(ctx context.Context, args ...string) error {
+	epc, shuf, isCurrent, err := epochShuffling(ctx, c.Chain, c.Epoch)
+	if err != nil {
+		return err
+	}
+	spec := epc.Spec
+	start := spec.EpochStartSlot(shuf.Epoch)
+	proposalSlots := make(map[beacon.ValidatorIndex][]beacon.Slot)
+	if isCurrent {
+		proposers := make([]beacon.ValidatorIndex, spec.SLOTS_PER_EPOCH)
+		for i := range proposers {
+			slot := start + beacon.Slot(i)
+			proposers[i], err = epc.GetBeaconProposer(slot)
+			if err != nil {
+				return err
+			}
+			proposalSlots[proposers[i]] = append(proposalSlots[proposers[i]], slot)
+		}
+		c.Log.WithFields(logrus.Fields{
+			"epoch":      shuf.Epoch,
+			"start_slot": start,
+			"proposers":  proposers,
+		}).Info("proposers")
+	} else {
+		c.Log.WithField("epoch", shuf.Epoch).Warn("proposers are only known for the epoch of the chain entry, try a later head")
+	}
+
+	if len(c.Validators.Indices) == 0 {
+		return nil
+	}
+	type assignment struct {
+		slot     beacon.Slot
+		index    beacon.CommitteeIndex
+		position int
+		size     int
+		count    uint64
+	}
+	wanted := make(map[beacon.ValidatorIndex]*assignment, len(c.Validators.Indices))
+	for _, v := range c.Validators.Indices {
+		wanted[v] = nil
+	}
+	for i, slotComms := range shuf.Committees {
+		slot := start + beacon.Slot(i)
+		for ci, committee := range slotComms {
+			for pos, v := range committee {
+				if a, ok := wanted[v]; ok && a == nil {
+					wanted[v] = &assignment{slot: slot, index: beacon.CommitteeIndex(ci), position: pos,
+						size: len(committee), count: uint64(len(slotComms))}
+				}
+			}
+		}
+	}
+	missing := 0
+	for _, v := range c.Validators.Indices {
+		a := wanted[v]
+		if a == nil {
+			missing += 1
+			c.Log.WithFields(logrus.Fields{"epoch": shuf.Epoch, "validator": v}).Warn("validator has no attester duty, it is not active")
+			continue
+		}
+		c.Log.WithFields(logrus.Fields{
+			"epoch":              shuf.Epoch,
+			"validator":          v,
+			"slot":               a.slot,
+			"committee_index":    a.index,
+			"committee_size":     a.size,
+			"position":           a.position,
+			"committees_at_slot": a.count,
+			"subnet":             attestationSubnet(spec, a.count, a.slot, a.index),
+			"proposal_slots":     proposalSlots[v],
+		}).Info("attester duty")
+	}
+	if missing == len(c.Validators.Indices) {
+		return errors.New("none of the validators have duties")
+	}
+	return nil
+}
@@ -0,0 +1,52 @@
+package chcmd
+
+import (
+	"context"
+	"fmt"
+	"github.com/protolambda/rumor/chain"
+	"github.com/protolambda/rumor/control/actor/base"
+	"github.com/sirupsen/logrus"
+	"strings"
+)
+
+type EventsCmd struct {
+	*base.Base
+	Chain chain.FullChain
+	Kinds string `ask:"--kinds" help:"Comma-separated event kinds to log: head, reorg, justified, finalized, pruned, proposer_slashing, attester_slashing. All if empty."`
+}
+
+func (c *EventsCmd) Help() string {
+	return "Log head, reorg, checkpoint, pruning and slashing events of the hot chain, until stopped"
+}
+
+func (c *EventsCmd) Run(ctx context.Context, args ...string) error {
+	uc, err := unfinalizedChain(c.Chain)
+	if err != nil {
+		return err
+	}
+	known := make(map[chain.ChainEventKind]bool, len(chain.ChainEventKinds))
+	for _, k := range chain.ChainEventKinds {
+		known[k] = true
+	}
+	kinds := make(map[chain.ChainEventKind]bool)
+	for _, k := range strings.Split(c.Kinds, ",") {
+		if k = strings.TrimSpace(k); k != "" {
+			if !known[chain.ChainEventKind(k)] {
+				return fmt.Errorf("unknown event kind: %s", k)
+			}
+			kinds[chain.ChainEventKind(k)] = true
+		}
+	}
+	unsubscribe := uc.SubscribeEvents(func(ev *chain.ChainEvent) {
+		if len(kinds) > 0 && !kinds[ev.Kind] {
+			return
+		}
+		c.Log.WithFields(logrus.Fields(ev.Fields())).Info(string(ev.Kind))
+	})
+	c.Control.RegisterStop(func(ctx context.Context) error {
+		unsubscribe()
+		c.Log.Info("stopped chain events")
+		return nil
+	})
+	return nil
+}
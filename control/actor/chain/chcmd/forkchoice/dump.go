@@ -0,0 +1,76 @@
+package forkchoice
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/protolambda/rumor/chain"
+	"github.com/protolambda/rumor/control/actor/base"
+	"github.com/sirupsen/logrus"
+	"io/ioutil"
+)
+
+type DumpCmd struct {
+	*base.Base
+	Chain  chain.FullChain
+	Format string `ask:"--format" help:"Output format: 'json' or 'dot' (Graphviz)"`
+	Output string `ask:"--output" help:"A file path to write the dump to. If empty, output to log."`
+}
+
+func (c *DumpCmd) Default() {
+	c.Format = "json"
+}
+
+func (c *DumpCmd) Help() string {
+	return "Dump every block of the fork-choice tree, with its parent, checkpoints, weight and best child and descendant"
+}
+
+func (c *DumpCmd) Run(ctx context.Context, args ...string) error {
+	hc, ok := c.Chain.(*chain.HotColdChain)
+	if !ok {
+		return errors.New("chain has no hot part")
+	}
+	uc, ok := hc.HotChain.(*chain.UnfinalizedChain)
+	if !ok {
+		return errors.New("hot chain has no fork-choice tree")
+	}
+	nodes, err := uc.ForkChoiceNodes()
+	if err != nil {
+		return fmt.Errorf("failed to get fork-choice nodes: %v", err)
+	}
+	var data []byte
+	switch c.Format {
+	case "json":
+		if c.Output == "" {
+			c.Log.WithFields(logrus.Fields{
+				"justified": uc.Justified(),
+				"finalized": uc.Finalized(),
+				"nodes":     nodes,
+			}).Info("fork-choice tree")
+			return nil
+		}
+		data, err = json.MarshalIndent(nodes, "", "  ")
+		if err != nil {
+			return err
+		}
+	case "dot":
+		dot := chain.ForkChoiceDOT(nodes, uc.Justified(), uc.Finalized())
+		if c.Output == "" {
+			c.Log.WithField("dot", dot).Info("fork-choice tree")
+			return nil
+		}
+		data = []byte(dot)
+	default:
+		return fmt.Errorf("unknown format %q, expected 'json' or 'dot'", c.Format)
+	}
+	if err := ioutil.WriteFile(c.Output, data, 0644); err != nil {
+		return fmt.Errorf("failed to write %s: %v", c.Output, err)
+	}
+	c.Log.WithFields(logrus.Fields{
+		"nodes":  len(nodes),
+		"format": c.Format,
+		"output": c.Output,
+	}).Info("dumped fork-choice tree")
+	return nil
+}
@@ -0,0 +1,30 @@
+package forkchoice
+
+import (
+	"github.com/protolambda/ask"
+	"github.com/protolambda/rumor/chain"
+	"github.com/protolambda/rumor/control/actor/base"
+)
+
+type ForkChoiceCmd struct {
+	*base.Base
+	Chain chain.FullChain
+}
+
+func (c *ForkChoiceCmd) Cmd(route string) (cmd interface{}, err error) {
+	switch route {
+	case "dump":
+		cmd = &DumpCmd{Base: c.Base, Chain: c.Chain}
+	default:
+		return nil, ask.UnrecognizedErr
+	}
+	return cmd, nil
+}
+
+func (c *ForkChoiceCmd) Routes() []string {
+	return []string{"dump"}
+}
+
+func (c *ForkChoiceCmd) Help() string {
+	return "Inspect the fork-choice tree of the hot part of the chain"
+}
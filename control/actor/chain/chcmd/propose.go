@@ -0,0 +1,201 @@
+package chcmd
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"github.com/protolambda/rumor/chain"
+	"github.com/protolambda/rumor/chain/clock"
+	bdb "github.com/protolambda/rumor/chain/db/blocks"
+	"github.com/protolambda/rumor/chain/interop"
+	"github.com/protolambda/rumor/control/actor/base"
+	"github.com/protolambda/rumor/control/actor/chain/clockcmd"
+	"github.com/protolambda/rumor/control/actor/gossip"
+	"github.com/protolambda/zrnt/eth2/beacon"
+	"github.com/protolambda/ztyp/codec"
+	"github.com/sirupsen/logrus"
+)
+
+type ProposeCmd struct {
+	*base.Base
+	Chain  chain.FullChain
+	Blocks bdb.DB
+	*clockcmd.ClockState
+	*gossip.GossipState
+	Slot       beacon.Slot `ask:"--slot" help:"The slot to propose at. Defaults to the slot after the head. Ignored with --continuous."`
+	Graffiti   string      `ask:"--graffiti" help:"Graffiti to put in the block, at most 32 bytes"`
+	Attest     bool        `ask:"--attest" help:"First attest to the head at the previous slot, with all committees, and add the attestations to the pool"`
+	Publish    bool        `ask:"--publish" help:"Publish the block on the beacon_block gossip topic. The topic must be joined."`
+	Continuous bool        `ask:"--continuous" help:"Propose a block at the start of every slot of the slot clock, or the genesis time of the chain if there is none, until stopped"`
+}
+
+func (c *ProposeCmd) Help() string {
+	return "Propose a block on the head of the chain with interop validator keys, add it to the chain and blocks DB, and optionally publish it."
+}
+
+func (c *ProposeCmd) Run(ctx context.Context, args ...string) error {
+	if len(c.Graffiti) > 32 {
+		return fmt.Errorf("graffiti is %d bytes, cannot be longer than 32 bytes", len(c.Graffiti))
+	}
+	uc, err := unfinalizedChain(c.Chain)
+	if err != nil {
+		return err
+	}
+	if !c.Continuous {
+		slot := c.Slot
+		if slot == 0 {
+			head, err := c.Chain.Head()
+			if err != nil {
+				return fmt.Errorf("failed to get head: %v", err)
+			}
+			slot = head.Slot() + 1
+		}
+		return c.propose(ctx, uc, slot)
+	}
+
+	cl, err := c.ClockState.Get()
+	if err != nil {
+		// No actor clock, follow the genesis time of the chain
+		head, err := c.Chain.Head()
+		if err != nil {
+			return fmt.Errorf("failed to get head: %v", err)
+		}
+		state, err := head.State(ctx)
+		if err != nil {
+			return fmt.Errorf("failed to get head state: %v", err)
+		}
+		if cl, err = clock.FromState(uc.Spec, state); err != nil {
+			return err
+		}
+	}
+	bgCtx, bgCancel := context.WithCancel(context.Background())
+	go cl.Schedule(bgCtx, 0, func(slot beacon.Slot) {
+		if slot == 0 {
+			return
+		}
+		if err := c.propose(bgCtx, uc, slot); err != nil {
+			c.Log.WithField("slot", slot).WithError(err).Warn("failed to propose block")
+		}
+	})
+	c.Control.RegisterStop(func(ctx context.Context) error {
+		bgCancel()
+		c.Log.Info("stopped proposing")
+		return nil
+	})
+	return nil
+}
+
+func (c *ProposeCmd) propose(ctx context.Context, uc *chain.UnfinalizedChain, slot beacon.Slot) error {
+	spec := uc.Spec
+	if c.Attest {
+		if err := c.attest(ctx, uc, slot-1); err != nil {
+			return fmt.Errorf("failed to attest: %v", err)
+		}
+	}
+	if slot > spec.SLOTS_PER_EPOCH {
+		uc.Attestations.Prune(slot - spec.SLOTS_PER_EPOCH)
+	}
+	pending := uc.Attestations.Includable(spec, slot)
+
+	head, err := c.Chain.Head()
+	if err != nil {
+		return fmt.Errorf("failed to get head: %v", err)
+	}
+	epc, err := head.EpochsContext(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to get epochs context of head: %v", err)
+	}
+	state, err := head.State(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to get head state: %v", err)
+	}
+	var graffiti beacon.Root
+	copy(graffiti[:], c.Graffiti)
+	block, err := interop.ProposeBlock(ctx, spec, epc, state, head.BlockRoot(), slot, graffiti, pending)
+	if err != nil {
+		return fmt.Errorf("failed to build block: %v", err)
+	}
+	if err := c.Chain.AddBlock(ctx, block); err != nil {
+		return fmt.Errorf("could not add block to chain: %v", err)
+	}
+	withRoot := bdb.WithRoot(spec, block)
+	if _, err := c.Blocks.Store(ctx, withRoot); err != nil {
+		return fmt.Errorf("failed to store block: %v", err)
+	}
+	f := logrus.Fields{
+		"slot":         slot,
+		"root":         withRoot.Root,
+		"parent":       block.Message.ParentRoot,
+		"proposer":     block.Message.ProposerIndex,
+		"attestations": len(block.Message.Body.Attestations),
+	}
+	if c.Publish {
+		topic, err := beaconBlockTopic(state)
+		if err != nil {
+			return err
+		}
+		var buf bytes.Buffer
+		if err := block.Serialize(spec, codec.NewEncodingWriter(&buf)); err != nil {
+			return fmt.Errorf("failed to encode block: %v", err)
+		}
+		if err := c.GossipState.Publish(ctx, topic, buf.Bytes()); err != nil {
+			return fmt.Errorf("block was added, but could not be published: %v", err)
+		}
+		f["topic"] = topic
+	}
+	c.Log.WithFields(f).Info("proposed block")
+	return nil
+}
+
+// attest adds attestations of all committees at the slot to the chain, voting for the head.
+func (c *ProposeCmd) attest(ctx context.Context, uc *chain.UnfinalizedChain, slot beacon.Slot) error {
+	head, err := c.Chain.Head()
+	if err != nil {
+		return fmt.Errorf("failed to get head: %v", err)
+	}
+	if head.Slot() > slot {
+		// Already a block at a later slot, nothing left to vote for
+		return nil
+	}
+	epc, err := head.EpochsContext(ctx)
+	if err != nil {
+		return err
+	}
+	state, err := head.State(ctx)
+	if err != nil {
+		return err
+	}
+	if head.Slot() < slot {
+		if err := uc.Spec.ProcessSlots(ctx, epc, state, slot); err != nil {
+			return err
+		}
+	}
+	atts, err := interop.Attestations(uc.Spec, epc, state, head.BlockRoot())
+	if err != nil {
+		return err
+	}
+	for i := range atts {
+		if err := uc.AddAttestation(&atts[i]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// beaconBlockTopic is the gossip topic of blocks, for the fork of the given state.
+func beaconBlockTopic(state *beacon.BeaconStateView) (string, error) {
+	fork, err := state.Fork()
+	if err != nil {
+		return "", err
+	}
+	version, err := fork.CurrentVersion()
+	if err != nil {
+		return "", err
+	}
+	genesisValRoot, err := state.GenesisValidatorsRoot()
+	if err != nil {
+		return "", err
+	}
+	digest := beacon.ComputeForkDigest(version, genesisValRoot)
+	return fmt.Sprintf("/eth2/%x/beacon_block/ssz_snappy", digest[:]), nil
+}
@@ -0,0 +1,38 @@
+package slashings
+
+import (
+	"context"
+	"github.com/protolambda/rumor/chain"
+	"github.com/protolambda/rumor/chain/slashing"
+	"github.com/protolambda/rumor/control/actor/base"
+	"github.com/sirupsen/logrus"
+)
+
+type ListCmd struct {
+	*base.Base
+	Detector *slashing.Detector
+}
+
+func (c *ListCmd) Help() string {
+	return "Log all slashings detected so far"
+}
+
+func (c *ListCmd) Run(ctx context.Context, args ...string) error {
+	detector := c.Detector
+	detector.Lock()
+	for i := range detector.ProposerSlashings {
+		c.Log.WithFields(logrus.Fields(chain.ProposerSlashingFields(&detector.ProposerSlashings[i]))).Info("proposer slashing")
+	}
+	for i := range detector.AttesterSlashings {
+		c.Log.WithFields(logrus.Fields(chain.AttesterSlashingFields(&detector.AttesterSlashings[i]))).Info("attester slashing")
+	}
+	detector.Unlock()
+	headers, voters, proposerSlashings, attesterSlashings := detector.Stats()
+	c.Log.WithFields(logrus.Fields{
+		"headers":            headers,
+		"voters":             voters,
+		"proposer_slashings": proposerSlashings,
+		"attester_slashings": attesterSlashings,
+	}).Info("slashing detector")
+	return nil
+}
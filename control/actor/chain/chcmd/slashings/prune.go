@@ -0,0 +1,25 @@
+package slashings
+
+import (
+	"context"
+	"github.com/protolambda/rumor/chain/slashing"
+	"github.com/protolambda/rumor/control/actor/base"
+	"github.com/protolambda/zrnt/eth2/beacon"
+)
+
+type PruneCmd struct {
+	*base.Base
+	Detector *slashing.Detector
+	Epoch    beacon.Epoch `ask:"<epoch>" help:"Forget block headers and attestations from before this epoch"`
+}
+
+func (c *PruneCmd) Help() string {
+	return "Prune the slashing detector, conflicts with pruned messages are not detected anymore"
+}
+
+func (c *PruneCmd) Run(ctx context.Context, args ...string) error {
+	c.Detector.Prune(c.Epoch)
+	headers, voters, _, _ := c.Detector.Stats()
+	c.Log.WithField("epoch", c.Epoch).WithField("headers", headers).WithField("voters", voters).Info("pruned slashing detector")
+	return nil
+}
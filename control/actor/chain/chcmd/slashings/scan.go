@@ -0,0 +1,69 @@
+package slashings
+
+import (
+	"context"
+	"fmt"
+	"github.com/protolambda/rumor/chain"
+	bdb "github.com/protolambda/rumor/chain/db/blocks"
+	"github.com/protolambda/rumor/chain/slashing"
+	"github.com/protolambda/rumor/control/actor/base"
+	"github.com/protolambda/zrnt/eth2/beacon"
+	"github.com/sirupsen/logrus"
+)
+
+type ScanCmd struct {
+	*base.Base
+	Chain        chain.FullChain
+	Detector     *slashing.Detector
+	Blocks       bdb.DB
+	Attestations bool `ask:"--attestations" help:"Also check the attestations included in the blocks"`
+}
+
+func (c *ScanCmd) Help() string {
+	return "Check all blocks in the blocks DB for conflicting proposals, and optionally their attestations for conflicting votes"
+}
+
+func (c *ScanCmd) Run(ctx context.Context, args ...string) error {
+	detector := c.Detector
+	var blocks, attestations, proposerSlashings, attesterSlashings int
+	for _, root := range c.Blocks.List() {
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		var block beacon.SignedBeaconBlock
+		exists, err := c.Blocks.Get(root, &block)
+		if err != nil {
+			return fmt.Errorf("failed to get block %s: %v", root, err)
+		}
+		if !exists {
+			continue
+		}
+		blocks += 1
+		if s := detector.OnBlock(&block); s != nil {
+			proposerSlashings += 1
+			c.Log.WithFields(logrus.Fields(chain.ProposerSlashingFields(s))).Warn("proposer slashing")
+		}
+		if !c.Attestations {
+			continue
+		}
+		for i := range block.Message.Body.Attestations {
+			indexed, err := indexedAttestation(ctx, c.Chain, &block.Message.Body.Attestations[i])
+			if err != nil {
+				c.Log.WithField("block", root).WithError(err).Debug("skipping attestation")
+				continue
+			}
+			attestations += 1
+			for _, s := range detector.OnAttestation(indexed) {
+				attesterSlashings += 1
+				c.Log.WithFields(logrus.Fields(chain.AttesterSlashingFields(&s))).Warn("attester slashing")
+			}
+		}
+	}
+	c.Log.WithFields(logrus.Fields{
+		"blocks":             blocks,
+		"attestations":       attestations,
+		"proposer_slashings": proposerSlashings,
+		"attester_slashings": attesterSlashings,
+	}).Info("scanned blocks DB")
+	return nil
+}
@@ -0,0 +1,87 @@
+package slashings
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/protolambda/ask"
+	"github.com/protolambda/rumor/chain"
+	bdb "github.com/protolambda/rumor/chain/db/blocks"
+	"github.com/protolambda/rumor/control/actor/base"
+	"github.com/protolambda/rumor/control/actor/gossip"
+	"github.com/protolambda/zrnt/eth2/beacon"
+)
+
+type SlashingsCmd struct {
+	*base.Base
+	Chain  chain.FullChain
+	Blocks bdb.DB
+	*gossip.GossipState
+}
+
+func (c *SlashingsCmd) Cmd(route string) (cmd interface{}, err error) {
+	uc, err := unfinalizedChain(c.Chain)
+	if err != nil {
+		return nil, err
+	}
+	switch route {
+	case "scan":
+		cmd = &ScanCmd{Base: c.Base, Chain: c.Chain, Detector: uc.Slashings, Blocks: c.Blocks}
+	case "watch":
+		cmd = &WatchCmd{Base: c.Base, Chain: c.Chain, Detector: uc.Slashings, GossipState: c.GossipState}
+	case "list":
+		cmd = &ListCmd{Base: c.Base, Detector: uc.Slashings}
+	case "prune":
+		cmd = &PruneCmd{Base: c.Base, Detector: uc.Slashings}
+	default:
+		return nil, ask.UnrecognizedErr
+	}
+	return cmd, nil
+}
+
+func (c *SlashingsCmd) Routes() []string {
+	return []string{"scan", "watch", "list", "prune"}
+}
+
+func (c *SlashingsCmd) Help() string {
+	return "Detect proposer and attester slashings in the chain, blocks DB and gossip"
+}
+
+func unfinalizedChain(ch chain.FullChain) (*chain.UnfinalizedChain, error) {
+	hc, ok := ch.(*chain.HotColdChain)
+	if !ok {
+		return nil, errors.New("chain does not have a hot part")
+	}
+	uc, ok := hc.HotChain.(*chain.UnfinalizedChain)
+	if !ok {
+		return nil, errors.New("hot part of chain does not detect slashings")
+	}
+	return uc, nil
+}
+
+// indexedAttestation looks up the committee of the attestation, with the shuffling of the head,
+// or else of the target block, to convert the attestation to its indexed form.
+func indexedAttestation(ctx context.Context, ch chain.FullChain, att *beacon.Attestation) (*beacon.IndexedAttestation, error) {
+	head, err := ch.Head()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get head: %v", err)
+	}
+	epc, err := head.EpochsContext(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get epochs context of head: %v", err)
+	}
+	committee, err := epc.GetBeaconCommittee(att.Data.Slot, att.Data.Index)
+	if err != nil {
+		target, err := ch.ByBlockRoot(att.Data.Target.Root)
+		if err != nil {
+			return nil, fmt.Errorf("no shuffling for attestation at slot %d: unknown target %s", att.Data.Slot, att.Data.Target.Root)
+		}
+		if epc, err = target.EpochsContext(ctx); err != nil {
+			return nil, fmt.Errorf("failed to get epochs context of target: %v", err)
+		}
+		if committee, err = epc.GetBeaconCommittee(att.Data.Slot, att.Data.Index); err != nil {
+			return nil, fmt.Errorf("no committee for attestation: %v", err)
+		}
+	}
+	return att.ConvertToIndexed(epc.Spec, committee)
+}
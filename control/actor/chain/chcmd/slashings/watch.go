@@ -0,0 +1,190 @@
+package slashings
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	pubsub "github.com/libp2p/go-libp2p-pubsub"
+	"github.com/protolambda/rumor/chain"
+	"github.com/protolambda/rumor/chain/slashing"
+	"github.com/protolambda/rumor/control/actor/base"
+	"github.com/protolambda/rumor/control/actor/gossip"
+	"github.com/protolambda/zrnt/eth2/beacon"
+	"github.com/protolambda/ztyp/codec"
+	"github.com/sirupsen/logrus"
+	"strings"
+)
+
+type WatchCmd struct {
+	*base.Base
+	Chain    chain.FullChain
+	Detector *slashing.Detector
+	*gossip.GossipState
+	Publish bool `ask:"--publish" help:"Publish detected slashings on the proposer_slashing and attester_slashing topics of the same fork digest. The topics must be joined."`
+}
+
+func (c *WatchCmd) Help() string {
+	return "Check the blocks and attestations of all joined beacon_block, beacon_aggregate_and_proof and beacon_attestation topics for conflicts, until stopped"
+}
+
+func (c *WatchCmd) Run(ctx context.Context, args ...string) error {
+	if c.GossipState.GsNode == nil {
+		return gossip.NoGossipErr
+	}
+	head, err := c.Chain.Head()
+	if err != nil {
+		return fmt.Errorf("failed to get head: %v", err)
+	}
+	epc, err := head.EpochsContext(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to get epochs context of head: %v", err)
+	}
+	spec := epc.Spec
+
+	handlers := make(map[string]func(ctx context.Context, topic string, data []byte) error)
+	c.GossipState.Topics.Range(func(key, value interface{}) bool {
+		topic := key.(string)
+		switch {
+		case strings.Contains(topic, "/beacon_block/"):
+			handlers[topic] = func(ctx context.Context, topic string, data []byte) error {
+				var block beacon.SignedBeaconBlock
+				if err := block.Deserialize(spec, codec.NewDecodingReader(bytes.NewReader(data), uint64(len(data)))); err != nil {
+					return fmt.Errorf("failed to decode block: %v", err)
+				}
+				if s := c.Detector.OnBlock(&block); s != nil {
+					c.onProposerSlashing(ctx, topic, s)
+				}
+				return nil
+			}
+		case strings.Contains(topic, "/beacon_aggregate_and_proof/"):
+			handlers[topic] = func(ctx context.Context, topic string, data []byte) error {
+				aggregate, err := aggregateOf(data)
+				if err != nil {
+					return err
+				}
+				return c.onAttestation(ctx, topic, spec, aggregate)
+			}
+		case strings.Contains(topic, "/beacon_attestation_"):
+			handlers[topic] = func(ctx context.Context, topic string, data []byte) error {
+				return c.onAttestation(ctx, topic, spec, data)
+			}
+		}
+		return true
+	})
+	if len(handlers) == 0 {
+		return errors.New("not on any beacon_block, beacon_aggregate_and_proof or beacon_attestation topic, join one first")
+	}
+
+	bgCtx, bgCancel := context.WithCancel(context.Background())
+	for topic, handle := range handlers {
+		go func(topic string, handle func(ctx context.Context, topic string, data []byte) error) {
+			err := c.GossipState.Listen(bgCtx, topic, func(msg *pubsub.Message, data []byte) {
+				if err := handle(bgCtx, topic, data); err != nil {
+					c.Log.WithField("topic", topic).WithField("from", msg.ReceivedFrom.String()).WithError(err).Debug("skipping message")
+				}
+			})
+			if err != nil {
+				c.Log.WithField("topic", topic).WithError(err).Error("stopped watching topic")
+			}
+		}(topic, handle)
+		c.Log.WithField("topic", topic).Info("watching topic for slashings")
+	}
+	c.Control.RegisterStop(func(ctx context.Context) error {
+		bgCancel()
+		c.Log.Info("stopped watching for slashings")
+		return nil
+	})
+	return nil
+}
+
+func (c *WatchCmd) onAttestation(ctx context.Context, topic string, spec *beacon.Spec, data []byte) error {
+	var att beacon.Attestation
+	if err := att.Deserialize(spec, codec.NewDecodingReader(bytes.NewReader(data), uint64(len(data)))); err != nil {
+		return fmt.Errorf("failed to decode attestation: %v", err)
+	}
+	indexed, err := indexedAttestation(ctx, c.Chain, &att)
+	if err != nil {
+		return err
+	}
+	for _, s := range c.Detector.OnAttestation(indexed) {
+		s := s
+		c.onAttesterSlashing(ctx, topic, spec, &s)
+	}
+	return nil
+}
+
+func (c *WatchCmd) onProposerSlashing(ctx context.Context, topic string, s *beacon.ProposerSlashing) {
+	c.Log.WithFields(logrus.Fields(chain.ProposerSlashingFields(s))).Warn("proposer slashing")
+	if !c.Publish {
+		return
+	}
+	var buf bytes.Buffer
+	if err := s.Serialize(codec.NewEncodingWriter(&buf)); err != nil {
+		c.Log.WithError(err).Error("failed to encode proposer slashing")
+		return
+	}
+	pubTopic, err := slashingTopic(topic, "proposer_slashing")
+	if err != nil {
+		c.Log.WithError(err).Error("not publishing slashing")
+		return
+	}
+	c.publish(ctx, pubTopic, buf.Bytes())
+}
+
+func (c *WatchCmd) onAttesterSlashing(ctx context.Context, topic string, spec *beacon.Spec, s *beacon.AttesterSlashing) {
+	c.Log.WithFields(logrus.Fields(chain.AttesterSlashingFields(s))).Warn("attester slashing")
+	if !c.Publish {
+		return
+	}
+	var buf bytes.Buffer
+	if err := s.Serialize(spec, codec.NewEncodingWriter(&buf)); err != nil {
+		c.Log.WithError(err).Error("failed to encode attester slashing")
+		return
+	}
+	pubTopic, err := slashingTopic(topic, "attester_slashing")
+	if err != nil {
+		c.Log.WithError(err).Error("not publishing slashing")
+		return
+	}
+	c.publish(ctx, pubTopic, buf.Bytes())
+}
+
+func (c *WatchCmd) publish(ctx context.Context, topic string, data []byte) {
+	if err := c.GossipState.Publish(ctx, topic, data); err != nil {
+		c.Log.WithField("topic", topic).WithError(err).Error("failed to publish slashing")
+	} else {
+		c.Log.WithField("topic", topic).Info("published slashing")
+	}
+}
+
+// slashingTopic is the topic with the given name, with the same fork digest and encoding as the topic the slashing was detected on.
+// Topics are formatted as /eth2/<fork digest>/<name>/<encoding>
+func slashingTopic(detectedOn string, name string) (string, error) {
+	parts := strings.Split(detectedOn, "/")
+	if len(parts) != 5 || parts[0] != "" || parts[1] != "eth2" {
+		return "", fmt.Errorf("cannot derive %s topic from topic %s", name, detectedOn)
+	}
+	parts[3] = name
+	return strings.Join(parts, "/"), nil
+}
+
+// aggregateOf extracts the attestation from an SSZ encoded SignedAggregateAndProof:
+// an offset to the message and the signature, where the message is the aggregator index,
+// an offset to the aggregate attestation, and the selection proof.
+func aggregateOf(data []byte) ([]byte, error) {
+	if len(data) < 4 {
+		return nil, errors.New("aggregate message too short")
+	}
+	msgOffset := uint64(binary.LittleEndian.Uint32(data[:4]))
+	if msgOffset > uint64(len(data)) || uint64(len(data))-msgOffset < 8+4 {
+		return nil, errors.New("bad aggregate message offset")
+	}
+	msg := data[msgOffset:]
+	aggOffset := uint64(binary.LittleEndian.Uint32(msg[8:12]))
+	if aggOffset > uint64(len(msg)) {
+		return nil, errors.New("bad aggregate offset")
+	}
+	return msg[aggOffset:], nil
+}
@@ -0,0 +1,65 @@
+package chcmd
+
+import (
+	"context"
+	"fmt"
+	"github.com/protolambda/rumor/chain"
+	"github.com/protolambda/rumor/chain/sszpath"
+	"github.com/protolambda/rumor/control/actor/base"
+	"github.com/protolambda/zrnt/eth2/beacon"
+	"github.com/sirupsen/logrus"
+	"strconv"
+	"strings"
+)
+
+type StateQueryCmd struct {
+	*base.Base
+	Chain chain.FullChain
+	At    string `ask:"<at>" help:"The chain entry to query the post-state of: a slot, 'head', or a 0x-prefixed state or block root"`
+	Path  string `ask:"<path>" help:"Path to the field, e.g. 'validators/12345/effective_balance', 'balances[0:64]', 'finalized_checkpoint'"`
+	Proof bool   `ask:"--proof" help:"Also output a Merkle proof (gindex, leaf and branch) of the selected node"`
+}
+
+func (c *StateQueryCmd) Help() string {
+	return "Query a field of the state at a slot or root in the chain by SSZ path, optionally with a Merkle proof"
+}
+
+func (c *StateQueryCmd) entry() (chain.ChainEntry, error) {
+	if c.At == "head" {
+		return c.Chain.Head()
+	}
+	if strings.HasPrefix(c.At, "0x") {
+		var root beacon.Root
+		if err := root.UnmarshalText([]byte(c.At)); err != nil {
+			return nil, fmt.Errorf("invalid root %q: %v", c.At, err)
+		}
+		if entry, err := c.Chain.ByStateRoot(root); err == nil {
+			return entry, nil
+		}
+		return c.Chain.ByBlockRoot(root)
+	}
+	slot, err := strconv.ParseUint(c.At, 10, 64)
+	if err != nil {
+		return nil, fmt.Errorf("expected slot, 'head' or root, got %q", c.At)
+	}
+	return c.Chain.BySlot(beacon.Slot(slot))
+}
+
+func (c *StateQueryCmd) Run(ctx context.Context, args ...string) error {
+	entry, err := c.entry()
+	if err != nil {
+		return fmt.Errorf("could not find chain entry %q: %v", c.At, err)
+	}
+	state, err := entry.State(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to get state of slot %d: %v", entry.Slot(), err)
+	}
+	out, err := sszpath.Describe(state.Type(), state.Backing(), c.Path, c.Proof)
+	if err != nil {
+		return err
+	}
+	out["slot"] = entry.Slot()
+	out["state_root"] = entry.StateRoot()
+	c.Log.WithFields(logrus.Fields(out)).Info("query result")
+	return nil
+}
@@ -0,0 +1,150 @@
+package chcmd
+
+import (
+	"context"
+	"errors"
+	"github.com/protolambda/rumor/chain"
+	"github.com/protolambda/rumor/control/actor/base"
+	"github.com/sirupsen/logrus"
+)
+
+func unfinalizedChain(ch chain.FullChain) (*chain.UnfinalizedChain, error) {
+	hc, ok := ch.(*chain.HotColdChain)
+	if !ok {
+		return nil, errors.New("chain has no hot part")
+	}
+	uc, ok := hc.HotChain.(*chain.UnfinalizedChain)
+	if !ok {
+		return nil, errors.New("hot chain is not an unfinalized chain")
+	}
+	return uc, nil
+}
+
+func opFields(op chain.OpTrace) map[string]interface{} {
+	return map[string]interface{}{"count": op.Count, "time": op.Time.Seconds()}
+}
+
+// traceFields formats a block trace for logging, with durations in seconds.
+func traceFields(t *chain.BlockTrace, withDeltas bool) logrus.Fields {
+	epochs := make([]map[string]interface{}, 0, len(t.Epochs))
+	for _, et := range t.Epochs {
+		epochs = append(epochs, map[string]interface{}{
+			"epoch":                 et.Epoch,
+			"total":                 et.Total().Seconds(),
+			"prepare":               et.Prepare.Seconds(),
+			"justification":         et.Justification.Seconds(),
+			"rewards_and_penalties": et.RewardsAndPenalties.Seconds(),
+			"registry_updates":      et.RegistryUpdates.Seconds(),
+			"slashings":             et.Slashings.Seconds(),
+			"final_updates":         et.FinalUpdates.Seconds(),
+			"rotate":                et.Rotate.Seconds(),
+		})
+	}
+	fields := logrus.Fields{
+		"slot":               t.Slot,
+		"block_root":         t.BlockRoot,
+		"total":              t.Total.Seconds(),
+		"slots":              t.Slots,
+		"slot_processing":    t.SlotProcessing.Seconds(),
+		"epochs":             epochs,
+		"signature":          t.Signature.Seconds(),
+		"header":             t.Header.Seconds(),
+		"randao":             t.Randao.Seconds(),
+		"eth1_vote":          t.Eth1Vote.Seconds(),
+		"proposer_slashings": opFields(t.ProposerSlashings),
+		"attester_slashings": opFields(t.AttesterSlashings),
+		"attestations":       opFields(t.Attestations),
+		"deposits":           opFields(t.Deposits),
+		"voluntary_exits":    opFields(t.VoluntaryExits),
+		"state_root":         t.StateRoot.Seconds(),
+		"balance_increased":  t.BalanceIncreased,
+		"balance_decreased":  t.BalanceDecreased,
+		"balance_net_delta":  t.BalanceNetDelta,
+	}
+	if withDeltas {
+		fields["balance_deltas"] = t.BalanceDeltas
+	}
+	return fields
+}
+
+type TraceCmd struct {
+	*base.Base
+	Chain    chain.FullChain
+	Enable   bool `ask:"--enable" help:"Start tracing every block processed by the hot chain"`
+	Disable  bool `ask:"--disable" help:"Stop tracing blocks. The collected traces are dropped."`
+	Clear    bool `ask:"--clear" help:"Remove the collected traces and reset the summary"`
+	Capacity int  `ask:"--capacity" help:"Number of recent block traces to keep, when enabling"`
+	Last     int  `ask:"--last" help:"Log the traces of this many of the most recent blocks"`
+	Deltas   bool `ask:"--deltas" help:"Include the balance deltas of each validator in the logged traces"`
+}
+
+func (c *TraceCmd) Default() {
+	c.Capacity = chain.DefaultTraceCapacity
+}
+
+func (c *TraceCmd) Help() string {
+	return "Enable or disable tracing of state transitions in the hot chain, and summarize the traces"
+}
+
+func (c *TraceCmd) Run(ctx context.Context, args ...string) error {
+	uc, err := unfinalizedChain(c.Chain)
+	if err != nil {
+		return err
+	}
+	if c.Disable {
+		uc.SetTracer(nil)
+		c.Log.Info("disabled tracing")
+		return nil
+	}
+	tracer := uc.GetTracer()
+	if c.Enable && tracer == nil {
+		tracer = chain.NewTransitionTracer(c.Capacity)
+		uc.SetTracer(tracer)
+		c.Log.WithField("capacity", c.Capacity).Info("enabled tracing")
+	}
+	if tracer == nil {
+		return errors.New("tracing is not enabled, enable it with --enable")
+	}
+	if c.Clear {
+		tracer.Clear()
+	}
+	for _, t := range tracer.Last(c.Last) {
+		c.Log.WithFields(traceFields(t, c.Deltas)).Info("block trace")
+	}
+	s := tracer.Summary()
+	var avg float64
+	if s.Blocks > 0 {
+		avg = s.Total.Seconds() / float64(s.Blocks)
+	}
+	ops := make(map[string]interface{}, len(s.Operations))
+	for name, op := range s.Operations {
+		ops[name] = opFields(op)
+	}
+	c.Log.WithFields(logrus.Fields{
+		"blocks":          s.Blocks,
+		"slots":           s.Slots,
+		"epochs":          s.Epochs,
+		"total":           s.Total.Seconds(),
+		"avg":             avg,
+		"max":             s.Max.Seconds(),
+		"max_slot":        s.MaxSlot,
+		"slot_processing": s.SlotProcessing.Seconds(),
+		"epoch_processing": map[string]interface{}{
+			"total":                 s.EpochTotals.Total().Seconds(),
+			"prepare":               s.EpochTotals.Prepare.Seconds(),
+			"justification":         s.EpochTotals.Justification.Seconds(),
+			"rewards_and_penalties": s.EpochTotals.RewardsAndPenalties.Seconds(),
+			"registry_updates":      s.EpochTotals.RegistryUpdates.Seconds(),
+			"slashings":             s.EpochTotals.Slashings.Seconds(),
+			"final_updates":         s.EpochTotals.FinalUpdates.Seconds(),
+			"rotate":                s.EpochTotals.Rotate.Seconds(),
+		},
+		"signature":  s.Signature.Seconds(),
+		"header":     s.Header.Seconds(),
+		"randao":     s.Randao.Seconds(),
+		"eth1_vote":  s.Eth1Vote.Seconds(),
+		"state_root": s.StateRoot.Seconds(),
+		"operations": ops,
+	}).Info("trace summary")
+	return nil
+}
@@ -0,0 +1,275 @@
+package chain
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/libp2p/go-libp2p-core/protocol"
+	"github.com/protolambda/rumor/chain"
+	bdb "github.com/protolambda/rumor/chain/db/blocks"
+	sdb "github.com/protolambda/rumor/chain/db/states"
+	"github.com/protolambda/rumor/control/actor/base"
+	"github.com/protolambda/rumor/control/actor/flags"
+	"github.com/protolambda/rumor/p2p/rpc/methods"
+	"github.com/protolambda/rumor/p2p/rpc/reqresp"
+	"github.com/protolambda/zrnt/eth2/beacon"
+	"github.com/protolambda/ztyp/codec"
+	"github.com/protolambda/ztyp/tree"
+	"github.com/protolambda/ztyp/view"
+	"github.com/sirupsen/logrus"
+	"os"
+	"time"
+)
+
+type ChainCheckpointSyncCmd struct {
+	*base.Base
+	chain.Chains
+	*ChainState
+	Blocks bdb.DB
+	States sdb.DB
+
+	Name  chain.ChainID `ask:"<name>" help:"The name to give to the created chain. Must not exist yet."`
+	State string        `ask:"--state" help:"A file path to read the finalized state from as ssz file."`
+	Block string        `ask:"--block" help:"A file path to read the signed block of the finalized state from as ssz file."`
+
+	PeerID      flags.PeerIDFlag      `ask:"--peer" help:"Peer to back-fill historical blocks from with blocks-by-range requests. No back-fill if empty."`
+	BackfillTo  beacon.Slot           `ask:"--backfill-to" help:"Back-fill blocks down to this slot (inclusive)"`
+	BatchSize   uint64                `ask:"--batch" help:"Number of slots to request per blocks-by-range request"`
+	Timeout     time.Duration         `ask:"--timeout" help:"Timeout for each blocks-by-range request and response. 0 to disable"`
+	Compression flags.CompressionFlag `ask:"--compression" help:"Compression. 'none' to disable, 'snappy' for streaming-snappy"`
+}
+
+func (c *ChainCheckpointSyncCmd) Default() {
+	c.BatchSize = 64
+	c.Timeout = 20 * time.Second
+	c.Compression.Compression = reqresp.SnappyCompression{}
+}
+
+func (c *ChainCheckpointSyncCmd) Help() string {
+	return "Create a new eth2 chain from a finalized (weak subjectivity) state and block, and back-fill the blocks before it"
+}
+
+func readSSZFile(path string, fn func(dr *codec.DecodingReader) error) error {
+	f, err := os.OpenFile(path, os.O_RDONLY, os.ModePerm)
+	if err != nil {
+		return fmt.Errorf("failed to open %s: %v", path, err)
+	}
+	defer f.Close()
+	fInfo, err := f.Stat()
+	if err != nil {
+		return fmt.Errorf("failed to get file size %s: %v", path, err)
+	}
+	if err := fn(codec.NewDecodingReader(f, uint64(fInfo.Size()))); err != nil {
+		return fmt.Errorf("failed to decode %s: %v", path, err)
+	}
+	return nil
+}
+
+// checkCheckpointPair checks that the state is the post-state of the block,
+// and that the finalized checkpoint of the state is consistent with its history. Returns the anchor entry.
+func checkCheckpointPair(spec *beacon.Spec, state *beacon.BeaconStateView, block *beacon.SignedBeaconBlock) (*chain.HotEntry, error) {
+	stateRoot := state.HashTreeRoot(tree.GetHashFn())
+	if block.Message.StateRoot != stateRoot {
+		return nil, fmt.Errorf("block has state root %s, but state has root %s", block.Message.StateRoot, stateRoot)
+	}
+	blockRoot := block.Message.HashTreeRoot(spec, tree.GetHashFn())
+	entry, err := chain.HotEntryFromState(spec, state)
+	if err != nil {
+		return nil, err
+	}
+	if entry.BlockRoot() != blockRoot {
+		return nil, fmt.Errorf("latest block header of state has root %s, but block has root %s", entry.BlockRoot(), blockRoot)
+	}
+	if entry.Slot() != block.Message.Slot {
+		return nil, fmt.Errorf("state is at slot %d, but block is at slot %d", entry.Slot(), block.Message.Slot)
+	}
+	finCh, err := state.FinalizedCheckpoint()
+	if err != nil {
+		return nil, err
+	}
+	fin, err := finCh.Raw()
+	if err != nil {
+		return nil, err
+	}
+	if stateEpoch := spec.SlotToEpoch(entry.Slot()); fin.Epoch > stateEpoch {
+		return nil, fmt.Errorf("finalized epoch %d is after the state epoch %d", fin.Epoch, stateEpoch)
+	}
+	finSlot := spec.EpochStartSlot(fin.Epoch)
+	if fin.Epoch != 0 && finSlot < entry.Slot() && entry.Slot()-finSlot <= spec.SLOTS_PER_HISTORICAL_ROOT {
+		// The finalized checkpoint root is the block root at the start of the epoch, which is still in the history of the state
+		blockRoots, err := state.BlockRoots()
+		if err != nil {
+			return nil, err
+		}
+		histRoot, err := blockRoots.GetRoot(finSlot)
+		if err != nil {
+			return nil, err
+		}
+		if histRoot != fin.Root {
+			return nil, fmt.Errorf("finalized checkpoint root %s does not match block root %s of slot %d in state history",
+				fin.Root, histRoot, finSlot)
+		}
+	}
+	return entry, nil
+}
+
+func (c *ChainCheckpointSyncCmd) Run(ctx context.Context, args ...string) error {
+	if c.State == "" || c.Block == "" {
+		return errors.New("both --state and --block are required")
+	}
+	if _, exists := c.Chains.Find(c.Name); exists {
+		return fmt.Errorf("chain %s already exists", c.Name)
+	}
+	spec := c.States.Spec()
+	var state *beacon.BeaconStateView
+	if err := readSSZFile(c.State, func(dr *codec.DecodingReader) (err error) {
+		state, err = beacon.AsBeaconStateView(spec.BeaconState().Deserialize(dr))
+		return
+	}); err != nil {
+		return err
+	}
+	var block beacon.SignedBeaconBlock
+	if err := readSSZFile(c.Block, func(dr *codec.DecodingReader) error {
+		return block.Deserialize(spec, dr)
+	}); err != nil {
+		return err
+	}
+	anchor, err := checkCheckpointPair(spec, state, &block)
+	if err != nil {
+		return fmt.Errorf("invalid checkpoint: %v", err)
+	}
+	if _, err := c.States.Store(ctx, state); err != nil {
+		return fmt.Errorf("failed to store checkpoint state: %v", err)
+	}
+	if _, err := c.Blocks.Store(ctx, bdb.WithRoot(spec, &block)); err != nil {
+		return fmt.Errorf("failed to store checkpoint block: %v", err)
+	}
+	if _, err := c.Chains.Create(c.Name, anchor, spec); err != nil {
+		return err
+	}
+	c.ChainState.CurrentChain = c.Name
+	c.Log.WithFields(logrus.Fields{
+		"slot":       anchor.Slot(),
+		"block_root": anchor.BlockRoot(),
+		"state_root": anchor.StateRoot(),
+	}).Info("created chain from checkpoint")
+
+	if c.PeerID.PeerID == "" || anchor.Slot() <= c.BackfillTo {
+		return nil
+	}
+	return c.backfill(ctx, spec, &block)
+}
+
+// backfill requests the blocks before the anchor in reverse, batch by batch,
+// and stores them if they link to the anchor through their parent roots.
+func (c *ChainCheckpointSyncCmd) backfill(ctx context.Context, spec *beacon.Spec, anchor *beacon.SignedBeaconBlock) error {
+	if c.BatchSize == 0 {
+		return errors.New("batch size must not be 0")
+	}
+	h, err := c.Host()
+	if err != nil {
+		return err
+	}
+	sFn := reqresp.NewStreamFn(h.NewStream)
+	method := methods.BlocksByRangeRPCv1(spec)
+	peerId := c.PeerID.PeerID
+
+	protocolId := method.Protocol
+	if c.Compression.Compression != nil {
+		protocolId += protocol.ID("_" + c.Compression.Compression.Name())
+	}
+	if protocols, err := h.Peerstore().SupportsProtocols(peerId, string(protocolId)); err != nil {
+		return fmt.Errorf("failed to check protocol support of peer %s: %v", peerId.String(), err)
+	} else if len(protocols) == 0 {
+		return fmt.Errorf("peer %s does not support protocol %s", peerId.String(), protocolId)
+	}
+
+	// The next block (going back) must have this root
+	expectedRoot := anchor.Message.ParentRoot
+	// And must be before this slot
+	before := anchor.Message.Slot
+	count := 0
+	for end := anchor.Message.Slot; end > c.BackfillTo; {
+		start := c.BackfillTo
+		if end-c.BackfillTo > beacon.Slot(c.BatchSize) {
+			start = end - beacon.Slot(c.BatchSize)
+		}
+		req := methods.BlocksByRangeReqV1{
+			StartSlot: start,
+			Count:     view.Uint64View(end - start),
+			Step:      1,
+		}
+		var batch []*beacon.SignedBeaconBlock
+		reqCtx := ctx
+		var cancel context.CancelFunc
+		if c.Timeout != 0 {
+			reqCtx, cancel = context.WithTimeout(reqCtx, c.Timeout)
+		}
+		err := method.RunRequest(reqCtx, sFn, peerId, c.Compression.Compression, reqresp.RequestSSZInput{Obj: &req}, uint64(req.Count),
+			func() error {
+				return nil
+			},
+			func(chunk reqresp.ChunkedResponseHandler) error {
+				switch resultCode := chunk.ResultCode(); resultCode {
+				case reqresp.ServerErrCode, reqresp.InvalidReqCode:
+					msg, err := chunk.ReadErrMsg()
+					if err != nil {
+						return err
+					}
+					return fmt.Errorf("got error response %d on chunk %d: %s", resultCode, chunk.ChunkIndex(), msg)
+				case reqresp.SuccessCode:
+					var block beacon.SignedBeaconBlock
+					if err := chunk.ReadObj(spec.Wrap(&block)); err != nil {
+						return err
+					}
+					if block.Message.Slot < start || block.Message.Slot >= end {
+						return fmt.Errorf("bad block, expected slot in range [%d, %d), got %d", start, end, block.Message.Slot)
+					}
+					batch = append(batch, &block)
+					return nil
+				default:
+					return fmt.Errorf("received chunk (index %d, size %d) with unknown result code %d", chunk.ChunkIndex(), chunk.ChunkSize(), resultCode)
+				}
+			})
+		if cancel != nil {
+			cancel()
+		}
+		if err != nil {
+			return fmt.Errorf("back-fill request [%d, %d) failed: %v", start, end, err)
+		}
+		// Link the blocks in reverse order
+		for i := len(batch) - 1; i >= 0; i-- {
+			b := bdb.WithRoot(spec, batch[i])
+			slot := b.Block.Message.Slot
+			if slot >= before {
+				return fmt.Errorf("blocks are not in ascending order, got slot %d after %d", slot, before)
+			}
+			if b.Root != expectedRoot {
+				return fmt.Errorf("block %s at slot %d does not match expected parent root %s", b.Root, slot, expectedRoot)
+			}
+			if _, err := c.Blocks.Store(ctx, b); err != nil {
+				return fmt.Errorf("failed to store block %s: %v", b.Root, err)
+			}
+			expectedRoot = b.Block.Message.ParentRoot
+			before = slot
+			count += 1
+		}
+		c.Log.WithFields(logrus.Fields{
+			"start":  start,
+			"end":    end,
+			"blocks": len(batch),
+			"total":  count,
+		}).Info("back-filled blocks")
+		end = start
+		if before == 0 {
+			// reached genesis
+			break
+		}
+	}
+	c.Log.WithFields(logrus.Fields{
+		"blocks":        count,
+		"earliest_slot": before,
+		"next_root":     expectedRoot,
+	}).Info("completed back-fill")
+	return nil
+}
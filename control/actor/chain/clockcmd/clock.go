@@ -0,0 +1,53 @@
+package clockcmd
+
+import (
+	"errors"
+	"github.com/protolambda/ask"
+	"github.com/protolambda/rumor/chain"
+	"github.com/protolambda/rumor/chain/clock"
+	"github.com/protolambda/rumor/control/actor/base"
+)
+
+type ClockState struct {
+	// Nil until initialized with 'chain clock init'
+	Clock *clock.SlotClock
+}
+
+var NoClockErr = errors.New("no slot clock, try 'chain clock init'")
+
+// Get returns the clock, or an error if it is not initialized yet.
+func (s *ClockState) Get() (*clock.SlotClock, error) {
+	if s == nil || s.Clock == nil {
+		return nil, NoClockErr
+	}
+	return s.Clock, nil
+}
+
+type ClockCmd struct {
+	*base.Base
+	*ClockState
+	// The current chain, nil if there is none
+	Chain chain.FullChain
+}
+
+func (c *ClockCmd) Cmd(route string) (cmd interface{}, err error) {
+	switch route {
+	case "init":
+		cmd = &InitCmd{Base: c.Base, ClockState: c.ClockState, Chain: c.Chain}
+	case "now":
+		cmd = &NowCmd{Base: c.Base, ClockState: c.ClockState}
+	case "ticks":
+		cmd = &TicksCmd{Base: c.Base, ClockState: c.ClockState}
+	default:
+		return nil, ask.UnrecognizedErr
+	}
+	return cmd, nil
+}
+
+func (c *ClockCmd) Routes() []string {
+	return []string{"init", "now", "ticks"}
+}
+
+func (c *ClockCmd) Help() string {
+	return "Manage the slot clock of the actor, to follow and schedule by the slots of the chain"
+}
@@ -0,0 +1,70 @@
+package clockcmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/protolambda/rumor/chain"
+	"github.com/protolambda/rumor/chain/clock"
+	"github.com/protolambda/rumor/control/actor/base"
+	"github.com/protolambda/zrnt/eth2/beacon"
+	"github.com/sirupsen/logrus"
+)
+
+type InitCmd struct {
+	*base.Base
+	*ClockState
+	Chain          chain.FullChain
+	GenesisTime    beacon.Timestamp `ask:"--genesis-time" help:"Unix time of genesis. Defaults to the genesis time of the current chain."`
+	SecondsPerSlot beacon.Timestamp `ask:"--seconds-per-slot" help:"Seconds per slot. Defaults to the spec of the current chain."`
+	SlotsPerEpoch  beacon.Slot      `ask:"--slots-per-epoch" help:"Slots per epoch. Defaults to the spec of the current chain."`
+}
+
+func (c *InitCmd) Help() string {
+	return "Initialize the slot clock of the actor, from the current chain, or the given timing."
+}
+
+func (c *InitCmd) Run(ctx context.Context, args ...string) error {
+	if c.GenesisTime == 0 || c.SecondsPerSlot == 0 || c.SlotsPerEpoch == 0 {
+		if c.Chain == nil {
+			return errors.New("no current chain to get the clock timing from, specify the timing or try 'chain create'")
+		}
+		head, err := c.Chain.Head()
+		if err != nil {
+			return fmt.Errorf("failed to get head: %v", err)
+		}
+		epc, err := head.EpochsContext(ctx)
+		if err != nil {
+			return fmt.Errorf("failed to get epochs context of head: %v", err)
+		}
+		state, err := head.State(ctx)
+		if err != nil {
+			return fmt.Errorf("failed to get head state: %v", err)
+		}
+		if c.GenesisTime == 0 {
+			if c.GenesisTime, err = state.GenesisTime(); err != nil {
+				return fmt.Errorf("failed to get genesis time: %v", err)
+			}
+		}
+		if c.SecondsPerSlot == 0 {
+			c.SecondsPerSlot = epc.Spec.SECONDS_PER_SLOT
+		}
+		if c.SlotsPerEpoch == 0 {
+			c.SlotsPerEpoch = epc.Spec.SLOTS_PER_EPOCH
+		}
+	}
+	cl, err := clock.NewSlotClock(c.GenesisTime, c.SecondsPerSlot, c.SlotsPerEpoch)
+	if err != nil {
+		return err
+	}
+	c.ClockState.Clock = cl
+	slot := cl.CurrentSlot()
+	c.Log.WithFields(logrus.Fields{
+		"genesis":         cl.Genesis,
+		"slot_duration":   cl.SlotDuration,
+		"slots_per_epoch": cl.SlotsPerEpoch,
+		"slot":            slot,
+		"epoch":           cl.EpochOf(slot),
+	}).Info("initialized slot clock")
+	return nil
+}
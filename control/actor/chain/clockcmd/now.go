@@ -0,0 +1,38 @@
+package clockcmd
+
+import (
+	"context"
+	"github.com/protolambda/rumor/control/actor/base"
+	"github.com/sirupsen/logrus"
+	"time"
+)
+
+type NowCmd struct {
+	*base.Base
+	*ClockState
+}
+
+func (c *NowCmd) Help() string {
+	return "Log the current slot and epoch, and the time into the slot."
+}
+
+func (c *NowCmd) Run(ctx context.Context, args ...string) error {
+	cl, err := c.ClockState.Get()
+	if err != nil {
+		return err
+	}
+	now := time.Now()
+	slot := cl.SlotAt(now)
+	f := logrus.Fields{
+		"slot":       slot,
+		"epoch":      cl.EpochOf(slot),
+		"slot_start": cl.SlotStart(slot),
+	}
+	if now.Before(cl.Genesis) {
+		f["until_genesis"] = cl.Genesis.Sub(now)
+	} else {
+		f["into_slot"] = now.Sub(cl.SlotStart(slot))
+	}
+	c.Log.WithFields(f).Info("slot clock")
+	return nil
+}
@@ -0,0 +1,49 @@
+package clockcmd
+
+import (
+	"context"
+	"github.com/protolambda/rumor/control/actor/base"
+	"github.com/protolambda/rumor/control/actor/flags"
+	"github.com/protolambda/zrnt/eth2/beacon"
+	"github.com/sirupsen/logrus"
+	"time"
+)
+
+type TicksCmd struct {
+	*base.Base
+	*ClockState
+	Offset flags.SlotOffsetFlag `ask:"--offset" help:"When to tick in each slot, as fraction of the slot (e.g. '1/3') or duration (e.g. '4s')"`
+	Epochs bool                 `ask:"--epochs" help:"Only tick at the start of every epoch"`
+}
+
+func (c *TicksCmd) Help() string {
+	return "Log a slot and epoch event at every tick of the slot clock, until stopped"
+}
+
+func (c *TicksCmd) Run(ctx context.Context, args ...string) error {
+	cl, err := c.ClockState.Get()
+	if err != nil {
+		return err
+	}
+	offset := c.Offset.Offset(cl.SlotDuration)
+	if err := cl.CheckOffset(offset); err != nil {
+		return err
+	}
+	bgCtx, bgCancel := context.WithCancel(context.Background())
+	go cl.Schedule(bgCtx, offset, func(slot beacon.Slot) {
+		epoch := cl.EpochOf(slot)
+		f := logrus.Fields{"slot": slot, "epoch": epoch, "time": time.Now()}
+		if cl.IsEpochStart(slot) {
+			c.Log.WithFields(f).Info("epoch")
+		}
+		if !c.Epochs {
+			c.Log.WithFields(f).Info("slot")
+		}
+	})
+	c.Control.RegisterStop(func(ctx context.Context) error {
+		bgCancel()
+		c.Log.Info("stopped slot clock ticks")
+		return nil
+	})
+	return nil
+}
@@ -2,69 +2,154 @@ package chain
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	ds "github.com/ipfs/go-datastore"
+	"github.com/ipfs/go-datastore/sync"
+	badger "github.com/ipfs/go-ds-badger"
+	leveldb "github.com/ipfs/go-ds-leveldb"
 	"github.com/protolambda/rumor/chain"
+	bdb "github.com/protolambda/rumor/chain/db/blocks"
 	sdb "github.com/protolambda/rumor/chain/db/states"
 	"github.com/protolambda/rumor/control/actor/base"
 	"github.com/protolambda/zrnt/eth2/beacon"
-	"github.com/protolambda/ztyp/tree"
+	"github.com/sirupsen/logrus"
+	"strings"
 )
 
 type ChainCreateCmd struct {
 	*base.Base
 	chain.Chains
 	*ChainState
+	Blocks    bdb.DB
 	States    sdb.DB
 	Name      chain.ChainID `ask:"<name>" help:"The name to give to the created chain. Must not exist yet."`
-	StateRoot beacon.Root   `ask:"<state>" help:"The state to start from, retrieved from the states DB"`
+	StateRoot beacon.Root   `ask:"[state]" help:"The state to start from, retrieved from the states DB. Not used with --from-disk."`
+
+	StoreType        string `ask:"--store-type" help:"The type of datastore to persist the finalized chain in. Options: 'mem', 'leveldb', 'badger'. Default: 'mem', or 'leveldb' with --from-disk"`
+	StorePath        string `ask:"--store-path" help:"The path of the datastore, must be empty for memory store."`
+	FromDisk         string `ask:"--from-disk" help:"Path of a datastore of a previously persisted chain, to continue the chain from."`
+	SnapshotInterval uint64 `ask:"--snapshot-epochs" help:"Persist a state snapshot every N epochs. Other finalized states are replayed from the blocks DB."`
+	StateCacheSize   int    `ask:"--state-cache" help:"Number of finalized states to keep in memory, to avoid replaying blocks. 0 to disable."`
+}
+
+func (c *ChainCreateCmd) Default() {
+	c.SnapshotInterval = 8
+	c.StateCacheSize = chain.DefaultStateCacheSize
 }
 
 func (c *ChainCreateCmd) Help() string {
-	return "Create a new eth2 chain from a pre-state (using same spec config as state)"
+	return "Create a new eth2 chain from a pre-state (using same spec config as state), or continue a chain persisted on disk"
+}
+
+func openDatastore(storeType string, path string) (ds.Batching, error) {
+	path = strings.TrimSpace(path)
+	switch storeType {
+	case "mem":
+		if path != "" {
+			return nil, errors.New("memory store cannot have store path")
+		}
+		return sync.MutexWrap(ds.NewMapDatastore()), nil
+	case "leveldb":
+		if path == "" {
+			return nil, errors.New("leveldb store requires a store path to be set")
+		}
+		return leveldb.NewDatastore(path, nil)
+	case "badger":
+		if path == "" {
+			return nil, errors.New("badger store requires a store path to be set")
+		}
+		return badger.NewDatastore(path, nil)
+	default:
+		return nil, fmt.Errorf("unrecognized store type: %s", storeType)
+	}
 }
 
 func (c *ChainCreateCmd) Run(ctx context.Context, args ...string) error {
-	state, exists, err := c.States.Get(c.StateRoot)
-	if err != nil {
-		return fmt.Errorf("failed to get state: %v", err)
+	if _, exists := c.Chains.Find(c.Name); exists {
+		return fmt.Errorf("chain %s already exists", c.Name)
 	}
-	if !exists {
-		return fmt.Errorf("state %s was not found", c.StateRoot)
+	if c.StateCacheSize < 0 {
+		return errors.New("state cache size cannot be negative")
 	}
 	spec := c.States.Spec()
-	slot, err := state.Slot()
-	if err != nil {
-		return err
+	if c.FromDisk != "" {
+		if c.StorePath != "" {
+			return errors.New("cannot use --store-path with --from-disk")
+		}
+		if c.StoreType == "" {
+			c.StoreType = "leveldb"
+		}
+		store, err := openDatastore(c.StoreType, c.FromDisk)
+		if err != nil {
+			return fmt.Errorf("failed to open datastore: %v", err)
+		}
+		coldStore, err := chain.OpenColdStore(store, spec)
+		if err != nil {
+			_ = store.Close()
+			return err
+		}
+		coldCh, anchor, err := chain.LoadFinalizedChain(coldStore)
+		if err != nil {
+			_ = store.Close()
+			return fmt.Errorf("failed to load chain: %v", err)
+		}
+		coldCh.Blocks = c.Blocks
+		coldCh.Cache.Resize(c.StateCacheSize)
+		if _, err := c.Chains.CreateFrom(c.Name, anchor, coldCh); err != nil {
+			_ = store.Close()
+			return err
+		}
+		// The entries after the anchor are finalized again by the hot chain that continues from it.
+		if err := coldStore.Truncate(anchor.Slot() + 1); err != nil {
+			c.Chains.Remove(c.Name)
+			return fmt.Errorf("failed to remove entries after anchor: %v", err)
+		}
+		c.Log.WithFields(logrus.Fields{
+			"start":       coldCh.Start(),
+			"anchor_slot": anchor.Slot(),
+			"anchor_root": anchor.BlockRoot(),
+		}).Info("loaded chain from disk")
+		c.ChainState.CurrentChain = c.Name
+		return nil
 	}
-	latestHeader, err := state.LatestBlockHeader()
-	if err != nil {
-		return err
+
+	if c.StateRoot == (beacon.Root{}) {
+		return errors.New("specify the state to start the chain from, or a chain to load with --from-disk")
 	}
-	latestHeader, err = beacon.AsBeaconBlockHeader(latestHeader.Copy())
+	state, exists, err := c.States.Get(c.StateRoot)
 	if err != nil {
-		return err
+		return fmt.Errorf("failed to get state: %v", err)
+	}
+	if !exists {
+		return fmt.Errorf("state %s was not found", c.StateRoot)
 	}
-	headerStateRoot, err := latestHeader.StateRoot()
+	entry, err := chain.HotEntryFromState(spec, state)
 	if err != nil {
 		return err
 	}
-	if headerStateRoot == (beacon.Root{}) {
-		if err := latestHeader.SetStateRoot(state.HashTreeRoot(tree.GetHashFn())); err != nil {
+	coldCh := chain.NewFinalizedChain(entry.Slot(), spec)
+	coldCh.Cache.Resize(c.StateCacheSize)
+	if c.StoreType != "" {
+		if c.SnapshotInterval == 0 {
+			return errors.New("snapshot interval must be at least 1 epoch")
+		}
+		store, err := openDatastore(c.StoreType, c.StorePath)
+		if err != nil {
+			return fmt.Errorf("failed to open datastore: %v", err)
+		}
+		coldStore, err := chain.NewColdStore(store, spec, entry, chain.Slot(c.SnapshotInterval)*spec.SLOTS_PER_EPOCH)
+		if err != nil {
+			_ = store.Close()
 			return err
 		}
+		coldCh.Store = coldStore
+		coldCh.Blocks = c.Blocks
 	}
-	blockRoot := latestHeader.HashTreeRoot(tree.GetHashFn())
-	parentRoot, err := latestHeader.ParentRoot()
-	if err != nil {
-		return err
-	}
-	epc, err := spec.NewEpochsContext(state)
-	if err != nil {
-		return err
-	}
-	entry := chain.NewHotEntry(slot, blockRoot, parentRoot, state, epc)
-	_, err = c.Chains.Create(c.Name, entry, spec)
-	if err != nil {
+	if _, err := c.Chains.CreateFrom(c.Name, entry, coldCh); err != nil {
+		if coldCh.Store != nil {
+			_ = coldCh.Store.Close()
+		}
 		return err
 	}
 	c.ChainState.CurrentChain = c.Name
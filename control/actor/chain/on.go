@@ -7,13 +7,17 @@ import (
 	sdb "github.com/protolambda/rumor/chain/db/states"
 	"github.com/protolambda/rumor/control/actor/base"
 	"github.com/protolambda/rumor/control/actor/chain/chcmd"
+	"github.com/protolambda/rumor/control/actor/chain/clockcmd"
+	"github.com/protolambda/rumor/control/actor/gossip"
 )
 
 type OnCmd struct {
 	*base.Base
 	chain.Chains
-	Blocks bdb.DB
-	States sdb.DB
+	ClockState *clockcmd.ClockState
+	Blocks     bdb.DB
+	States     sdb.DB
+	*gossip.GossipState
 }
 
 func (c *OnCmd) Help() string {
@@ -32,5 +36,5 @@ func (c *OnCmd) Cmd(route string) (cmd interface{}, err error) {
 	if !ok {
 		return nil, errors.New("chain not available, create one with 'chains create'")
 	}
-	return &chcmd.ChainCmd{Base: c.Base, Chain: ch, Blocks: c.Blocks, States: c.States}, nil
+	return &chcmd.ChainCmd{Base: c.Base, Chain: ch, Blocks: c.Blocks, States: c.States, ClockState: c.ClockState, GossipState: c.GossipState}, nil
 }
@@ -0,0 +1,197 @@
+package crawl
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/libp2p/go-libp2p-core/host"
+	"github.com/protolambda/rumor/control/actor/base"
+	"github.com/protolambda/rumor/control/actor/chain/clockcmd"
+	"github.com/protolambda/rumor/control/actor/dv5"
+	"github.com/protolambda/rumor/control/actor/peer"
+	"github.com/protolambda/rumor/control/actor/peer/metadata"
+	"github.com/protolambda/rumor/control/actor/peer/status"
+	"github.com/protolambda/rumor/p2p/track"
+	"github.com/protolambda/zrnt/eth2/beacon"
+	"github.com/sirupsen/logrus"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+type CrawlCmd struct {
+	*base.Base
+	Dv5State          *dv5.Dv5State
+	Store             track.DynamicPeerstore
+	PeerStatusState   *status.PeerStatusState
+	PeerMetadataState *metadata.PeerMetadataState
+	Clock             *clockcmd.ClockState
+
+	Discover     bool              `ask:"--discover" help:"Look for random nodes with discv5, and add them to the peerstore. Requires 'dv5 run'."`
+	FilterDigest beacon.ForkDigest `ask:"--filter-digest" help:"Only add and dial peers with the given fork digest in their ENR"`
+	Filtering    bool              `changed:"filter-digest"`
+
+	Dial        bool          `ask:"--dial" help:"Dial the peers in the peerstore"`
+	DialTimeout time.Duration `ask:"--dial-timeout" help:"Connection timeout, 0 to disable"`
+	Workers     uint64        `ask:"--workers" help:"How many parallel routines should be dialing peers"`
+	MaxPeers    uint64        `ask:"--max-peers" help:"Max amount of peers, pause dialing when above this"`
+
+	Poll         bool          `ask:"--poll" help:"Request the status and metadata of connected peers"`
+	PollInterval time.Duration `ask:"--poll-interval" help:"Interval to request status and metadata on"`
+
+	Fingerprint bool `ask:"--fingerprint" help:"Classify the client of every peer, and save it in the peerstore, before reporting"`
+
+	Output         string        `ask:"--output" help:"File to write the report to, replaced on every interval. Only logged if empty."`
+	Format         string        `ask:"--format" help:"Format of the report file: 'json' or 'csv'"`
+	ReportInterval time.Duration `ask:"--report-interval" help:"Interval to aggregate and write the report on"`
+	SlotsPerEpoch  beacon.Slot   `ask:"--slots-per-epoch" help:"Slots per epoch, to bucket the head slots of peers by epochs behind"`
+}
+
+func (c *CrawlCmd) Default() {
+	c.Discover = true
+	c.Dial = true
+	c.DialTimeout = 10 * time.Second
+	c.Workers = 10
+	c.MaxPeers = 200
+	c.Poll = true
+	c.Fingerprint = true
+	c.PollInterval = 30 * time.Second
+	c.Format = "json"
+	c.ReportInterval = time.Minute
+	c.SlotsPerEpoch = 32
+}
+
+func (c *CrawlCmd) Help() string {
+	return "Crawl the network: discover and dial peers, exchange status and metadata, and report client, fork, head, attnets, protocol and reachability statistics of the peerstore, until stopped"
+}
+
+func (c *CrawlCmd) Run(ctx context.Context, args ...string) error {
+	if c.Store == nil || !c.Store.Initialized() {
+		return errors.New("crawling requires a peerstore, try 'peerstore create'")
+	}
+	h, err := c.Host()
+	if err != nil {
+		return err
+	}
+	if c.Format != "json" && c.Format != "csv" {
+		return fmt.Errorf("unrecognized report format: %s", c.Format)
+	}
+	if c.ReportInterval <= 0 {
+		return errors.New("report interval must be positive")
+	}
+	if c.SlotsPerEpoch == 0 {
+		return errors.New("slots per epoch must not be 0")
+	}
+	if c.Discover {
+		if c.Dv5State.Dv5Node == nil {
+			return dv5.NoDv5Err
+		}
+		discover := &dv5.Dv5RandomCmd{Base: c.Base, Dv5State: c.Dv5State}
+		discover.Default()
+		discover.HandleENR.Store = c.Store
+		discover.HandleENR.FilterDigest = c.FilterDigest
+		discover.HandleENR.Filtering = c.Filtering
+		if err := discover.Run(ctx); err != nil {
+			return fmt.Errorf("failed to start discovery: %v", err)
+		}
+	}
+	if c.Dial {
+		dial := &peer.PeerConnectAllCmd{Base: c.Base, Store: c.Store}
+		dial.Default()
+		dial.Timeout = c.DialTimeout
+		dial.Workers = c.Workers
+		dial.MaxPeers = c.MaxPeers
+		dial.FilterDigest = c.FilterDigest
+		dial.Filtering = c.Filtering
+		if err := dial.Run(ctx); err != nil {
+			return fmt.Errorf("failed to start dialing: %v", err)
+		}
+	}
+	if c.Poll {
+		pollStatus := &status.PeerStatusPollCmd{Base: c.Base, PeerStatusState: c.PeerStatusState, Clock: c.Clock, Book: c.Store}
+		pollStatus.Default()
+		pollStatus.Interval = c.PollInterval
+		if err := pollStatus.Run(ctx); err != nil {
+			return fmt.Errorf("failed to start status polling: %v", err)
+		}
+		pollMetadata := &metadata.PeerMetadataPollCmd{Base: c.Base, PeerMetadataState: c.PeerMetadataState, Clock: c.Clock, Store: c.Store}
+		pollMetadata.Default()
+		pollMetadata.Interval = c.PollInterval
+		if err := pollMetadata.Run(ctx); err != nil {
+			return fmt.Errorf("failed to start metadata polling: %v", err)
+		}
+	}
+
+	bgCtx, bgCancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		ticker := time.NewTicker(c.ReportInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				c.report(bgCtx, h)
+			case <-bgCtx.Done():
+				return
+			}
+		}
+	}()
+	c.Control.RegisterStop(func(ctx context.Context) error {
+		bgCancel()
+		<-done
+		// One last report, with everything that was found
+		c.report(ctx, h)
+		c.Log.Info("stopped crawling")
+		return nil
+	})
+	return nil
+}
+
+func (c *CrawlCmd) report(ctx context.Context, h host.Host) {
+	if c.Fingerprint {
+		fp := &peer.PeerFingerprintCmd{Base: c.Base, Store: c.Store}
+		fp.Default()
+		if err := fp.Run(ctx); err != nil {
+			c.Log.WithError(err).Warn("failed to fingerprint peers")
+		}
+	}
+	r := BuildReport(c.Store, h.Network(), c.SlotsPerEpoch)
+	c.Log.WithFields(logrus.Fields{
+		"known":           r.Known,
+		"connected":       r.Connected,
+		"identified":      r.Identified,
+		"with_status":     r.WithStatus,
+		"with_metadata":   r.WithMetadata,
+		"reachability":    r.ReachabilityRate,
+		"client_families": r.ClientFamilies,
+		"highest_head":    r.HighestHead,
+		"attnets_covered": r.AttnetsCovered,
+	}).Info("crawl report")
+	if c.Output == "" {
+		return
+	}
+	if err := writeReport(r, c.Output, c.Format); err != nil {
+		c.Log.WithError(err).Error("failed to write crawl report")
+	}
+}
+
+// writeReport replaces the report file, by writing to a temporary file first and renaming it,
+// so readers never see a partially written report.
+func writeReport(r *Report, path string, format string) error {
+	f, err := ioutil.TempFile(filepath.Dir(path), filepath.Base(path)+".tmp*")
+	if err != nil {
+		return err
+	}
+	if err := r.Write(f, format); err != nil {
+		_ = f.Close()
+		_ = os.Remove(f.Name())
+		return err
+	}
+	if err := f.Close(); err != nil {
+		_ = os.Remove(f.Name())
+		return err
+	}
+	return os.Rename(f.Name(), path)
+}
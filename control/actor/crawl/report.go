@@ -0,0 +1,223 @@
+package crawl
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"github.com/libp2p/go-libp2p-core/network"
+	"github.com/libp2p/go-libp2p-core/peer"
+	"github.com/protolambda/rumor/p2p/track"
+	"github.com/protolambda/rumor/p2p/track/fingerprint"
+	"github.com/protolambda/zrnt/eth2/beacon"
+	"io"
+	"sort"
+	"strconv"
+	"time"
+)
+
+// Head slot distances from the highest known head, in epochs
+var headBuckets = []struct {
+	name      string
+	maxEpochs uint64
+}{
+	{"synced", 1},
+	{"behind_1_4_epochs", 4},
+	{"behind_4_32_epochs", 32},
+}
+
+const headBucketFar = "behind_32_epochs_or_more"
+
+type Report struct {
+	Time time.Time `json:"time"`
+
+	// Peers in the peerstore
+	Known int `json:"known"`
+	// Peers with addresses, that can be dialed
+	Dialable int `json:"dialable"`
+	// Peers we are connected to now
+	Connected int `json:"connected"`
+	// Peers that completed identify, i.e. that we connected to at some point
+	Identified int `json:"identified"`
+	// Peers that responded with a status
+	WithStatus int `json:"with_status"`
+	// Peers that responded with metadata
+	WithMetadata int `json:"with_metadata"`
+	// Identified peers relative to dialable peers
+	ReachabilityRate float64 `json:"reachability_rate"`
+
+	UserAgents map[string]int `json:"user_agents"`
+	// Client families, by the stored fingerprint, or else the user agent
+	ClientFamilies map[string]int `json:"client_families"`
+	// Fork digests in ENRs
+	EnrForkDigests map[string]int `json:"enr_fork_digests"`
+	// Fork digests in status responses
+	StatusForkDigests map[string]int `json:"status_fork_digests"`
+
+	HighestHead beacon.Slot    `json:"highest_head"`
+	HeadSlots   map[string]int `json:"head_slots"`
+
+	// For each attestation subnet, the number of peers that is subscribed to it, by metadata, or else ENR
+	Attnets [beacon.ATTESTATION_SUBNET_COUNT]int `json:"attnets"`
+	// Subnets with at least one subscribed peer
+	AttnetsCovered int `json:"attnets_covered"`
+
+	Protocols map[string]int `json:"protocols"`
+}
+
+// BuildReport aggregates the data of all peers in the peerstore.
+// The network is optional, to count the connected peers.
+func BuildReport(store track.ExtendedPeerstore, net network.Network, slotsPerEpoch beacon.Slot) *Report {
+	r := &Report{
+		Time:              time.Now(),
+		UserAgents:        make(map[string]int),
+		ClientFamilies:    make(map[string]int),
+		EnrForkDigests:    make(map[string]int),
+		StatusForkDigests: make(map[string]int),
+		HeadSlots:         make(map[string]int),
+		Protocols:         make(map[string]int),
+	}
+	peers := store.Peers()
+	if net != nil {
+		// Connected peers may not be in the address book yet
+		known := make(map[peer.ID]struct{}, len(peers))
+		for _, id := range peers {
+			known[id] = struct{}{}
+		}
+		for _, id := range net.Peers() {
+			if _, ok := known[id]; !ok {
+				peers = append(peers, id)
+			}
+		}
+	}
+	var heads []beacon.Slot
+	for _, id := range peers {
+		data := store.GetAllData(id)
+		if data == nil {
+			continue
+		}
+		r.Known += 1
+		if len(data.Addrs) > 0 {
+			r.Dialable += 1
+		}
+		if net != nil && net.Connectedness(id) == network.Connected {
+			r.Connected += 1
+		}
+		if data.UserAgent != "" || data.ProtocolVersion != "" {
+			r.Identified += 1
+			r.UserAgents[data.UserAgent] += 1
+			if data.Fingerprint == nil {
+				r.ClientFamilies[fingerprint.ClientFamily(data.UserAgent)] += 1
+			}
+		}
+		if data.Fingerprint != nil {
+			// classified peers may not have identified themselves
+			r.ClientFamilies[data.Fingerprint.Client] += 1
+		}
+		if data.ForkDigest != nil {
+			r.EnrForkDigests[data.ForkDigest.String()] += 1
+		}
+		if data.Status != nil {
+			r.WithStatus += 1
+			r.StatusForkDigests[data.Status.ForkDigest.String()] += 1
+			heads = append(heads, data.Status.HeadSlot)
+		}
+		attnets := data.Attnets
+		if data.MetaData != nil {
+			r.WithMetadata += 1
+			attnets = &data.MetaData.Attnets
+		}
+		if attnets != nil {
+			for i := 0; i < beacon.ATTESTATION_SUBNET_COUNT; i++ {
+				if (attnets[i/8]>>(i%8))&1 == 1 {
+					r.Attnets[i] += 1
+				}
+			}
+		}
+		for _, p := range data.Protocols {
+			r.Protocols[p] += 1
+		}
+	}
+	if r.Dialable > 0 {
+		r.ReachabilityRate = float64(r.Identified) / float64(r.Dialable)
+	}
+	for _, count := range r.Attnets {
+		if count > 0 {
+			r.AttnetsCovered += 1
+		}
+	}
+	for _, h := range heads {
+		if h > r.HighestHead {
+			r.HighestHead = h
+		}
+	}
+	for _, h := range heads {
+		r.HeadSlots[headBucket(uint64(r.HighestHead-h), uint64(slotsPerEpoch))] += 1
+	}
+	return r
+}
+
+func headBucket(behind uint64, slotsPerEpoch uint64) string {
+	for _, b := range headBuckets {
+		if behind < b.maxEpochs*slotsPerEpoch {
+			return b.name
+		}
+	}
+	return headBucketFar
+}
+
+func (r *Report) WriteJSON(w io.Writer) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(r)
+}
+
+// WriteCSV writes the report as rows of section, key and value.
+func (r *Report) WriteCSV(w io.Writer) error {
+	cw := csv.NewWriter(w)
+	row := func(section string, key string, value string) {
+		_ = cw.Write([]string{section, key, value})
+	}
+	row("section", "key", "value")
+	row("time", "", r.Time.UTC().Format(time.RFC3339))
+	row("peers", "known", strconv.Itoa(r.Known))
+	row("peers", "dialable", strconv.Itoa(r.Dialable))
+	row("peers", "connected", strconv.Itoa(r.Connected))
+	row("peers", "identified", strconv.Itoa(r.Identified))
+	row("peers", "with_status", strconv.Itoa(r.WithStatus))
+	row("peers", "with_metadata", strconv.Itoa(r.WithMetadata))
+	row("peers", "reachability_rate", strconv.FormatFloat(r.ReachabilityRate, 'f', 4, 64))
+	counts := func(section string, m map[string]int) {
+		keys := make([]string, 0, len(m))
+		for k := range m {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		for _, k := range keys {
+			row(section, k, strconv.Itoa(m[k]))
+		}
+	}
+	counts("user_agent", r.UserAgents)
+	counts("client_family", r.ClientFamilies)
+	counts("enr_fork_digest", r.EnrForkDigests)
+	counts("status_fork_digest", r.StatusForkDigests)
+	row("head", "highest", strconv.FormatUint(uint64(r.HighestHead), 10))
+	counts("head", r.HeadSlots)
+	for i, count := range r.Attnets {
+		row("attnet", strconv.Itoa(i), strconv.Itoa(count))
+	}
+	row("attnets", "covered", strconv.Itoa(r.AttnetsCovered))
+	counts("protocol", r.Protocols)
+	cw.Flush()
+	return cw.Error()
+}
+
+func (r *Report) Write(w io.Writer, format string) error {
+	switch format {
+	case "json":
+		return r.WriteJSON(w)
+	case "csv":
+		return r.WriteCSV(w)
+	default:
+		return fmt.Errorf("unrecognized report format: %s", format)
+	}
+}
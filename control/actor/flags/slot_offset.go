@@ -0,0 +1,72 @@
+package flags
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// SlotOffsetFlag is a point in time within a slot: a fraction of the slot ("1/3", "0.5"), or a duration ("4s").
+type SlotOffsetFlag struct {
+	// Fraction of the slot, used if Duration is 0
+	Fraction float64
+	Duration time.Duration
+	// Specified is true if a value was parsed, to tell an explicit start of the slot from no offset
+	Specified bool
+}
+
+// Offset is the offset into a slot of the given duration.
+func (f *SlotOffsetFlag) Offset(slotDuration time.Duration) time.Duration {
+	if f.Duration != 0 {
+		return f.Duration
+	}
+	return time.Duration(f.Fraction * float64(slotDuration))
+}
+
+func (f *SlotOffsetFlag) String() string {
+	if f == nil {
+		return "nil slot offset"
+	}
+	if f.Duration != 0 {
+		return f.Duration.String()
+	}
+	return strconv.FormatFloat(f.Fraction, 'f', -1, 64)
+}
+
+func (f *SlotOffsetFlag) Set(v string) error {
+	v = strings.TrimSpace(v)
+	*f = SlotOffsetFlag{}
+	if i := strings.IndexByte(v, '/'); i >= 0 {
+		num, err := strconv.ParseUint(v[:i], 10, 64)
+		if err != nil {
+			return fmt.Errorf("bad fraction numerator %q: %v", v, err)
+		}
+		denom, err := strconv.ParseUint(v[i+1:], 10, 64)
+		if err != nil {
+			return fmt.Errorf("bad fraction denominator %q: %v", v, err)
+		}
+		if denom == 0 || num >= denom {
+			return fmt.Errorf("fraction %q is not within a slot", v)
+		}
+		f.Fraction = float64(num) / float64(denom)
+	} else if frac, err := strconv.ParseFloat(v, 64); err == nil {
+		if frac < 0 || frac >= 1 {
+			return fmt.Errorf("fraction %q is not within a slot", v)
+		}
+		f.Fraction = frac
+	} else if d, err := time.ParseDuration(v); err == nil {
+		if d < 0 {
+			return fmt.Errorf("negative slot offset %q", v)
+		}
+		f.Duration = d
+	} else {
+		return fmt.Errorf("slot offset %q is not a fraction or duration", v)
+	}
+	f.Specified = true
+	return nil
+}
+
+func (f *SlotOffsetFlag) Type() string {
+	return "slot offset"
+}
@@ -0,0 +1,68 @@
+package flags
+
+import (
+	"testing"
+	"time"
+)
+
+func TestSlotOffsetFlagSet(t *testing.T) {
+	slot := 12 * time.Second
+	cases := []struct {
+		input  string
+		offset time.Duration
+		str    string
+	}{
+		{"0", 0, "0"},
+		{"1/3", 4 * time.Second, "0.3333333333333333"},
+		{" 2/3 ", 8 * time.Second, "0.6666666666666666"},
+		{"0/5", 0, "0"},
+		{"0.5", 6 * time.Second, "0.5"},
+		{"4s", 4 * time.Second, "4s"},
+		{"1500ms", 1500 * time.Millisecond, "1.5s"},
+		// A zero duration is the start of the slot
+		{"0s", 0, "0"},
+		// Durations are not checked against the slot duration here, the slot clock does that
+		{"30s", 30 * time.Second, "30s"},
+	}
+	for _, c := range cases {
+		var f SlotOffsetFlag
+		if err := f.Set(c.input); err != nil {
+			t.Fatalf("%q: %v", c.input, err)
+		}
+		if !f.Specified {
+			t.Errorf("%q: expected offset to be specified", c.input)
+		}
+		if offset := f.Offset(slot); offset != c.offset {
+			t.Errorf("%q: expected offset %s, got %s", c.input, c.offset, offset)
+		}
+		if s := f.String(); s != c.str {
+			t.Errorf("%q: expected string %q, got %q", c.input, c.str, s)
+		}
+	}
+}
+
+func TestSlotOffsetFlagSetInvalid(t *testing.T) {
+	for _, input := range []string{
+		"",
+		"-1s",
+		"-0.5",
+		"-1/3",
+		"1/-3",
+		"1",
+		"1.5",
+		"3/3",
+		"4/3",
+		"1/0",
+		"a/3",
+		"1/b",
+		"soon",
+	} {
+		f := SlotOffsetFlag{Duration: time.Second, Specified: true}
+		if err := f.Set(input); err == nil {
+			t.Errorf("%q: expected slot offset to be rejected, got %s", input, f.String())
+		}
+		if f.Specified {
+			t.Errorf("%q: expected rejected offset to not be specified", input)
+		}
+	}
+}
@@ -0,0 +1,84 @@
+package flags
+
+import (
+	"fmt"
+	"github.com/protolambda/zrnt/eth2/beacon"
+	"strconv"
+	"strings"
+)
+
+// MaxValidatorIndices is the maximum number of validator indices a ValidatorIndicesFlag can hold.
+const MaxValidatorIndices = 1 << 20
+
+// ValidatorIndicesFlag parses a comma separated list of validator indices and inclusive ranges, e.g. "1,2,5-10"
+type ValidatorIndicesFlag struct {
+	Indices []beacon.ValidatorIndex
+}
+
+func (f *ValidatorIndicesFlag) String() string {
+	if f == nil {
+		return "nil validator indices"
+	}
+	parts := make([]string, 0, len(f.Indices))
+	for i := 0; i < len(f.Indices); i++ {
+		start := f.Indices[i]
+		end := start
+		for i+1 < len(f.Indices) && f.Indices[i+1] == end+1 {
+			i++
+			end++
+		}
+		if start == end {
+			parts = append(parts, fmt.Sprintf("%d", start))
+		} else {
+			parts = append(parts, fmt.Sprintf("%d-%d", start, end))
+		}
+	}
+	return strings.Join(parts, ",")
+}
+
+func (f *ValidatorIndicesFlag) Set(v string) error {
+	f.Indices = f.Indices[:0]
+	for _, part := range strings.Split(v, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+		if i := strings.IndexByte(part, '-'); i >= 0 {
+			start, err := strconv.ParseUint(part[:i], 10, 64)
+			if err != nil {
+				return fmt.Errorf("bad range start %q: %v", part, err)
+			}
+			end, err := strconv.ParseUint(part[i+1:], 10, 64)
+			if err != nil {
+				return fmt.Errorf("bad range end %q: %v", part, err)
+			}
+			if end < start {
+				return fmt.Errorf("bad range %q: end is before start", part)
+			}
+			if end-start >= MaxValidatorIndices-uint64(len(f.Indices)) {
+				return fmt.Errorf("bad range %q: more than %d validator indices", part, MaxValidatorIndices)
+			}
+			for x := start; ; x++ {
+				f.Indices = append(f.Indices, beacon.ValidatorIndex(x))
+				// no x <= end loop condition, x would overflow if end is the max uint64
+				if x == end {
+					break
+				}
+			}
+		} else {
+			x, err := strconv.ParseUint(part, 10, 64)
+			if err != nil {
+				return fmt.Errorf("bad validator index %q: %v", part, err)
+			}
+			if len(f.Indices) >= MaxValidatorIndices {
+				return fmt.Errorf("more than %d validator indices", MaxValidatorIndices)
+			}
+			f.Indices = append(f.Indices, beacon.ValidatorIndex(x))
+		}
+	}
+	return nil
+}
+
+func (f *ValidatorIndicesFlag) Type() string {
+	return "validator indices"
+}
@@ -21,14 +21,19 @@ func (c *GossipPublishCmd) Help() string {
 }
 
 func (c *GossipPublishCmd) Run(ctx context.Context, args ...string) error {
-	if c.GossipState.GsNode == nil {
+	return c.GossipState.Publish(ctx, c.TopicName, c.Message)
+}
+
+// Publish publishes the uncompressed message to a joined topic, snappy-compressed if the topic requires it.
+func (gs *GossipState) Publish(ctx context.Context, topicName string, msg []byte) error {
+	if gs.GsNode == nil {
 		return NoGossipErr
 	}
-	if top, ok := c.GossipState.Topics.Load(c.TopicName); !ok {
-		return fmt.Errorf("not on gossip topic %s", c.TopicName)
+	if top, ok := gs.Topics.Load(topicName); !ok {
+		return fmt.Errorf("not on gossip topic %s", topicName)
 	} else {
-		data := c.Message
-		if strings.HasSuffix(c.TopicName, "_snappy") {
+		data := msg
+		if strings.HasSuffix(topicName, "_snappy") {
 			data = snappy.Encode(nil, data)
 		}
 		if err := top.(*pubsub.Topic).Publish(ctx, data); err != nil {
@@ -37,3 +42,36 @@ func (c *GossipPublishCmd) Run(ctx context.Context, args ...string) error {
 		return nil
 	}
 }
+
+// Listen subscribes to a joined topic, and calls fn with every message and its uncompressed data, until the context is done.
+// Messages that cannot be decompressed are skipped. Returns nil when the context is done.
+func (gs *GossipState) Listen(ctx context.Context, topicName string, fn func(msg *pubsub.Message, data []byte)) error {
+	if gs.GsNode == nil {
+		return NoGossipErr
+	}
+	top, ok := gs.Topics.Load(topicName)
+	if !ok {
+		return fmt.Errorf("not on gossip topic %s", topicName)
+	}
+	sub, err := top.(*pubsub.Topic).Subscribe()
+	if err != nil {
+		return fmt.Errorf("cannot open subscription on topic %s: %v", topicName, err)
+	}
+	defer sub.Cancel()
+	for {
+		msg, err := sub.Next(ctx)
+		if err != nil {
+			if err == ctx.Err() { // expected quit, context stopped.
+				return nil
+			}
+			return err
+		}
+		data := msg.Data
+		if strings.HasSuffix(topicName, "_snappy") {
+			if data, err = snappy.Decode(nil, msg.Data); err != nil {
+				continue
+			}
+		}
+		fn(msg, data)
+	}
+}
@@ -4,6 +4,7 @@ import (
 	"github.com/libp2p/go-libp2p-core/host"
 	"github.com/protolambda/ask"
 	"github.com/protolambda/rumor/control/actor/base"
+	"github.com/protolambda/rumor/p2p/memnet"
 	"github.com/protolambda/rumor/p2p/track"
 )
 
@@ -21,6 +22,8 @@ type HostCmd struct {
 	GlobalPeerstores track.Peerstores
 	CurrentPeerstore track.DynamicPeerstore
 
+	MemNet *memnet.Network
+
 	WithSetHost
 	WithCloseHost
 	base.PrivSettings
@@ -31,7 +34,7 @@ func (c *HostCmd) Cmd(route string) (cmd interface{}, err error) {
 	switch route {
 	case "start":
 		cmd = &HostStartCmd{Base: c.Base, WithSetHost: c.WithSetHost, PrivSettings: c.PrivSettings,
-			GlobalPeerstores: c.GlobalPeerstores, CurrentPeerstore: c.CurrentPeerstore}
+			GlobalPeerstores: c.GlobalPeerstores, CurrentPeerstore: c.CurrentPeerstore, MemNet: c.MemNet}
 	case "stop":
 		cmd = &HostStopCmd{Base: c.Base, WithCloseHost: c.WithCloseHost}
 	case "view":
@@ -40,6 +43,8 @@ func (c *HostCmd) Cmd(route string) (cmd interface{}, err error) {
 		cmd = &HostListenCmd{Base: c.Base, WithEnrNode: c.WithEnrNode}
 	case "notify":
 		cmd = &HostNotifyCmd{c.Base}
+	case "memlink":
+		cmd = &HostMemLinkCmd{Base: c.Base, MemNet: c.MemNet}
 	default:
 		return nil, ask.UnrecognizedErr
 	}
@@ -47,7 +52,7 @@ func (c *HostCmd) Cmd(route string) (cmd interface{}, err error) {
 }
 
 func (c *HostCmd) Routes() []string {
-	return []string{"start", "stop", "view", "listen", "event"}
+	return []string{"start", "stop", "view", "listen", "notify", "memlink"}
 }
 
 func (c *HostCmd) Help() string {
@@ -0,0 +1,55 @@
+package host
+
+import (
+	"context"
+	"errors"
+	"github.com/protolambda/rumor/control/actor/base"
+	"github.com/protolambda/rumor/control/actor/flags"
+	"github.com/protolambda/rumor/p2p/memnet"
+	"time"
+)
+
+type HostMemLinkCmd struct {
+	*base.Base
+	MemNet *memnet.Network
+
+	PeerID    flags.PeerIDFlag `ask:"<peer-id>" help:"The remote peer of the link"`
+	Latency   time.Duration    `ask:"--latency" help:"Simulated one-way latency"`
+	Bandwidth uint64           `ask:"--bandwidth" help:"Simulated bandwidth in bytes/sec. 0 for unlimited"`
+	Loss      float64          `ask:"--loss" help:"Simulated packet loss probability (0 to 1)"`
+	Both      bool             `ask:"--both" help:"Apply the settings to the link in both directions, not just outgoing traffic"`
+	Reset     bool             `ask:"--reset" help:"Remove the link settings, the host defaults (see 'host start --mem-*') apply again"`
+}
+
+func (c *HostMemLinkCmd) Help() string {
+	return "Change the simulated latency, bandwidth and packet loss of the in-memory link to a peer."
+}
+
+func (c *HostMemLinkCmd) Run(ctx context.Context, args ...string) error {
+	h, err := c.Host()
+	if err != nil {
+		return err
+	}
+	if c.MemNet == nil {
+		return errors.New("no in-memory network available")
+	}
+	if c.Loss < 0 || c.Loss > 1 {
+		return errors.New("loss must be a probability between 0 and 1")
+	}
+	local, remote := h.ID(), c.PeerID.PeerID
+	if c.Reset {
+		c.MemNet.ResetLink(local, remote)
+		if c.Both {
+			c.MemNet.ResetLink(remote, local)
+		}
+	} else {
+		settings := memnet.LinkSettings{Latency: c.Latency, Bandwidth: c.Bandwidth, Loss: c.Loss}
+		c.MemNet.SetLink(local, remote, settings)
+		if c.Both {
+			c.MemNet.SetLink(remote, local, settings)
+		}
+	}
+	c.Log.WithField("peer", remote.String()).WithField("outgoing", c.MemNet.Link(local, remote).String()).
+		WithField("incoming", c.MemNet.Link(remote, local).String()).Info("in-memory link settings")
+	return nil
+}
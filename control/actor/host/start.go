@@ -23,6 +23,7 @@ import (
 	"github.com/protolambda/rumor/control/actor/flags"
 	"github.com/protolambda/rumor/control/actor/peerstore"
 	"github.com/protolambda/rumor/p2p/custom"
+	"github.com/protolambda/rumor/p2p/memnet"
 	"github.com/protolambda/rumor/p2p/track"
 	"strings"
 	"time"
@@ -36,8 +37,10 @@ type HostStartCmd struct {
 	GlobalPeerstores track.Peerstores
 	CurrentPeerstore track.DynamicPeerstore
 
+	MemNet *memnet.Network
+
 	PrivKey            flags.P2pPrivKeyFlag `ask:"--priv" help:"hex-encoded private key for libp2p host. Random if none is specified."`
-	TransportsStrArr   []string             `ask:"--transport" help:"Transports to use. Options: tcp, ws"`
+	TransportsStrArr   []string             `ask:"--transport" help:"Transports to use. Options: tcp, ws, mem"`
 	MuxStrArr          []string             `ask:"--mux" help:"Multiplexers to use"`
 	SecurityArr        []string             `ask:"--security" help:"Security to use. Multiple can be selected, order matters. Options: secio, noise, tls, none"`
 	RelayEnabled       bool                 `ask:"--relay" help:"enable relayer functionality"`
@@ -51,6 +54,9 @@ type HostStartCmd struct {
 	EnablePing         bool                 `ask:"--libp2p-ping" help:"Enable the libp2p ping background service"`
 	NegotiationTimeout time.Duration        `ask:"--negotiation-timeout" help:"Time to allow for negotiation. Negative to disable."`
 	SignedPeerRecord   bool                 `ask:"--signed-peer-records" help:"Use signed peer records"`
+	MemLatency         time.Duration        `ask:"--mem-latency" help:"Simulated one-way latency of outgoing traffic, for the mem transport"`
+	MemBandwidth       uint64               `ask:"--mem-bandwidth" help:"Simulated bandwidth (bytes/sec) of outgoing traffic, for the mem transport. 0 for unlimited"`
+	MemLoss            float64              `ask:"--mem-loss" help:"Simulated packet loss probability (0 to 1) of outgoing traffic, for the mem transport"`
 }
 
 func (c *HostStartCmd) Default() {
@@ -78,6 +84,9 @@ func (c *HostStartCmd) Run(ctx context.Context, args ...string) error {
 	if err == nil {
 		return errors.New("already have a host open")
 	}
+	if c.MemLoss < 0 || c.MemLoss > 1 {
+		return errors.New("mem loss must be a probability between 0 and 1")
+	}
 
 	hostOptions := custom.Config{}
 	{
@@ -116,6 +125,7 @@ func (c *HostStartCmd) Run(ctx context.Context, args ...string) error {
 		hostOptions.PeerKey = priv
 	}
 
+	useMem := false
 	for _, v := range c.TransportsStrArr {
 		v = strings.ToLower(strings.TrimSpace(v))
 		var tp interface{}
@@ -124,6 +134,12 @@ func (c *HostStartCmd) Run(ctx context.Context, args ...string) error {
 			tp = tcp.NewTCPTransport
 		case "ws":
 			tp = ws.New
+		case "mem":
+			if c.MemNet == nil {
+				return errors.New("no in-memory network available")
+			}
+			tp = memnet.Constructor(c.MemNet)
+			useMem = true
 		default:
 			return fmt.Errorf("could not recognize transport %s", v)
 		}
@@ -221,5 +237,24 @@ func (c *HostStartCmd) Run(ctx context.Context, args ...string) error {
 	if err != nil {
 		return err
 	}
-	return c.SetHost(h)
+	if err := c.SetHost(h); err != nil {
+		return err
+	}
+	if useMem {
+		// Memory addresses are free, no need for a separate 'host listen' to pick one.
+		c.MemNet.SetDefault(h.ID(), memnet.LinkSettings{
+			Latency:   c.MemLatency,
+			Bandwidth: c.MemBandwidth,
+			Loss:      c.MemLoss,
+		})
+		if err := h.Network().Listen(memnet.MemAddr(0)); err != nil {
+			return fmt.Errorf("failed to listen on in-memory network: %v", err)
+		}
+		for _, a := range h.Network().ListenAddresses() {
+			if _, err := memnet.AddrID(a); err == nil {
+				c.Log.WithField("addr", a.String()+"/p2p/"+h.ID().String()).Info("started listening on in-memory address")
+			}
+		}
+	}
+	return nil
 }
@@ -0,0 +1,103 @@
+package peer
+
+import (
+	"context"
+	"fmt"
+	"github.com/libp2p/go-libp2p-core/peer"
+	"github.com/protolambda/rumor/control/actor/base"
+	"github.com/protolambda/rumor/p2p/track"
+	"github.com/protolambda/rumor/p2p/track/fingerprint"
+	"github.com/sirupsen/logrus"
+)
+
+type PeerFingerprintCmd struct {
+	*base.Base
+	Store track.ExtendedPeerstore
+
+	Which string `ask:"[which]" help:"Which peers to fingerprint, possible values: 'all', 'connected'."`
+	Learn bool   `ask:"--learn" help:"Learn the behaviour of clients from all peers that identify themselves, to classify peers without a recognized user agent"`
+	Save  bool   `ask:"--save" help:"Save the fingerprints in the peerstore"`
+}
+
+func (c *PeerFingerprintCmd) Help() string {
+	return "Classify peers into client implementations and versions, by user agent, protocols, compression, ENR keys and RPC quirks."
+}
+
+func (c *PeerFingerprintCmd) Default() {
+	c.Which = "all"
+	c.Learn = true
+	c.Save = true
+}
+
+func fingerprintInput(data *track.PeerAllData) *fingerprint.Input {
+	return &fingerprint.Input{
+		UserAgent:       data.UserAgent,
+		ProtocolVersion: data.ProtocolVersion,
+		Protocols:       data.Protocols,
+		ENR:             data.ENR,
+		Quirks:          data.Quirks,
+	}
+}
+
+func (c *PeerFingerprintCmd) Run(ctx context.Context, args ...string) error {
+	var peers []peer.ID
+	switch c.Which {
+	case "all":
+		peers = c.Store.Peers()
+	case "connected":
+		h, err := c.Host()
+		if err != nil {
+			return err
+		}
+		peers = h.Network().Peers()
+	default:
+		return fmt.Errorf("invalid peer selection type: %s", c.Which)
+	}
+	var profiles *fingerprint.Profiles
+	if c.Learn {
+		profiles = fingerprint.NewProfiles()
+		learned := 0
+		// learn from all known peers, not just the selection
+		for _, p := range c.Store.Peers() {
+			if err := ctx.Err(); err != nil {
+				return err
+			}
+			data := c.Store.GetAllData(p)
+			if data == nil {
+				continue
+			}
+			if profiles.Learn(fingerprintInput(data)) != fingerprint.Unknown {
+				learned += 1
+			}
+		}
+		c.Log.WithField("peers", learned).Debug("learned client profiles")
+	}
+	clients := make(map[string]int)
+	for _, p := range peers {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		data := c.Store.GetAllData(p)
+		if data == nil {
+			continue
+		}
+		fp := fingerprint.Classify(fingerprintInput(data), profiles)
+		clients[fp.Client] += 1
+		c.Log.WithFields(logrus.Fields{
+			"peer_id":     p,
+			"client":      fp.Client,
+			"version":     fp.Version,
+			"libp2p":      fp.Libp2p,
+			"confidence":  fp.Confidence,
+			"compression": fp.Compression,
+			"signals":     fp.Signals,
+		}).Debug("fingerprint")
+		if c.Save {
+			if err := c.Store.RegisterFingerprint(p, fp); err != nil {
+				return fmt.Errorf("failed to store fingerprint of peer %s: %v", p, err)
+			}
+		}
+	}
+	c.Log.WithField("clients", clients).Infof("fingerprinted %d peers", len(peers))
+	return nil
+}
@@ -2,6 +2,7 @@ package peer
 
 import (
 	"context"
+	"fmt"
 	"github.com/protolambda/rumor/control/actor/base"
 	"github.com/protolambda/rumor/control/actor/flags"
 	"github.com/protolambda/rumor/p2p/track"
@@ -21,6 +22,9 @@ func (c *PeerInfoCmd) Help() string {
 
 func (c *PeerInfoCmd) Run(ctx context.Context, args ...string) error {
 	info := c.Store.GetAllData(c.PeerID.PeerID)
+	if info == nil {
+		return fmt.Errorf("peer %s has no secp256k1 key, it is not an eth2 peer", c.PeerID.PeerID)
+	}
 	f := logrus.Fields{}
 	f["peer_id"] = info.PeerID
 	f["node_id"] = info.NodeID
@@ -66,6 +70,12 @@ func (c *PeerInfoCmd) Run(ctx context.Context, args ...string) error {
 	if info.ENR != nil {
 		f["enr"] = info.ENR
 	}
+	if info.Fingerprint != nil {
+		f["fingerprint"] = info.Fingerprint
+	}
+	if info.Quirks != nil {
+		f["quirks"] = info.Quirks
+	}
 	c.Log.WithFields(f).Infof("peer info")
 	return nil
 }
@@ -1,11 +1,17 @@
 package peer
 
 import (
+	"bufio"
+	"bytes"
 	"context"
 	"fmt"
+	"github.com/libp2p/go-libp2p-core/network"
 	"github.com/libp2p/go-libp2p-core/peer"
 	"github.com/protolambda/rumor/control/actor/base"
 	"github.com/protolambda/rumor/p2p/track"
+	"github.com/protolambda/rumor/p2p/track/query"
+	"os"
+	"strings"
 )
 
 type PeerListCmd struct {
@@ -14,14 +20,25 @@ type PeerListCmd struct {
 
 	Which   string `ask:"[which]" help:"Which peers to list, possible values: 'all', 'connected'."`
 	Details bool   `ask:"--details" help:"List detailed data of each peer"`
+
+	Where string `ask:"--where" help:"Query to filter peers by, e.g. 'status.head_slot > 1000 && enr.attnets[5] && agent ~ \"Prysm\"'"`
+	Sort  string `ask:"--sort" help:"Field to sort peers by, prefix with '-' to sort descending, e.g. '-status.head_slot'"`
+	Limit uint64 `ask:"--limit" help:"Max amount of peers to list, 0 to list all"`
+
+	Format        string `ask:"--format" help:"Output format: 'log', 'table', 'csv' or 'jsonl'"`
+	Fields        string `ask:"--fields" help:"Comma separated fields to output in table, csv and jsonl formats. By default, jsonl outputs all data."`
+	FieldsChanged bool   `changed:"fields"`
+	Output        string `ask:"--output" help:"File to write table, csv or jsonl output to. Logged line by line if empty."`
 }
 
 func (c *PeerListCmd) Help() string {
-	return "List peers."
+	return "List peers. Fields to query: " + strings.Join(query.Fields(), ", ")
 }
 
 func (c *PeerListCmd) Default() {
 	c.Which = "connected"
+	c.Format = "log"
+	c.Fields = "peer_id,agent,fingerprint.client,status.head_slot,connected"
 }
 
 func (c *PeerListCmd) Run(ctx context.Context, args ...string) error {
@@ -38,14 +55,93 @@ func (c *PeerListCmd) Run(ctx context.Context, args ...string) error {
 	default:
 		return fmt.Errorf("invalid peer selection type: %s", c.Which)
 	}
-	if c.Details {
-		peerData := make(map[string]*track.PeerAllData)
-		for _, p := range peers {
-			peerData[p.String()] = c.Store.GetAllData(p)
+	q, err := query.Parse(c.Where)
+	if err != nil {
+		return fmt.Errorf("bad query: %v", err)
+	}
+	var sortBy *query.Path
+	desc := strings.HasPrefix(c.Sort, "-")
+	if c.Sort != "" {
+		if sortBy, err = query.ParsePath(strings.TrimPrefix(c.Sort, "-")); err != nil {
+			return fmt.Errorf("bad sort field: %v", err)
 		}
-		c.Log.WithField("peers", peerData).Infof("%d peers", len(peers))
-	} else {
+	}
+	names, paths, err := query.ParsePaths(c.Fields)
+	if err != nil {
+		return fmt.Errorf("bad fields: %v", err)
+	}
+	if c.Format == "jsonl" && !c.FieldsChanged {
+		names, paths = nil, nil
+	}
+
+	if c.Format == "log" && c.Where == "" && c.Sort == "" && c.Limit == 0 && !c.Details {
 		c.Log.WithField("peers", peers).Infof("%d peers", len(peers))
+		return nil
+	}
+
+	records := make([]*query.Record, 0, len(peers))
+	for _, p := range peers {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		data := c.Store.GetAllData(p)
+		if data == nil {
+			continue
+		}
+		r := &query.Record{PeerAllData: data}
+		if hostErr == nil {
+			r.Connected = h.Network().Connectedness(p) == network.Connected
+		}
+		if q.Match(r) {
+			records = append(records, r)
+		}
+	}
+	if sortBy != nil {
+		query.Sort(records, sortBy, desc)
+	}
+	if c.Limit != 0 && uint64(len(records)) > c.Limit {
+		records = records[:c.Limit]
+	}
+
+	if c.Format == "log" {
+		if c.Details {
+			peerData := make(map[string]*track.PeerAllData)
+			for _, r := range records {
+				peerData[r.PeerID.String()] = r.PeerAllData
+			}
+			c.Log.WithField("peers", peerData).Infof("%d peers", len(records))
+		} else {
+			ids := make([]peer.ID, 0, len(records))
+			for _, r := range records {
+				ids = append(ids, r.PeerID)
+			}
+			c.Log.WithField("peers", ids).Infof("%d peers", len(records))
+		}
+		return nil
+	}
+
+	if c.Output != "" {
+		f, err := os.Create(c.Output)
+		if err != nil {
+			return fmt.Errorf("failed to create output file: %v", err)
+		}
+		defer f.Close()
+		w := bufio.NewWriter(f)
+		if err := query.Write(w, c.Format, records, names, paths); err != nil {
+			return fmt.Errorf("failed to write peers: %v", err)
+		}
+		if err := w.Flush(); err != nil {
+			return fmt.Errorf("failed to write peers: %v", err)
+		}
+		c.Log.WithField("output", c.Output).Infof("listed %d peers", len(records))
+		return nil
+	}
+	var buf bytes.Buffer
+	if err := query.Write(&buf, c.Format, records, names, paths); err != nil {
+		return fmt.Errorf("failed to format peers: %v", err)
+	}
+	for _, line := range strings.Split(strings.TrimRight(buf.String(), "\n"), "\n") {
+		c.Log.Info(line)
 	}
 	return nil
 }
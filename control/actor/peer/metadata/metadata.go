@@ -6,9 +6,11 @@ import (
 	"github.com/libp2p/go-libp2p-core/peer"
 	"github.com/protolambda/ask"
 	"github.com/protolambda/rumor/control/actor/base"
+	"github.com/protolambda/rumor/control/actor/chain/clockcmd"
 	"github.com/protolambda/rumor/p2p/rpc/methods"
 	"github.com/protolambda/rumor/p2p/rpc/reqresp"
 	"github.com/protolambda/rumor/p2p/track"
+	"github.com/protolambda/rumor/p2p/track/fingerprint"
 	"github.com/protolambda/zrnt/eth2/beacon"
 )
 
@@ -20,6 +22,7 @@ type PeerMetadataState struct {
 type PeerMetadataCmd struct {
 	*base.Base
 	*PeerMetadataState
+	Clock *clockcmd.ClockState
 	Store track.ExtendedPeerstore
 }
 
@@ -40,7 +43,7 @@ func (c *PeerMetadataCmd) Cmd(route string) (cmd interface{}, err error) {
 	case "req":
 		cmd = &PeerMetadataReqCmd{Base: c.Base, PeerMetadataState: c.PeerMetadataState, Book: c.Store}
 	case "poll":
-		cmd = &PeerMetadataPollCmd{Base: c.Base, PeerMetadataState: c.PeerMetadataState, Store: c.Store}
+		cmd = &PeerMetadataPollCmd{Base: c.Base, PeerMetadataState: c.PeerMetadataState, Clock: c.Clock, Store: c.Store}
 	case "serve":
 		cmd = &PeerMetadataServeCmd{Base: c.Base, PeerMetadataState: c.PeerMetadataState}
 	case "follow":
@@ -84,6 +87,12 @@ func (c *PeerMetadataState) fetch(book track.MetadataBook, sFn reqresp.NewStream
 			}
 			return nil
 		})
+	// remember how the peer responded, for client fingerprinting
+	if fb, ok := book.(track.FingerprintBook); ok {
+		if quirk, ok := fingerprint.ResponseQuirk("metadata", resCode == reqresp.SuccessCode, uint8(resCode), errMsg, err); ok {
+			fb.RegisterQuirk(peerID, quirk)
+		}
+	}
 	return
 }
 
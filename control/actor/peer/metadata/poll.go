@@ -2,11 +2,15 @@ package metadata
 
 import (
 	"context"
+	"github.com/libp2p/go-libp2p-core/host"
 	"github.com/libp2p/go-libp2p-core/peer"
+	"github.com/protolambda/rumor/chain/clock"
 	"github.com/protolambda/rumor/control/actor/base"
+	"github.com/protolambda/rumor/control/actor/chain/clockcmd"
 	"github.com/protolambda/rumor/control/actor/flags"
 	"github.com/protolambda/rumor/p2p/rpc/reqresp"
 	"github.com/protolambda/rumor/p2p/track"
+	"github.com/protolambda/zrnt/eth2/beacon"
 	"sync"
 	"time"
 )
@@ -14,9 +18,11 @@ import (
 type PeerMetadataPollCmd struct {
 	*base.Base
 	*PeerMetadataState
+	Clock         *clockcmd.ClockState
 	Store         track.ExtendedPeerstore
 	Timeout       time.Duration         `ask:"--timeout" help:"request timeout for ping, 0 to disable."`
 	Interval      time.Duration         `ask:"--interval" help:"interval to send pings to peers on, applied as timeout to a round of work"`
+	SlotOffset    flags.SlotOffsetFlag  `ask:"--slot-offset" help:"Poll every slot of the slot clock instead of on the interval, at this fraction (e.g. '1/3') or duration into the slot"`
 	Update        bool                  `ask:"--update" help:"If the seq nr pong is higher than known, request metadata"`
 	ForceUpdate   bool                  `ask:"--force-update" help:"Force a metadata request, even if the ping results in an already known pong seq nr"`
 	UpdateTimeout time.Duration         `ask:"--update-timeout" help:"If updating, use this timeout for the update request, 0 to disable."`
@@ -34,7 +40,7 @@ func (c *PeerMetadataPollCmd) Default() {
 }
 
 func (c *PeerMetadataPollCmd) Help() string {
-	return "Ping all connected peers, repeatedly on the given interval. Optionally update if seq nr is new."
+	return "Ping all connected peers, repeatedly on the given interval, or every slot with --slot-offset. Optionally update if seq nr is new."
 }
 
 func (c *PeerMetadataPollCmd) Run(ctx context.Context, args ...string) error {
@@ -43,42 +49,34 @@ func (c *PeerMetadataPollCmd) Run(ctx context.Context, args ...string) error {
 		return err
 	}
 
+	var cl *clock.SlotClock
+	var offset time.Duration
+	if c.SlotOffset.Specified {
+		if cl, err = c.Clock.Get(); err != nil {
+			return err
+		}
+		offset = c.SlotOffset.Offset(cl.SlotDuration)
+		if err := cl.CheckOffset(offset); err != nil {
+			return err
+		}
+	}
+
 	stopping := false
 	bgCtx, bgCancel := context.WithCancel(context.Background())
 	go func() {
+		if cl != nil {
+			// a round every slot, timing out before the next
+			cl.Schedule(bgCtx, offset, func(slot beacon.Slot) {
+				c.pollRound(bgCtx, h, cl.SlotDuration)
+			})
+			return
+		}
 		for {
 			if stopping {
 				return
 			}
 			start := time.Now()
-			var wg sync.WaitGroup
-
-			// apply timeout to each poll target in this round
-			reqCtx, _ := context.WithTimeout(bgCtx, c.Interval)
-
-			for _, p := range h.Network().Peers() {
-				wg.Add(1)
-				go func(peerID peer.ID) {
-					pingCmd := &PeerMetadataPingCmd{
-						Base:              c.Base,
-						PeerMetadataState: c.PeerMetadataState,
-						Store:             c.Store,
-						Timeout:           c.Timeout,
-						Compression:       c.Compression,
-						Update:            c.Update,
-						ForceUpdate:       c.ForceUpdate,
-						UpdateTimeout:     c.UpdateTimeout,
-						MaxTries:          c.MaxTries,
-						PeerID:            flags.PeerIDFlag{PeerID: peerID},
-					}
-					if err := pingCmd.Run(reqCtx); err != nil {
-						c.Log.WithField("peer", peerID.String()).WithError(err).Warn("failed to poll peer")
-					}
-
-					wg.Done()
-				}(p)
-			}
-			wg.Wait()
+			c.pollRound(bgCtx, h, c.Interval)
 			pollStepDuration := time.Since(start)
 			if pollStepDuration < c.Interval {
 				time.Sleep(c.Interval - pollStepDuration)
@@ -94,3 +92,36 @@ func (c *PeerMetadataPollCmd) Run(ctx context.Context, args ...string) error {
 
 	return nil
 }
+
+// pollRound polls all connected peers, with the timeout applied to the whole round.
+func (c *PeerMetadataPollCmd) pollRound(ctx context.Context, h host.Host, timeout time.Duration) {
+	var wg sync.WaitGroup
+
+	// apply timeout to each poll target in this round
+	reqCtx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	for _, p := range h.Network().Peers() {
+		wg.Add(1)
+		go func(peerID peer.ID) {
+			pingCmd := &PeerMetadataPingCmd{
+				Base:              c.Base,
+				PeerMetadataState: c.PeerMetadataState,
+				Store:             c.Store,
+				Timeout:           c.Timeout,
+				Compression:       c.Compression,
+				Update:            c.Update,
+				ForceUpdate:       c.ForceUpdate,
+				UpdateTimeout:     c.UpdateTimeout,
+				MaxTries:          c.MaxTries,
+				PeerID:            flags.PeerIDFlag{PeerID: peerID},
+			}
+			if err := pingCmd.Run(reqCtx); err != nil {
+				c.Log.WithField("peer", peerID.String()).WithError(err).Warn("failed to poll peer")
+			}
+
+			wg.Done()
+		}(p)
+	}
+	wg.Wait()
+}
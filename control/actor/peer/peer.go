@@ -4,6 +4,7 @@ import (
 	"errors"
 	"github.com/protolambda/ask"
 	"github.com/protolambda/rumor/control/actor/base"
+	"github.com/protolambda/rumor/control/actor/chain/clockcmd"
 	"github.com/protolambda/rumor/control/actor/peer/metadata"
 	"github.com/protolambda/rumor/control/actor/peer/status"
 	trackcmd "github.com/protolambda/rumor/control/actor/peer/track"
@@ -14,6 +15,7 @@ type PeerCmd struct {
 	*base.Base
 	*status.PeerStatusState
 	*metadata.PeerMetadataState
+	Clock *clockcmd.ClockState
 	Store track.ExtendedPeerstore
 }
 
@@ -40,6 +42,8 @@ func (c *PeerCmd) Cmd(route string) (cmd interface{}, err error) {
 		cmd = &PeerListCmd{Base: c.Base, Store: c.Store}
 	case "info":
 		cmd = &PeerInfoCmd{Base: c.Base, Store: c.Store}
+	case "fingerprint":
+		cmd = &PeerFingerprintCmd{Base: c.Base, Store: c.Store}
 	case "identify":
 		cmd = &PeerIdentifyCmd{Base: c.Base}
 	case "track":
@@ -47,9 +51,9 @@ func (c *PeerCmd) Cmd(route string) (cmd interface{}, err error) {
 	case "addrs":
 		cmd = &PeerAddrsCmd{Base: c.Base}
 	case "status":
-		cmd = &status.PeerStatusCmd{Base: c.Base, PeerStatusState: c.PeerStatusState, Book: c.Store}
+		cmd = &status.PeerStatusCmd{Base: c.Base, PeerStatusState: c.PeerStatusState, Clock: c.Clock, Book: c.Store}
 	case "metadata":
-		cmd = &metadata.PeerMetadataCmd{Base: c.Base, PeerMetadataState: c.PeerMetadataState, Store: c.Store}
+		cmd = &metadata.PeerMetadataCmd{Base: c.Base, PeerMetadataState: c.PeerMetadataState, Clock: c.Clock, Store: c.Store}
 	default:
 		return nil, ask.UnrecognizedErr
 	}
@@ -58,7 +62,7 @@ func (c *PeerCmd) Cmd(route string) (cmd interface{}, err error) {
 
 func (c *PeerCmd) Routes() []string {
 	return []string{"connect", "disconnect", "connectall", "protect", "unprotect", "add", "trim",
-		"list", "info", "identify", "track", "addrs", "status", "metadata"}
+		"list", "info", "fingerprint", "identify", "track", "addrs", "status", "metadata"}
 }
 
 func (c *PeerCmd) Help() string {
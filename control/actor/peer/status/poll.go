@@ -2,11 +2,15 @@ package status
 
 import (
 	"context"
+	"github.com/libp2p/go-libp2p-core/host"
 	"github.com/libp2p/go-libp2p-core/peer"
+	"github.com/protolambda/rumor/chain/clock"
 	"github.com/protolambda/rumor/control/actor/base"
+	"github.com/protolambda/rumor/control/actor/chain/clockcmd"
 	"github.com/protolambda/rumor/control/actor/flags"
 	"github.com/protolambda/rumor/p2p/rpc/reqresp"
 	"github.com/protolambda/rumor/p2p/track"
+	"github.com/protolambda/zrnt/eth2/beacon"
 	"sync"
 	"time"
 )
@@ -14,14 +18,16 @@ import (
 type PeerStatusPollCmd struct {
 	*base.Base
 	*PeerStatusState
+	Clock       *clockcmd.ClockState
 	Book        track.StatusBook
 	Timeout     time.Duration         `ask:"--timeout" help:"request timeout, 0 to disable."`
 	Interval    time.Duration         `ask:"--interval" help:"interval to request status of peers on, applied as timeout to a round of work"`
+	SlotOffset  flags.SlotOffsetFlag  `ask:"--slot-offset" help:"Poll every slot of the slot clock instead of on the interval, at this fraction (e.g. '1/3') or duration into the slot"`
 	Compression flags.CompressionFlag `ask:"--compression" help:"Compression. 'none' to disable, 'snappy' for streaming-snappy"`
 }
 
 func (c *PeerStatusPollCmd) Help() string {
-	return "Fetch status of all connected peers, repeatedly on the given interval."
+	return "Fetch status of all connected peers, repeatedly on the given interval, or every slot with --slot-offset."
 }
 
 func (c *PeerStatusPollCmd) Default() {
@@ -36,38 +42,34 @@ func (c *PeerStatusPollCmd) Run(ctx context.Context, args ...string) error {
 		return err
 	}
 
+	var cl *clock.SlotClock
+	var offset time.Duration
+	if c.SlotOffset.Specified {
+		if cl, err = c.Clock.Get(); err != nil {
+			return err
+		}
+		offset = c.SlotOffset.Offset(cl.SlotDuration)
+		if err := cl.CheckOffset(offset); err != nil {
+			return err
+		}
+	}
+
 	stopping := false
 	bgCtx, bgCancel := context.WithCancel(context.Background())
 	go func() {
+		if cl != nil {
+			// a round every slot, timing out before the next
+			cl.Schedule(bgCtx, offset, func(slot beacon.Slot) {
+				c.pollRound(bgCtx, h, cl.SlotDuration)
+			})
+			return
+		}
 		for {
 			if stopping {
 				return
 			}
 			start := time.Now()
-			var wg sync.WaitGroup
-
-			// apply timeout to each poll target in this round
-			reqCtx, _ := context.WithTimeout(bgCtx, c.Interval)
-
-			for _, p := range h.Network().Peers() {
-				wg.Add(1)
-				go func(peerID peer.ID) {
-					statusCmd := &PeerStatusReqCmd{
-						Base:            c.Base,
-						PeerStatusState: c.PeerStatusState,
-						Book:            c.Book,
-						Timeout:         c.Timeout,
-						Compression:     c.Compression,
-						PeerID:          flags.PeerIDFlag{PeerID: peerID},
-					}
-					if err := statusCmd.Run(reqCtx); err != nil {
-						c.Log.WithField("peer", peerID.String()).WithError(err).Warn("failed to poll peer")
-					}
-
-					wg.Done()
-				}(p)
-			}
-			wg.Wait()
+			c.pollRound(bgCtx, h, c.Interval)
 			pollStepDuration := time.Since(start)
 			if pollStepDuration < c.Interval {
 				time.Sleep(c.Interval - pollStepDuration)
@@ -83,3 +85,32 @@ func (c *PeerStatusPollCmd) Run(ctx context.Context, args ...string) error {
 
 	return nil
 }
+
+// pollRound polls all connected peers, with the timeout applied to the whole round.
+func (c *PeerStatusPollCmd) pollRound(ctx context.Context, h host.Host, timeout time.Duration) {
+	var wg sync.WaitGroup
+
+	// apply timeout to each poll target in this round
+	reqCtx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	for _, p := range h.Network().Peers() {
+		wg.Add(1)
+		go func(peerID peer.ID) {
+			statusCmd := &PeerStatusReqCmd{
+				Base:            c.Base,
+				PeerStatusState: c.PeerStatusState,
+				Book:            c.Book,
+				Timeout:         c.Timeout,
+				Compression:     c.Compression,
+				PeerID:          flags.PeerIDFlag{PeerID: peerID},
+			}
+			if err := statusCmd.Run(reqCtx); err != nil {
+				c.Log.WithField("peer", peerID.String()).WithError(err).Warn("failed to poll peer")
+			}
+
+			wg.Done()
+		}(p)
+	}
+	wg.Wait()
+}
@@ -6,9 +6,11 @@ import (
 	"github.com/libp2p/go-libp2p-core/peer"
 	"github.com/protolambda/ask"
 	"github.com/protolambda/rumor/control/actor/base"
+	"github.com/protolambda/rumor/control/actor/chain/clockcmd"
 	"github.com/protolambda/rumor/p2p/rpc/methods"
 	"github.com/protolambda/rumor/p2p/rpc/reqresp"
 	"github.com/protolambda/rumor/p2p/track"
+	"github.com/protolambda/rumor/p2p/track/fingerprint"
 	"github.com/protolambda/zrnt/eth2/beacon"
 )
 
@@ -20,7 +22,8 @@ type PeerStatusState struct {
 type PeerStatusCmd struct {
 	*base.Base
 	*PeerStatusState
-	Book track.StatusBook
+	Clock *clockcmd.ClockState
+	Book  track.StatusBook
 }
 
 func (c *PeerStatusCmd) Help() string {
@@ -36,7 +39,7 @@ func (c *PeerStatusCmd) Cmd(route string) (cmd interface{}, err error) {
 	case "req":
 		cmd = &PeerStatusReqCmd{Base: c.Base, PeerStatusState: c.PeerStatusState, Book: c.Book}
 	case "poll":
-		cmd = &PeerStatusPollCmd{Base: c.Base, PeerStatusState: c.PeerStatusState, Book: c.Book}
+		cmd = &PeerStatusPollCmd{Base: c.Base, PeerStatusState: c.PeerStatusState, Clock: c.Clock, Book: c.Book}
 	case "serve":
 		cmd = &PeerStatusServeCmd{Base: c.Base, PeerStatusState: c.PeerStatusState, Book: c.Book}
 	case "follow":
@@ -80,5 +83,11 @@ func (c *PeerStatusState) fetch(book track.StatusBook, sFn reqresp.NewStreamFn,
 			}
 			return nil
 		})
+	// remember how the peer responded, for client fingerprinting
+	if fb, ok := book.(track.FingerprintBook); ok {
+		if quirk, ok := fingerprint.ResponseQuirk("status", resCode == reqresp.SuccessCode, uint8(resCode), errMsg, err); ok {
+			fb.RegisterQuirk(peerID, quirk)
+		}
+	}
 	return
 }
@@ -0,0 +1,49 @@
+package peerstore
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"github.com/protolambda/rumor/control/actor/base"
+	"github.com/protolambda/rumor/p2p/track"
+	"github.com/protolambda/rumor/p2p/track/snapshot"
+	"os"
+)
+
+type ExportCmd struct {
+	*base.Base
+
+	CurrentPeerstore track.DynamicPeerstore
+
+	Path string `ask:"<path>" help:"File to write the peers to, as JSON lines"`
+}
+
+func (c *ExportCmd) Help() string {
+	return "Export all peers of the current peerstore, with addresses, ENR, status, metadata and fingerprint. Private keys are not exported."
+}
+
+func (c *ExportCmd) Run(ctx context.Context, args ...string) error {
+	if !c.CurrentPeerstore.Initialized() {
+		return errors.New("no current peerstore to export")
+	}
+	f, err := os.Create(c.Path)
+	if err != nil {
+		return fmt.Errorf("failed to create export file: %v", err)
+	}
+	w := bufio.NewWriter(f)
+	exported, err := snapshot.Export(c.CurrentPeerstore, w, nil)
+	if err != nil {
+		_ = f.Close()
+		return fmt.Errorf("failed to export peers: %v", err)
+	}
+	if err := w.Flush(); err != nil {
+		_ = f.Close()
+		return fmt.Errorf("failed to write export file: %v", err)
+	}
+	if err := f.Close(); err != nil {
+		return fmt.Errorf("failed to close export file: %v", err)
+	}
+	c.Log.WithField("store", c.CurrentPeerstore.PeerstoreID()).Infof("exported %d peers", exported)
+	return nil
+}
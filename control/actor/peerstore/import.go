@@ -0,0 +1,51 @@
+package peerstore
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"github.com/protolambda/rumor/control/actor/base"
+	"github.com/protolambda/rumor/p2p/track"
+	"github.com/protolambda/rumor/p2p/track/snapshot"
+	"github.com/sirupsen/logrus"
+	"os"
+)
+
+type ImportCmd struct {
+	*base.Base
+
+	CurrentPeerstore track.DynamicPeerstore
+
+	MergePolicy snapshot.MergePolicy `ask:"--merge-policy" help:"What to do with known peers: 'newest-enr' to update them, 'keep' to only fill in missing data"`
+	Path        string               `ask:"<path>" help:"File to read peers from, as JSON lines, like 'peerstore export' writes"`
+}
+
+func (c *ImportCmd) Default() {
+	c.MergePolicy = snapshot.MergeNewestENR
+}
+
+func (c *ImportCmd) Help() string {
+	return "Import peers into the current peerstore, and merge them with the known peers"
+}
+
+func (c *ImportCmd) Run(ctx context.Context, args ...string) error {
+	if !c.CurrentPeerstore.Initialized() {
+		return errors.New("no current peerstore to import into, try 'peerstore create'")
+	}
+	f, err := os.Open(c.Path)
+	if err != nil {
+		return fmt.Errorf("failed to open import file: %v", err)
+	}
+	defer f.Close()
+	added, merged, err := snapshot.Import(c.CurrentPeerstore, bufio.NewReader(f), c.MergePolicy, nil)
+	if err != nil {
+		return fmt.Errorf("failed to import peers: %v", err)
+	}
+	c.Log.WithFields(logrus.Fields{
+		"store":  c.CurrentPeerstore.PeerstoreID(),
+		"added":  added,
+		"merged": merged,
+	}).Info("imported peers")
+	return nil
+}
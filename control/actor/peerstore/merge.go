@@ -0,0 +1,54 @@
+package peerstore
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/protolambda/rumor/control/actor/base"
+	"github.com/protolambda/rumor/p2p/track"
+	"github.com/protolambda/rumor/p2p/track/snapshot"
+	"github.com/sirupsen/logrus"
+)
+
+type MergeCmd struct {
+	*base.Base
+
+	GlobalPeerstores track.Peerstores
+
+	MergePolicy snapshot.MergePolicy `ask:"--merge-policy" help:"What to do with peers known to the destination: 'newest-enr' to update them, 'keep' to only fill in missing data"`
+	Src         track.PeerstoreID    `ask:"<src>" help:"ID of the peerstore to copy peers from"`
+	Dst         track.PeerstoreID    `ask:"<dst>" help:"ID of the peerstore to merge peers into"`
+}
+
+func (c *MergeCmd) Default() {
+	c.MergePolicy = snapshot.MergeNewestENR
+}
+
+func (c *MergeCmd) Help() string {
+	return "Merge all peers of one peerstore into another"
+}
+
+func (c *MergeCmd) Run(ctx context.Context, args ...string) error {
+	if c.Src == c.Dst {
+		return errors.New("cannot merge a peerstore into itself")
+	}
+	src, ok := c.GlobalPeerstores.Find(c.Src)
+	if !ok {
+		return fmt.Errorf("peerstore %s does not exist", c.Src)
+	}
+	dst, ok := c.GlobalPeerstores.Find(c.Dst)
+	if !ok {
+		return fmt.Errorf("peerstore %s does not exist", c.Dst)
+	}
+	added, merged, err := snapshot.Merge(src, dst, c.MergePolicy)
+	if err != nil {
+		return fmt.Errorf("failed to merge peers: %v", err)
+	}
+	c.Log.WithFields(logrus.Fields{
+		"src":    c.Src,
+		"dst":    c.Dst,
+		"added":  added,
+		"merged": merged,
+	}).Info("merged peerstores")
+	return nil
+}
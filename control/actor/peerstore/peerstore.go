@@ -13,8 +13,6 @@ type PeerstoreCmd struct {
 	CurrentPeerstore track.DynamicPeerstore
 }
 
-// TODO: import/export peerstore command
-
 func (c *PeerstoreCmd) Cmd(route string) (cmd interface{}, err error) {
 	switch route {
 	case "create":
@@ -23,6 +21,12 @@ func (c *PeerstoreCmd) Cmd(route string) (cmd interface{}, err error) {
 		cmd = &SwitchCmd{Base: c.Base, GlobalPeerstores: c.GlobalPeerstores, CurrentPeerstore: c.CurrentPeerstore}
 	case "list":
 		cmd = &ListCmd{Base: c.Base, GlobalPeerstores: c.GlobalPeerstores, CurrentPeerstore: c.CurrentPeerstore}
+	case "export":
+		cmd = &ExportCmd{Base: c.Base, CurrentPeerstore: c.CurrentPeerstore}
+	case "import":
+		cmd = &ImportCmd{Base: c.Base, CurrentPeerstore: c.CurrentPeerstore}
+	case "merge":
+		cmd = &MergeCmd{Base: c.Base, GlobalPeerstores: c.GlobalPeerstores}
 	default:
 		return nil, ask.UnrecognizedErr
 	}
@@ -30,7 +34,7 @@ func (c *PeerstoreCmd) Cmd(route string) (cmd interface{}, err error) {
 }
 
 func (c *PeerstoreCmd) Routes() []string {
-	return []string{"create", "switch", "list"}
+	return []string{"create", "switch", "list", "export", "import", "merge"}
 }
 
 func (c *PeerstoreCmd) Help() string {
@@ -31,6 +31,12 @@ func (c *DBCmd) Cmd(route string) (cmd interface{}, err error) {
 		cmd = &StatesStatsCmd{Base: c.Base, DB: c.DB}
 	case "list":
 		cmd = &StatesListCmd{Base: c.Base, DB: c.DB}
+	case "query":
+		cmd = &StatesQueryCmd{Base: c.Base, DB: c.DB}
+	case "verify":
+		cmd = &StatesVerifyCmd{Base: c.Base, DB: c.DB}
+	case "diff":
+		cmd = &StatesDiffCmd{Base: c.Base, DB: c.DB}
 	default:
 		return nil, ask.UnrecognizedErr
 	}
@@ -38,7 +44,7 @@ func (c *DBCmd) Cmd(route string) (cmd interface{}, err error) {
 }
 
 func (c *DBCmd) Routes() []string {
-	return []string{"import", "export", "get", "rm", "stats", "list"}
+	return []string{"import", "export", "get", "rm", "stats", "list", "query", "diff", "verify"}
 }
 
 func (c *DBCmd) Help() string {
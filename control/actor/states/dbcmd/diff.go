@@ -0,0 +1,112 @@
+package dbcmd
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	sdb "github.com/protolambda/rumor/chain/db/states"
+	"github.com/protolambda/rumor/chain/sszpath"
+	"github.com/protolambda/rumor/control/actor/base"
+	"github.com/protolambda/zrnt/eth2/beacon"
+	"github.com/protolambda/ztyp/codec"
+	"github.com/protolambda/ztyp/tree"
+	"github.com/sirupsen/logrus"
+	"io/ioutil"
+	"os"
+	"strings"
+)
+
+type StatesDiffCmd struct {
+	*base.Base
+	sdb.DB
+	A      string `ask:"<a>" help:"The first state: a 0x-prefixed state root in the DB, or a path to an SSZ file"`
+	B      string `ask:"<b>" help:"The second state: a 0x-prefixed state root in the DB, or a path to an SSZ file"`
+	Max    int    `ask:"--max" help:"Maximum number of differences to report. 0 for no limit."`
+	JSON   bool   `ask:"--json" help:"Log all differences as a single JSON list, instead of one log entry per difference"`
+	Output string `ask:"--output" help:"A file path to write the differences to as JSON"`
+}
+
+func (c *StatesDiffCmd) Default() {
+	c.Max = 1000
+}
+
+func (c *StatesDiffCmd) Help() string {
+	return "Compare two states, and report the fields, validators and balances that differ"
+}
+
+func (c *StatesDiffCmd) loadState(v string) (*beacon.BeaconStateView, error) {
+	if strings.HasPrefix(v, "0x") {
+		var root beacon.Root
+		if err := root.UnmarshalText([]byte(v)); err != nil {
+			return nil, fmt.Errorf("invalid state root %q: %v", v, err)
+		}
+		state, exists, err := c.DB.Get(root)
+		if err != nil {
+			return nil, err
+		}
+		if !exists {
+			return nil, fmt.Errorf("state %s is not known", root)
+		}
+		return state, nil
+	}
+	f, err := os.OpenFile(v, os.O_RDONLY, os.ModePerm)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open %s: %v", v, err)
+	}
+	defer f.Close()
+	fInfo, err := f.Stat()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get file size %s: %v", v, err)
+	}
+	state, err := beacon.AsBeaconStateView(c.DB.Spec().BeaconState().Deserialize(codec.NewDecodingReader(f, uint64(fInfo.Size()))))
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode state %s: %v", v, err)
+	}
+	return state, nil
+}
+
+func (c *StatesDiffCmd) Run(ctx context.Context, args ...string) error {
+	a, err := c.loadState(c.A)
+	if err != nil {
+		return err
+	}
+	b, err := c.loadState(c.B)
+	if err != nil {
+		return err
+	}
+	diffs, truncated, err := sszpath.Diff(a.Type(), a.Backing(), b.Backing(), c.Max)
+	if err != nil {
+		return fmt.Errorf("failed to diff states: %v", err)
+	}
+	if c.Output != "" {
+		data, err := json.MarshalIndent(diffs, "", "  ")
+		if err != nil {
+			return err
+		}
+		if err := ioutil.WriteFile(c.Output, data, 0644); err != nil {
+			return fmt.Errorf("failed to write %s: %v", c.Output, err)
+		}
+	}
+	if c.JSON {
+		c.Log.WithField("diff", diffs).Info("state differences")
+	} else if c.Output == "" {
+		for _, d := range diffs {
+			c.Log.WithFields(logrus.Fields{"path": d.Path, "a": d.A, "b": d.B}).Info("difference")
+		}
+	}
+	// Summarize per top-level field, e.g. the number of differing validators and balances
+	fields := make(map[string]int)
+	for _, d := range diffs {
+		name := strings.SplitN(strings.TrimPrefix(d.Path, "/"), "/", 2)[0]
+		fields[name] += 1
+	}
+	h := tree.GetHashFn()
+	c.Log.WithFields(logrus.Fields{
+		"a_root":      a.HashTreeRoot(h),
+		"b_root":      b.HashTreeRoot(h),
+		"differences": len(diffs),
+		"truncated":   truncated,
+		"fields":      fields,
+	}).Info("compared states")
+	return nil
+}
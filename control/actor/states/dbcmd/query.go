@@ -0,0 +1,40 @@
+package dbcmd
+
+import (
+	"context"
+	"fmt"
+	sdb "github.com/protolambda/rumor/chain/db/states"
+	"github.com/protolambda/rumor/chain/sszpath"
+	"github.com/protolambda/rumor/control/actor/base"
+	"github.com/protolambda/zrnt/eth2/beacon"
+	"github.com/sirupsen/logrus"
+)
+
+type StatesQueryCmd struct {
+	*base.Base
+	sdb.DB
+	StateRoot beacon.Root `ask:"<root>" help:"Root of the state to query"`
+	Path      string      `ask:"<path>" help:"Path to the field, e.g. 'validators/12345/effective_balance', 'balances[0:64]', 'finalized_checkpoint'"`
+	Proof     bool        `ask:"--proof" help:"Also output a Merkle proof (gindex, leaf and branch) of the selected node"`
+}
+
+func (c *StatesQueryCmd) Help() string {
+	return "Query a field of a state by SSZ path, optionally with a Merkle proof"
+}
+
+func (c *StatesQueryCmd) Run(ctx context.Context, args ...string) error {
+	state, exists, err := c.DB.Get(c.StateRoot)
+	if err != nil {
+		return err
+	}
+	if !exists {
+		return fmt.Errorf("state %s is not known", c.StateRoot)
+	}
+	out, err := sszpath.Describe(state.Type(), state.Backing(), c.Path, c.Proof)
+	if err != nil {
+		return err
+	}
+	out["state_root"] = c.StateRoot
+	c.Log.WithFields(logrus.Fields(out)).Info("query result")
+	return nil
+}
@@ -0,0 +1,68 @@
+package dbcmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	sdb "github.com/protolambda/rumor/chain/db/states"
+	"github.com/protolambda/rumor/control/actor/base"
+	"github.com/sirupsen/logrus"
+)
+
+type StatesVerifyCmd struct {
+	*base.Base
+	sdb.DB
+	Quarantine string `ask:"--quarantine" help:"Move bad entries out of the DB, to 0x<root>.ssz files in this directory. Unreadable states cannot be saved, and are kept in the DB."`
+	Remove     bool   `ask:"--remove" help:"Remove bad entries from the DB"`
+}
+
+func (c *StatesVerifyCmd) Help() string {
+	return "Read every state and check its root, and report and optionally quarantine or remove bad entries"
+}
+
+func (c *StatesVerifyCmd) Run(ctx context.Context, args ...string) error {
+	if c.Quarantine != "" && c.Remove {
+		return errors.New("cannot both quarantine and remove bad entries")
+	}
+	counts := make(map[sdb.Problem]int)
+	var bad []sdb.Issue
+	checked, err := sdb.Verify(ctx, c.DB, func(issue sdb.Issue) {
+		counts[issue.Problem] += 1
+		c.Log.WithFields(logrus.Fields{
+			"root":    issue.Root,
+			"problem": issue.Problem,
+			"detail":  issue.Detail,
+		}).Warn("bad state entry")
+		bad = append(bad, issue)
+	})
+	if err != nil {
+		return err
+	}
+	repaired, kept := 0, 0
+	if c.Quarantine != "" || c.Remove {
+		for _, issue := range bad {
+			if c.Quarantine != "" && issue.Problem == sdb.ProblemCorrupt {
+				// Nothing to save, leave it for --remove
+				kept += 1
+				continue
+			}
+			if c.Quarantine != "" {
+				err = sdb.Quarantine(c.DB, issue.Root, c.Quarantine)
+			} else {
+				_, err = c.DB.Remove(issue.Root)
+			}
+			if err != nil {
+				return fmt.Errorf("failed to repair DB, %d of %d bad entries done: %v", repaired, len(bad), err)
+			}
+			repaired += 1
+		}
+	}
+	c.Log.WithFields(logrus.Fields{
+		"checked":  checked,
+		"corrupt":  counts[sdb.ProblemCorrupt],
+		"mismatch": counts[sdb.ProblemMismatch],
+		"repaired": repaired,
+		"kept":     kept,
+	}).Info("verified states DB")
+	return nil
+}
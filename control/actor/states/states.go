@@ -36,6 +36,18 @@ func (c *StatesCmd) Cmd(route string) (cmd interface{}, err error) {
 			return nil, errors.New("current DB not available. Create one with 'states create'")
 		}
 		cmd = &dbcmd.DBCmd{Base: c.Base, DB: db}
+	case "query":
+		db, ok := c.DBs.Find(c.CurrentDB)
+		if !ok {
+			return nil, errors.New("current DB not available. Create one with 'states create'")
+		}
+		cmd = &dbcmd.StatesQueryCmd{Base: c.Base, DB: db}
+	case "diff":
+		db, ok := c.DBs.Find(c.CurrentDB)
+		if !ok {
+			return nil, errors.New("current DB not available. Create one with 'states create'")
+		}
+		cmd = &dbcmd.StatesDiffCmd{Base: c.Base, DB: db}
 	case "on":
 		cmd = &OnCmd{Base: c.Base, DBs: c.DBs}
 	default:
@@ -45,7 +57,7 @@ func (c *StatesCmd) Cmd(route string) (cmd interface{}, err error) {
 }
 
 func (c *StatesCmd) Routes() []string {
-	return []string{"create", "copy", "switch", "rm", "list", "db", "on"}
+	return []string{"create", "copy", "switch", "rm", "list", "db", "query", "diff", "on"}
 }
 
 func (c *StatesCmd) Help() string {
@@ -0,0 +1,162 @@
+package control
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/spf13/pflag"
+)
+
+// waitFor implements the "wait-for" builtin:
+//
+//	wait-for <call-id> [--field key=value]... [--timeout 30s]
+//
+// It blocks until the call logs an entry with all the given fields.
+// Without fields, it waits for the call to complete, including any background tasks.
+func (sess *Session) waitFor(ctx context.Context, customCallID CallID, args []string) error {
+	flags := pflag.NewFlagSet("wait-for", pflag.ContinueOnError)
+	var fieldArgs []string
+	var timeout time.Duration
+	flags.StringArrayVar(&fieldArgs, "field", nil, "key=value that the log entry must have. Repeat for multiple fields")
+	flags.DurationVar(&timeout, "timeout", 30*time.Second, "how long to wait before failing. 0 to wait indefinitely")
+	if err := flags.Parse(args); err != nil {
+		return ParseError.WithErr(err)
+	}
+	callID := customCallID
+	switch flags.NArg() {
+	case 0:
+		if callID == "" {
+			if last := sess.lastCall; last != nil {
+				callID = last.id
+			} else {
+				return ParseError.WithErr(errors.New("specify the call ID to wait for"))
+			}
+		}
+	case 1:
+		if callID != "" {
+			return ParseError.WithErr(errors.New("call ID specified twice"))
+		}
+		callID = CallID(strings.TrimPrefix(flags.Arg(0), "_"))
+	default:
+		return ParseError.WithErr(fmt.Errorf("too many arguments: %v", flags.Args()))
+	}
+	fields := make(map[string]string, len(fieldArgs))
+	for _, f := range fieldArgs {
+		i := strings.Index(f, "=")
+		if i <= 0 {
+			return ParseError.WithErr(fmt.Errorf("field %q is not formatted as key=value", f))
+		}
+		fields[f[:i]] = f[i+1:]
+	}
+	if timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+	if err := sess.global.WaitForLog(ctx, callID, fields); err != nil {
+		if len(fields) == 0 {
+			return RuntimeError.WithErr(fmt.Errorf("call %s did not complete: %v", callID, err))
+		}
+		return RuntimeError.WithErr(fmt.Errorf("call %s did not log %v: %v", callID, fields, err))
+	}
+	sess.log.WithField("call_id", callID).WithField("fields", fields).Debug("wait-for matched")
+	return nil
+}
+
+type assertOp func(a, b string) (bool, error)
+
+func numericOp(cmp func(a, b float64) bool) assertOp {
+	return func(a, b string) (bool, error) {
+		x, err := strconv.ParseFloat(a, 64)
+		if err != nil {
+			return false, fmt.Errorf("not a number: %q", a)
+		}
+		y, err := strconv.ParseFloat(b, 64)
+		if err != nil {
+			return false, fmt.Errorf("not a number: %q", b)
+		}
+		return cmp(x, y), nil
+	}
+}
+
+// equalOp compares numbers by value if both sides are numbers, and as strings otherwise.
+func equalOp(a, b string) (bool, error) {
+	if eq, err := numericOp(func(x, y float64) bool { return x == y })(a, b); err == nil {
+		return eq, nil
+	}
+	return a == b, nil
+}
+
+var assertOps = map[string]assertOp{
+	"==":  equalOp,
+	"-eq": equalOp,
+	"!=": func(a, b string) (bool, error) {
+		eq, err := equalOp(a, b)
+		return !eq, err
+	},
+	"-ne": func(a, b string) (bool, error) {
+		eq, err := equalOp(a, b)
+		return !eq, err
+	},
+	"<":   numericOp(func(x, y float64) bool { return x < y }),
+	"-lt": numericOp(func(x, y float64) bool { return x < y }),
+	"<=":  numericOp(func(x, y float64) bool { return x <= y }),
+	"-le": numericOp(func(x, y float64) bool { return x <= y }),
+	">":   numericOp(func(x, y float64) bool { return x > y }),
+	"-gt": numericOp(func(x, y float64) bool { return x > y }),
+	">=":  numericOp(func(x, y float64) bool { return x >= y }),
+	"-ge": numericOp(func(x, y float64) bool { return x >= y }),
+	"=~": func(a, b string) (bool, error) {
+		r, err := regexp.Compile(b)
+		if err != nil {
+			return false, fmt.Errorf("bad regex %q: %v", b, err)
+		}
+		return r.MatchString(a), nil
+	},
+	"contains": func(a, b string) (bool, error) {
+		return strings.Contains(a, b), nil
+	},
+}
+
+// assert implements the "assert" builtin:
+//
+//	assert <value>
+//	assert <a> <op> <b>
+//
+// A single value must be non-empty, and not "false" or "0".
+// Operators: == != (-eq -ne) compare numbers by value and anything else as strings,
+// < <= > >= (-lt -le -gt -ge) compare numbers, =~ matches a regex, contains checks for a substring.
+// A failed (or malformed) assertion is fatal: the script stops, regardless of "set -e" or "||".
+func (sess *Session) assert(args []string) error {
+	var ok bool
+	var expr string
+	switch len(args) {
+	case 1:
+		expr = strconv.Quote(args[0])
+		v := strings.TrimSpace(args[0])
+		ok = v != "" && v != "false" && v != "0"
+	case 3:
+		expr = fmt.Sprintf("%q %s %q", args[0], args[1], args[2])
+		op, known := assertOps[args[1]]
+		if !known {
+			return fmt.Errorf("unknown assert operator %q", args[1])
+		}
+		var err error
+		ok, err = op(args[0], args[2])
+		if err != nil {
+			return fmt.Errorf("assertion %s failed: %v", expr, err)
+		}
+	default:
+		return fmt.Errorf("assert needs a value, or 'a op b', got %d arguments (quote empty values)", len(args))
+	}
+	if !ok {
+		sess.log.WithField("assertion", expr).Error("assertion failed")
+		return fmt.Errorf("assertion failed: %s", expr)
+	}
+	return nil
+}
@@ -0,0 +1,225 @@
+package scripttest
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/protolambda/rumor/control"
+	"github.com/sirupsen/logrus"
+	"mvdan.cc/sh/v3/interp"
+	"mvdan.cc/sh/v3/syntax"
+)
+
+// logCollector keeps every log entry of the session, with all values formatted as strings.
+type logCollector struct {
+	sync.Mutex
+	entries []map[string]string
+}
+
+func (lc *logCollector) Levels() []logrus.Level {
+	return logrus.AllLevels
+}
+
+func (lc *logCollector) Fire(entry *logrus.Entry) error {
+	e := make(map[string]string, len(entry.Data)+2)
+	for k, v := range entry.Data {
+		e[k] = control.FormatLogValue(v)
+	}
+	e["msg"] = entry.Message
+	e["level"] = entry.Level.String()
+	lc.Lock()
+	lc.entries = append(lc.entries, e)
+	lc.Unlock()
+	return nil
+}
+
+// Result of running a script
+type Result struct {
+	ExitCode uint8
+	// Fatal error that stopped the script, if any
+	Err error
+	// If the script did not complete before the timeout
+	TimedOut bool
+	// All log entries of the session
+	Entries []map[string]string
+	// The formatted log output, for debugging
+	Output string
+	// Log data of calls, as available to the script with the "$_key" variables
+	Data map[string]string
+}
+
+// Run the script in a new session processor, and collect the results.
+func (s *Script) Run(ctx context.Context) (*Result, error) {
+	inputFile, err := os.Open(s.Path)
+	if err != nil {
+		return nil, err
+	}
+	fileDoc, err := syntax.NewParser().Parse(inputFile, s.Path)
+	_ = inputFile.Close()
+	if err != nil {
+		return nil, err
+	}
+
+	var out bytes.Buffer
+	collector := new(logCollector)
+	log := logrus.New()
+	log.SetOutput(&out)
+	log.SetLevel(logrus.TraceLevel)
+	log.SetFormatter(&logrus.JSONFormatter{TimestampFormat: time.RFC3339Nano})
+	log.AddHook(collector)
+
+	sp := control.NewSessionProcessor(log)
+	sess := sp.NewSession(log)
+
+	ctx, cancel := context.WithTimeout(ctx, s.Timeout)
+	defer cancel()
+	res := &Result{Data: make(map[string]string)}
+	if err := sess.Run(ctx, fileDoc); err != nil {
+		if e, ok := interp.IsExitStatus(err); ok {
+			res.ExitCode = e
+		} else {
+			res.Err = err
+			res.ExitCode = 1
+		}
+	}
+	res.TimedOut = ctx.Err() == context.DeadlineExceeded
+	// Collect the log data before the processor closes
+	for _, id := range append(append([]string{}, s.Ok...), s.Fail...) {
+		for _, k := range []string{"__success", "__error"} {
+			if v, ok := sp.GetLogData(id + "_" + k); ok {
+				res.Data[id+"_"+k] = control.FormatLogValue(v)
+			}
+		}
+	}
+	for k := range s.Data {
+		if v, ok := sp.GetLogData(k); ok {
+			res.Data[k] = control.FormatLogValue(v)
+		}
+	}
+	_ = sess.Close()
+	sp.Close()
+
+	collector.Lock()
+	res.Entries = collector.entries
+	collector.Unlock()
+	res.Output = out.String()
+	return res, nil
+}
+
+// Check the result against the expectations of the script, and return every mismatch.
+func (s *Script) Check(res *Result) (errs []error) {
+	if res.TimedOut {
+		errs = append(errs, fmt.Errorf("script timed out after %s", s.Timeout))
+	}
+	if res.ExitCode != s.Exit {
+		if res.Err != nil {
+			errs = append(errs, fmt.Errorf("expected exit code %d, got %d: %v", s.Exit, res.ExitCode, res.Err))
+		} else {
+			errs = append(errs, fmt.Errorf("expected exit code %d, got %d", s.Exit, res.ExitCode))
+		}
+	}
+	find := func(m Match) bool {
+		for _, e := range res.Entries {
+			if m.Matches(e) {
+				return true
+			}
+		}
+		return false
+	}
+	for _, m := range s.Expect {
+		if !find(m) {
+			errs = append(errs, fmt.Errorf("no log entry matched: %s", m))
+		}
+	}
+	for _, m := range s.ExpectNot {
+		if find(m) {
+			errs = append(errs, fmt.Errorf("unexpected log entry matched: %s", m))
+		}
+	}
+	for _, id := range s.Ok {
+		if _, ok := res.Data[id+"___success"]; !ok {
+			if e, failed := res.Data[id+"___error"]; failed {
+				errs = append(errs, fmt.Errorf("expected call %s to succeed, but it failed: %s", id, e))
+			} else {
+				errs = append(errs, fmt.Errorf("expected call %s to succeed, but it did not complete", id))
+			}
+		}
+	}
+	for _, id := range s.Fail {
+		if _, ok := res.Data[id+"___error"]; !ok {
+			errs = append(errs, fmt.Errorf("expected call %s to fail", id))
+		}
+	}
+	keys := make([]string, 0, len(s.Data))
+	for k := range s.Data {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		got, ok := res.Data[k]
+		if !ok {
+			errs = append(errs, fmt.Errorf("expected log data %s, but it is missing", k))
+		} else if strings.TrimSpace(got) != strings.TrimSpace(s.Data[k]) {
+			errs = append(errs, fmt.Errorf("expected log data %s=%q, got %q", k, s.Data[k], got))
+		}
+	}
+	return errs
+}
+
+// RunFile runs the script at the given path as a test.
+func RunFile(t *testing.T, path string) {
+	s, err := ParseScript(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s.Skip != "" {
+		t.Skip(s.Skip)
+	}
+	if s.ShortSkip != "" && testing.Short() {
+		t.Skip(s.ShortSkip)
+	}
+	res, err := s.Run(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if errs := s.Check(res); len(errs) > 0 {
+		for _, err := range errs {
+			t.Error(err)
+		}
+		t.Log("script output:\n" + res.Output)
+	}
+}
+
+// RunDir runs every script in the directory that matches one of the glob patterns, each as a sub-test.
+// Scripts run one after the other, since they may listen on the same ports.
+func RunDir(t *testing.T, dir string, patterns ...string) {
+	if len(patterns) == 0 {
+		patterns = []string{"*.rumor"}
+	}
+	var paths []string
+	for _, p := range patterns {
+		matches, err := filepath.Glob(filepath.Join(dir, p))
+		if err != nil {
+			t.Fatal(err)
+		}
+		paths = append(paths, matches...)
+	}
+	sort.Strings(paths)
+	if len(paths) == 0 {
+		t.Fatalf("no scripts found in %s", dir)
+	}
+	for _, p := range paths {
+		p := p
+		t.Run(filepath.Base(p), func(t *testing.T) {
+			RunFile(t, p)
+		})
+	}
+}
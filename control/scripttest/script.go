@@ -0,0 +1,201 @@
+// Package scripttest runs rumor scripts as Go tests.
+//
+// A script declares its expectations with annotation comments, one per line:
+//
+//	# @exit <code>            expected exit code of the script, 0 by default
+//	# @timeout <duration>     cancel the script after this duration, 2m by default
+//	# @skip <reason>          skip the script
+//	# @short-skip <reason>    skip the script when running "go test -short"
+//	# @expect k=v k2="v 2"    some log entry must have all the given fields
+//	# @expect-not k=v         no log entry may have all the given fields
+//	# @ok <call-id>           the call must have completed successfully
+//	# @fail <call-id>         the call must have failed
+//	# @data <key>=<value>     log data after the script ran, like the "$_key" variables in the script
+//
+// Log entries are matched on their fields, and the "msg" and "level" pseudo-fields.
+// Values are compared as trimmed strings. Call IDs are the custom IDs without the "_" prefix, e.g. "hostdata" for "_hostdata".
+package scripttest
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const DefaultTimeout = 2 * time.Minute
+
+// Match is a set of fields that a log entry should all have.
+type Match map[string]string
+
+func (m Match) String() string {
+	var parts []string
+	for k, v := range m {
+		parts = append(parts, fmt.Sprintf("%s=%q", k, v))
+	}
+	return strings.Join(parts, " ")
+}
+
+func (m Match) Matches(entry map[string]string) bool {
+	for k, v := range m {
+		got, ok := entry[k]
+		if !ok || strings.TrimSpace(got) != strings.TrimSpace(v) {
+			return false
+		}
+	}
+	return true
+}
+
+// Script is a rumor script with the expectations parsed from its annotations.
+type Script struct {
+	Path      string
+	Exit      uint8
+	Timeout   time.Duration
+	Skip      string
+	ShortSkip string
+	Expect    []Match
+	ExpectNot []Match
+	Ok        []string
+	Fail      []string
+	Data      map[string]string
+}
+
+// ParseScript reads the annotations of the script at the given path.
+func ParseScript(path string) (*Script, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	s := &Script{Path: path, Timeout: DefaultTimeout, Data: make(map[string]string)}
+	scanner := bufio.NewScanner(f)
+	lineNr := 0
+	for scanner.Scan() {
+		lineNr += 1
+		line := strings.TrimSpace(scanner.Text())
+		if !strings.HasPrefix(line, "#") {
+			continue
+		}
+		line = strings.TrimSpace(strings.TrimPrefix(line, "#"))
+		if !strings.HasPrefix(line, "@") {
+			continue
+		}
+		if err := s.annotate(line[1:]); err != nil {
+			return nil, fmt.Errorf("%s:%d: %v", path, lineNr, err)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+func (s *Script) annotate(line string) error {
+	var name, rest string
+	if i := strings.IndexAny(line, " \t"); i >= 0 {
+		name, rest = line[:i], strings.TrimSpace(line[i:])
+	} else {
+		name = line
+	}
+	switch name {
+	case "exit":
+		code, err := strconv.ParseUint(rest, 10, 8)
+		if err != nil {
+			return fmt.Errorf("bad exit code: %v", err)
+		}
+		s.Exit = uint8(code)
+	case "timeout":
+		d, err := time.ParseDuration(rest)
+		if err != nil {
+			return fmt.Errorf("bad timeout: %v", err)
+		}
+		s.Timeout = d
+	case "skip":
+		s.Skip = rest
+		if s.Skip == "" {
+			s.Skip = "skipped by annotation"
+		}
+	case "short-skip":
+		s.ShortSkip = rest
+		if s.ShortSkip == "" {
+			s.ShortSkip = "skipped in short mode"
+		}
+	case "expect", "expect-not":
+		m, err := parseFields(rest)
+		if err != nil {
+			return err
+		}
+		if len(m) == 0 {
+			return fmt.Errorf("@%s needs at least one field", name)
+		}
+		if name == "expect" {
+			s.Expect = append(s.Expect, m)
+		} else {
+			s.ExpectNot = append(s.ExpectNot, m)
+		}
+	case "ok", "fail":
+		if rest == "" || strings.ContainsAny(rest, " \t") {
+			return fmt.Errorf("@%s needs a single call ID", name)
+		}
+		id := strings.TrimPrefix(rest, "_")
+		if name == "ok" {
+			s.Ok = append(s.Ok, id)
+		} else {
+			s.Fail = append(s.Fail, id)
+		}
+	case "data":
+		m, err := parseFields(rest)
+		if err != nil {
+			return err
+		}
+		for k, v := range m {
+			s.Data[strings.TrimPrefix(k, "_")] = v
+		}
+	default:
+		return fmt.Errorf("unknown annotation @%s", name)
+	}
+	return nil
+}
+
+// parseFields parses space separated key=value pairs. Values may be single or double quoted.
+func parseFields(line string) (Match, error) {
+	out := make(Match)
+	i := 0
+	for {
+		for i < len(line) && (line[i] == ' ' || line[i] == '\t') {
+			i++
+		}
+		if i >= len(line) {
+			return out, nil
+		}
+		eq := strings.IndexByte(line[i:], '=')
+		if eq <= 0 {
+			return nil, fmt.Errorf("expected key=value at %q", line[i:])
+		}
+		key := line[i : i+eq]
+		if strings.ContainsAny(key, " \t\"'") {
+			return nil, fmt.Errorf("bad key %q", key)
+		}
+		i += eq + 1
+		var value string
+		if i < len(line) && (line[i] == '"' || line[i] == '\'') {
+			quote := line[i]
+			end := strings.IndexByte(line[i+1:], quote)
+			if end < 0 {
+				return nil, fmt.Errorf("unterminated quote in value of %q", key)
+			}
+			value = line[i+1 : i+1+end]
+			i += end + 2
+		} else {
+			end := strings.IndexAny(line[i:], " \t")
+			if end < 0 {
+				end = len(line) - i
+			}
+			value = line[i : i+end]
+			i += end
+		}
+		out[key] = value
+	}
+}
@@ -31,6 +31,7 @@ type EnvGlobal interface {
 	GetCalls(id actor.ActorID) map[CallID]CallSummary
 	GetLogData(key string) (value interface{}, ok bool)
 	ClearLogData()
+	WaitForLog(ctx context.Context, callID CallID, fields map[string]string) error
 }
 
 type SessionID string
@@ -316,6 +317,14 @@ func (sess *Session) runCmd(ctx context.Context, args []string) error {
 		return nil
 	}
 
+	if len(args) >= 1 && args[0] == "wait-for" {
+		return sess.waitFor(ctx, customCallID, args[1:])
+	}
+
+	if len(args) >= 1 && args[0] == "assert" {
+		return sess.assert(args[1:])
+	}
+
 	wOut := interp.HandlerCtx(ctx).Stdout
 
 	// Useful hack to dump variables to Stdout in json format
@@ -470,7 +479,8 @@ func (sess *Session) Get(name string) expand.Variable {
 				}
 				return expand.Variable{Exported: true, Kind: expand.Indexed, ReadOnly: true, List: dat}
 			default:
-				return expand.Variable{Exported: true, Kind: expand.String, ReadOnly: true, Str: fmt.Sprintf("%v", val)}
+				// format like the JSON logs, e.g. a SeqNr is "42", not "SeqNr(42)"
+				return expand.Variable{Exported: true, Kind: expand.String, ReadOnly: true, Str: FormatLogValue(val)}
 			}
 		}
 	}
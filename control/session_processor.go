@@ -9,6 +9,7 @@ import (
 	sdb "github.com/protolambda/rumor/chain/db/states"
 	"github.com/protolambda/rumor/control/actor"
 	"github.com/protolambda/rumor/control/actor/base"
+	"github.com/protolambda/rumor/p2p/memnet"
 	"github.com/protolambda/rumor/p2p/track"
 	"github.com/sirupsen/logrus"
 	"io"
@@ -43,7 +44,14 @@ type SessionProcessor struct {
 	// logData is a map of all past log data, like map[string]interface{}.
 	// Keys are formatted as "{callid}_{entrykey}", i.e. they are concatenated with an underscore.
 	// The call ID here excludes the prefix-underscore.
-	logData   sync.Map
+	logData sync.Map
+	// logHistory keeps the latest log entries of each call, for wait-for to match against.
+	// watchers get every new log entry of a call, until they are done.
+	// Both are guarded by the same lock, so a watcher does not miss any entry.
+	logHistoryLock sync.Mutex
+	logHistory     map[CallID][]*loggedEntry
+	watchers       map[*logWatcher]struct{}
+
 	log       logrus.FieldLogger
 	closeLock sync.Mutex
 	closing   bool
@@ -65,8 +73,11 @@ func NewSessionProcessor(adminLog logrus.FieldLogger) *SessionProcessor {
 			GlobalChains:     &chain.ChainsMap{},
 			GlobalBlocksDBs:  &bdb.DBMap{},
 			GlobalStatesDBs:  &sdb.DBMap{},
+			GlobalMemNet:     memnet.NewNetwork(),
 		},
 		sessions:            make(map[*Session]struct{}),
+		logHistory:          make(map[CallID][]*loggedEntry),
+		watchers:            make(map[*logWatcher]struct{}),
 		log:                 log,
 		mainEnv:             expand.ListEnviron(os.Environ()...),
 		globalActorCancel:   globActCancel,
@@ -95,6 +106,7 @@ func NewSessionProcessor(adminLog logrus.FieldLogger) *SessionProcessor {
 			}
 			sp.logData.Store(string(callID)+"_"+k, v)
 		}
+		sp.recordEntry(callID, entry)
 		sp.sessionsLock.RLock()
 		defer sp.sessionsLock.RUnlock()
 		for s := range sp.sessions {
@@ -190,6 +202,105 @@ func (sp *SessionProcessor) ClearLogData() {
 		}
 		return true
 	})
+	sp.logHistoryLock.Lock()
+	for id := range sp.logHistory {
+		if _, ok := openCalls[id]; !ok {
+			delete(sp.logHistory, id)
+		}
+	}
+	sp.logHistoryLock.Unlock()
+}
+
+// maxCallHistory is the number of log entries that are remembered per call
+const maxCallHistory = 256
+
+type loggedEntry struct {
+	msg   string
+	level logrus.Level
+	data  logrus.Fields
+}
+
+func (e *loggedEntry) matches(fields map[string]string) bool {
+	if len(fields) == 0 {
+		// Without fields, only the entry that marks the end of the call matches
+		_, ok := e.data["__freed"]
+		return ok
+	}
+	for k, v := range fields {
+		switch k {
+		case "msg":
+			if e.msg != v {
+				return false
+			}
+		case "level":
+			if e.level.String() != v {
+				return false
+			}
+		default:
+			got, ok := e.data[k]
+			if !ok || FormatLogValue(got) != v {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+type logWatcher struct {
+	callID CallID
+	fields map[string]string
+	done   chan struct{}
+}
+
+func (sp *SessionProcessor) recordEntry(callID CallID, entry *logrus.Entry) {
+	e := &loggedEntry{msg: entry.Message, level: entry.Level, data: make(logrus.Fields, len(entry.Data))}
+	for k, v := range entry.Data {
+		e.data[k] = v
+	}
+	sp.logHistoryLock.Lock()
+	defer sp.logHistoryLock.Unlock()
+	h := append(sp.logHistory[callID], e)
+	if len(h) > maxCallHistory {
+		h = h[len(h)-maxCallHistory:]
+	}
+	sp.logHistory[callID] = h
+	for w := range sp.watchers {
+		if w.callID == callID && e.matches(w.fields) {
+			close(w.done)
+			delete(sp.watchers, w)
+		}
+	}
+}
+
+// WaitForLog blocks until the call logs an entry with all the given fields, or until the context is done.
+// The "msg" and "level" fields match the message and level of the entry, other values are formatted with FormatLogValue.
+// Recent entries that were logged before waiting are matched too.
+// Without any fields, it waits for the call (including background tasks) to be freed.
+func (sp *SessionProcessor) WaitForLog(ctx context.Context, callID CallID, fields map[string]string) error {
+	sp.logHistoryLock.Lock()
+	for _, e := range sp.logHistory[callID] {
+		if e.matches(fields) {
+			sp.logHistoryLock.Unlock()
+			return nil
+		}
+	}
+	if len(fields) == 0 && sp.GetCall(callID) == nil {
+		sp.logHistoryLock.Unlock()
+		return nil
+	}
+	w := &logWatcher{callID: callID, fields: fields, done: make(chan struct{})}
+	sp.watchers[w] = struct{}{}
+	sp.logHistoryLock.Unlock()
+
+	select {
+	case <-w.done:
+		return nil
+	case <-ctx.Done():
+		sp.logHistoryLock.Lock()
+		delete(sp.watchers, w)
+		sp.logHistoryLock.Unlock()
+		return ctx.Err()
+	}
 }
 
 func (sp *SessionProcessor) MakeCall(callCtx context.Context, out io.Writer,
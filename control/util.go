@@ -1,10 +1,31 @@
 package control
 
 import (
+	"encoding/json"
+	"fmt"
 	"github.com/sirupsen/logrus"
 	"io"
 )
 
+// FormatLogValue formats a log field value like it shows in the JSON log output, without the quotes of strings.
+func FormatLogValue(v interface{}) string {
+	switch x := v.(type) {
+	case string:
+		return x
+	case error:
+		return x.Error()
+	}
+	data, err := json.Marshal(v)
+	if err != nil {
+		return fmt.Sprintf("%v", v)
+	}
+	var str string
+	if json.Unmarshal(data, &str) == nil {
+		return str
+	}
+	return string(data)
+}
+
 type WriteableFn func(msg string)
 
 func (fn WriteableFn) Write(p []byte) (n int, err error) {
@@ -10,12 +10,13 @@ require (
 	github.com/chzyer/readline v0.0.0-20180603132655-2972be24d48e
 	github.com/chzyer/test v0.0.0-20180213035817-a1ea475d72b1 // indirect
 	github.com/davidlazar/go-crypto v0.0.0-20200604182044-b73af7476f6c // indirect
+	github.com/dgraph-io/badger v1.6.1
 	github.com/ethereum/go-ethereum v1.9.23-0.20201009092346-706f5e3b98a4
 	github.com/gliderlabs/ssh v0.3.0
 	github.com/golang/snappy v0.0.2-0.20200707131729-196ae77b8a26
 	github.com/google/gopacket v1.1.18 // indirect
 	github.com/gorilla/websocket v1.4.2
-	github.com/herumi/bls-eth-go-binary v0.0.0-20200722032157-41fc56eba7b4 // indirect
+	github.com/herumi/bls-eth-go-binary v0.0.0-20200722032157-41fc56eba7b4
 	github.com/ipfs/go-datastore v0.4.4
 	github.com/ipfs/go-ds-badger v0.2.3
 	github.com/ipfs/go-ds-leveldb v0.4.2
@@ -56,6 +57,7 @@ require (
 	github.com/protolambda/ztyp v0.1.0
 	github.com/sirupsen/logrus v1.6.0
 	github.com/spf13/cobra v1.0.0
+	github.com/spf13/pflag v1.0.5
 	golang.org/x/crypto v0.0.0-20201002170205-7f63de1d35b0 // indirect
 	golang.org/x/sync v0.0.0-20200625203802-6e8e738ad208 // indirect
 	golang.org/x/sys v0.0.0-20201009025420-dfb3f7c4e634 // indirect
@@ -0,0 +1,84 @@
+package memnet
+
+import (
+	"encoding/binary"
+	"fmt"
+	"net"
+	"strconv"
+
+	ma "github.com/multiformats/go-multiaddr"
+)
+
+// P_MEMORY is the multicodec of the in-memory transport address: /memory/<id>
+const P_MEMORY = 0x0309
+
+func init() {
+	if err := ma.AddProtocol(ma.Protocol{
+		Name:       "memory",
+		Code:       P_MEMORY,
+		VCode:      ma.CodeToVarint(P_MEMORY),
+		Size:       64,
+		Transcoder: ma.NewTranscoderFromFunctions(memoryStB, memoryBtS, memoryVal),
+	}); err != nil {
+		panic(fmt.Errorf("failed to register memory multiaddr protocol: %v", err))
+	}
+}
+
+func memoryStB(s string) ([]byte, error) {
+	id, err := strconv.ParseUint(s, 10, 64)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse memory addr: %s %v", s, err)
+	}
+	var out [8]byte
+	binary.BigEndian.PutUint64(out[:], id)
+	return out[:], nil
+}
+
+func memoryBtS(b []byte) (string, error) {
+	if err := memoryVal(b); err != nil {
+		return "", err
+	}
+	return strconv.FormatUint(binary.BigEndian.Uint64(b), 10), nil
+}
+
+func memoryVal(b []byte) error {
+	if len(b) != 8 {
+		return fmt.Errorf("invalid length (should be 8 bytes): %d", len(b))
+	}
+	return nil
+}
+
+// MemAddr creates the multiaddr for the given memory address ID.
+func MemAddr(id uint64) ma.Multiaddr {
+	addr, err := ma.NewMultiaddr(fmt.Sprintf("/memory/%d", id))
+	if err != nil {
+		panic(err)
+	}
+	return addr
+}
+
+// AddrID parses the memory address ID from a multiaddr, only /memory/<id> addresses are valid.
+func AddrID(addr ma.Multiaddr) (uint64, error) {
+	protos := addr.Protocols()
+	if len(protos) != 1 || protos[0].Code != P_MEMORY {
+		return 0, fmt.Errorf("not a memory address: %s", addr)
+	}
+	v, err := addr.ValueForProtocol(P_MEMORY)
+	if err != nil {
+		return 0, err
+	}
+	return strconv.ParseUint(v, 10, 64)
+}
+
+// netAddr implements net.Addr for memory addresses
+type netAddr uint64
+
+var _ net.Addr = netAddr(0)
+
+func (a netAddr) Network() string {
+	return "memory"
+}
+
+func (a netAddr) String() string {
+	return strconv.FormatUint(uint64(a), 10)
+}
@@ -0,0 +1,261 @@
+package memnet
+
+import (
+	"io"
+	"net"
+	"sync"
+	"time"
+
+	"github.com/libp2p/go-libp2p-core/peer"
+	ma "github.com/multiformats/go-multiaddr"
+)
+
+type timeoutErr struct{}
+
+func (timeoutErr) Error() string   { return "i/o timeout" }
+func (timeoutErr) Timeout() bool   { return true }
+func (timeoutErr) Temporary() bool { return true }
+
+var errTimeout net.Error = timeoutErr{}
+
+type chunk struct {
+	data      []byte
+	deliverAt time.Time
+}
+
+// pipe is one direction of a connection. Writes never block, they are queued
+// and become readable once their (simulated) delivery time has passed.
+type pipe struct {
+	sync.Mutex
+	net *Network
+	key linkKey
+
+	queue []chunk
+	// time the last queued write finished transmitting, writes are serialized on the link
+	lastTransmit time.Time
+	// time the last write arrives, delivery is in-order
+	lastDeliver time.Time
+	closed      bool
+	// signals readers that the queue or closed state changed
+	notify chan struct{}
+}
+
+func newPipe(n *Network, from peer.ID, to peer.ID) *pipe {
+	return &pipe{net: n, key: linkKey{from: from, to: to}, notify: make(chan struct{}, 1)}
+}
+
+func (p *pipe) signal() {
+	select {
+	case p.notify <- struct{}{}:
+	default:
+	}
+}
+
+func (p *pipe) write(b []byte) (int, error) {
+	latency, transmit := p.net.shape(p.key, len(b))
+	data := make([]byte, len(b))
+	copy(data, b)
+
+	p.Lock()
+	defer p.Unlock()
+	if p.closed {
+		return 0, io.ErrClosedPipe
+	}
+	now := time.Now()
+	start := now
+	if p.lastTransmit.After(start) {
+		start = p.lastTransmit
+	}
+	p.lastTransmit = start.Add(transmit)
+	deliverAt := p.lastTransmit.Add(latency)
+	if deliverAt.Before(p.lastDeliver) {
+		deliverAt = p.lastDeliver
+	}
+	p.lastDeliver = deliverAt
+	p.queue = append(p.queue, chunk{data: data, deliverAt: deliverAt})
+	p.signal()
+	return len(b), nil
+}
+
+func (p *pipe) read(b []byte, deadline <-chan struct{}) (int, error) {
+	for {
+		p.Lock()
+		if len(p.queue) > 0 {
+			head := &p.queue[0]
+			wait := time.Until(head.deliverAt)
+			if wait <= 0 {
+				n := copy(b, head.data)
+				head.data = head.data[n:]
+				if len(head.data) == 0 {
+					p.queue = p.queue[1:]
+				}
+				p.Unlock()
+				return n, nil
+			}
+			p.Unlock()
+			timer := time.NewTimer(wait)
+			select {
+			case <-timer.C:
+			case <-p.notify:
+				timer.Stop()
+			case <-deadline:
+				timer.Stop()
+				return 0, errTimeout
+			}
+			continue
+		}
+		if p.closed {
+			p.Unlock()
+			return 0, io.EOF
+		}
+		p.Unlock()
+		select {
+		case <-p.notify:
+		case <-deadline:
+			return 0, errTimeout
+		}
+	}
+}
+
+func (p *pipe) close() {
+	p.Lock()
+	defer p.Unlock()
+	p.closed = true
+	p.signal()
+}
+
+// deadline is a resettable timer that closes a channel when the deadline passes.
+type deadline struct {
+	sync.Mutex
+	timer *time.Timer
+	// incremented on every change, to ignore stale timer callbacks
+	gen uint64
+	ch  chan struct{}
+}
+
+func newDeadline() *deadline {
+	return &deadline{ch: make(chan struct{})}
+}
+
+func (d *deadline) set(t time.Time) {
+	d.Lock()
+	defer d.Unlock()
+	if d.timer != nil {
+		d.timer.Stop()
+		d.timer = nil
+	}
+	d.gen += 1
+	if d.passed() {
+		// re-arm if it already passed
+		d.ch = make(chan struct{})
+	}
+	if t.IsZero() {
+		return
+	}
+	if wait := time.Until(t); wait <= 0 {
+		close(d.ch)
+	} else {
+		gen := d.gen
+		d.timer = time.AfterFunc(wait, func() {
+			d.Lock()
+			defer d.Unlock()
+			// the deadline may have been changed in the meantime
+			if d.gen == gen && !d.passed() {
+				close(d.ch)
+			}
+		})
+	}
+}
+
+// passed checks if the deadline channel is closed, the lock must be held.
+func (d *deadline) passed() bool {
+	select {
+	case <-d.ch:
+		return true
+	default:
+		return false
+	}
+}
+
+func (d *deadline) wait() <-chan struct{} {
+	d.Lock()
+	defer d.Unlock()
+	return d.ch
+}
+
+// conn is one end of an in-memory connection. It implements manet.Conn.
+type conn struct {
+	in  *pipe
+	out *pipe
+
+	localAddr  uint64
+	remoteAddr uint64
+
+	readDeadline  *deadline
+	writeDeadline *deadline
+
+	closeOnce sync.Once
+}
+
+func newConnPair(n *Network, dialer peer.ID, dialerAddr uint64, listener peer.ID, listenerAddr uint64) (dialerConn *conn, listenerConn *conn) {
+	up := newPipe(n, dialer, listener)
+	down := newPipe(n, listener, dialer)
+	dialerConn = &conn{in: down, out: up, localAddr: dialerAddr, remoteAddr: listenerAddr,
+		readDeadline: newDeadline(), writeDeadline: newDeadline()}
+	listenerConn = &conn{in: up, out: down, localAddr: listenerAddr, remoteAddr: dialerAddr,
+		readDeadline: newDeadline(), writeDeadline: newDeadline()}
+	return
+}
+
+func (c *conn) Read(b []byte) (int, error) {
+	return c.in.read(b, c.readDeadline.wait())
+}
+
+func (c *conn) Write(b []byte) (int, error) {
+	select {
+	case <-c.writeDeadline.wait():
+		return 0, errTimeout
+	default:
+	}
+	return c.out.write(b)
+}
+
+func (c *conn) Close() error {
+	c.closeOnce.Do(func() {
+		c.out.close()
+		c.in.close()
+	})
+	return nil
+}
+
+func (c *conn) LocalAddr() net.Addr {
+	return netAddr(c.localAddr)
+}
+
+func (c *conn) RemoteAddr() net.Addr {
+	return netAddr(c.remoteAddr)
+}
+
+func (c *conn) LocalMultiaddr() ma.Multiaddr {
+	return MemAddr(c.localAddr)
+}
+
+func (c *conn) RemoteMultiaddr() ma.Multiaddr {
+	return MemAddr(c.remoteAddr)
+}
+
+func (c *conn) SetDeadline(t time.Time) error {
+	c.readDeadline.set(t)
+	c.writeDeadline.set(t)
+	return nil
+}
+
+func (c *conn) SetReadDeadline(t time.Time) error {
+	c.readDeadline.set(t)
+	return nil
+}
+
+func (c *conn) SetWriteDeadline(t time.Time) error {
+	c.writeDeadline.set(t)
+	return nil
+}
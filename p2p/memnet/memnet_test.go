@@ -0,0 +1,140 @@
+package memnet
+
+import (
+	"context"
+	"io"
+	"testing"
+	"time"
+
+	"github.com/libp2p/go-libp2p"
+	"github.com/libp2p/go-libp2p-core/host"
+	"github.com/libp2p/go-libp2p-core/network"
+	"github.com/libp2p/go-libp2p-core/peer"
+)
+
+const echoProtocol = "/test/echo"
+
+func newTestHost(t *testing.T, ctx context.Context, n *Network) host.Host {
+	h, err := libp2p.New(ctx, libp2p.Transport(Constructor(n)), libp2p.NoListenAddrs)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		_ = h.Close()
+	})
+	if err := h.Network().Listen(MemAddr(0)); err != nil {
+		t.Fatal(err)
+	}
+	h.SetStreamHandler(echoProtocol, func(s network.Stream) {
+		_, _ = io.Copy(s, s)
+		_ = s.Close()
+	})
+	return h
+}
+
+// roundTrip sends a message over a new stream to the echo handler of the remote host,
+// and returns how long it took to get it back.
+func roundTrip(t *testing.T, ctx context.Context, local host.Host, remote peer.ID) time.Duration {
+	s, err := local.NewStream(ctx, remote, echoProtocol)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+	msg := []byte("hello")
+	start := time.Now()
+	if _, err := s.Write(msg); err != nil {
+		t.Fatal(err)
+	}
+	buf := make([]byte, len(msg))
+	if _, err := io.ReadFull(s, buf); err != nil {
+		t.Fatal(err)
+	}
+	if string(buf) != string(msg) {
+		t.Fatalf("expected echo %q, got %q", msg, buf)
+	}
+	return time.Since(start)
+}
+
+func TestDialStreamLatency(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	n := NewNetwork()
+	a := newTestHost(t, ctx, n)
+	b := newTestHost(t, ctx, n)
+	if err := a.Connect(ctx, peer.AddrInfo{ID: b.ID(), Addrs: b.Addrs()}); err != nil {
+		t.Fatal(err)
+	}
+	if fast := roundTrip(t, ctx, a, b.ID()); fast > 50*time.Millisecond {
+		t.Fatalf("expected fast round trip on an ideal link, took %s", fast)
+	}
+
+	latency := 30 * time.Millisecond
+	n.SetDefault(a.ID(), LinkSettings{Latency: latency})
+	n.SetLink(b.ID(), a.ID(), LinkSettings{Latency: latency})
+	if slow := roundTrip(t, ctx, a, b.ID()); slow < 2*latency {
+		t.Fatalf("expected round trip of at least %s, took %s", 2*latency, slow)
+	}
+
+	// Resetting the link of b falls back to the defaults of b, which are ideal
+	n.ResetLink(b.ID(), a.ID())
+	if l := n.Link(b.ID(), a.ID()); l != (LinkSettings{}) {
+		t.Fatalf("expected ideal link after reset, got %s", l)
+	}
+	if l := n.Link(a.ID(), b.ID()); l.Latency != latency {
+		t.Fatalf("expected default latency %s of a, got %s", latency, l)
+	}
+}
+
+func TestDialStreamLoss(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	n := NewNetwork()
+	a := newTestHost(t, ctx, n)
+	b := newTestHost(t, ctx, n)
+	if err := a.Connect(ctx, peer.AddrInfo{ID: b.ID(), Addrs: b.Addrs()}); err != nil {
+		t.Fatal(err)
+	}
+	// Every write of a is lost once: a retransmission delays it by the timeout and a round trip
+	n.SetLink(a.ID(), b.ID(), LinkSettings{Loss: 1})
+	if lossy := roundTrip(t, ctx, a, b.ID()); lossy < retransmitTimeout {
+		t.Fatalf("expected retransmission delay of at least %s, took %s", retransmitTimeout, lossy)
+	}
+}
+
+func TestShapeLoss(t *testing.T) {
+	n := NewNetwork()
+	key := linkKey{from: peer.ID("a"), to: peer.ID("b")}
+	latency := 10 * time.Millisecond
+	cases := []struct {
+		loss float64
+		// expected range of the fraction of writes with retransmissions
+		min, max float64
+	}{
+		{0, 0, 0},
+		{0.25, 0.2, 0.3},
+		{1, 1, 1},
+	}
+	for _, c := range cases {
+		n.SetLink(key.from, key.to, LinkSettings{Latency: latency, Bandwidth: 1000, Loss: c.loss})
+		delayed := 0
+		const writes = 1000
+		for i := 0; i < writes; i++ {
+			l, transmit := n.shape(key, 100)
+			if l < latency {
+				t.Fatalf("loss %.2f: latency %s is less than the link latency %s", c.loss, l, latency)
+			}
+			if l > latency {
+				delayed += 1
+				// each retransmission doubles the transmission time
+				if transmit <= 100*time.Millisecond {
+					t.Fatalf("loss %.2f: retransmitted write has transmission time %s", c.loss, transmit)
+				}
+			} else if transmit != 100*time.Millisecond {
+				t.Fatalf("loss %.2f: expected transmission time of 100 bytes at 1000 B/s, got %s", c.loss, transmit)
+			}
+		}
+		if frac := float64(delayed) / writes; frac < c.min || frac > c.max {
+			t.Fatalf("loss %.2f: expected fraction of delayed writes in [%.2f, %.2f], got %.3f", c.loss, c.min, c.max, frac)
+		}
+	}
+}
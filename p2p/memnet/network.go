@@ -0,0 +1,172 @@
+package memnet
+
+import (
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"math/rand"
+	"sync"
+	"time"
+
+	"github.com/libp2p/go-libp2p-core/peer"
+)
+
+// LinkSettings shape the traffic in one direction of a link between two peers.
+// The zero value is an ideal link: no latency, unlimited bandwidth, no loss.
+type LinkSettings struct {
+	// One-way delay of each write
+	Latency time.Duration
+	// Bytes per second, 0 for unlimited
+	Bandwidth uint64
+	// Probability (0 to 1) that a write is lost. The transport is reliable,
+	// so a lost write is retransmitted, delaying it (and everything after it) by an extra round trip.
+	Loss float64
+}
+
+func (s LinkSettings) String() string {
+	return fmt.Sprintf("latency: %s, bandwidth: %d B/s, loss: %.3f", s.Latency, s.Bandwidth, s.Loss)
+}
+
+type linkKey struct {
+	from peer.ID
+	to   peer.ID
+}
+
+// Network is an in-memory network shared by the libp2p hosts in the same process.
+// Hosts listen on /memory/<id> addresses, and dial each other without opening any sockets.
+type Network struct {
+	sync.Mutex
+	nextID    uint64
+	listeners map[uint64]*listener
+	// default egress settings per peer
+	defaults map[peer.ID]LinkSettings
+	// settings for specific directed links, override the defaults
+	links map[linkKey]LinkSettings
+	// randomness per directed link, seeded by the peer IDs of the link, for deterministic runs
+	rngs map[linkKey]*rand.Rand
+}
+
+func NewNetwork() *Network {
+	return &Network{
+		nextID:    1,
+		listeners: make(map[uint64]*listener),
+		defaults:  make(map[peer.ID]LinkSettings),
+		links:     make(map[linkKey]LinkSettings),
+		rngs:      make(map[linkKey]*rand.Rand),
+	}
+}
+
+// SetDefault sets the egress settings of the given peer, for all links that do not have specific settings.
+func (n *Network) SetDefault(from peer.ID, settings LinkSettings) {
+	n.Lock()
+	defer n.Unlock()
+	n.defaults[from] = settings
+}
+
+// SetLink sets the settings of the link in the direction from -> to.
+func (n *Network) SetLink(from peer.ID, to peer.ID, settings LinkSettings) {
+	n.Lock()
+	defer n.Unlock()
+	n.links[linkKey{from: from, to: to}] = settings
+}
+
+// ResetLink removes the specific link settings, the defaults of the sender apply again.
+func (n *Network) ResetLink(from peer.ID, to peer.ID) {
+	n.Lock()
+	defer n.Unlock()
+	delete(n.links, linkKey{from: from, to: to})
+}
+
+// Link returns the effective settings of the link in the direction from -> to.
+func (n *Network) Link(from peer.ID, to peer.ID) LinkSettings {
+	n.Lock()
+	defer n.Unlock()
+	return n.link(linkKey{from: from, to: to})
+}
+
+func (n *Network) link(key linkKey) LinkSettings {
+	if s, ok := n.links[key]; ok {
+		return s
+	}
+	return n.defaults[key.from]
+}
+
+// shape computes the extra delay of a write of the given size, beyond the link latency,
+// caused by the bandwidth limit and retransmissions.
+func (n *Network) shape(key linkKey, size int) (latency time.Duration, transmit time.Duration) {
+	n.Lock()
+	defer n.Unlock()
+	s := n.link(key)
+	latency = s.Latency
+	if s.Bandwidth > 0 {
+		transmit = time.Duration(uint64(size) * uint64(time.Second) / s.Bandwidth)
+	}
+	if s.Loss > 0 {
+		rng, ok := n.rngs[key]
+		if !ok {
+			h := fnv.New64a()
+			_, _ = h.Write([]byte(key.from))
+			_, _ = h.Write([]byte(key.to))
+			rng = rand.New(rand.NewSource(int64(h.Sum64())))
+			n.rngs[key] = rng
+		}
+		// Each lost attempt costs a round-trip (the missing ack) and a retransmission.
+		for i := 0; i < maxRetransmits && rng.Float64() < s.Loss; i++ {
+			latency += 2*s.Latency + retransmitTimeout
+			transmit *= 2
+		}
+	}
+	return
+}
+
+const maxRetransmits = 8
+
+const retransmitTimeout = 200 * time.Millisecond
+
+var ErrAddrInUse = errors.New("memory address already in use")
+
+var ErrNoListener = errors.New("no listener at memory address")
+
+func (n *Network) listen(id uint64, l *listener) (uint64, error) {
+	n.Lock()
+	defer n.Unlock()
+	if id == 0 {
+		for {
+			id = n.nextID
+			n.nextID += 1
+			if _, ok := n.listeners[id]; !ok {
+				break
+			}
+		}
+	} else if _, ok := n.listeners[id]; ok {
+		return 0, ErrAddrInUse
+	}
+	n.listeners[id] = l
+	return id, nil
+}
+
+func (n *Network) unlisten(id uint64) {
+	n.Lock()
+	defer n.Unlock()
+	delete(n.listeners, id)
+}
+
+func (n *Network) getListener(id uint64) (*listener, bool) {
+	n.Lock()
+	defer n.Unlock()
+	l, ok := n.listeners[id]
+	return l, ok
+}
+
+// ephemeralID allocates an address ID for the local side of an outgoing connection.
+func (n *Network) ephemeralID() uint64 {
+	n.Lock()
+	defer n.Unlock()
+	for {
+		id := n.nextID
+		n.nextID += 1
+		if _, ok := n.listeners[id]; !ok {
+			return id
+		}
+	}
+}
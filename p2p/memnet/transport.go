@@ -0,0 +1,136 @@
+package memnet
+
+import (
+	"context"
+	"errors"
+	"net"
+	"sync"
+
+	"github.com/libp2p/go-libp2p-core/host"
+	"github.com/libp2p/go-libp2p-core/peer"
+	"github.com/libp2p/go-libp2p-core/transport"
+	tptu "github.com/libp2p/go-libp2p-transport-upgrader"
+	ma "github.com/multiformats/go-multiaddr"
+	manet "github.com/multiformats/go-multiaddr-net"
+)
+
+// Transport is a libp2p transport over a shared in-memory Network.
+type Transport struct {
+	net      *Network
+	local    peer.ID
+	upgrader *tptu.Upgrader
+}
+
+var _ transport.Transport = (*Transport)(nil)
+
+// Constructor creates a transport constructor for the libp2p config, bound to the given network.
+func Constructor(n *Network) func(h host.Host, upgrader *tptu.Upgrader) *Transport {
+	return func(h host.Host, upgrader *tptu.Upgrader) *Transport {
+		return &Transport{net: n, local: h.ID(), upgrader: upgrader}
+	}
+}
+
+func (t *Transport) Dial(ctx context.Context, raddr ma.Multiaddr, p peer.ID) (transport.CapableConn, error) {
+	id, err := AddrID(raddr)
+	if err != nil {
+		return nil, err
+	}
+	l, ok := t.net.getListener(id)
+	if !ok {
+		return nil, ErrNoListener
+	}
+	dialerConn, listenerConn := newConnPair(t.net, t.local, t.net.ephemeralID(), l.local, id)
+	if err := l.push(ctx, listenerConn); err != nil {
+		_ = dialerConn.Close()
+		return nil, err
+	}
+	return t.upgrader.UpgradeOutbound(ctx, t, dialerConn, p)
+}
+
+func (t *Transport) CanDial(addr ma.Multiaddr) bool {
+	_, err := AddrID(addr)
+	return err == nil
+}
+
+// Listen on a memory address. Address /memory/0 picks an unused address ID.
+func (t *Transport) Listen(laddr ma.Multiaddr) (transport.Listener, error) {
+	id, err := AddrID(laddr)
+	if err != nil {
+		return nil, err
+	}
+	l := &listener{
+		net:      t.net,
+		local:    t.local,
+		incoming: make(chan *conn),
+		closed:   make(chan struct{}),
+	}
+	id, err = t.net.listen(id, l)
+	if err != nil {
+		return nil, err
+	}
+	l.id = id
+	return t.upgrader.UpgradeListener(t, l), nil
+}
+
+func (t *Transport) Protocols() []int {
+	return []int{P_MEMORY}
+}
+
+func (t *Transport) Proxy() bool {
+	return false
+}
+
+func (t *Transport) String() string {
+	return "memory"
+}
+
+// listener implements manet.Listener, the upgrader wraps it into a libp2p listener.
+type listener struct {
+	net      *Network
+	local    peer.ID
+	id       uint64
+	incoming chan *conn
+
+	closeOnce sync.Once
+	closed    chan struct{}
+}
+
+var _ manet.Listener = (*listener)(nil)
+
+var errListenerClosed = errors.New("memory listener closed")
+
+func (l *listener) push(ctx context.Context, c *conn) error {
+	select {
+	case l.incoming <- c:
+		return nil
+	case <-l.closed:
+		return errListenerClosed
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func (l *listener) Accept() (manet.Conn, error) {
+	select {
+	case c := <-l.incoming:
+		return c, nil
+	case <-l.closed:
+		return nil, errListenerClosed
+	}
+}
+
+func (l *listener) Close() error {
+	l.closeOnce.Do(func() {
+		l.net.unlisten(l.id)
+		close(l.closed)
+	})
+	return nil
+}
+
+func (l *listener) Multiaddr() ma.Multiaddr {
+	return MemAddr(l.id)
+}
+
+func (l *listener) Addr() net.Addr {
+	return netAddr(l.id)
+}
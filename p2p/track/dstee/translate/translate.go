@@ -6,6 +6,7 @@ import (
 	"encoding/binary"
 	"encoding/gob"
 	"encoding/hex"
+	"encoding/json"
 	"errors"
 	"fmt"
 	"github.com/ethereum/go-ethereum/p2p/enode"
@@ -16,11 +17,13 @@ import (
 	pstore_pb "github.com/libp2p/go-libp2p-peerstore/pb"
 	"github.com/multiformats/go-base32"
 	"github.com/protolambda/rumor/p2p/addrutil"
+	"github.com/protolambda/rumor/p2p/track/fingerprint"
 	"github.com/protolambda/zrnt/eth2/beacon"
 	"github.com/protolambda/ztyp/codec"
 	"net"
 	"sort"
 	"strconv"
+	"strings"
 )
 
 /*
@@ -28,22 +31,24 @@ Peerstore layout, partially due to libp2p legacy:
 
 	/peers
 		- /eth2
-		 	- /<peer-id>
+			- /<peer-id>
 				- /metadata           <- ssz encoded
 				- /metadata_claim     <- ssz encoded
 				- /status             <- ssz encoded
 				- /enr                <- stored in raw base64 enr presentation. Then expanded into subfields when reading:
-				  - /raw              <- base64 enr representation
-                  - /other            <- map of unrecognized key/value pairs. Values encoded as hex bytes by us.
-                  - /eth2_data        <- Eth2Data
-                  - /attnets          <- bitfield
-                  - /seq              <- ENR seq num
-                  - /ip               <- IP (v4 or v6)
-                  - /udp              <- UDP port
-                  - /tcp              <- TCP port
+					- /raw            <- base64 enr representation
+					- /other          <- map of unrecognized key/value pairs. Values encoded as hex bytes by us.
+					- /eth2_data      <- Eth2Data
+					- /attnets        <- bitfield
+					- /seq            <- ENR seq num
+					- /ip             <- IP (v4 or v6)
+					- /udp            <- UDP port
+					- /tcp            <- TCP port
+				- /fingerprint        <- json encoded client classification
+				- /quirks             <- json encoded list of observed RPC quirks
 
 		- /addrs
-			- /<peer-id>              <- no subkeys. Encoded as `pstore_pb.AddrBookRecord` protobuf
+			- /<peer-id>          <- no subkeys. Encoded as `pstore_pb.AddrBookRecord` protobuf
 		- /metadata
 			- /<peer-id>
 				- /protocols          <- bare map[string]struct{}, encoded by libp2p, using Gob for encode/decode :(
@@ -53,8 +58,8 @@ Peerstore layout, partially due to libp2p legacy:
 				- /<other misc keys>  <- user can add anything
 		- /keys
 			- /<peer-id>
-				- /pub      <- encoded as protobuf by libp2p crypto package
-				- /priv     <- encoded as protobuf by libp2p crypto package
+				- /pub                <- encoded as protobuf by libp2p crypto package
+				- /priv               <- encoded as protobuf by libp2p crypto package
 */
 
 type ENRData struct {
@@ -92,10 +97,12 @@ type AddrBookRecord_CertifiedRecord struct {
 }
 
 type Eth2Data struct {
-	Metadata      *beacon.MetaData `json:"metadata,omitempty"`
-	MetadataClaim beacon.SeqNr     `json:"metadata_claim,omitempty"`
-	Status        *beacon.Status   `json:"status,omitempty"`
-	ENR           *ENRData         `json:"enr,omitempty"`
+	Metadata      *beacon.MetaData         `json:"metadata,omitempty"`
+	MetadataClaim beacon.SeqNr             `json:"metadata_claim,omitempty"`
+	Status        *beacon.Status           `json:"status,omitempty"`
+	ENR           *ENRData                 `json:"enr,omitempty"`
+	Fingerprint   *fingerprint.Fingerprint `json:"fingerprint,omitempty"`
+	Quirks        []string                 `json:"quirks,omitempty"`
 }
 
 type PartialPeerstoreEntry struct {
@@ -122,6 +129,12 @@ func (p *PartialPeerstoreEntry) Merge(other *PartialPeerstoreEntry) {
 			if other.Eth2.Status != nil {
 				p.Eth2.Status = other.Eth2.Status
 			}
+			if other.Eth2.Fingerprint != nil {
+				p.Eth2.Fingerprint = other.Eth2.Fingerprint
+			}
+			if other.Eth2.Quirks != nil {
+				p.Eth2.Quirks = other.Eth2.Quirks
+			}
 			if other.Eth2.MetadataClaim > p.Eth2.MetadataClaim {
 				p.Eth2.MetadataClaim = other.Eth2.MetadataClaim
 			}
@@ -190,6 +203,19 @@ func (p *PartialPeerstoreEntry) ToCSV(prefixFields ...string) (out [][]string) {
 		if p.Eth2.MetadataClaim > p.Eth2.MetadataClaim {
 			entry("eth2/metadata_claim", strconv.FormatUint(uint64(p.Eth2.MetadataClaim), 10))
 		}
+		if p.Eth2.Fingerprint != nil {
+			entry("eth2/fingerprint/client", p.Eth2.Fingerprint.Client)
+			if p.Eth2.Fingerprint.Version != "" {
+				entry("eth2/fingerprint/version", p.Eth2.Fingerprint.Version)
+			}
+			if p.Eth2.Fingerprint.Libp2p != "" {
+				entry("eth2/fingerprint/libp2p", p.Eth2.Fingerprint.Libp2p)
+			}
+			entry("eth2/fingerprint/confidence", strconv.FormatFloat(p.Eth2.Fingerprint.Confidence, 'f', 4, 64))
+		}
+		if p.Eth2.Quirks != nil {
+			entry("eth2/quirks", strings.Join(p.Eth2.Quirks, ";"))
+		}
 		if p.Eth2.Metadata != nil {
 			entry("eth2/metadata/seq_number", strconv.FormatUint(uint64(p.Eth2.Metadata.SeqNumber), 10))
 			entry("eth2/metadata/attnets", p.Eth2.Metadata.Attnets.String())
@@ -273,6 +299,10 @@ func KeyToPath(k ds.Key) (id peer.ID, p string, err error) {
 				p = "eth2/status"
 			case "enr":
 				p = "eth2/enr"
+			case "fingerprint":
+				p = "eth2/fingerprint"
+			case "quirks":
+				p = "eth2/quirks"
 			default:
 				err = fmt.Errorf("%w key: %s", UnknownKey, k)
 			}
@@ -387,6 +417,19 @@ func ItemToEntry(k ds.Key, v []byte) (id peer.ID, out PartialPeerstoreEntry, err
 					}
 					out.Eth2.ENR.Other = enrKV
 				}
+			case "fingerprint":
+				var fp fingerprint.Fingerprint
+				if e := json.Unmarshal(v, &fp); e == nil {
+					out.Eth2.Fingerprint = &fp
+				} else {
+					err = fmt.Errorf("bad fingerprint in peerstore: %v", e)
+					return
+				}
+			case "quirks":
+				if e := json.Unmarshal(v, &out.Eth2.Quirks); e != nil {
+					err = fmt.Errorf("bad quirks in peerstore: %v", e)
+					return
+				}
 			default:
 				err = fmt.Errorf("%w key: %s", UnknownKey, k)
 			}
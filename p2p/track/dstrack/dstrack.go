@@ -35,6 +35,7 @@ type dsExtendedPeerstore struct {
 	*dsStatusBook
 	*dsMetadataBook
 	*dsENRBook
+	*dsFingerprintBook
 }
 
 func NewExtendedPeerstore(ctx context.Context, store ds.Batching, opts pstoreds.Options) (track.ExtendedPeerstore, error) {
@@ -59,14 +60,19 @@ func NewExtendedPeerstore(ctx context.Context, store ds.Batching, opts pstoreds.
 	if err != nil {
 		return nil, err
 	}
+	fb, err := NewFingerprintBook(store)
+	if err != nil {
+		return nil, err
+	}
 
 	return &dsExtendedPeerstore{
-		multiTee:       mul,
-		store:          store,
-		Peerstore:      ps,
-		dsStatusBook:   sb,
-		dsMetadataBook: mb,
-		dsENRBook:      eb,
+		multiTee:          mul,
+		store:             store,
+		Peerstore:         ps,
+		dsStatusBook:      sb,
+		dsMetadataBook:    mb,
+		dsENRBook:         eb,
+		dsFingerprintBook: fb,
 	}, nil
 }
 
@@ -174,8 +180,11 @@ func (ep *dsExtendedPeerstore) Close() error {
 }
 
 func (ep *dsExtendedPeerstore) GetAllData(id peer.ID) *track.PeerAllData {
-	pub := ep.PubKey(id)
-	secpKey := (pub).(*ic.Secp256k1PublicKey)
+	// Only eth2 peers have a secp256k1 key, and a node ID
+	secpKey, ok := ep.PubKey(id).(*ic.Secp256k1PublicKey)
+	if !ok {
+		return nil
+	}
 	keyBytes, err := secpKey.Raw()
 	pubStr := ""
 	if err == nil {
@@ -228,5 +237,7 @@ func (ep *dsExtendedPeerstore) GetAllData(id peer.ID) *track.PeerAllData {
 		ClaimedSeq:      seq,
 		Status:          ep.Status(id),
 		ENR:             en,
+		Fingerprint:     ep.Fingerprint(id),
+		Quirks:          ep.Quirks(id),
 	}
 }
@@ -0,0 +1,96 @@
+package dstrack
+
+import (
+	"encoding/json"
+	"fmt"
+	ds "github.com/ipfs/go-datastore"
+	"github.com/libp2p/go-libp2p-core/peer"
+	"github.com/protolambda/rumor/p2p/track"
+	"github.com/protolambda/rumor/p2p/track/fingerprint"
+	"sort"
+	"sync"
+)
+
+// fingerprints and quirks are stored under the /eth2/<peer id>/fingerprint and /quirks paths, json encoded
+var (
+	fingerprintSuffix = ds.NewKey("/fingerprint")
+	quirksSuffix      = ds.NewKey("/quirks")
+)
+
+type dsFingerprintBook struct {
+	ds ds.Datastore
+	// quirks are read-modify-write, serialize them
+	quirksLock sync.Mutex
+}
+
+var _ track.FingerprintBook = (*dsFingerprintBook)(nil)
+
+func NewFingerprintBook(store ds.Datastore) (*dsFingerprintBook, error) {
+	return &dsFingerprintBook{ds: store}, nil
+}
+
+func (fb *dsFingerprintBook) Fingerprint(id peer.ID) *fingerprint.Fingerprint {
+	key := peerIdToKey(eth2Base, id).Child(fingerprintSuffix)
+	value, err := fb.ds.Get(key)
+	if err != nil {
+		return nil
+	}
+	var fp fingerprint.Fingerprint
+	if err := json.Unmarshal(value, &fp); err != nil {
+		return nil
+	}
+	return &fp
+}
+
+func (fb *dsFingerprintBook) RegisterFingerprint(id peer.ID, fp *fingerprint.Fingerprint) error {
+	key := peerIdToKey(eth2Base, id).Child(fingerprintSuffix)
+	value, err := json.Marshal(fp)
+	if err != nil {
+		return fmt.Errorf("failed to encode fingerprint: %v", err)
+	}
+	if err := fb.ds.Put(key, value); err != nil {
+		return fmt.Errorf("failed to store fingerprint: %v", err)
+	}
+	return nil
+}
+
+func (fb *dsFingerprintBook) loadQuirks(id peer.ID) []string {
+	key := peerIdToKey(eth2Base, id).Child(quirksSuffix)
+	value, err := fb.ds.Get(key)
+	if err != nil {
+		return nil
+	}
+	var quirks []string
+	if err := json.Unmarshal(value, &quirks); err != nil {
+		return nil
+	}
+	return quirks
+}
+
+func (fb *dsFingerprintBook) Quirks(id peer.ID) []string {
+	fb.quirksLock.Lock()
+	defer fb.quirksLock.Unlock()
+	return fb.loadQuirks(id)
+}
+
+func (fb *dsFingerprintBook) RegisterQuirk(id peer.ID, quirk string) (added bool) {
+	fb.quirksLock.Lock()
+	defer fb.quirksLock.Unlock()
+	quirks := fb.loadQuirks(id)
+	i := sort.SearchStrings(quirks, quirk)
+	if i < len(quirks) && quirks[i] == quirk {
+		return false
+	}
+	quirks = append(quirks, "")
+	copy(quirks[i+1:], quirks[i:])
+	quirks[i] = quirk
+	value, err := json.Marshal(quirks)
+	if err != nil {
+		return false
+	}
+	key := peerIdToKey(eth2Base, id).Child(quirksSuffix)
+	if err := fb.ds.Put(key, value); err != nil {
+		return false
+	}
+	return true
+}
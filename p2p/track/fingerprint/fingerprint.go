@@ -0,0 +1,275 @@
+package fingerprint
+
+import (
+	"github.com/ethereum/go-ethereum/p2p/enode"
+	"sort"
+	"strings"
+)
+
+const (
+	Unknown = "unknown"
+	Other   = "other"
+)
+
+// Known clients, matched by user agent prefix
+var Clients = []string{"lighthouse", "prysm", "teku", "nimbus", "lodestar", "trinity", "rumor"}
+
+// Libp2p implementations that clients are built with, when the client itself cannot be told apart.
+var libp2pClients = map[string]string{
+	"rust-libp2p": "lighthouse",
+	"go-libp2p":   "prysm",
+	"jvm-libp2p":  "teku",
+	"nim-libp2p":  "nimbus",
+	"js-libp2p":   "lodestar",
+	"py-libp2p":   "trinity",
+}
+
+// Protocols that only some libp2p implementations support
+var libp2pProtocols = map[string]string{
+	"/p2p/id/delta/1.0.0": "go-libp2p",
+}
+
+// Weights of the different signals. The agent is the strongest, if the peer is honest about it.
+const (
+	agentWeight    = 1.0
+	libp2pWeight   = 0.6
+	protocolWeight = 0.4
+	profileWeight  = 0.5
+)
+
+// Fingerprint is the classification of the client implementation of a peer.
+type Fingerprint struct {
+	// Client family, e.g. "lighthouse", or "unknown"
+	Client string `json:"client"`
+	// Client version, if the user agent has it
+	Version string `json:"version,omitempty"`
+	// Libp2p implementation, e.g. "go-libp2p"
+	Libp2p string `json:"libp2p,omitempty"`
+	// Share of the signal weight that agrees with the client, between 0 and 1.
+	// Without a recognized user agent, it is at most the weight of the other signals.
+	Confidence float64 `json:"confidence"`
+	// Supported req-resp compression: "snappy" and/or "none"
+	Compression []string `json:"compression,omitempty"`
+	// Keys of the latest ENR, in record order
+	ENRKeys []string `json:"enr_keys,omitempty"`
+	// The signals that were used, e.g. "agent:lighthouse"
+	Signals []string `json:"signals,omitempty"`
+}
+
+// Input is everything known about a peer that is used for fingerprinting.
+type Input struct {
+	UserAgent       string
+	ProtocolVersion string
+	Protocols       []string
+	ENR             *enode.Node
+	// RPC quirks, see ResponseQuirk
+	Quirks []string
+}
+
+// ParseUserAgent splits the user agent into client family, version and libp2p implementation.
+// E.g. "Lighthouse/v0.2.8-87181204/x86_64-linux" is client "lighthouse" with version "v0.2.8-87181204".
+// And "js-libp2p/0.28.10" is libp2p "js-libp2p", with client "unknown", since the agent does not tell it.
+func ParseUserAgent(userAgent string) (client string, version string, libp2p string) {
+	if userAgent == "" {
+		return Unknown, "", ""
+	}
+	parts := strings.Split(strings.TrimSpace(userAgent), "/")
+	name := strings.ToLower(parts[0])
+	for _, c := range Clients {
+		if strings.HasPrefix(name, c) {
+			client = c
+			break
+		}
+	}
+	if client == "" {
+		if _, ok := libp2pClients[name]; ok {
+			return Unknown, "", name
+		}
+		return Other, "", ""
+	}
+	if len(parts) > 1 {
+		v := parts[1]
+		if strings.HasPrefix(v, "v") || (len(v) > 0 && v[0] >= '0' && v[0] <= '9') {
+			version = v
+		}
+	}
+	return client, version, ""
+}
+
+// ClientFamily identifies the client by the user agent only, e.g. "Lighthouse/v0.2.8/x86_64-linux" is "lighthouse".
+func ClientFamily(userAgent string) string {
+	client, _, _ := ParseUserAgent(userAgent)
+	return client
+}
+
+// Compression lists the compression of the eth2 req-resp protocols the peer supports.
+func Compression(protocols []string) (out []string) {
+	snappy, none := false, false
+	for _, p := range protocols {
+		if !strings.HasPrefix(p, "/eth2/") {
+			continue
+		}
+		if strings.HasSuffix(p, "/ssz_snappy") {
+			snappy = true
+		} else if strings.HasSuffix(p, "/ssz") {
+			none = true
+		}
+	}
+	if snappy {
+		out = append(out, "snappy")
+	}
+	if none {
+		out = append(out, "none")
+	}
+	return
+}
+
+// ENRKeys lists the keys of the ENR, in the order of the record.
+func ENRKeys(n *enode.Node) (out []string) {
+	if n == nil {
+		return nil
+	}
+	pairs := n.Record().AppendElements(nil)
+	// the first element is the seq nr, then key-value pairs
+	for i := 1; i+1 < len(pairs); i += 2 {
+		if k, ok := pairs[i].(string); ok {
+			out = append(out, k)
+		}
+	}
+	return
+}
+
+// Classify fingerprints the peer with the user agent and protocols, and with the profiles, if not nil.
+func Classify(in *Input, profiles *Profiles) *Fingerprint {
+	fp := &Fingerprint{
+		Client:      Unknown,
+		Compression: Compression(in.Protocols),
+		ENRKeys:     ENRKeys(in.ENR),
+	}
+	votes := make(map[string]float64)
+	total := 0.0
+	vote := func(client string, weight float64, signal string) {
+		votes[client] += weight
+		total += weight
+		fp.Signals = append(fp.Signals, signal)
+	}
+
+	client, version, libp2p := ParseUserAgent(in.UserAgent)
+	// An unrecognized agent says nothing about the client, it does not outvote the other signals.
+	if client != Unknown && client != Other {
+		vote(client, agentWeight, "agent:"+client)
+		fp.Version = version
+	}
+	if libp2p == "" {
+		for _, p := range in.Protocols {
+			if impl, ok := libp2pProtocols[p]; ok {
+				libp2p = impl
+				vote(libp2pClients[impl], protocolWeight, "protocol:"+p)
+				break
+			}
+		}
+	} else {
+		vote(libp2pClients[libp2p], libp2pWeight, "libp2p:"+libp2p)
+	}
+	fp.Libp2p = libp2p
+
+	if profiles != nil {
+		for _, sig := range signatures(in) {
+			if counts, ok := profiles.bySignature[sig]; ok {
+				best, share := counts.best()
+				vote(best, profileWeight*share, "profile:"+sig)
+			}
+		}
+	}
+
+	best := 0.0
+	var names []string
+	for c := range votes {
+		names = append(names, c)
+	}
+	// deterministic tie breaking
+	sort.Strings(names)
+	for _, c := range names {
+		if votes[c] > best {
+			best = votes[c]
+			fp.Client = c
+		}
+	}
+	if total < agentWeight {
+		// weak signals alone are not as certain as a user agent
+		total = agentWeight
+	}
+	fp.Confidence = best / total
+	if len(votes) == 0 && client == Other {
+		// Nothing else to go by, but the peer is not one of the known clients.
+		fp.Client = Other
+	}
+	if fp.Client != client {
+		// the version is only meaningful for the client the agent claims to be
+		fp.Version = ""
+	}
+	return fp
+}
+
+// signatures are the behaviour traits of a peer, that are independent of the agent.
+func signatures(in *Input) (out []string) {
+	if keys := ENRKeys(in.ENR); len(keys) > 0 {
+		out = append(out, "enr_keys="+strings.Join(keys, ","))
+	}
+	if len(in.Protocols) > 0 {
+		protocols := append([]string(nil), in.Protocols...)
+		sort.Strings(protocols)
+		out = append(out, "protocols="+strings.Join(protocols, ","))
+	}
+	if in.ProtocolVersion != "" {
+		out = append(out, "protocol_version="+in.ProtocolVersion)
+	}
+	for _, q := range in.Quirks {
+		out = append(out, "quirk="+q)
+	}
+	return
+}
+
+type clientCounts map[string]int
+
+func (cc clientCounts) best() (client string, share float64) {
+	total, max := 0, 0
+	for c, n := range cc {
+		total += n
+		if n > max || (n == max && c < client) {
+			max, client = n, c
+		}
+	}
+	if total == 0 {
+		return Unknown, 0
+	}
+	return client, float64(max) / float64(total)
+}
+
+// Profiles learns the behaviour of clients from peers that identify themselves,
+// to classify peers with a missing or unrecognized user agent.
+type Profiles struct {
+	bySignature map[string]clientCounts
+}
+
+func NewProfiles() *Profiles {
+	return &Profiles{bySignature: make(map[string]clientCounts)}
+}
+
+// Learn adds the behaviour of the peer to the profile of the client its user agent claims,
+// if any. Returns the client, or "unknown" if nothing was learned.
+func (p *Profiles) Learn(in *Input) string {
+	client, _, _ := ParseUserAgent(in.UserAgent)
+	if client == Unknown || client == Other {
+		return Unknown
+	}
+	for _, sig := range signatures(in) {
+		counts, ok := p.bySignature[sig]
+		if !ok {
+			counts = make(clientCounts)
+			p.bySignature[sig] = counts
+		}
+		counts[client] += 1
+	}
+	return client
+}
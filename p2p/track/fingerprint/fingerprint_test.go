@@ -0,0 +1,173 @@
+package fingerprint
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseUserAgent(t *testing.T) {
+	cases := []struct {
+		userAgent string
+		client    string
+		version   string
+		libp2p    string
+	}{
+		{"Lighthouse/v0.2.8-87181204/x86_64-linux", "lighthouse", "v0.2.8-87181204", ""},
+		{"Prysm/v1.0.0-alpha.24/0ea7c7b3d7e9ec2ae7a5bcd5d4f3c5c4b9a1e2f3", "prysm", "v1.0.0-alpha.24", ""},
+		{"teku/v0.12.5/linux-x86_64/oracle-java-11", "teku", "v0.12.5", ""},
+		{"nimbus", "nimbus", "", ""},
+		{"lodestar/0.11.0", "lodestar", "0.11.0", ""},
+		{"Trinity/v0.1.0-alpha.35/linux/cpython3.8.2", "trinity", "v0.1.0-alpha.35", ""},
+		{"Rumor", "rumor", "", ""},
+		{" Lighthouse/v1.0.0 ", "lighthouse", "v1.0.0", ""},
+		// Not a version
+		{"teku/custom-build", "teku", "", ""},
+		// Only the libp2p implementation
+		{"js-libp2p/0.28.10", Unknown, "", "js-libp2p"},
+		{"rust-libp2p/0.22.0", Unknown, "", "rust-libp2p"},
+		{"go-libp2p", Unknown, "", "go-libp2p"},
+		{"", Unknown, "", ""},
+		{"geth/v1.9.0", Other, "", ""},
+	}
+	for _, c := range cases {
+		client, version, libp2p := ParseUserAgent(c.userAgent)
+		if client != c.client || version != c.version || libp2p != c.libp2p {
+			t.Errorf("%q: expected (%q, %q, %q), got (%q, %q, %q)",
+				c.userAgent, c.client, c.version, c.libp2p, client, version, libp2p)
+		}
+		if family := ClientFamily(c.userAgent); family != c.client {
+			t.Errorf("%q: expected client family %q, got %q", c.userAgent, c.client, family)
+		}
+	}
+}
+
+func TestClassify(t *testing.T) {
+	cases := []struct {
+		name       string
+		in         Input
+		client     string
+		version    string
+		libp2p     string
+		confidence float64
+		signals    []string
+	}{
+		{
+			name:       "known agent",
+			in:         Input{UserAgent: "Lighthouse/v0.2.8/x86_64-linux"},
+			client:     "lighthouse",
+			version:    "v0.2.8",
+			confidence: 1,
+			signals:    []string{"agent:lighthouse"},
+		},
+		{
+			name:       "libp2p agent",
+			in:         Input{UserAgent: "js-libp2p/0.28.10"},
+			client:     "lodestar",
+			libp2p:     "js-libp2p",
+			confidence: libp2pWeight,
+			signals:    []string{"libp2p:js-libp2p"},
+		},
+		{
+			name:       "unrecognized agent with go-libp2p protocol",
+			in:         Input{UserAgent: "geth/v1.9.0", Protocols: []string{"/ipfs/id/1.0.0", "/p2p/id/delta/1.0.0"}},
+			client:     "prysm",
+			libp2p:     "go-libp2p",
+			confidence: protocolWeight,
+			signals:    []string{"protocol:/p2p/id/delta/1.0.0"},
+		},
+		{
+			name:       "agent outvotes protocol",
+			in:         Input{UserAgent: "teku/v0.12.5", Protocols: []string{"/p2p/id/delta/1.0.0"}},
+			client:     "teku",
+			version:    "v0.12.5",
+			libp2p:     "go-libp2p",
+			confidence: agentWeight / (agentWeight + protocolWeight),
+			signals:    []string{"agent:teku", "protocol:/p2p/id/delta/1.0.0"},
+		},
+		{
+			name:   "unrecognized agent",
+			in:     Input{UserAgent: "geth/v1.9.0"},
+			client: Other,
+		},
+		{
+			name:   "no agent",
+			in:     Input{},
+			client: Unknown,
+		},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			fp := Classify(&c.in, nil)
+			if fp.Client != c.client || fp.Version != c.version || fp.Libp2p != c.libp2p {
+				t.Errorf("expected (%q, %q, %q), got (%q, %q, %q)",
+					c.client, c.version, c.libp2p, fp.Client, fp.Version, fp.Libp2p)
+			}
+			if fp.Confidence != c.confidence {
+				t.Errorf("expected confidence %f, got %f", c.confidence, fp.Confidence)
+			}
+			if !reflect.DeepEqual(fp.Signals, c.signals) {
+				t.Errorf("expected signals %q, got %q", c.signals, fp.Signals)
+			}
+		})
+	}
+}
+
+func TestCompression(t *testing.T) {
+	cases := []struct {
+		protocols []string
+		expected  []string
+	}{
+		{nil, nil},
+		{[]string{"/ipfs/ping/1.0.0"}, nil},
+		{[]string{"/eth2/beacon_chain/req/status/1/ssz_snappy"}, []string{"snappy"}},
+		{[]string{"/eth2/beacon_chain/req/status/1/ssz"}, []string{"none"}},
+		{[]string{
+			"/eth2/beacon_chain/req/status/1/ssz",
+			"/eth2/beacon_chain/req/status/1/ssz_snappy",
+		}, []string{"snappy", "none"}},
+	}
+	for _, c := range cases {
+		if out := Compression(c.protocols); !reflect.DeepEqual(out, c.expected) {
+			t.Errorf("%q: expected %q, got %q", c.protocols, c.expected, out)
+		}
+	}
+}
+
+func TestProfiles(t *testing.T) {
+	profiles := NewProfiles()
+	protocols := []string{"/eth2/beacon_chain/req/status/1/ssz_snappy", "/meshsub/1.1.0"}
+	quirk := UnsupportedQuirk("metadata")
+	if client := profiles.Learn(&Input{UserAgent: "teku/v0.12.5", Protocols: protocols, Quirks: []string{quirk}}); client != "teku" {
+		t.Fatalf("expected to learn teku, got %q", client)
+	}
+	// Peers without a known client are not learned from
+	if client := profiles.Learn(&Input{UserAgent: "geth/v1.9.0", Protocols: protocols}); client != Unknown {
+		t.Fatalf("expected nothing to be learned, got %q", client)
+	}
+
+	fp := Classify(&Input{Protocols: protocols, Quirks: []string{quirk}}, profiles)
+	if fp.Client != "teku" {
+		t.Fatalf("expected teku by profile, got %q", fp.Client)
+	}
+	// Two profile signals of full share, compared to the weight of a user agent
+	if fp.Confidence != 1 || len(fp.Signals) != 2 {
+		t.Fatalf("expected confidence 1 from 2 profile signals, got %f from %q", fp.Confidence, fp.Signals)
+	}
+	fp = Classify(&Input{Protocols: []string{"/meshsub/1.1.0"}}, profiles)
+	if fp.Client != Unknown || fp.Confidence != 0 {
+		t.Fatalf("expected unknown client without a matching profile, got %q (confidence %f)", fp.Client, fp.Confidence)
+	}
+}
+
+func TestResponseQuirk(t *testing.T) {
+	if q, ok := ResponseQuirk("status", true, 0, "", nil); ok {
+		t.Errorf("expected no quirk for success, got %q", q)
+	}
+	if q, ok := ResponseQuirk("status", false, 1, "  Invalid Fork Digest ", nil); !ok || q != "status:err_1:invalid fork digest" {
+		t.Errorf("expected error quirk, got %q", q)
+	}
+	long := ErrorQuirk("goodbye", 2, "0123456789012345678901234567890123456789012345678901234567890123456789")
+	if expected := "goodbye:err_2:" + "012345678901234567890123456789012345678901234567"; long != expected {
+		t.Errorf("expected truncated message %q, got %q", expected, long)
+	}
+}
@@ -0,0 +1,36 @@
+package fingerprint
+
+import (
+	"fmt"
+	ms "github.com/multiformats/go-multistream"
+	"strings"
+)
+
+// Error messages are free-form, only the start is kept, to tell implementations apart
+const maxQuirkMsgLen = 48
+
+// ErrorQuirk describes a non-success RPC response, e.g. "status:err_1:invalid fork digest".
+func ErrorQuirk(method string, code uint8, msg string) string {
+	msg = strings.ToLower(strings.TrimSpace(msg))
+	if len(msg) > maxQuirkMsgLen {
+		msg = msg[:maxQuirkMsgLen]
+	}
+	return fmt.Sprintf("%s:err_%d:%s", method, code, msg)
+}
+
+// UnsupportedQuirk describes an RPC protocol that the peer does not support, e.g. "metadata:unsupported".
+func UnsupportedQuirk(method string) string {
+	return method + ":unsupported"
+}
+
+// ResponseQuirk describes the outcome of an RPC request, if it is a quirk:
+// an unsupported protocol, or an error response.
+func ResponseQuirk(method string, success bool, code uint8, errMsg string, err error) (quirk string, ok bool) {
+	if err == ms.ErrNotSupported {
+		return UnsupportedQuirk(method), true
+	}
+	if err == nil && !success {
+		return ErrorQuirk(method, code, errMsg), true
+	}
+	return "", false
+}
@@ -7,6 +7,7 @@ import (
 	"github.com/libp2p/go-libp2p-core/peer"
 	"github.com/libp2p/go-libp2p-core/peerstore"
 	"github.com/protolambda/rumor/p2p/track/dstee"
+	"github.com/protolambda/rumor/p2p/track/fingerprint"
 	"github.com/protolambda/zrnt/eth2/beacon"
 	"time"
 )
@@ -41,6 +42,17 @@ type MetadataBook interface {
 	RegisterMetadata(id peer.ID, md beacon.MetaData) (newer bool)
 }
 
+type FingerprintBook interface {
+	// Fingerprint retrieves the latest client classification of the peer, and may be nil if it was never classified
+	Fingerprint(peer.ID) *fingerprint.Fingerprint
+	// RegisterFingerprint updates the client classification of the peer
+	RegisterFingerprint(peer.ID, *fingerprint.Fingerprint) error
+	// Quirks lists the RPC quirks that were observed of the peer
+	Quirks(peer.ID) []string
+	// RegisterQuirk adds an observed RPC quirk of the peer, and returns true if it is new
+	RegisterQuirk(id peer.ID, quirk string) (added bool)
+}
+
 type PeerAllData struct {
 	PeerID peer.ID  `json:"peer_id"`
 	NodeID enode.ID `json:"node_id"`
@@ -68,6 +80,10 @@ type PeerAllData struct {
 	Status *beacon.Status `json:"status,omitempty"`
 	// Latest ENR
 	ENR *enode.Node `json:"enr,omitempty"`
+	// Latest client classification
+	Fingerprint *fingerprint.Fingerprint `json:"fingerprint,omitempty"`
+	// Observed RPC quirks
+	Quirks []string `json:"quirks,omitempty"`
 }
 
 func (p *PeerAllData) String() string {
@@ -83,6 +99,7 @@ func (p *PeerAllData) String() string {
 }
 
 type AllDataGetter interface {
+	// GetAllData collects the data of the peer, nil if the peer has no secp256k1 key
 	GetAllData(id peer.ID) *PeerAllData
 }
 
@@ -102,6 +119,7 @@ type ExtendedPeerstore interface {
 	StatusBook
 	MetadataBook
 	ENRBook
+	FingerprintBook
 	AllDataGetter
 	// TODO: maybe track when we've last been connected to a peer?
 }
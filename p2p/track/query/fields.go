@@ -0,0 +1,205 @@
+package query
+
+import (
+	"github.com/protolambda/rumor/p2p/track"
+	"github.com/protolambda/zrnt/eth2/beacon"
+	"sort"
+	"time"
+)
+
+// Record is a peer to evaluate queries on.
+type Record struct {
+	*track.PeerAllData
+	// If the peer is connected right now
+	Connected bool
+}
+
+// Values are nil, bool, float64, string, or []interface{} of these.
+type fieldFn func(r *Record) interface{}
+
+func attnetsValue(bits *beacon.AttnetBits) interface{} {
+	out := make([]interface{}, beacon.ATTESTATION_SUBNET_COUNT)
+	for i := range out {
+		out[i] = (bits[i/8]>>(i%8))&1 == 1
+	}
+	return out
+}
+
+func stringValue(v string) interface{} {
+	if v == "" {
+		return nil
+	}
+	return v
+}
+
+func stringsValue(v []string) interface{} {
+	if v == nil {
+		return nil
+	}
+	out := make([]interface{}, len(v))
+	for i, s := range v {
+		out[i] = s
+	}
+	return out
+}
+
+var fields = map[string]fieldFn{
+	"peer_id":   func(r *Record) interface{} { return r.PeerID.String() },
+	"node_id":   func(r *Record) interface{} { return r.NodeID.String() },
+	"pubkey":    func(r *Record) interface{} { return stringValue(r.Pubkey) },
+	"addrs":     func(r *Record) interface{} { return stringsValue(r.Addrs) },
+	"protocols": func(r *Record) interface{} { return stringsValue(r.Protocols) },
+	"latency": func(r *Record) interface{} {
+		if r.Latency == 0 {
+			return nil
+		}
+		return float64(r.Latency) / float64(time.Millisecond)
+	},
+	"agent":            func(r *Record) interface{} { return stringValue(r.UserAgent) },
+	"protocol_version": func(r *Record) interface{} { return stringValue(r.ProtocolVersion) },
+	"connected":        func(r *Record) interface{} { return r.Connected },
+	"claimed_seq":      func(r *Record) interface{} { return float64(r.ClaimedSeq) },
+	"quirks":           func(r *Record) interface{} { return stringsValue(r.Quirks) },
+
+	"enr": func(r *Record) interface{} {
+		if r.ENR == nil {
+			return nil
+		}
+		return r.ENR.String()
+	},
+	"enr.seq": func(r *Record) interface{} {
+		if r.ENR == nil {
+			return nil
+		}
+		return float64(r.ENR.Seq())
+	},
+	"enr.ip": func(r *Record) interface{} {
+		if r.ENR == nil || r.ENR.IP() == nil {
+			return nil
+		}
+		return r.ENR.IP().String()
+	},
+	"enr.tcp": func(r *Record) interface{} {
+		if r.ENR == nil {
+			return nil
+		}
+		return float64(r.ENR.TCP())
+	},
+	"enr.udp": func(r *Record) interface{} {
+		if r.ENR == nil {
+			return nil
+		}
+		return float64(r.ENR.UDP())
+	},
+	"enr.fork_digest": func(r *Record) interface{} {
+		if r.ForkDigest == nil {
+			return nil
+		}
+		return r.ForkDigest.String()
+	},
+	"enr.next_fork_version": func(r *Record) interface{} {
+		if r.NextForkVersion == nil {
+			return nil
+		}
+		return r.NextForkVersion.String()
+	},
+	"enr.next_fork_epoch": func(r *Record) interface{} {
+		if r.NextForkEpoch == nil {
+			return nil
+		}
+		return float64(*r.NextForkEpoch)
+	},
+	"enr.attnets": func(r *Record) interface{} {
+		if r.Attnets == nil {
+			return nil
+		}
+		return attnetsValue(r.Attnets)
+	},
+
+	"metadata": func(r *Record) interface{} { return r.MetaData != nil },
+	"metadata.seq_number": func(r *Record) interface{} {
+		if r.MetaData == nil {
+			return nil
+		}
+		return float64(r.MetaData.SeqNumber)
+	},
+	"metadata.attnets": func(r *Record) interface{} {
+		if r.MetaData == nil {
+			return nil
+		}
+		return attnetsValue(&r.MetaData.Attnets)
+	},
+
+	"status": func(r *Record) interface{} { return r.Status != nil },
+	"status.fork_digest": func(r *Record) interface{} {
+		if r.Status == nil {
+			return nil
+		}
+		return r.Status.ForkDigest.String()
+	},
+	"status.finalized_root": func(r *Record) interface{} {
+		if r.Status == nil {
+			return nil
+		}
+		return r.Status.FinalizedRoot.String()
+	},
+	"status.finalized_epoch": func(r *Record) interface{} {
+		if r.Status == nil {
+			return nil
+		}
+		return float64(r.Status.FinalizedEpoch)
+	},
+	"status.head_root": func(r *Record) interface{} {
+		if r.Status == nil {
+			return nil
+		}
+		return r.Status.HeadRoot.String()
+	},
+	"status.head_slot": func(r *Record) interface{} {
+		if r.Status == nil {
+			return nil
+		}
+		return float64(r.Status.HeadSlot)
+	},
+
+	"fingerprint": func(r *Record) interface{} { return r.Fingerprint != nil },
+	"fingerprint.client": func(r *Record) interface{} {
+		if r.Fingerprint == nil {
+			return nil
+		}
+		return r.Fingerprint.Client
+	},
+	"fingerprint.version": func(r *Record) interface{} {
+		if r.Fingerprint == nil {
+			return nil
+		}
+		return stringValue(r.Fingerprint.Version)
+	},
+	"fingerprint.libp2p": func(r *Record) interface{} {
+		if r.Fingerprint == nil {
+			return nil
+		}
+		return stringValue(r.Fingerprint.Libp2p)
+	},
+	"fingerprint.confidence": func(r *Record) interface{} {
+		if r.Fingerprint == nil {
+			return nil
+		}
+		return r.Fingerprint.Confidence
+	},
+}
+
+var fieldAliases = map[string]string{
+	"user_agent": "agent",
+	"id":         "peer_id",
+}
+
+// Fields lists the names of all fields that can be queried.
+func Fields() []string {
+	out := make([]string, 0, len(fields))
+	for k := range fields {
+		out = append(out, k)
+	}
+	sort.Strings(out)
+	return out
+}
@@ -0,0 +1,112 @@
+package query
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"text/tabwriter"
+)
+
+// Sort orders the records by the value of the path, with missing values first.
+// The sort is stable, records with equal values keep their order.
+func Sort(records []*Record, by *Path, desc bool) {
+	sort.SliceStable(records, func(i, j int) bool {
+		c := Compare(by.Get(records[i]), by.Get(records[j]))
+		if desc {
+			return c > 0
+		}
+		return c < 0
+	})
+}
+
+// ParsePaths parses a comma separated list of paths.
+func ParsePaths(list string) (names []string, paths []*Path, err error) {
+	for _, name := range strings.Split(list, ",") {
+		name = strings.TrimSpace(name)
+		if name == "" {
+			continue
+		}
+		p, err := ParsePath(name)
+		if err != nil {
+			return nil, nil, err
+		}
+		names = append(names, name)
+		paths = append(paths, p)
+	}
+	return names, paths, nil
+}
+
+// WriteTable writes the records as aligned columns, with a header of the path names.
+func WriteTable(w io.Writer, records []*Record, names []string, paths []*Path) error {
+	tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+	if _, err := fmt.Fprintln(tw, strings.Join(names, "\t")); err != nil {
+		return err
+	}
+	row := make([]string, len(paths))
+	for _, r := range records {
+		for i, p := range paths {
+			row[i] = Format(p.Get(r))
+		}
+		if _, err := fmt.Fprintln(tw, strings.Join(row, "\t")); err != nil {
+			return err
+		}
+	}
+	return tw.Flush()
+}
+
+// WriteCSV writes the records as CSV, with a header of the path names.
+func WriteCSV(w io.Writer, records []*Record, names []string, paths []*Path) error {
+	cw := csv.NewWriter(w)
+	if err := cw.Write(names); err != nil {
+		return err
+	}
+	row := make([]string, len(paths))
+	for _, r := range records {
+		for i, p := range paths {
+			row[i] = Format(p.Get(r))
+		}
+		if err := cw.Write(row); err != nil {
+			return err
+		}
+	}
+	cw.Flush()
+	return cw.Error()
+}
+
+// WriteJSONLines writes a JSON object per record. Without paths, the object is all the data of the peer.
+func WriteJSONLines(w io.Writer, records []*Record, names []string, paths []*Path) error {
+	enc := json.NewEncoder(w)
+	for _, r := range records {
+		if len(paths) == 0 {
+			if err := enc.Encode(r.PeerAllData); err != nil {
+				return err
+			}
+			continue
+		}
+		obj := make(map[string]interface{}, len(paths))
+		for i, p := range paths {
+			obj[names[i]] = p.Get(r)
+		}
+		if err := enc.Encode(obj); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Write writes the records in the given format: "table", "csv" or "jsonl".
+func Write(w io.Writer, format string, records []*Record, names []string, paths []*Path) error {
+	switch format {
+	case "table":
+		return WriteTable(w, records, names, paths)
+	case "csv":
+		return WriteCSV(w, records, names, paths)
+	case "jsonl":
+		return WriteJSONLines(w, records, names, paths)
+	default:
+		return fmt.Errorf("unrecognized output format: %s", format)
+	}
+}
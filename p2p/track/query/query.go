@@ -0,0 +1,558 @@
+package query
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// A query is a boolean expression over the fields of a peer, e.g.:
+//
+//	status.head_slot > 1000 && enr.attnets[5] && agent ~ "Prysm"
+//
+// Operators, from lowest to highest precedence: '||', '&&', '!', and the comparisons
+// '==', '!=', '<', '<=', '>', '>=', '~' (regex match) and '!~'.
+// Operands are field paths, with optional list indices, numbers, "strings", true, false and null.
+// Comparisons with a list are true if any element matches, and '!=' if none is equal. Missing values are null,
+// and only equal to null. A value on its own is true if it is not false, null, 0, "" or an empty list.
+
+type tokenKind uint8
+
+const (
+	tokEOF tokenKind = iota
+	tokIdent
+	tokNumber
+	tokString
+	tokOp
+	tokLParen
+	tokRParen
+	tokLBracket
+	tokRBracket
+	tokDot
+)
+
+type token struct {
+	kind tokenKind
+	text string
+	pos  int
+}
+
+var operators = []string{"||", "&&", "==", "!=", "<=", ">=", "!~", "<", ">", "~", "!"}
+
+func lex(input string) ([]token, error) {
+	var out []token
+	i := 0
+	for i < len(input) {
+		c := rune(input[i])
+		switch {
+		case unicode.IsSpace(c):
+			i++
+		case c == '(':
+			out = append(out, token{tokLParen, "(", i})
+			i++
+		case c == ')':
+			out = append(out, token{tokRParen, ")", i})
+			i++
+		case c == '[':
+			out = append(out, token{tokLBracket, "[", i})
+			i++
+		case c == ']':
+			out = append(out, token{tokRBracket, "]", i})
+			i++
+		case c == '.':
+			out = append(out, token{tokDot, ".", i})
+			i++
+		case c == '"' || c == '\'':
+			start := i
+			i++
+			var sb strings.Builder
+			for {
+				if i >= len(input) {
+					return nil, fmt.Errorf("unterminated string at %d", start)
+				}
+				if input[i] == '\\' && i+1 < len(input) {
+					sb.WriteByte(input[i+1])
+					i += 2
+					continue
+				}
+				if rune(input[i]) == c {
+					i++
+					break
+				}
+				sb.WriteByte(input[i])
+				i++
+			}
+			out = append(out, token{tokString, sb.String(), start})
+		case c == '-' || unicode.IsDigit(c):
+			start := i
+			i++
+			for i < len(input) && (unicode.IsDigit(rune(input[i])) || input[i] == '.') {
+				i++
+			}
+			out = append(out, token{tokNumber, input[start:i], start})
+		case c == '_' || unicode.IsLetter(c):
+			start := i
+			for i < len(input) && (input[i] == '_' || unicode.IsLetter(rune(input[i])) || unicode.IsDigit(rune(input[i]))) {
+				i++
+			}
+			out = append(out, token{tokIdent, input[start:i], start})
+		default:
+			matched := false
+			for _, op := range operators {
+				if strings.HasPrefix(input[i:], op) {
+					out = append(out, token{tokOp, op, i})
+					i += len(op)
+					matched = true
+					break
+				}
+			}
+			if !matched {
+				return nil, fmt.Errorf("unexpected character %q at %d", c, i)
+			}
+		}
+	}
+	out = append(out, token{tokEOF, "", len(input)})
+	return out, nil
+}
+
+type node interface {
+	eval(r *Record) interface{}
+}
+
+type literal struct {
+	value interface{}
+}
+
+func (n *literal) eval(r *Record) interface{} {
+	return n.value
+}
+
+type fieldRef struct {
+	name    string
+	fn      fieldFn
+	indices []int
+}
+
+func (n *fieldRef) eval(r *Record) interface{} {
+	v := n.fn(r)
+	for _, i := range n.indices {
+		list, ok := v.([]interface{})
+		if !ok || i < 0 || i >= len(list) {
+			return nil
+		}
+		v = list[i]
+	}
+	return v
+}
+
+type notNode struct {
+	x node
+}
+
+func (n *notNode) eval(r *Record) interface{} {
+	return !truthy(n.x.eval(r))
+}
+
+type logicNode struct {
+	and  bool
+	x, y node
+}
+
+func (n *logicNode) eval(r *Record) interface{} {
+	if n.and {
+		return truthy(n.x.eval(r)) && truthy(n.y.eval(r))
+	}
+	return truthy(n.x.eval(r)) || truthy(n.y.eval(r))
+}
+
+type cmpNode struct {
+	op   string
+	x, y node
+	// compiled regex, if the right side of a match is a literal
+	re *regexp.Regexp
+}
+
+func (n *cmpNode) eval(r *Record) interface{} {
+	x, y := n.x.eval(r), n.y.eval(r)
+	if n.op == "~" || n.op == "!~" {
+		re := n.re
+		if re == nil {
+			s, ok := y.(string)
+			if !ok {
+				return false
+			}
+			var err error
+			if re, err = regexp.Compile(s); err != nil {
+				return false
+			}
+		}
+		matched := anyOf(x, func(v interface{}) bool {
+			s, ok := v.(string)
+			return ok && re.MatchString(s)
+		})
+		if n.op == "!~" {
+			return !matched
+		}
+		return matched
+	}
+	if n.op == "!=" {
+		return !anyOf(x, func(v interface{}) bool { return Compare(v, y) == 0 && comparable(v, y) })
+	}
+	return anyOf(x, func(v interface{}) bool {
+		if !comparable(v, y) {
+			return false
+		}
+		c := Compare(v, y)
+		switch n.op {
+		case "==":
+			return c == 0
+		case "<":
+			return c < 0
+		case "<=":
+			return c <= 0
+		case ">":
+			return c > 0
+		case ">=":
+			return c >= 0
+		}
+		return false
+	})
+}
+
+// anyOf applies fn to the value, or to every element if the value is a list.
+func anyOf(v interface{}, fn func(v interface{}) bool) bool {
+	if list, ok := v.([]interface{}); ok {
+		for _, e := range list {
+			if fn(e) {
+				return true
+			}
+		}
+		return false
+	}
+	return fn(v)
+}
+
+func truthy(v interface{}) bool {
+	switch x := v.(type) {
+	case nil:
+		return false
+	case bool:
+		return x
+	case float64:
+		return x != 0
+	case string:
+		return x != ""
+	case []interface{}:
+		return len(x) > 0
+	}
+	return true
+}
+
+// comparable is true if both values are of the same kind, or both null.
+func comparable(a, b interface{}) bool {
+	switch a.(type) {
+	case nil:
+		return b == nil
+	case bool:
+		_, ok := b.(bool)
+		return ok
+	case float64:
+		_, ok := b.(float64)
+		return ok
+	case string:
+		_, ok := b.(string)
+		return ok
+	}
+	return false
+}
+
+// Compare orders values: null first, then false, true, numbers and strings.
+func Compare(a, b interface{}) int {
+	rank := func(v interface{}) int {
+		switch v.(type) {
+		case nil:
+			return 0
+		case bool:
+			return 1
+		case float64:
+			return 2
+		case string:
+			return 3
+		}
+		return 4
+	}
+	ra, rb := rank(a), rank(b)
+	if ra != rb {
+		if ra < rb {
+			return -1
+		}
+		return 1
+	}
+	switch x := a.(type) {
+	case bool:
+		y := b.(bool)
+		if x == y {
+			return 0
+		} else if !x {
+			return -1
+		}
+		return 1
+	case float64:
+		y := b.(float64)
+		if x < y {
+			return -1
+		} else if x > y {
+			return 1
+		}
+		return 0
+	case string:
+		return strings.Compare(x, b.(string))
+	}
+	return 0
+}
+
+type parser struct {
+	tokens []token
+	pos    int
+}
+
+func (p *parser) peek() token {
+	return p.tokens[p.pos]
+}
+
+func (p *parser) next() token {
+	t := p.tokens[p.pos]
+	if t.kind != tokEOF {
+		p.pos++
+	}
+	return t
+}
+
+func (p *parser) parseOr() (node, error) {
+	x, err := p.parseAnd()
+	if err != nil {
+		return nil, err
+	}
+	for p.peek().kind == tokOp && p.peek().text == "||" {
+		p.next()
+		y, err := p.parseAnd()
+		if err != nil {
+			return nil, err
+		}
+		x = &logicNode{and: false, x: x, y: y}
+	}
+	return x, nil
+}
+
+func (p *parser) parseAnd() (node, error) {
+	x, err := p.parseUnary()
+	if err != nil {
+		return nil, err
+	}
+	for p.peek().kind == tokOp && p.peek().text == "&&" {
+		p.next()
+		y, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		x = &logicNode{and: true, x: x, y: y}
+	}
+	return x, nil
+}
+
+func (p *parser) parseUnary() (node, error) {
+	if t := p.peek(); t.kind == tokOp && t.text == "!" {
+		p.next()
+		x, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		return &notNode{x: x}, nil
+	}
+	return p.parseCmp()
+}
+
+func (p *parser) parseCmp() (node, error) {
+	x, err := p.parseOperand()
+	if err != nil {
+		return nil, err
+	}
+	t := p.peek()
+	if t.kind != tokOp {
+		return x, nil
+	}
+	switch t.text {
+	case "==", "!=", "<", "<=", ">", ">=", "~", "!~":
+	default:
+		return x, nil
+	}
+	p.next()
+	y, err := p.parseOperand()
+	if err != nil {
+		return nil, err
+	}
+	n := &cmpNode{op: t.text, x: x, y: y}
+	if lit, ok := y.(*literal); ok && (t.text == "~" || t.text == "!~") {
+		s, ok := lit.value.(string)
+		if !ok {
+			return nil, fmt.Errorf("expected regex string after %q at %d", t.text, t.pos)
+		}
+		if n.re, err = regexp.Compile(s); err != nil {
+			return nil, fmt.Errorf("bad regex at %d: %v", t.pos, err)
+		}
+	}
+	return n, nil
+}
+
+func (p *parser) parseOperand() (node, error) {
+	t := p.next()
+	switch t.kind {
+	case tokLParen:
+		x, err := p.parseOr()
+		if err != nil {
+			return nil, err
+		}
+		if p.next().kind != tokRParen {
+			return nil, fmt.Errorf("expected ')' for '(' at %d", t.pos)
+		}
+		return x, nil
+	case tokNumber:
+		v, err := strconv.ParseFloat(t.text, 64)
+		if err != nil {
+			return nil, fmt.Errorf("bad number %q at %d", t.text, t.pos)
+		}
+		return &literal{value: v}, nil
+	case tokString:
+		return &literal{value: t.text}, nil
+	case tokIdent:
+		switch t.text {
+		case "true":
+			return &literal{value: true}, nil
+		case "false":
+			return &literal{value: false}, nil
+		case "null":
+			return &literal{value: nil}, nil
+		}
+		p.pos--
+		return p.parsePath()
+	case tokEOF:
+		return nil, fmt.Errorf("unexpected end of query")
+	default:
+		return nil, fmt.Errorf("unexpected %q at %d", t.text, t.pos)
+	}
+}
+
+func (p *parser) parsePath() (*fieldRef, error) {
+	start := p.next()
+	name := start.text
+	for p.peek().kind == tokDot {
+		p.next()
+		t := p.next()
+		if t.kind != tokIdent {
+			return nil, fmt.Errorf("expected field name after '.' at %d", t.pos)
+		}
+		name += "." + t.text
+	}
+	if alias, ok := fieldAliases[name]; ok {
+		name = alias
+	}
+	fn, ok := fields[name]
+	if !ok {
+		return nil, fmt.Errorf("unknown field %q at %d", name, start.pos)
+	}
+	ref := &fieldRef{name: name, fn: fn}
+	for p.peek().kind == tokLBracket {
+		p.next()
+		t := p.next()
+		i, err := strconv.Atoi(t.text)
+		if t.kind != tokNumber || err != nil {
+			return nil, fmt.Errorf("expected index at %d", t.pos)
+		}
+		if p.next().kind != tokRBracket {
+			return nil, fmt.Errorf("expected ']' at %d", t.pos)
+		}
+		ref.indices = append(ref.indices, i)
+	}
+	return ref, nil
+}
+
+// Query is a parsed query expression.
+type Query struct {
+	root node
+}
+
+// Parse parses a query expression. An empty expression matches every peer.
+func Parse(expr string) (*Query, error) {
+	if strings.TrimSpace(expr) == "" {
+		return &Query{root: &literal{value: true}}, nil
+	}
+	tokens, err := lex(expr)
+	if err != nil {
+		return nil, err
+	}
+	p := &parser{tokens: tokens}
+	root, err := p.parseOr()
+	if err != nil {
+		return nil, err
+	}
+	if t := p.peek(); t.kind != tokEOF {
+		return nil, fmt.Errorf("unexpected %q at %d", t.text, t.pos)
+	}
+	return &Query{root: root}, nil
+}
+
+// Match evaluates the query for the peer.
+func (q *Query) Match(r *Record) bool {
+	return truthy(q.root.eval(r))
+}
+
+// Path is a field with optional list indices, e.g. "enr.attnets[5]", to select and sort values by.
+type Path struct {
+	ref *fieldRef
+}
+
+func ParsePath(path string) (*Path, error) {
+	tokens, err := lex(path)
+	if err != nil {
+		return nil, err
+	}
+	p := &parser{tokens: tokens}
+	if p.peek().kind != tokIdent {
+		return nil, fmt.Errorf("expected field name: %q", path)
+	}
+	ref, err := p.parsePath()
+	if err != nil {
+		return nil, err
+	}
+	if t := p.peek(); t.kind != tokEOF {
+		return nil, fmt.Errorf("unexpected %q at %d", t.text, t.pos)
+	}
+	return &Path{ref: ref}, nil
+}
+
+func (p *Path) Get(r *Record) interface{} {
+	return p.ref.eval(r)
+}
+
+// Format formats a value for table and CSV output. Lists are joined with ';'.
+func Format(v interface{}) string {
+	switch x := v.(type) {
+	case nil:
+		return ""
+	case bool:
+		return strconv.FormatBool(x)
+	case float64:
+		return strconv.FormatFloat(x, 'f', -1, 64)
+	case string:
+		return x
+	case []interface{}:
+		parts := make([]string, len(x))
+		for i, e := range x {
+			parts[i] = Format(e)
+		}
+		return strings.Join(parts, ";")
+	}
+	return fmt.Sprintf("%v", v)
+}
@@ -0,0 +1,195 @@
+package query
+
+import (
+	"github.com/libp2p/go-libp2p-core/peer"
+	"github.com/protolambda/rumor/p2p/track"
+	"github.com/protolambda/zrnt/eth2/beacon"
+	"testing"
+	"time"
+)
+
+func testRecords() []*Record {
+	var attnets beacon.AttnetBits
+	attnets[0] = 1 << 5
+	return []*Record{
+		{
+			PeerAllData: &track.PeerAllData{
+				PeerID:    peer.ID("a"),
+				UserAgent: "Prysm/v1.0.0",
+				Protocols: []string{"/eth2/beacon_chain/req/status/1/ssz_snappy", "/meshsub/1.1.0"},
+				Latency:   20 * time.Millisecond,
+				Attnets:   &attnets,
+				Status:    &beacon.Status{HeadSlot: 2000, FinalizedEpoch: 60},
+				Quirks:    []string{"status:timeout"},
+			},
+			Connected: true,
+		},
+		{
+			PeerAllData: &track.PeerAllData{
+				PeerID:    peer.ID("b"),
+				UserAgent: "Lighthouse/v0.3.0",
+				Protocols: []string{"/meshsub/1.0.0"},
+				Status:    &beacon.Status{HeadSlot: 500, FinalizedEpoch: 10},
+			},
+		},
+		{
+			PeerAllData: &track.PeerAllData{PeerID: peer.ID("c")},
+		},
+	}
+}
+
+func TestMatch(t *testing.T) {
+	records := testRecords()
+	cases := []struct {
+		expr     string
+		expected [3]bool
+	}{
+		{"", [3]bool{true, true, true}},
+		{"connected", [3]bool{true, false, false}},
+		{"status.head_slot > 1000", [3]bool{true, false, false}},
+		{"status.head_slot <= 500", [3]bool{false, true, false}},
+		{"status.head_slot > -1", [3]bool{true, true, false}},
+		{"status.finalized_epoch == 10", [3]bool{false, true, false}},
+		{"latency >= 20", [3]bool{true, false, false}},
+
+		// strings and regexes
+		{`agent ~ "Prysm"`, [3]bool{true, false, false}},
+		{`user_agent ~ "^light"`, [3]bool{false, false, false}},
+		{`agent ~ '(?i)^light'`, [3]bool{false, true, false}},
+		{`agent !~ "Prysm"`, [3]bool{false, true, true}},
+		{`agent < "M"`, [3]bool{false, true, false}},
+		{`agent == "Lighthouse/v0.3.0"`, [3]bool{false, true, false}},
+		{`agent == "Say \"hi\""`, [3]bool{false, false, false}},
+
+		// precedence: comparisons, then '!', then '&&', then '||'
+		{`agent ~ "Light" || status.head_slot > 0 && connected`, [3]bool{true, true, false}},
+		{`(agent ~ "Light" || status.head_slot > 0) && connected`, [3]bool{true, false, false}},
+		{"!connected && status", [3]bool{false, true, false}},
+		{"!(connected || status)", [3]bool{false, false, true}},
+		{"!!connected", [3]bool{true, false, false}},
+		{"!status.head_slot > 1000", [3]bool{false, true, true}},
+		{"connected && agent || !status", [3]bool{true, false, true}},
+
+		// lists: any element matches, '!=' and '!~' if none does
+		{`protocols == "/meshsub/1.1.0"`, [3]bool{true, false, false}},
+		{`protocols != "/meshsub/1.1.0"`, [3]bool{false, true, true}},
+		{`protocols ~ "meshsub"`, [3]bool{true, true, false}},
+		{`protocols !~ "status"`, [3]bool{false, true, true}},
+		{`quirks ~ "timeout"`, [3]bool{true, false, false}},
+		{"protocols", [3]bool{true, true, false}},
+		{"protocols[1]", [3]bool{true, false, false}},
+		{`protocols[0] == "/meshsub/1.0.0"`, [3]bool{false, true, false}},
+		{"enr.attnets[5]", [3]bool{true, false, false}},
+		{"enr.attnets[4] == false", [3]bool{true, false, false}},
+		{"enr.attnets == true", [3]bool{true, false, false}},
+		{"enr.attnets[100]", [3]bool{false, false, false}},
+
+		// null: missing values are only equal to null, and not comparable with anything else
+		{"status == false", [3]bool{false, false, true}},
+		{"status.head_slot == null", [3]bool{false, false, true}},
+		{"status.head_slot != null", [3]bool{true, true, false}},
+		{"status.head_slot < 1", [3]bool{false, false, false}},
+		{`agent > 5`, [3]bool{false, false, false}},
+		{"metadata", [3]bool{false, false, false}},
+		{"true", [3]bool{true, true, true}},
+		{"0", [3]bool{false, false, false}},
+	}
+	for _, c := range cases {
+		t.Run(c.expr, func(t *testing.T) {
+			q, err := Parse(c.expr)
+			if err != nil {
+				t.Fatal(err)
+			}
+			for i, r := range records {
+				if got := q.Match(r); got != c.expected[i] {
+					t.Errorf("record %d: expected %v, got %v", i, c.expected[i], got)
+				}
+			}
+		})
+	}
+}
+
+func TestParseBadInput(t *testing.T) {
+	for _, expr := range []string{
+		"status.head_slot >",
+		"(connected",
+		"connected)",
+		"&& connected",
+		"connected connected",
+		"unknown_field",
+		"status.",
+		"status.unknown",
+		`agent ~ 5`,
+		`agent ~ "("`,
+		`"unterminated`,
+		"enr.attnets[x]",
+		"enr.attnets[1",
+		"1.2.3 > 0",
+		"-",
+		"connected @ true",
+		"connected = true",
+	} {
+		if _, err := Parse(expr); err == nil {
+			t.Errorf("expected error for %q", expr)
+		}
+	}
+}
+
+func TestParsePath(t *testing.T) {
+	records := testRecords()
+	p, err := ParsePath("protocols[1]")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if v := p.Get(records[0]); v != "/meshsub/1.1.0" {
+		t.Fatalf("unexpected value: %v", v)
+	}
+	if v := p.Get(records[1]); v != nil {
+		t.Fatalf("expected null for out of range index, got %v", v)
+	}
+	for _, path := range []string{"", "1", "connected && status", "status.head_slot[", "unknown"} {
+		if _, err := ParsePath(path); err == nil {
+			t.Errorf("expected error for path %q", path)
+		}
+	}
+}
+
+func TestSortAndFormat(t *testing.T) {
+	records := testRecords()
+	p, err := ParsePath("status.head_slot")
+	if err != nil {
+		t.Fatal(err)
+	}
+	order := func() string {
+		var out string
+		for _, r := range records {
+			out += string(r.PeerID)
+		}
+		return out
+	}
+	Sort(records, p, false)
+	// missing values first
+	if got := order(); got != "cba" {
+		t.Fatalf("unexpected ascending order: %s", got)
+	}
+	Sort(records, p, true)
+	if got := order(); got != "abc" {
+		t.Fatalf("unexpected descending order: %s", got)
+	}
+
+	for _, c := range []struct {
+		v        interface{}
+		expected string
+	}{
+		{nil, ""},
+		{true, "true"},
+		{float64(20), "20"},
+		{1.5, "1.5"},
+		{"x", "x"},
+		{[]interface{}{"a", float64(1), nil}, "a;1;"},
+	} {
+		if got := Format(c.v); got != c.expected {
+			t.Errorf("format %v: expected %q, got %q", c.v, c.expected, got)
+		}
+	}
+}
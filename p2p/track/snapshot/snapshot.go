@@ -0,0 +1,293 @@
+package snapshot
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	ds "github.com/ipfs/go-datastore"
+	ic "github.com/libp2p/go-libp2p-core/crypto"
+	"github.com/libp2p/go-libp2p-core/peer"
+	"github.com/libp2p/go-libp2p-core/peerstore"
+	pstore_pb "github.com/libp2p/go-libp2p-peerstore/pb"
+	"github.com/multiformats/go-base32"
+	ma "github.com/multiformats/go-multiaddr"
+	"github.com/protolambda/rumor/p2p/track"
+	"io"
+	"time"
+)
+
+// Same keys as the datastore-backed libp2p address and key books
+var (
+	addrBookBase = ds.NewKey("/peers/addrs")
+	keyBookBase  = ds.NewKey("/peers/keys")
+	pubKeySuffix = ds.NewKey("/pub")
+)
+
+// AddrTTL is an address of a peer, and how long it is valid for.
+type AddrTTL struct {
+	Addr string `json:"addr"`
+	// Unix time in seconds when the address expires
+	Expiry int64 `json:"expiry"`
+	// The original TTL of the address
+	TTL time.Duration `json:"ttl"`
+}
+
+// Entry is a peer in a snapshot, a JSON line in an export file.
+// The ENR of the peer data is encoded in raw "enr:..." representation.
+type Entry struct {
+	*track.PeerAllData
+	AddrTTLs []AddrTTL `json:"addr_ttls,omitempty"`
+}
+
+// MergePolicy decides what happens to the data of peers that are already known.
+type MergePolicy string
+
+const (
+	// Update the known data: ENRs and metadata with a higher sequence number, and anything else that is available.
+	MergeNewestENR MergePolicy = "newest-enr"
+	// Keep the known data, only fill in what is missing.
+	MergeKeep MergePolicy = "keep"
+)
+
+func (p *MergePolicy) Set(v string) error {
+	switch MergePolicy(v) {
+	case MergeNewestENR, MergeKeep:
+		*p = MergePolicy(v)
+		return nil
+	default:
+		return fmt.Errorf("unrecognized merge policy: %s", v)
+	}
+}
+
+func (p *MergePolicy) String() string {
+	return string(*p)
+}
+
+func (p *MergePolicy) Type() string {
+	return "merge policy"
+}
+
+func addrTTLs(store track.ExtendedPeerstore, id peer.ID) ([]AddrTTL, error) {
+	key := addrBookBase.ChildString(base32.RawStdEncoding.EncodeToString([]byte(id)))
+	value, err := store.Datastore().Get(key)
+	if err == ds.ErrNotFound {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+	var rec pstore_pb.AddrBookRecord
+	if err := rec.Unmarshal(value); err != nil {
+		return nil, fmt.Errorf("bad address record: %v", err)
+	}
+	now := time.Now().Unix()
+	var out []AddrTTL
+	for _, a := range rec.Addrs {
+		if a.Addr == nil || a.Expiry <= now {
+			continue
+		}
+		out = append(out, AddrTTL{Addr: a.Addr.String(), Expiry: a.Expiry, TTL: time.Duration(a.Ttl)})
+	}
+	return out, nil
+}
+
+// known checks if the public key of the peer is stored.
+// The key book itself cannot tell, it extracts and stores the key from the peer ID if it is missing.
+func known(store track.ExtendedPeerstore, id peer.ID) (bool, error) {
+	key := keyBookBase.ChildString(base32.RawStdEncoding.EncodeToString([]byte(id))).Child(pubKeySuffix)
+	return store.Datastore().Has(key)
+}
+
+// exportable checks if the peer has an eth2 (secp256k1) key, all other peers are ignored.
+func exportable(store track.ExtendedPeerstore, id peer.ID) bool {
+	_, ok := store.PubKey(id).(*ic.Secp256k1PublicKey)
+	return ok
+}
+
+// ExportPeer collects all data of the peer. Private keys are never exported.
+func ExportPeer(store track.ExtendedPeerstore, id peer.ID) (*Entry, error) {
+	addrs, err := addrTTLs(store, id)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read addresses of %s: %v", id, err)
+	}
+	return &Entry{PeerAllData: store.GetAllData(id), AddrTTLs: addrs}, nil
+}
+
+// Export writes every peer with a secp256k1 key in the store as a JSON line. Progress is called after every peer.
+func Export(store track.ExtendedPeerstore, w io.Writer, progress func(done int, total int)) (exported int, err error) {
+	enc := json.NewEncoder(w)
+	var peers []peer.ID
+	for _, id := range store.Peers() {
+		if exportable(store, id) {
+			peers = append(peers, id)
+		}
+	}
+	for i, id := range peers {
+		entry, err := ExportPeer(store, id)
+		if err != nil {
+			return exported, err
+		}
+		if err := enc.Encode(entry); err != nil {
+			return exported, err
+		}
+		exported += 1
+		if progress != nil {
+			progress(i+1, len(peers))
+		}
+	}
+	return exported, nil
+}
+
+// ImportEntry adds the data of the peer to the store, following the merge policy for data that is already known.
+// Returns true if the peer was not known yet.
+func ImportEntry(store track.ExtendedPeerstore, e *Entry, policy MergePolicy) (isNew bool, err error) {
+	if e.PeerAllData == nil || e.PeerID == "" {
+		return false, fmt.Errorf("entry has no peer ID")
+	}
+	id := e.PeerID
+	keep := policy == MergeKeep
+	if ok, err := known(store, id); err != nil {
+		return false, fmt.Errorf("failed to check peer %s: %v", id, err)
+	} else if !ok {
+		isNew = true
+		if e.Pubkey == "" {
+			return false, fmt.Errorf("new peer %s has no public key", id)
+		}
+		raw, err := hex.DecodeString(e.Pubkey)
+		if err != nil {
+			return false, fmt.Errorf("bad public key of peer %s: %v", id, err)
+		}
+		pub, err := ic.UnmarshalSecp256k1PublicKey(raw)
+		if err != nil {
+			return false, fmt.Errorf("bad public key of peer %s: %v", id, err)
+		}
+		// AddPubKey checks that the key matches the peer ID
+		if err := store.AddPubKey(id, pub); err != nil {
+			return false, fmt.Errorf("failed to add public key of peer %s: %v", id, err)
+		}
+	}
+
+	if !keep || len(store.Addrs(id)) == 0 {
+		now := time.Now()
+		for _, a := range e.AddrTTLs {
+			addr, err := ma.NewMultiaddr(a.Addr)
+			if err != nil {
+				return isNew, fmt.Errorf("bad address of peer %s: %v", id, err)
+			}
+			if remaining := time.Unix(a.Expiry, 0).Sub(now); remaining > 0 {
+				store.AddAddr(id, addr, remaining)
+			}
+		}
+		if e.AddrTTLs == nil {
+			// entries of other tools may only have the plain addresses
+			for _, a := range e.Addrs {
+				addr, err := ma.NewMultiaddr(a)
+				if err != nil {
+					return isNew, fmt.Errorf("bad address of peer %s: %v", id, err)
+				}
+				store.AddAddr(id, addr, peerstore.AddressTTL)
+			}
+		}
+	}
+
+	if len(e.Protocols) > 0 {
+		if known, _ := store.GetProtocols(id); !keep || len(known) == 0 {
+			if err := store.AddProtocols(id, e.Protocols...); err != nil {
+				return isNew, fmt.Errorf("failed to add protocols of peer %s: %v", id, err)
+			}
+		}
+	}
+	putString := func(key string, value string) error {
+		if value == "" {
+			return nil
+		}
+		if keep {
+			if known, err := store.Get(id, key); err == nil && known != "" {
+				return nil
+			}
+		}
+		return store.Put(id, key, value)
+	}
+	// these are the keys that libp2p identify uses
+	if err := putString("AgentVersion", e.UserAgent); err != nil {
+		return isNew, fmt.Errorf("failed to set user agent of peer %s: %v", id, err)
+	}
+	if err := putString("ProtocolVersion", e.ProtocolVersion); err != nil {
+		return isNew, fmt.Errorf("failed to set protocol version of peer %s: %v", id, err)
+	}
+
+	if e.ENR != nil {
+		if !keep || store.LatestENR(id) == nil {
+			if _, err := store.UpdateENRMaybe(id, e.ENR); err != nil {
+				return isNew, fmt.Errorf("failed to update ENR of peer %s: %v", id, err)
+			}
+		}
+	}
+	if e.MetaData != nil && (!keep || store.Metadata(id) == nil) {
+		store.RegisterMetadata(id, *e.MetaData)
+	}
+	if e.ClaimedSeq != 0 {
+		if _, ok := store.ClaimedSeq(id); !keep || !ok {
+			store.RegisterSeqClaim(id, e.ClaimedSeq)
+		}
+	}
+	if e.Status != nil && (!keep || store.Status(id) == nil) {
+		store.RegisterStatus(id, *e.Status)
+	}
+	if e.Fingerprint != nil && (!keep || store.Fingerprint(id) == nil) {
+		if err := store.RegisterFingerprint(id, e.Fingerprint); err != nil {
+			return isNew, err
+		}
+	}
+	for _, q := range e.Quirks {
+		store.RegisterQuirk(id, q)
+	}
+	return isNew, nil
+}
+
+// Import reads JSON lines of peers, and adds them to the store. Progress is called after every peer.
+func Import(store track.ExtendedPeerstore, r io.Reader, policy MergePolicy, progress func(done int)) (added int, merged int, err error) {
+	dec := json.NewDecoder(r)
+	for i := 0; ; i++ {
+		var e Entry
+		if err := dec.Decode(&e); err == io.EOF {
+			return added, merged, nil
+		} else if err != nil {
+			return added, merged, fmt.Errorf("failed to decode peer %d: %v", i, err)
+		}
+		isNew, err := ImportEntry(store, &e, policy)
+		if err != nil {
+			return added, merged, err
+		}
+		if isNew {
+			added += 1
+		} else {
+			merged += 1
+		}
+		if progress != nil {
+			progress(i + 1)
+		}
+	}
+}
+
+// Merge adds all peers of the src store to the dst store.
+func Merge(src track.ExtendedPeerstore, dst track.ExtendedPeerstore, policy MergePolicy) (added int, merged int, err error) {
+	for _, id := range src.Peers() {
+		if !exportable(src, id) {
+			continue
+		}
+		e, err := ExportPeer(src, id)
+		if err != nil {
+			return added, merged, err
+		}
+		isNew, err := ImportEntry(dst, e, policy)
+		if err != nil {
+			return added, merged, err
+		}
+		if isNew {
+			added += 1
+		} else {
+			merged += 1
+		}
+	}
+	return added, merged, nil
+}
@@ -0,0 +1,156 @@
+package snapshot
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	ds "github.com/ipfs/go-datastore"
+	"github.com/ipfs/go-datastore/sync"
+	ic "github.com/libp2p/go-libp2p-core/crypto"
+	"github.com/libp2p/go-libp2p-core/peer"
+	"github.com/libp2p/go-libp2p-peerstore/pstoreds"
+	ma "github.com/multiformats/go-multiaddr"
+	"github.com/protolambda/rumor/p2p/track"
+	"github.com/protolambda/rumor/p2p/track/dstrack"
+	"github.com/protolambda/zrnt/eth2/beacon"
+	"testing"
+	"time"
+)
+
+func newTestPeerstore(t *testing.T) track.ExtendedPeerstore {
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+	store, err := dstrack.NewExtendedPeerstore(ctx, sync.MutexWrap(ds.NewMapDatastore()), pstoreds.DefaultOpts())
+	if err != nil {
+		t.Fatal(err)
+	}
+	return store
+}
+
+// testPeer describes the data of a peer to add to a store
+type testPeer struct {
+	addr      string
+	userAgent string
+	seq       beacon.SeqNr
+	headSlot  beacon.Slot
+}
+
+func addTestPeer(t *testing.T, store track.ExtendedPeerstore, pub ic.PubKey, p testPeer) peer.ID {
+	id, err := peer.IDFromPublicKey(pub)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := store.AddPubKey(id, pub); err != nil {
+		t.Fatal(err)
+	}
+	store.AddAddr(id, ma.StringCast(p.addr), time.Hour)
+	if err := store.Put(id, "AgentVersion", p.userAgent); err != nil {
+		t.Fatal(err)
+	}
+	store.RegisterSeqClaim(id, p.seq)
+	store.RegisterMetadata(id, beacon.MetaData{SeqNumber: p.seq})
+	store.RegisterStatus(id, beacon.Status{HeadSlot: p.headSlot})
+	return id
+}
+
+func secp256k1Key(t *testing.T) ic.PubKey {
+	_, pub, err := ic.GenerateSecp256k1Key(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return pub
+}
+
+// checkPeer compares the data of the peer in the store with the expected data
+func checkPeer(t *testing.T, store track.ExtendedPeerstore, id peer.ID, p testPeer) {
+	t.Helper()
+	data := store.GetAllData(id)
+	if data.UserAgent != p.userAgent {
+		t.Errorf("expected user agent %q, got %q", p.userAgent, data.UserAgent)
+	}
+	if data.ClaimedSeq != p.seq {
+		t.Errorf("expected claimed seq %d, got %d", p.seq, data.ClaimedSeq)
+	}
+	if data.MetaData == nil || data.MetaData.SeqNumber != p.seq {
+		t.Errorf("expected metadata with seq %d, got %v", p.seq, data.MetaData)
+	}
+	if data.Status == nil || data.Status.HeadSlot != p.headSlot {
+		t.Errorf("expected status with head slot %d, got %v", p.headSlot, data.Status)
+	}
+	found := false
+	for _, a := range data.Addrs {
+		found = found || a == p.addr
+	}
+	if !found {
+		t.Errorf("expected address %s, got %v", p.addr, data.Addrs)
+	}
+}
+
+func TestExportImportRoundTrip(t *testing.T) {
+	src := newTestPeerstore(t)
+	p := testPeer{addr: "/ip4/1.2.3.4/tcp/9000", userAgent: "Lighthouse/v1.0.0", seq: 3, headSlot: 100}
+	id := addTestPeer(t, src, secp256k1Key(t), p)
+	// Peers without an eth2 key are not exported
+	_, edPub, err := ic.GenerateEd25519Key(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	edID := addTestPeer(t, src, edPub, testPeer{addr: "/ip4/5.6.7.8/tcp/9000", userAgent: "other"})
+	if data := src.GetAllData(edID); data != nil {
+		t.Fatalf("expected no data of peer without secp256k1 key, got %v", data)
+	}
+
+	var buf bytes.Buffer
+	exported, err := Export(src, &buf, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if exported != 1 {
+		t.Fatalf("expected 1 exported peer, got %d", exported)
+	}
+	if lines := bytes.Count(buf.Bytes(), []byte("\n")); lines != 1 {
+		t.Fatalf("expected 1 JSON line, got %d", lines)
+	}
+
+	dst := newTestPeerstore(t)
+	added, merged, err := Import(dst, bytes.NewReader(buf.Bytes()), MergeNewestENR, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if added != 1 || merged != 0 {
+		t.Fatalf("expected 1 added and 0 merged peers, got %d and %d", added, merged)
+	}
+	checkPeer(t, dst, id, p)
+	if pub := dst.PubKey(id); pub == nil || !pub.Equals(src.PubKey(id)) {
+		t.Fatalf("expected public key of %s to be imported", id)
+	}
+}
+
+func TestMergePolicies(t *testing.T) {
+	pub := secp256k1Key(t)
+	older := testPeer{addr: "/ip4/1.2.3.4/tcp/9000", userAgent: "Prysm/v1.0.0", seq: 2, headSlot: 100}
+	newer := testPeer{addr: "/ip4/1.2.3.4/tcp/9000", userAgent: "Prysm/v1.1.0", seq: 5, headSlot: 200}
+	cases := []struct {
+		policy   MergePolicy
+		expected testPeer
+	}{
+		{MergeKeep, older},
+		{MergeNewestENR, newer},
+	}
+	for _, c := range cases {
+		t.Run(string(c.policy), func(t *testing.T) {
+			src := newTestPeerstore(t)
+			dst := newTestPeerstore(t)
+			id := addTestPeer(t, src, pub, newer)
+			addTestPeer(t, dst, pub, older)
+			added, merged, err := Merge(src, dst, c.policy)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if added != 0 || merged != 1 {
+				t.Fatalf("expected 0 added and 1 merged peers, got %d and %d", added, merged)
+			}
+			checkPeer(t, dst, id, c.expected)
+		})
+	}
+}
@@ -1,3 +1,5 @@
+# @expect level=warning msg=failed
+# @expect stdout=post
 LISTEN_IP=127.0.0.1
 LISTEN_PORT=13000
 
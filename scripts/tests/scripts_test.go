@@ -0,0 +1,11 @@
+package tests
+
+import (
+	"testing"
+
+	"github.com/protolambda/rumor/control/scripttest"
+)
+
+func TestScripts(t *testing.T) {
+	scripttest.RunDir(t, ".", "*.rumor", "*.sh")
+}
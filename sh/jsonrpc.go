@@ -0,0 +1,459 @@
+package sh
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/protolambda/ask"
+	"github.com/protolambda/rumor/control"
+	"github.com/protolambda/rumor/control/actor"
+	"github.com/sirupsen/logrus"
+	"github.com/spf13/pflag"
+)
+
+// JSON-RPC 2.0 error codes, see https://www.jsonrpc.org/specification#error_object
+const (
+	jsonRpcParseErr       = -32700
+	jsonRpcInvalidRequest = -32600
+	jsonRpcMethodNotFound = -32601
+	jsonRpcInvalidParams  = -32602
+	// Server-defined: the command was accepted but returned an error.
+	jsonRpcCommandErr = -32000
+)
+
+// Reserved params, mirroring the shell syntax: 'alice: _my_call lvl_info host start'
+const (
+	jsonRpcActorParam  = "_actor"
+	jsonRpcCallIDParam = "_call_id"
+	jsonRpcLevelParam  = "_level"
+)
+
+// The method to list all available methods and their params.
+const jsonRpcMethodsMethod = "rumor.methods"
+
+const defaultJsonRpcActor = actor.ActorID("DEFAULT_ACTOR_ID")
+
+// Calls of a request are stopped before the write timeout of the server, to still respond with their result.
+const jsonRpcCallTimeout = 12 * time.Second
+
+type jsonRpcRequest struct {
+	Version string          `json:"jsonrpc"`
+	ID      json.RawMessage `json:"id,omitempty"`
+	Method  string          `json:"method"`
+	Params  json.RawMessage `json:"params,omitempty"`
+}
+
+type jsonRpcError struct {
+	Code    int         `json:"code"`
+	Message string      `json:"message"`
+	Data    interface{} `json:"data,omitempty"`
+}
+
+type jsonRpcResponse struct {
+	Version string          `json:"jsonrpc"`
+	ID      json.RawMessage `json:"id"`
+	Result  interface{}     `json:"result,omitempty"`
+	Error   *jsonRpcError   `json:"error,omitempty"`
+}
+
+type jsonRpcLogEntry struct {
+	Time  time.Time              `json:"time"`
+	Level string                 `json:"level"`
+	Msg   string                 `json:"msg"`
+	Data  map[string]interface{} `json:"data,omitempty"`
+}
+
+type jsonRpcCallResult struct {
+	CallID control.CallID     `json:"call_id"`
+	Actor  actor.ActorID      `json:"actor"`
+	Logs   []*jsonRpcLogEntry `json:"logs"`
+	Stdout string             `json:"stdout,omitempty"`
+}
+
+type jsonRpcCallErr struct {
+	*jsonRpcCallResult
+	Error string `json:"error"`
+}
+
+type jsonRpcMethodParam struct {
+	Name     string `json:"name"`
+	Type     string `json:"type"`
+	Help     string `json:"help,omitempty"`
+	Default  string `json:"default,omitempty"`
+	Required bool   `json:"required"`
+}
+
+type jsonRpcMethod struct {
+	Method string                `json:"method"`
+	Help   string                `json:"help,omitempty"`
+	Params []*jsonRpcMethodParam `json:"params"`
+}
+
+// logCollector is a logrus hook that keeps all the entries that were logged to the session of a JSON-RPC call.
+type logCollector struct {
+	sync.Mutex
+	entries []*jsonRpcLogEntry
+}
+
+func (lc *logCollector) Levels() []logrus.Level {
+	return logrus.AllLevels
+}
+
+func (lc *logCollector) Fire(entry *logrus.Entry) error {
+	data := make(map[string]interface{}, len(entry.Data))
+	for k, v := range entry.Data {
+		// Same as the logrus JSON formatter: errors are not JSON-encodable, use the message instead.
+		if err, ok := v.(error); ok {
+			data[k] = err.Error()
+		} else {
+			data[k] = v
+		}
+	}
+	lc.Lock()
+	defer lc.Unlock()
+	lc.entries = append(lc.entries, &jsonRpcLogEntry{
+		Time:  entry.Time,
+		Level: entry.Level.String(),
+		Msg:   entry.Message,
+		Data:  data,
+	})
+	return nil
+}
+
+func (lc *logCollector) Entries() []*jsonRpcLogEntry {
+	lc.Lock()
+	defer lc.Unlock()
+	out := make([]*jsonRpcLogEntry, len(lc.entries))
+	copy(out, lc.entries)
+	return out
+}
+
+func (s *Server) newJsonRpc(rw http.ResponseWriter, req *http.Request) {
+	rw.Header().Set("Content-Type", "application/json; charset=UTF-8")
+	ctx, cancel := context.WithTimeout(req.Context(), jsonRpcCallTimeout)
+	defer cancel()
+	body, err := ioutil.ReadAll(req.Body)
+	_ = req.Body.Close()
+	if err != nil {
+		s.writeJsonRpc(rw, &jsonRpcResponse{Version: "2.0",
+			Error: &jsonRpcError{Code: jsonRpcParseErr, Message: err.Error()}})
+		return
+	}
+	body = bytes.TrimSpace(body)
+	if len(body) > 0 && body[0] == '[' {
+		var batch []json.RawMessage
+		if err := json.Unmarshal(body, &batch); err != nil {
+			s.writeJsonRpc(rw, &jsonRpcResponse{Version: "2.0",
+				Error: &jsonRpcError{Code: jsonRpcParseErr, Message: err.Error()}})
+			return
+		}
+		if len(batch) == 0 {
+			s.writeJsonRpc(rw, &jsonRpcResponse{Version: "2.0",
+				Error: &jsonRpcError{Code: jsonRpcInvalidRequest, Message: "empty batch"}})
+			return
+		}
+		out := make([]*jsonRpcResponse, 0, len(batch))
+		for _, item := range batch {
+			if resp := s.handleJsonRpc(ctx, item); resp != nil {
+				out = append(out, resp)
+			}
+		}
+		if len(out) == 0 {
+			// only notifications, nothing to respond with
+			rw.WriteHeader(http.StatusNoContent)
+			return
+		}
+		s.writeJsonRpc(rw, out)
+		return
+	}
+	if resp := s.handleJsonRpc(ctx, body); resp != nil {
+		s.writeJsonRpc(rw, resp)
+	} else {
+		rw.WriteHeader(http.StatusNoContent)
+	}
+}
+
+func (s *Server) writeJsonRpc(rw http.ResponseWriter, v interface{}) {
+	enc := json.NewEncoder(rw)
+	if err := enc.Encode(v); err != nil {
+		s.log.WithError(err).Warn("failed to respond to JSON-RPC request")
+	}
+}
+
+// handleJsonRpc processes a single JSON-RPC request. Nil is returned for notifications (requests without ID).
+func (s *Server) handleJsonRpc(ctx context.Context, data []byte) *jsonRpcResponse {
+	var req jsonRpcRequest
+	if err := json.Unmarshal(data, &req); err != nil {
+		return &jsonRpcResponse{Version: "2.0",
+			Error: &jsonRpcError{Code: jsonRpcParseErr, Message: err.Error()}}
+	}
+	resp := &jsonRpcResponse{Version: "2.0", ID: req.ID}
+	if req.Version != "2.0" || req.Method == "" {
+		resp.Error = &jsonRpcError{Code: jsonRpcInvalidRequest, Message: "expected JSON-RPC 2.0 request with method"}
+		return resp
+	}
+	params := make(map[string]interface{})
+	if len(req.Params) > 0 && string(req.Params) != "null" {
+		dec := json.NewDecoder(bytes.NewReader(req.Params))
+		// keep numbers as-is, to not lose precision of large uint64 values like slots or gwei amounts
+		dec.UseNumber()
+		if err := dec.Decode(&params); err != nil {
+			resp.Error = &jsonRpcError{Code: jsonRpcInvalidParams, Message: "params must be an object: " + err.Error()}
+			return resp
+		}
+	}
+	result, rpcErr := s.runJsonRpc(ctx, req.Method, params)
+	if len(req.ID) == 0 {
+		return nil
+	}
+	resp.Result = result
+	resp.Error = rpcErr
+	return resp
+}
+
+func (s *Server) runJsonRpc(ctx context.Context, method string, params map[string]interface{}) (interface{}, *jsonRpcError) {
+	actorName := defaultJsonRpcActor
+	if v, ok := params[jsonRpcActorParam]; ok {
+		str, ok := v.(string)
+		if !ok || str == "" {
+			return nil, &jsonRpcError{Code: jsonRpcInvalidParams, Message: "actor param must be a non-empty string"}
+		}
+		actorName = actor.ActorID(str)
+	}
+	lvl := logrus.DebugLevel
+	if v, ok := params[jsonRpcLevelParam]; ok {
+		str, _ := v.(string)
+		var err error
+		lvl, err = logrus.ParseLevel(str)
+		if err != nil {
+			return nil, &jsonRpcError{Code: jsonRpcInvalidParams, Message: fmt.Sprintf("bad log level: %v", err)}
+		}
+	}
+	var callID control.CallID
+	if v, ok := params[jsonRpcCallIDParam]; ok {
+		str, ok := v.(string)
+		if !ok || str == "" {
+			return nil, &jsonRpcError{Code: jsonRpcInvalidParams, Message: "call ID param must be a non-empty string"}
+		}
+		callID = control.CallID(str)
+	}
+
+	if method == jsonRpcMethodsMethod {
+		return s.listJsonRpcMethods(actorName), nil
+	}
+
+	args, rpcErr := s.jsonRpcArgs(actorName, method, params)
+	if rpcErr != nil {
+		return nil, rpcErr
+	}
+
+	collector := new(logCollector)
+	log := logrus.New()
+	log.SetOutput(control.VoidWriter{})
+	log.SetLevel(logrus.TraceLevel)
+	log.AddHook(collector)
+
+	sess := s.sp.NewSession(log)
+	defer sess.Close()
+	if callID == "" {
+		callID = sess.NewCallID()
+	}
+	sess.SetInterest(callID, lvl)
+
+	var stdout bytes.Buffer
+	_, callErr := s.sp.MakeCall(ctx, &stdout, actorName, callID, args)
+	result := &jsonRpcCallResult{
+		CallID: callID,
+		Actor:  actorName,
+		Logs:   collector.Entries(),
+		Stdout: stdout.String(),
+	}
+	if callErr != nil {
+		return nil, &jsonRpcError{
+			Code:    jsonRpcCommandErr,
+			Message: "command failed",
+			Data:    &jsonRpcCallErr{jsonRpcCallResult: result, Error: callErr.Error()},
+		}
+	}
+	return result, nil
+}
+
+// jsonRpcCmd resolves the command description of a method like "peer.status.req",
+// by routing through the command tree of the given actor.
+func (s *Server) jsonRpcCmd(actorName actor.ActorID, method string) (*ask.CommandDescription, []string, *jsonRpcError) {
+	routes := strings.Split(method, ".")
+	if !actor.IsActorCmd(routes) || routes[0] == "help" {
+		return nil, nil, &jsonRpcError{Code: jsonRpcMethodNotFound, Message: fmt.Sprintf("unknown method %q", method)}
+	}
+	// The command is only loaded to check the method and params, it does not run, and has no controller or output.
+	descr, err := ask.Load(s.sp.GetActor(actorName).MakeCmd(s.log, nil, nil))
+	if err != nil {
+		return nil, nil, &jsonRpcError{Code: jsonRpcMethodNotFound, Message: err.Error()}
+	}
+	for _, route := range routes {
+		if descr.CommandRoute == nil {
+			return nil, nil, &jsonRpcError{Code: jsonRpcMethodNotFound, Message: fmt.Sprintf("unknown method %q", method)}
+		}
+		sub, err := descr.CommandRoute.Cmd(route)
+		if err != nil {
+			return nil, nil, &jsonRpcError{Code: jsonRpcMethodNotFound,
+				Message: fmt.Sprintf("method %q is not available: %v", method, err)}
+		}
+		if sub == nil {
+			return nil, nil, &jsonRpcError{Code: jsonRpcMethodNotFound, Message: fmt.Sprintf("unknown method %q", method)}
+		}
+		descr, err = ask.Load(sub)
+		if err != nil {
+			return nil, nil, &jsonRpcError{Code: jsonRpcMethodNotFound, Message: err.Error()}
+		}
+	}
+	if descr.Command == nil {
+		return nil, nil, &jsonRpcError{Code: jsonRpcMethodNotFound,
+			Message: fmt.Sprintf("method %q is a group of methods, not a method", method)}
+	}
+	return descr, routes, nil
+}
+
+// jsonRpcArgs converts the params of a method into shell args, and checks them against the flags of the command.
+func (s *Server) jsonRpcArgs(actorName actor.ActorID, method string, params map[string]interface{}) ([]string, *jsonRpcError) {
+	descr, args, rpcErr := s.jsonRpcCmd(actorName, method)
+	if rpcErr != nil {
+		return nil, rpcErr
+	}
+	keys := make([]string, 0, len(params))
+	for k := range params {
+		if k == jsonRpcActorParam || k == jsonRpcCallIDParam || k == jsonRpcLevelParam {
+			continue
+		}
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		if descr.FlagsSet.Lookup(k) == nil {
+			return nil, &jsonRpcError{Code: jsonRpcInvalidParams, Message: fmt.Sprintf("unknown param %q", k)}
+		}
+		if params[k] == nil {
+			continue
+		}
+		values, err := jsonRpcParamToArgs(params[k])
+		if err != nil {
+			return nil, &jsonRpcError{Code: jsonRpcInvalidParams, Message: fmt.Sprintf("param %q: %v", k, err)}
+		}
+		if _, isList := params[k].([]interface{}); isList {
+			if _, ok := descr.FlagsSet.Lookup(k).Value.(pflag.SliceValue); !ok {
+				return nil, &jsonRpcError{Code: jsonRpcInvalidParams, Message: fmt.Sprintf("param %q does not take a list", k)}
+			}
+		}
+		// Each list element is a flag value of its own, like a repeated flag in the shell.
+		for _, v := range values {
+			// Type-check the param by parsing it into the (throw-away) command.
+			if err := descr.FlagsSet.Set(k, v); err != nil {
+				return nil, &jsonRpcError{Code: jsonRpcInvalidParams, Message: fmt.Sprintf("param %q: %v", k, err)}
+			}
+			args = append(args, "--"+k+"="+v)
+		}
+	}
+	for _, req := range descr.RequiredArgs {
+		if !descr.FlagsSet.Changed(req) {
+			return nil, &jsonRpcError{Code: jsonRpcInvalidParams, Message: fmt.Sprintf("missing required param %q", req)}
+		}
+	}
+	return args, nil
+}
+
+// jsonRpcParamToArgs converts a param into flag values, one for a plain value, or one per element of a list.
+func jsonRpcParamToArgs(v interface{}) ([]string, error) {
+	if x, ok := v.([]interface{}); ok {
+		out := make([]string, 0, len(x))
+		for i, el := range x {
+			s, err := jsonRpcParamToArg(el)
+			if err != nil {
+				return nil, fmt.Errorf("list element %d: %v", i, err)
+			}
+			out = append(out, s)
+		}
+		return out, nil
+	}
+	s, err := jsonRpcParamToArg(v)
+	if err != nil {
+		return nil, err
+	}
+	return []string{s}, nil
+}
+
+func jsonRpcParamToArg(v interface{}) (string, error) {
+	switch x := v.(type) {
+	case string:
+		return x, nil
+	case json.Number:
+		return x.String(), nil
+	case bool:
+		return strconv.FormatBool(x), nil
+	default:
+		return "", errors.New("expected a string, number, bool or list of those")
+	}
+}
+
+// listJsonRpcMethods walks the command tree of the actor, and describes every command that is currently available.
+func (s *Server) listJsonRpcMethods(actorName actor.ActorID) []*jsonRpcMethod {
+	out := make([]*jsonRpcMethod, 0)
+	descr, err := ask.Load(s.sp.GetActor(actorName).MakeCmd(s.log, nil, nil))
+	if err != nil {
+		return out
+	}
+	var walk func(path []string, descr *ask.CommandDescription)
+	walk = func(path []string, descr *ask.CommandDescription) {
+		if descr.Command != nil && len(path) > 0 {
+			m := &jsonRpcMethod{Method: strings.Join(path, "."), Params: make([]*jsonRpcMethodParam, 0)}
+			if descr.Help != nil {
+				m.Help = descr.Help.Help()
+			}
+			required := make(map[string]struct{}, len(descr.RequiredArgs))
+			for _, r := range descr.RequiredArgs {
+				required[r] = struct{}{}
+			}
+			descr.FlagsSet.VisitAll(func(f *pflag.Flag) {
+				_, isRequired := required[f.Name]
+				m.Params = append(m.Params, &jsonRpcMethodParam{
+					Name:     f.Name,
+					Type:     f.Value.Type(),
+					Help:     f.Usage,
+					Default:  f.DefValue,
+					Required: isRequired,
+				})
+			})
+			out = append(out, m)
+		}
+		if descr.CommandRoute == nil {
+			return
+		}
+		known, ok := descr.CommandRoute.(ask.CommandKnownRoutes)
+		if !ok {
+			return
+		}
+		for _, route := range known.Routes() {
+			// commands that are not available in the current actor state are not listed
+			sub, err := descr.CommandRoute.Cmd(route)
+			if err != nil || sub == nil {
+				continue
+			}
+			subDescr, err := ask.Load(sub)
+			if err != nil {
+				continue
+			}
+			walk(append(append(make([]string, 0, len(path)+1), path...), route), subDescr)
+		}
+	}
+	walk(nil, descr)
+	return out
+}
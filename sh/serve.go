@@ -302,6 +302,11 @@ func (s *Server) http(httpAddr, apiKey string) (close func() error, err error) {
 		apiKeyMiddleware(apiKey),
 	))
 
+	m.Handle("/jsonrpc", Middleware(
+		http.HandlerFunc(s.newJsonRpc),
+		apiKeyMiddleware(apiKey),
+	))
+
 	m.Handle("/report", Middleware(
 		http.HandlerFunc(s.newHttpReport),
 		apiKeyMiddleware(apiKey),
@@ -503,6 +508,7 @@ func ServeCmd() *cobra.Command {
 			log.Info("Started server")
 
 			<-ctx.Done()
+			closeAll()
 		},
 	}
 	cmd.Flags().StringVar(&level, "level", "debug", "Log-level of the server log. Valid values: trace, debug, info, warn, error, fatal, panic")